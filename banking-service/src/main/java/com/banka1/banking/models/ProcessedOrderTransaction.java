@@ -0,0 +1,28 @@
+package com.banka1.banking.models;
+
+import jakarta.persistence.*;
+import lombok.Getter;
+import lombok.NoArgsConstructor;
+import lombok.Setter;
+
+@Entity
+@Getter
+@Setter
+@NoArgsConstructor
+@Table(name = "processed_order_transaction")
+public class ProcessedOrderTransaction {
+    @Id
+    @GeneratedValue(strategy = GenerationType.IDENTITY)
+    private Long id;
+
+    @Column(nullable = false, unique = true)
+    private String uid;
+
+    @Column(nullable = false)
+    private Long processedAt;
+
+    public ProcessedOrderTransaction(String uid, Long processedAt) {
+        this.uid = uid;
+        this.processedAt = processedAt;
+    }
+}
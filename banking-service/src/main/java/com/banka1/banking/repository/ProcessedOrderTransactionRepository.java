@@ -0,0 +1,10 @@
+package com.banka1.banking.repository;
+
+import com.banka1.banking.models.ProcessedOrderTransaction;
+import org.springframework.data.jpa.repository.JpaRepository;
+import org.springframework.stereotype.Repository;
+
+@Repository
+public interface ProcessedOrderTransactionRepository extends JpaRepository<ProcessedOrderTransaction, Long> {
+    boolean existsByUid(String uid);
+}
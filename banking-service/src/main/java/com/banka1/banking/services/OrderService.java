@@ -4,11 +4,13 @@ import com.banka1.banking.dto.MoneyTransferDTO;
 import com.banka1.banking.dto.OrderTransactionInitiationDTO;
 import com.banka1.banking.models.Account;
 import com.banka1.banking.models.Currency;
+import com.banka1.banking.models.ProcessedOrderTransaction;
 import com.banka1.banking.models.Transaction;
 import com.banka1.banking.models.Transfer;
 import com.banka1.banking.models.helper.TransferStatus;
 import com.banka1.banking.repository.AccountRepository;
 import com.banka1.banking.repository.CurrencyRepository;
+import com.banka1.banking.repository.ProcessedOrderTransactionRepository;
 import com.banka1.banking.services.implementation.AuthService;
 import lombok.RequiredArgsConstructor;
 import lombok.extern.slf4j.Slf4j;
@@ -33,6 +35,7 @@ public class OrderService {
     private final TransactionRepository transactionRepository;
     private final CurrencyService currencyService;
     private final CurrencyRepository currencyRepository;
+    private final ProcessedOrderTransactionRepository processedOrderTransactionRepository;
 
     @Transactional
     public Double executeOrder(String direction, Long userId, Long accountId, Double amount, Double fee) {
@@ -116,6 +119,13 @@ public class OrderService {
         System.out.println("Seller ID: " + dto.getSellerAccountId());
         System.out.println("Amount: " + dto.getAmount());
 
+        // Trading servis ponovo šalje istu poruku kada odgovor ne stigne, pa se
+        // transakcija sa već primenjenim uid-om samo potvrđuje.
+        if (dto.getUid() != null && processedOrderTransactionRepository.existsByUid(dto.getUid())) {
+            System.out.println("Transakcija " + dto.getUid() + " je vec izvrsena, preskacem");
+            return;
+        }
+
         Account buyer = accountRepository.findById(dto.getBuyerAccountId()).orElseThrow();
         Account seller = accountRepository.findById(dto.getSellerAccountId()).orElseThrow();
 
@@ -185,6 +195,10 @@ public class OrderService {
 
         transactionRepository.save(transaction);
 
+        if (dto.getUid() != null) {
+            processedOrderTransactionRepository.save(new ProcessedOrderTransaction(dto.getUid(), Instant.now().toEpochMilli()));
+        }
+
         System.out.println("=== ZAVRŠEN processOrderTransaction ===");
     }
 
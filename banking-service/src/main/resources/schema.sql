@@ -324,6 +324,16 @@ create table otc_transaction
 );
 
 
+drop table if exists processed_order_transaction cascade;
+create table processed_order_transaction
+(
+    id           bigint generated by default as identity
+        primary key,
+    uid          varchar(255) not null
+        unique,
+    processed_at bigint       not null
+);
+
 -- Drop if exists (za sigurnost)
 drop table if exists event_delivery cascade;
 drop table if exists event cascade;
@@ -4,11 +4,13 @@ import com.banka1.banking.dto.MoneyTransferDTO;
 import com.banka1.banking.dto.OrderTransactionInitiationDTO;
 import com.banka1.banking.models.Account;
 import com.banka1.banking.models.Currency;
+import com.banka1.banking.models.ProcessedOrderTransaction;
 import com.banka1.banking.models.Transaction;
 import com.banka1.banking.models.Transfer;
 import com.banka1.banking.models.helper.CurrencyType;
 import com.banka1.banking.repository.AccountRepository;
 import com.banka1.banking.repository.CurrencyRepository;
+import com.banka1.banking.repository.ProcessedOrderTransactionRepository;
 import com.banka1.banking.repository.TransactionRepository;
 import org.junit.jupiter.api.BeforeEach;
 import org.junit.jupiter.api.Test;
@@ -40,6 +42,8 @@ public class OrderServiceTest {
     private TransactionRepository transactionRepository;
     @Mock
     private TransferService transferService;
+    @Mock
+    private ProcessedOrderTransactionRepository processedOrderTransactionRepository;
 
     @InjectMocks
     private OrderService orderService;
@@ -196,6 +200,46 @@ public class OrderServiceTest {
         verify(transactionRepository, times(1)).save(any(Transaction.class));
     }
 
+    @Test
+    void testProcessOrderTransaction_RecordsUid() {
+        Currency currency = new Currency();
+        currency.setCode(CurrencyType.RSD);
+        OrderTransactionInitiationDTO dto = new OrderTransactionInitiationDTO();
+        dto.setUid("COMPENSATE-ORDER-1");
+        dto.setBuyerAccountId(1L);
+        dto.setSellerAccountId(2L);
+        dto.setAmount(200.0);
+
+        when(processedOrderTransactionRepository.existsByUid("COMPENSATE-ORDER-1")).thenReturn(false);
+        when(accountRepository.findById(1L)).thenReturn(Optional.of(userAccount));
+        when(accountRepository.findById(2L)).thenReturn(Optional.of(bankAccount));
+        when(currencyRepository.getByCode(CurrencyType.RSD)).thenReturn(currency);
+        when(transferService.createMoneyTransferEntity(any(), any(), any())).thenReturn(new Transfer());
+
+        orderService.processOrderTransaction(dto);
+
+        ArgumentCaptor<ProcessedOrderTransaction> captor = ArgumentCaptor.forClass(ProcessedOrderTransaction.class);
+        verify(processedOrderTransactionRepository).save(captor.capture());
+        assertEquals("COMPENSATE-ORDER-1", captor.getValue().getUid());
+    }
+
+    @Test
+    void testProcessOrderTransaction_DuplicateUidIsSkipped() {
+        OrderTransactionInitiationDTO dto = new OrderTransactionInitiationDTO();
+        dto.setUid("COMPENSATE-ORDER-1");
+        dto.setBuyerAccountId(1L);
+        dto.setSellerAccountId(2L);
+        dto.setAmount(200.0);
+
+        when(processedOrderTransactionRepository.existsByUid("COMPENSATE-ORDER-1")).thenReturn(true);
+
+        orderService.processOrderTransaction(dto);
+
+        verify(accountRepository, never()).findById(any());
+        verify(accountRepository, never()).save(any(Account.class));
+        verify(transactionRepository, never()).save(any(Transaction.class));
+    }
+
     @Test
     void testProcessOrderTransaction_InsufficientFunds() {
         bankAccount.setBalance(100.0);
@@ -22,6 +22,11 @@ func Connect(network, hostname string) {
 	conn = c
 }
 
+// Connected proverava da li je uspostavljena konekcija sa brokerom.
+func Connected() bool {
+	return conn != nil
+}
+
 var tempQueueNumber atomic.Uint64
 
 func sendAndRecieve(address string, object any, response any) error {
@@ -9,6 +9,7 @@ import (
 
 	"banka1.com/db"
 	"banka1.com/dto"
+	"banka1.com/holds"
 	"banka1.com/saga"
 	"banka1.com/types"
 
@@ -229,6 +230,10 @@ func removeOwnership(uid string) error {
 		if err := tx.Save(&sellerPortfolio).Error; err != nil {
 			return fmt.Errorf("Greška prilikom ažuriranja portfolija prodavca: %w", err)
 		}
+		// Hartije su napustile portfolio, pa ih ugovor više ne drži
+		if err := holds.Consume(tx, holds.ReasonOTCContract, holds.Ref(contract.ID)); err != nil {
+			return fmt.Errorf("Greška prilikom zatvaranja holda ugovora: %w", err)
+		}
 
 		return saga.StateManager.UpdatePhase(tx, uid, types.PhaseOwnershipRemoved)
 	})
@@ -350,6 +355,9 @@ func rollbackOwnership(uid string) error {
 				if err := tx.Save(&sellerPortfolio).Error; err != nil {
 					return fmt.Errorf("Greška prilikom vraćanja portfolija prodavcu: %w", err)
 				}
+				if err := holds.Place(tx, holds.ContractHold(contract)); err != nil {
+					return fmt.Errorf("Greška prilikom vraćanja holda ugovora: %w", err)
+				}
 			}
 		}
 
@@ -374,6 +382,10 @@ func rollbackOwnership(uid string) error {
 			}
 		}
 
+		if err := holds.Release(tx, holds.ReasonSaga, uid); err != nil {
+			return fmt.Errorf("Greška prilikom oslobađanja sredstava kupca: %w", err)
+		}
+
 		return saga.StateManager.Remove(tx, uid)
 	})
 }
@@ -419,6 +431,9 @@ func markContractAsExercised(uid string) error {
 		if err != nil {
 			return err
 		}
+		if err := holds.Consume(tx, holds.ReasonSaga, uid); err != nil {
+			return err
+		}
 		return tx.Model(&types.OptionContract{}).
 			Where("uid = ?", uid).
 			Updates(map[string]any{
@@ -60,6 +60,19 @@ func SendOrderTransactionInit(dto *dto.OrderTransactionInitiationDTO) error {
 	return errors.New(*m)
 }
 
+// SendOrderTransactionCompensation vraća ranije izvršeno plaćanje kroz isti
+// order-init tok sa potvrdom: kupac i prodavac menjaju uloge za isti iznos.
+// Banking servis kroz order-init ne naplaćuje proviziju, pa se ni ne vraća.
+// Greška znači da banking servis nije potvrdio kompenzaciju.
+func SendOrderTransactionCompensation(compensation *dto.OrderTransactionCompensationDTO) error {
+	return SendOrderTransactionInit(&dto.OrderTransactionInitiationDTO{
+		Uid:             "COMPENSATE-" + compensation.Uid,
+		SellerAccountId: compensation.BuyerAccountId,
+		BuyerAccountId:  compensation.SellerAccountId,
+		Amount:          compensation.Amount,
+	})
+}
+
 func SendTaxCollection(dto *dto.TaxCollectionDTO) error {
 	if conn == nil {
 		return nil
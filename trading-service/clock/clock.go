@@ -0,0 +1,110 @@
+package clock
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Clock je izvor trenutnog vremena za matching engine, cron poslove i OTC.
+type Clock interface {
+	Now() time.Time
+}
+
+// RandomSource je izvor slučajnih brojeva (pauze između izvršenja delova ordera).
+type RandomSource interface {
+	Intn(n int) int
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// lockedRand je RandomSource bezbedan za istovremeno korišćenje iz više gorutina.
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func (l *lockedRand) Intn(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Intn(n)
+}
+
+// Seeded vraća deterministički RandomSource za dati seed.
+func Seeded(seed int64) RandomSource {
+	return &lockedRand{r: rand.New(rand.NewSource(seed))}
+}
+
+var (
+	mu        sync.RWMutex
+	current   Clock        = realClock{}
+	random    RandomSource = Seeded(time.Now().UnixNano())
+	listeners []func()
+)
+
+// Now vraća vreme trenutno podešenog sata.
+func Now() time.Time {
+	mu.RLock()
+	c := current
+	mu.RUnlock()
+	return c.Now()
+}
+
+// Intn vraća slučajan broj u [0, n) iz trenutno podešenog izvora.
+func Intn(n int) int {
+	mu.RLock()
+	r := random
+	mu.RUnlock()
+	return r.Intn(n)
+}
+
+// Use menja sat i vraća funkciju koja vraća prethodni.
+func Use(c Clock) func() {
+	mu.Lock()
+	previous := current
+	current = c
+	mu.Unlock()
+	notify()
+
+	return func() {
+		mu.Lock()
+		current = previous
+		mu.Unlock()
+		notify()
+	}
+}
+
+// UseRandom menja izvor slučajnih brojeva i vraća funkciju koja vraća prethodni.
+func UseRandom(r RandomSource) func() {
+	mu.Lock()
+	previous := random
+	random = r
+	mu.Unlock()
+
+	return func() {
+		mu.Lock()
+		random = previous
+		mu.Unlock()
+	}
+}
+
+// OnChange registruje handler koji se poziva kada se sat zameni ili virtuelno vreme pomeri,
+// npr. da bi red odloženih pokušaja ponovo proverio koji su orderi na redu.
+func OnChange(handler func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	listeners = append(listeners, handler)
+}
+
+func notify() {
+	mu.RLock()
+	handlers := make([]func(), len(listeners))
+	copy(handlers, listeners)
+	mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler()
+	}
+}
@@ -0,0 +1,67 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulated_AdvanceAndRestore(t *testing.T) {
+	start := time.Date(2025, 3, 14, 15, 0, 0, 0, time.UTC)
+	sim := NewSimulated(start, 0)
+
+	changes := 0
+	OnChange(func() { changes++ })
+
+	restore := Use(sim)
+	assert.Equal(t, start, Now())
+
+	sim.Advance(30 * time.Minute)
+	assert.Equal(t, start.Add(30*time.Minute), Now())
+
+	active, ok := Simulation()
+	assert.True(t, ok)
+	assert.Same(t, sim, active)
+
+	restore()
+	_, ok = Simulation()
+	assert.False(t, ok)
+	assert.WithinDuration(t, time.Now(), Now(), time.Second)
+	assert.Equal(t, 3, changes)
+}
+
+func TestSimulated_Speed(t *testing.T) {
+	start := time.Date(2025, 3, 14, 15, 0, 0, 0, time.UTC)
+	sim := NewSimulated(start, 3600)
+
+	time.Sleep(20 * time.Millisecond)
+	// 20ms stvarnog vremena je najmanje 72s virtuelnog
+	assert.True(t, sim.Now().Sub(start) >= 72*time.Second)
+}
+
+func TestSeeded_IsReproducible(t *testing.T) {
+	first, second := Seeded(42), Seeded(42)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, first.Intn(100), second.Intn(100))
+	}
+
+	restore := UseRandom(Seeded(7))
+	a := Intn(1000)
+	restore()
+	restore = UseRandom(Seeded(7))
+	defer restore()
+	assert.Equal(t, a, Intn(1000))
+}
+
+func TestConfigureFromEnv(t *testing.T) {
+	t.Setenv("SIMULATION_START", "2025-01-02T10:00:00Z")
+	t.Setenv("SIMULATION_SPEED", "0")
+	assert.NoError(t, ConfigureFromEnv())
+	defer Use(realClock{})
+
+	assert.Equal(t, time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC), Now())
+
+	t.Setenv("SIMULATION_START", "sutra")
+	assert.Error(t, ConfigureFromEnv())
+}
@@ -0,0 +1,108 @@
+package clock
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Simulated je virtuelni sat. Vreme teče brzinom Speed u odnosu na stvarno
+// vreme (0 znači da stoji) i može se ručno pomeriti sa Advance i Set.
+type Simulated struct {
+	mu     sync.Mutex
+	base   time.Time // virtuelno vreme u trenutku anchor
+	anchor time.Time // stvarno vreme poslednjeg pomeranja
+	speed  float64
+}
+
+// NewSimulated pravi virtuelni sat koji kreće od start i teče brzinom speed.
+func NewSimulated(start time.Time, speed float64) *Simulated {
+	return &Simulated{base: start, anchor: time.Now(), speed: speed}
+}
+
+func (s *Simulated) nowLocked() time.Time {
+	if s.speed == 0 {
+		return s.base
+	}
+	elapsed := time.Since(s.anchor)
+	return s.base.Add(time.Duration(float64(elapsed) * s.speed))
+}
+
+func (s *Simulated) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nowLocked()
+}
+
+// Speed vraća brzinu virtuelnog vremena.
+func (s *Simulated) Speed() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.speed
+}
+
+// Advance pomera virtuelno vreme unapred za d.
+func (s *Simulated) Advance(d time.Duration) time.Time {
+	s.mu.Lock()
+	s.base = s.nowLocked().Add(d)
+	s.anchor = time.Now()
+	now := s.base
+	s.mu.Unlock()
+
+	notify()
+	return now
+}
+
+// Set postavlja virtuelno vreme na t.
+func (s *Simulated) Set(t time.Time) {
+	s.mu.Lock()
+	s.base = t
+	s.anchor = time.Now()
+	s.mu.Unlock()
+
+	notify()
+}
+
+// Simulation vraća virtuelni sat ako je simulacija uključena.
+func Simulation() (*Simulated, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	sim, ok := current.(*Simulated)
+	return sim, ok
+}
+
+// ConfigureFromEnv uključuje simulaciju kada je podešen SIMULATION_START (RFC3339).
+// SIMULATION_SPEED je brzina virtuelnog vremena (podrazumevano 1), a
+// SIMULATION_SEED čini slučajne pauze ponovljivim.
+func ConfigureFromEnv() error {
+	if seed := os.Getenv("SIMULATION_SEED"); seed != "" {
+		value, err := strconv.ParseInt(seed, 10, 64)
+		if err != nil {
+			return fmt.Errorf("nevalidan SIMULATION_SEED: %w", err)
+		}
+		UseRandom(Seeded(value))
+	}
+
+	start := os.Getenv("SIMULATION_START")
+	if start == "" {
+		return nil
+	}
+	startAt, err := time.Parse(time.RFC3339, start)
+	if err != nil {
+		return fmt.Errorf("nevalidan SIMULATION_START: %w", err)
+	}
+
+	speed := 1.0
+	if value := os.Getenv("SIMULATION_SPEED"); value != "" {
+		speed, err = strconv.ParseFloat(value, 64)
+		if err != nil || speed < 0 {
+			return fmt.Errorf("nevalidan SIMULATION_SPEED: %s", value)
+		}
+	}
+
+	Use(NewSimulated(startAt, speed))
+	fmt.Printf("Simulacija ukljucena: virtuelno vreme %s, brzina %.2f\n", startAt.Format(time.RFC3339), speed)
+	return nil
+}
@@ -296,6 +296,7 @@ func (ac *ActuaryController) ChangeAgentLimits(c *fiber.Ctx) error {
 
 	result := tx.First(&actuary, id)
 	if result.Error != nil {
+		tx.Rollback()
 		return c.Status(404).JSON(types.Response{
 			Success: false,
 			Data:    nil,
@@ -305,6 +306,7 @@ func (ac *ActuaryController) ChangeAgentLimits(c *fiber.Ctx) error {
 
 	var updateData dto.UpdateActuaryDTO
 	if err := c.BodyParser(&updateData); err != nil {
+		tx.Rollback()
 		return c.Status(400).JSON(types.Response{
 			Success: false,
 			Data:    nil,
@@ -315,6 +317,7 @@ func (ac *ActuaryController) ChangeAgentLimits(c *fiber.Ctx) error {
 	if updateData.LimitAmount != nil {
 		float, err := strconv.ParseFloat(*updateData.LimitAmount, 64)
 		if err != nil {
+			tx.Rollback()
 			return c.Status(400).JSON(types.Response{
 				Success: false,
 				Data:    nil,
@@ -486,6 +489,7 @@ func (ac *ActuaryController) ResetActuaryLimit(c *fiber.Ctx) error {
 
 	result := tx.First(&actuary, id)
 	if result.Error != nil {
+		tx.Rollback()
 		return c.Status(404).JSON(types.Response{
 			Success: false,
 			Data:    nil,
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+
+	"banka1.com/db"
+	"banka1.com/middlewares"
+	"banka1.com/types"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type ApprovalRuleController struct {
+}
+
+func NewApprovalRuleController() *ApprovalRuleController {
+	return &ApprovalRuleController{}
+}
+
+// approvalRuleFromRequest čita i proverava telo zahteva za pravilo odobravanja.
+func approvalRuleFromRequest(c *fiber.Ctx, rule *types.ApprovalRule) *fiber.Error {
+	var request types.ApprovalRuleRequest
+	if err := c.BodyParser(&request); err != nil {
+		return fiber.NewError(400, "Neuspelo parsiranje: "+err.Error())
+	}
+	if err := validate.Struct(request); err != nil {
+		return fiber.NewError(400, "Neuspela validacija: "+err.Error())
+	}
+
+	rule.Name = strings.TrimSpace(request.Name)
+	rule.Priority = request.Priority
+	rule.Action = request.Action
+	rule.Enabled = request.Enabled == nil || *request.Enabled
+	rule.NeedApproval = request.NeedApproval
+	rule.ExceedsLimit = request.ExceedsLimit
+	rule.MinNotional = request.MinNotional
+	rule.InstrumentTypes = strings.TrimSpace(request.InstrumentTypes)
+	rule.Margin = request.Margin
+	rule.AfterHours = request.AfterHours
+	rule.SettlementWithinDays = request.SettlementWithinDays
+	return nil
+}
+
+// GetApprovalRules godoc
+//
+//	@Summary		Lista pravila odobravanja
+//	@Description	Vraća pravila politike odobravanja naloga agenata redom kojim se proveravaju.
+//	@Tags			Approval rules
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=[]types.ApprovalRule}	"Pravila odobravanja"
+//	@Failure		500	{object}	types.Response								"Greška pri čitanju pravila"
+//	@Router			/approval-rules [get]
+func (ac *ApprovalRuleController) GetApprovalRules(c *fiber.Ctx) error {
+	var rules []types.ApprovalRule
+	if err := db.DB.Order("priority").Order("id").Find(&rules).Error; err != nil {
+		return c.Status(500).JSON(types.Response{Success: false, Error: "Greška pri čitanju pravila odobravanja: " + err.Error()})
+	}
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    rules,
+	})
+}
+
+// CreateApprovalRule godoc
+//
+//	@Summary		Dodavanje pravila odobravanja
+//	@Description	Nalog agenta dobija akciju prvog uključenog pravila (po prioritetu) čiji su svi zadati uslovi ispunjeni: approve ga odobrava, supervisor ga šalje na odobrenje, a reject ga odbija. Ako nijedno pravilo ne odgovara, nalog čeka supervizora kada agent ima needApproval ili bi prešao limit.
+//	@Tags			Approval rules
+//	@Accept			json
+//	@Produce		json
+//	@Param			rule	body	types.ApprovalRuleRequest	true	"Uslovi i akcija pravila"
+//	@Security		BearerAuth
+//	@Success		201	{object}	types.Response{data=types.ApprovalRule}	"Dodato pravilo"
+//	@Failure		400	{object}	types.Response							"Nevalidan zahtev"
+//	@Router			/approval-rules [post]
+func (ac *ApprovalRuleController) CreateApprovalRule(c *fiber.Ctx) error {
+	var rule types.ApprovalRule
+	if ferr := approvalRuleFromRequest(c, &rule); ferr != nil {
+		return c.Status(ferr.Code).JSON(types.Response{Success: false, Error: ferr.Message})
+	}
+	if err := db.DB.Create(&rule).Error; err != nil {
+		return c.Status(500).JSON(types.Response{Success: false, Error: "Greška pri čuvanju pravila odobravanja: " + err.Error()})
+	}
+	return c.Status(201).JSON(types.Response{
+		Success: true,
+		Data:    rule,
+	})
+}
+
+// UpdateApprovalRule godoc
+//
+//	@Summary		Izmena pravila odobravanja
+//	@Description	Zamenjuje uslove, akciju i prioritet pravila. Izmena važi za naloge kreirane ili izmenjene posle nje.
+//	@Tags			Approval rules
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path	int							true	"ID pravila"
+//	@Param			rule	body	types.ApprovalRuleRequest	true	"Uslovi i akcija pravila"
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=types.ApprovalRule}	"Izmenjeno pravilo"
+//	@Failure		400	{object}	types.Response							"Nevalidan zahtev"
+//	@Failure		404	{object}	types.Response							"Pravilo nije pronađeno"
+//	@Router			/approval-rules/{id} [put]
+func (ac *ApprovalRuleController) UpdateApprovalRule(c *fiber.Ctx) error {
+	var rule types.ApprovalRule
+	if err := db.DB.First(&rule, c.Params("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(404).JSON(types.Response{Success: false, Error: "Pravilo odobravanja nije pronađeno"})
+		}
+		return c.Status(500).JSON(types.Response{Success: false, Error: "Greška pri čitanju pravila odobravanja: " + err.Error()})
+	}
+	if ferr := approvalRuleFromRequest(c, &rule); ferr != nil {
+		return c.Status(ferr.Code).JSON(types.Response{Success: false, Error: ferr.Message})
+	}
+	if err := db.DB.Save(&rule).Error; err != nil {
+		return c.Status(500).JSON(types.Response{Success: false, Error: "Greška pri čuvanju pravila odobravanja: " + err.Error()})
+	}
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    rule,
+	})
+}
+
+// DeleteApprovalRule godoc
+//
+//	@Summary		Brisanje pravila odobravanja
+//	@Description	Uklanja pravilo iz politike odobravanja. Nalozi koji su ga zabeležili zadržavaju njegov ID.
+//	@Tags			Approval rules
+//	@Produce		json
+//	@Param			id	path	int	true	"ID pravila"
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response	"Pravilo obrisano"
+//	@Failure		404	{object}	types.Response	"Pravilo nije pronađeno"
+//	@Router			/approval-rules/{id} [delete]
+func (ac *ApprovalRuleController) DeleteApprovalRule(c *fiber.Ctx) error {
+	result := db.DB.Delete(&types.ApprovalRule{}, c.Params("id"))
+	if result.Error != nil {
+		return c.Status(500).JSON(types.Response{Success: false, Error: "Greška pri brisanju pravila odobravanja: " + result.Error.Error()})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(404).JSON(types.Response{Success: false, Error: "Pravilo odobravanja nije pronađeno"})
+	}
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    "Pravilo odobravanja obrisano",
+	})
+}
+
+func InitApprovalRuleRoutes(app *fiber.App) {
+	ruleController := NewApprovalRuleController()
+
+	ruleGroup := app.Group("/approval-rules", middlewares.Auth, middlewares.DepartmentCheck("SUPERVISOR"))
+	ruleGroup.Get("", ruleController.GetApprovalRules)
+	ruleGroup.Post("", ruleController.CreateApprovalRule)
+	ruleGroup.Put("/:id", ruleController.UpdateApprovalRule)
+	ruleGroup.Delete("/:id", ruleController.DeleteApprovalRule)
+}
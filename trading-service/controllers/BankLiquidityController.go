@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"banka1.com/controllers/orders"
+	"banka1.com/db"
+	"banka1.com/middlewares"
+	"banka1.com/types"
+	"github.com/gofiber/fiber/v2"
+)
+
+type BankLiquidityController struct {
+}
+
+func NewBankLiquidityController() *BankLiquidityController {
+	return &BankLiquidityController{}
+}
+
+func bankInventoryResponse(security types.Security) (types.BankInventoryResponse, error) {
+	position, reserved, limit, err := orders.BankInventory(db.DB, security.ID)
+	if err != nil {
+		return types.BankInventoryResponse{}, err
+	}
+	return types.BankInventoryResponse{
+		SecurityID:  security.ID,
+		Ticker:      security.Ticker,
+		Position:    position,
+		Reserved:    reserved,
+		MaxPosition: limit.MaxPosition,
+		MinPosition: limit.MinPosition,
+	}, nil
+}
+
+// GetBankInventory godoc
+//
+//	@Summary		Inventar banke kao pružaoca likvidnosti
+//	@Description	Vraća poziciju banke i limite inventara za hartije u kojima banka ima poziciju ili podešen limit.
+//	@Tags			Bank
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=[]types.BankInventoryResponse}	"Inventar banke"
+//	@Failure		500	{object}	types.Response										"Greška pri čitanju inventara"
+//	@Router			/bank/inventory [get]
+func (bc *BankLiquidityController) GetBankInventory(c *fiber.Ctx) error {
+	var securities []types.Security
+	if err := db.DB.
+		Where("id IN (?) OR id IN (?)",
+			db.DB.Model(&types.BankInventoryLimit{}).Select("security_id"),
+			db.DB.Model(&types.Portfolio{}).Select("security_id").Where("user_id = ?", orders.BankUserID)).
+		Order("id").
+		Find(&securities).Error; err != nil {
+		return c.Status(500).JSON(types.Response{
+			Success: false,
+			Error:   "Greška pri čitanju inventara: " + err.Error(),
+		})
+	}
+
+	responses := make([]types.BankInventoryResponse, 0, len(securities))
+	for _, security := range securities {
+		response, err := bankInventoryResponse(security)
+		if err != nil {
+			return c.Status(500).JSON(types.Response{
+				Success: false,
+				Error:   "Greška pri čitanju inventara: " + err.Error(),
+			})
+		}
+		responses = append(responses, response)
+	}
+
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    responses,
+	})
+}
+
+// UpdateBankInventoryLimit godoc
+//
+//	@Summary		Podešavanje limita inventara banke
+//	@Description	Postavlja najveću i najmanju poziciju koju banka sme da ima u hartiji kada preuzima drugu stranu ordera.
+//	@Tags			Bank
+//	@Accept			json
+//	@Produce		json
+//	@Param			securityId	path	int										true	"ID hartije"
+//	@Param			limit		body	types.UpdateBankInventoryLimitRequest	true	"Novi limiti"
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=types.BankInventoryResponse}	"Limit sačuvan"
+//	@Failure		400	{object}	types.Response										"Neispravan zahtev"
+//	@Failure		404	{object}	types.Response										"Hartija nije pronađena"
+//	@Router			/bank/inventory/{securityId} [put]
+func (bc *BankLiquidityController) UpdateBankInventoryLimit(c *fiber.Ctx) error {
+	securityID, err := c.ParamsInt("securityId", -1)
+	if err != nil || securityID <= 0 {
+		return c.Status(400).JSON(types.Response{Success: false, Error: "Nevalidan ID hartije"})
+	}
+
+	var security types.Security
+	if err := db.DB.First(&security, securityID).Error; err != nil {
+		return c.Status(404).JSON(types.Response{Success: false, Error: "Hartija nije pronađena"})
+	}
+
+	var request types.UpdateBankInventoryLimitRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(400).JSON(types.Response{
+			Success: false,
+			Error:   "Neuspelo parsiranje: " + err.Error(),
+		})
+	}
+	if err := validate.Struct(request); err != nil {
+		return c.Status(400).JSON(types.Response{
+			Success: false,
+			Error:   "Neuspela validacija: " + err.Error(),
+		})
+	}
+
+	if _, err := orders.SetBankInventoryLimit(security.ID, request.MaxPosition, request.MinPosition); err != nil {
+		return c.Status(500).JSON(types.Response{
+			Success: false,
+			Error:   "Greška pri upisu limita: " + err.Error(),
+		})
+	}
+
+	response, err := bankInventoryResponse(security)
+	if err != nil {
+		return c.Status(500).JSON(types.Response{
+			Success: false,
+			Error:   "Greška pri čitanju inventara: " + err.Error(),
+		})
+	}
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    response,
+	})
+}
+
+func InitBankLiquidityRoutes(app *fiber.App) {
+	bankController := NewBankLiquidityController()
+
+	app.Get("/bank/inventory", middlewares.Auth, middlewares.DepartmentCheck("SUPERVISOR"), bankController.GetBankInventory)
+	app.Put("/bank/inventory/:securityId", middlewares.Auth, middlewares.DepartmentCheck("SUPERVISOR"), bankController.UpdateBankInventoryLimit)
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+
+	"banka1.com/controllers/orders"
+	"banka1.com/db"
+	"banka1.com/middlewares"
+	"banka1.com/types"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type BorrowController struct {
+}
+
+func NewBorrowController() *BorrowController {
+	return &BorrowController{}
+}
+
+// GetBorrows godoc
+//
+//	@Summary		Pozajmice hartija prijavljenog korisnika
+//	@Description	Vraća pozajmice hartija iz inventara banke za short prodaju, sa obračunatom naknadom i rokom vraćanja ako je pozajmica opozvana.
+//	@Tags			Borrow
+//	@Produce		json
+//	@Param			status	query	string	false	"Status pozajmice (active, recalled, buy_in, closed)"
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=[]types.SecurityBorrow}	"Pozajmice"
+//	@Failure		500	{object}	types.Response								"Greška pri čitanju pozajmica"
+//	@Router			/borrows [get]
+func (bc *BorrowController) GetBorrows(c *fiber.Ctx) error {
+	userID := uint(c.Locals("user_id").(float64))
+
+	query := db.DB.Where("user_id = ?", userID).Order("id desc")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var borrows []types.SecurityBorrow
+	if err := query.Find(&borrows).Error; err != nil {
+		return c.Status(500).JSON(types.Response{Success: false, Error: "Greška pri čitanju pozajmica: " + err.Error()})
+	}
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    borrows,
+	})
+}
+
+// RecallBorrow godoc
+//
+//	@Summary		Opoziv pozajmice
+//	@Description	Banka opoziva pozajmicu hartija. Ako korisnik ne zatvori short poziciju do roka, pozicija se zatvara prinudnom kupovinom.
+//	@Tags			Borrow
+//	@Produce		json
+//	@Param			id	path	int	true	"ID pozajmice"
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=types.SecurityBorrow}	"Opozvana pozajmica"
+//	@Failure		400	{object}	types.Response								"Pozajmica nije aktivna"
+//	@Failure		404	{object}	types.Response								"Pozajmica nije pronađena"
+//	@Router			/borrows/{id}/recall [post]
+func (bc *BorrowController) RecallBorrow(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(types.Response{Success: false, Error: "Nevalidan ID pozajmice"})
+	}
+
+	var borrow types.SecurityBorrow
+	if err := db.DB.First(&borrow, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(404).JSON(types.Response{Success: false, Error: "Pozajmica nije pronađena"})
+		}
+		return c.Status(500).JSON(types.Response{Success: false, Error: "Greška pri čitanju pozajmice: " + err.Error()})
+	}
+	if borrow.Status != orders.BorrowStatusActive {
+		return c.Status(400).JSON(types.Response{Success: false, Error: "Pozajmica nije aktivna"})
+	}
+	if err := orders.RecallBorrow(db.DB, &borrow); err != nil {
+		return c.Status(500).JSON(types.Response{Success: false, Error: "Greška pri opozivu pozajmice: " + err.Error()})
+	}
+
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    borrow,
+	})
+}
+
+func InitBorrowRoutes(app *fiber.App) {
+	borrowController := NewBorrowController()
+
+	app.Get("/borrows", middlewares.Auth, borrowController.GetBorrows)
+	app.Post("/borrows/:id/recall", middlewares.Auth, middlewares.DepartmentCheck("SUPERVISOR"), borrowController.RecallBorrow)
+}
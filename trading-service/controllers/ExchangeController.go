@@ -1,13 +1,17 @@
 package controllers
 
 import (
+	"banka1.com/clock"
 	"banka1.com/db"
+	"banka1.com/exchanges"
 	"banka1.com/middlewares"
 	"banka1.com/types"
 	"errors"
+	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -176,9 +180,337 @@ func (ec *ExchangeController) GetExchangeByAcronym(c *fiber.Ctx) error {
 	})
 }
 
+// GetExchangeStatus godoc
+//
+//	@Summary		Trenutno stanje berze
+//	@Description	Vraća da li je berza otvorena, zatvorena, u pre-market ili post-market periodu, na osnovu radnog vremena i vremenske zone berze.
+//	@Tags			Exchanges
+//	@Produce		json
+//	@Param			id	path		int												true	"ID berze"
+//	@Success		200	{object}	types.Response{data=types.ExchangeStatusResponse}	"Stanje berze"
+//	@Failure		400	{object}	types.Response									"Neispravan format ID-ja"
+//	@Failure		404	{object}	types.Response									"Berza sa datim ID-jem nije pronađena"
+//	@Failure		500	{object}	types.Response									"Neispravno radno vreme ili vremenska zona berze"
+//	@Router			/exchanges/{id}/status [get]
+func (ec *ExchangeController) GetExchangeStatus(c *fiber.Ctx) error {
+	exchange, ferr := exchangeFromParam(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(types.Response{
+			Success: false,
+			Data:    nil,
+			Error:   ferr.Message,
+		})
+	}
+
+	now := clock.Now()
+	status, err := exchanges.MarketStatus(exchange, now)
+	if err != nil {
+		return c.Status(500).JSON(types.Response{
+			Success: false,
+			Data:    nil,
+			Error:   err.Error(),
+		})
+	}
+	loc, _ := exchanges.Location(exchange)
+	holiday, _ := exchanges.HolidayName(exchange.MicCode, now.In(loc))
+
+	return c.JSON(types.Response{
+		Success: true,
+		Data: types.ExchangeStatusResponse{
+			ExchangeID: exchange.ID,
+			MicCode:    exchange.MicCode,
+			Status:     status,
+			LocalTime:  now.In(loc).Format(time.RFC3339),
+			OpenTime:   strings.TrimSpace(exchange.OpenTime),
+			CloseTime:  strings.TrimSpace(exchange.CloseTime),
+			Holiday:    holiday,
+		},
+		Error: "",
+	})
+}
+
+// exchangeFromParam učitava berzu na osnovu parametra "id" iz putanje.
+func exchangeFromParam(c *fiber.Ctx) (types.Exchange, *fiber.Error) {
+	var exchange types.Exchange
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return exchange, fiber.NewError(400, "Neispravan format ID-ja")
+	}
+	if result := db.DB.First(&exchange, id); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return exchange, fiber.NewError(404, "Berza sa datim ID-jem nije pronađena")
+		}
+		return exchange, fiber.NewError(500, "Greška pri preuzimanju berze: "+result.Error.Error())
+	}
+	return exchange, nil
+}
+
+// GetExchangeHolidays godoc
+//
+//	@Summary		Neradni dani berze
+//	@Description	Vraća kalendar praznika berze u kojima se ne trguje, sortiran po datumu.
+//	@Tags			Exchanges
+//	@Produce		json
+//	@Param			id	path		int												true	"ID berze"
+//	@Success		200	{object}	types.Response{data=[]types.ExchangeHoliday}	"Lista neradnih dana"
+//	@Failure		400	{object}	types.Response									"Neispravan format ID-ja"
+//	@Failure		404	{object}	types.Response									"Berza sa datim ID-jem nije pronađena"
+//	@Router			/exchanges/{id}/holidays [get]
+func (ec *ExchangeController) GetExchangeHolidays(c *fiber.Ctx) error {
+	exchange, ferr := exchangeFromParam(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(types.Response{
+			Success: false,
+			Data:    nil,
+			Error:   ferr.Message,
+		})
+	}
+
+	var holidays []types.ExchangeHoliday
+	if err := db.DB.Where("mic_code = ?", strings.TrimSpace(exchange.MicCode)).Order("date").Find(&holidays).Error; err != nil {
+		return c.Status(500).JSON(types.Response{
+			Success: false,
+			Data:    nil,
+			Error:   "Greška pri preuzimanju neradnih dana: " + err.Error(),
+		})
+	}
+
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    holidays,
+		Error:   "",
+	})
+}
+
+// CreateExchangeHoliday godoc
+//
+//	@Summary		Dodavanje neradnog dana berze
+//	@Description	Supervizor dodaje praznik u kalendar berze. Na taj dan berza je zatvorena, DAY nalozi ističu na sledećem radnom danu, a futures ugovori se ne izmiruju.
+//	@Tags			Exchanges
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path	int									true	"ID berze"
+//	@Param			holiday	body	types.CreateExchangeHolidayRequest	true	"Datum (YYYY-MM-DD) i naziv praznika"
+//	@Security		BearerAuth
+//	@Success		201	{object}	types.Response{data=types.ExchangeHoliday}	"Neradni dan dodat"
+//	@Failure		400	{object}	types.Response								"Neispravan zahtev"
+//	@Failure		404	{object}	types.Response								"Berza sa datim ID-jem nije pronađena"
+//	@Failure		409	{object}	types.Response								"Neradni dan već postoji"
+//	@Router			/exchanges/{id}/holidays [post]
+func (ec *ExchangeController) CreateExchangeHoliday(c *fiber.Ctx) error {
+	exchange, ferr := exchangeFromParam(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(types.Response{
+			Success: false,
+			Data:    nil,
+			Error:   ferr.Message,
+		})
+	}
+
+	var req types.CreateExchangeHolidayRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(types.Response{
+			Success: false,
+			Data:    nil,
+			Error:   "Neuspelo parsiranje: " + err.Error(),
+		})
+	}
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(types.Response{
+			Success: false,
+			Data:    nil,
+			Error:   "Neuspela validacija: " + err.Error(),
+		})
+	}
+
+	holiday := types.ExchangeHoliday{
+		MicCode: strings.TrimSpace(exchange.MicCode),
+		Date:    req.Date,
+		Name:    req.Name,
+	}
+	var count int64
+	db.DB.Model(&types.ExchangeHoliday{}).Where("mic_code = ? AND date = ?", holiday.MicCode, holiday.Date).Count(&count)
+	if count > 0 {
+		return c.Status(409).JSON(types.Response{
+			Success: false,
+			Data:    nil,
+			Error:   "Neradni dan " + holiday.Date + " već postoji za berzu " + holiday.MicCode,
+		})
+	}
+	if err := db.DB.Create(&holiday).Error; err != nil {
+		return c.Status(500).JSON(types.Response{
+			Success: false,
+			Data:    nil,
+			Error:   "Greška pri upisu neradnog dana: " + err.Error(),
+		})
+	}
+	if err := exchanges.ReloadHolidays(); err != nil {
+		fmt.Printf("Greska pri osvezavanju kalendara: %v\n", err)
+	}
+
+	return c.Status(201).JSON(types.Response{
+		Success: true,
+		Data:    holiday,
+		Error:   "",
+	})
+}
+
+// DeleteExchangeHoliday godoc
+//
+//	@Summary		Brisanje neradnog dana berze
+//	@Description	Supervizor uklanja praznik iz kalendara berze.
+//	@Tags			Exchanges
+//	@Produce		json
+//	@Param			id		path	int		true	"ID berze"
+//	@Param			date	path	string	true	"Datum praznika (YYYY-MM-DD)"
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response	"Neradni dan obrisan"
+//	@Failure		404	{object}	types.Response	"Berza ili neradni dan nisu pronađeni"
+//	@Router			/exchanges/{id}/holidays/{date} [delete]
+func (ec *ExchangeController) DeleteExchangeHoliday(c *fiber.Ctx) error {
+	exchange, ferr := exchangeFromParam(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(types.Response{
+			Success: false,
+			Data:    nil,
+			Error:   ferr.Message,
+		})
+	}
+
+	result := db.DB.Where("mic_code = ? AND date = ?", strings.TrimSpace(exchange.MicCode), c.Params("date")).Delete(&types.ExchangeHoliday{})
+	if result.Error != nil {
+		return c.Status(500).JSON(types.Response{
+			Success: false,
+			Data:    nil,
+			Error:   "Greška pri brisanju neradnog dana: " + result.Error.Error(),
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(404).JSON(types.Response{
+			Success: false,
+			Data:    nil,
+			Error:   "Neradni dan nije pronađen",
+		})
+	}
+	if err := exchanges.ReloadHolidays(); err != nil {
+		fmt.Printf("Greska pri osvezavanju kalendara: %v\n", err)
+	}
+
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    "Neradni dan obrisan",
+		Error:   "",
+	})
+}
+
+// SetExchangeAuctionMode godoc
+//
+//	@Summary		Uključivanje aukcijskog režima berze
+//	@Description	U aukcijskom režimu se orderi prikupljeni u pre-market periodu i pred zatvaranje berze ukrštaju po jedinstvenoj ceni u trenutku otvaranja, odnosno zatvaranja. Cena aukcije zatvaranja je zvanična cena zatvaranja.
+//	@Tags			Exchanges
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path	int									true	"ID berze"
+//	@Param			auction	body	types.ExchangeAuctionModeRequest	true	"Uključen ili isključen aukcijski režim"
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=types.Exchange}	"Ažurirana berza"
+//	@Failure		400	{object}	types.Response						"Neispravan zahtev"
+//	@Failure		404	{object}	types.Response						"Berza sa datim ID-jem nije pronađena"
+//	@Router			/exchanges/{id}/auction [put]
+func (ec *ExchangeController) SetExchangeAuctionMode(c *fiber.Ctx) error {
+	exchange, ferr := exchangeFromParam(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(types.Response{
+			Success: false,
+			Data:    nil,
+			Error:   ferr.Message,
+		})
+	}
+
+	var req types.ExchangeAuctionModeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(types.Response{
+			Success: false,
+			Data:    nil,
+			Error:   "Neuspelo parsiranje: " + err.Error(),
+		})
+	}
+	if err := validate.Struct(req); err != nil {
+		return c.Status(400).JSON(types.Response{
+			Success: false,
+			Data:    nil,
+			Error:   "Neuspela validacija: " + err.Error(),
+		})
+	}
+
+	if err := db.DB.Model(&exchange).Update("auction_mode", *req.Enabled).Error; err != nil {
+		return c.Status(500).JSON(types.Response{
+			Success: false,
+			Data:    nil,
+			Error:   "Greška pri izmeni berze: " + err.Error(),
+		})
+	}
+	exchange.AuctionMode = *req.Enabled
+
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    exchange,
+		Error:   "",
+	})
+}
+
+// GetExchangeAuctions godoc
+//
+//	@Summary		Rezultati aukcija berze
+//	@Description	Vraća cene i količine aukcija otvaranja i zatvaranja hartija berze, najnovije prvo. Sa session=YYYY-MM-DD vraća samo aukcije te sesije.
+//	@Tags			Exchanges
+//	@Produce		json
+//	@Param			id		path	int		true	"ID berze"
+//	@Param			session	query	string	false	"Datum sesije (YYYY-MM-DD)"
+//	@Success		200	{object}	types.Response{data=[]types.Auction}	"Rezultati aukcija"
+//	@Failure		404	{object}	types.Response							"Berza sa datim ID-jem nije pronađena"
+//	@Router			/exchanges/{id}/auctions [get]
+func (ec *ExchangeController) GetExchangeAuctions(c *fiber.Ctx) error {
+	exchange, ferr := exchangeFromParam(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(types.Response{
+			Success: false,
+			Data:    nil,
+			Error:   ferr.Message,
+		})
+	}
+
+	query := db.DB.Where("exchange_id = ?", exchange.ID).Order("uncrossed_at desc").Order("security_id")
+	if session := c.Query("session"); session != "" {
+		query = query.Where("session = ?", session)
+	}
+	var auctions []types.Auction
+	if err := query.Find(&auctions).Error; err != nil {
+		return c.Status(500).JSON(types.Response{
+			Success: false,
+			Data:    nil,
+			Error:   "Greška pri preuzimanju aukcija: " + err.Error(),
+		})
+	}
+
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    auctions,
+		Error:   "",
+	})
+}
+
 func InitExchangeRoutes(app *fiber.App) {
 	ec := NewExchangeController()
 
+	// Stanje berze se menja tokom dana, pa se registruje pre grupe sa keširanjem
+	app.Get("/exchanges/:id/status", ec.GetExchangeStatus)
+	app.Get("/exchanges/:id/holidays", ec.GetExchangeHolidays)
+	app.Post("/exchanges/:id/holidays", middlewares.Auth, middlewares.DepartmentCheck("SUPERVISOR"), ec.CreateExchangeHoliday)
+	app.Delete("/exchanges/:id/holidays/:date", middlewares.Auth, middlewares.DepartmentCheck("SUPERVISOR"), ec.DeleteExchangeHoliday)
+	app.Put("/exchanges/:id/auction", middlewares.Auth, middlewares.DepartmentCheck("SUPERVISOR"), ec.SetExchangeAuctionMode)
+	app.Get("/exchanges/:id/auctions", ec.GetExchangeAuctions)
+
 	exchangeGroup := app.Group("/exchanges", middlewares.CacheMiddleware(12*time.Hour))
 
 	exchangeGroup.Get("", ec.GetAllExchanges)
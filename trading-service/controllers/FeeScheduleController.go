@@ -0,0 +1,257 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+
+	"banka1.com/clock"
+	"banka1.com/controllers/orders"
+	"banka1.com/db"
+	"banka1.com/middlewares"
+	"banka1.com/types"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type FeeScheduleController struct {
+}
+
+func NewFeeScheduleController() *FeeScheduleController {
+	return &FeeScheduleController{}
+}
+
+// feeScheduleFromRequest proverava zahtev i pravi tarifu sa normalizovanim kriterijumima.
+func feeScheduleFromRequest(c *fiber.Ctx) (types.FeeSchedule, *fiber.Error) {
+	var request types.FeeScheduleRequest
+	if err := c.BodyParser(&request); err != nil {
+		return types.FeeSchedule{}, fiber.NewError(400, "Neuspelo parsiranje: "+err.Error())
+	}
+	if err := validate.Struct(request); err != nil {
+		return types.FeeSchedule{}, fiber.NewError(400, "Neuspela validacija: "+err.Error())
+	}
+	if request.Cap != nil && *request.Cap < request.Minimum {
+		return types.FeeSchedule{}, fiber.NewError(400, "cap ne može biti manji od minimum")
+	}
+
+	schedule := types.FeeSchedule{
+		Name:           request.Name,
+		OrderType:      strings.ToUpper(strings.TrimSpace(request.OrderType)),
+		Segment:        strings.ToLower(strings.TrimSpace(request.Segment)),
+		InstrumentType: strings.TrimSpace(request.InstrumentType),
+		ExchangeID:     request.ExchangeID,
+		Minimum:        request.Minimum,
+		Cap:            request.Cap,
+		EffectiveFrom:  clock.Now(),
+		EffectiveTo:    request.EffectiveTo,
+	}
+	if request.EffectiveFrom != nil {
+		schedule.EffectiveFrom = *request.EffectiveFrom
+	}
+	if schedule.EffectiveTo != nil && !schedule.EffectiveTo.After(schedule.EffectiveFrom) {
+		return types.FeeSchedule{}, fiber.NewError(400, "effective_to mora biti posle effective_from")
+	}
+
+	for _, tier := range request.Tiers {
+		schedule.Tiers = append(schedule.Tiers, types.FeeScheduleTier{UpTo: tier.UpTo, Percent: tier.Percent})
+	}
+	if err := orders.ValidateFeeTiers(schedule.Tiers); err != nil {
+		return types.FeeSchedule{}, fiber.NewError(400, "Nevalidni stepeni tarife: "+err.Error())
+	}
+
+	if schedule.ExchangeID != nil {
+		var exchange types.Exchange
+		if err := db.DB.First(&exchange, *schedule.ExchangeID).Error; err != nil {
+			return types.FeeSchedule{}, fiber.NewError(404, "Berza nije pronađena")
+		}
+	}
+	return schedule, nil
+}
+
+func findFeeSchedule(c *fiber.Ctx) (types.FeeSchedule, *fiber.Error) {
+	id, err := c.ParamsInt("id", -1)
+	if err != nil || id <= 0 {
+		return types.FeeSchedule{}, fiber.NewError(400, "Nevalidan ID tarife")
+	}
+
+	var schedule types.FeeSchedule
+	if err := db.DB.Preload("Tiers").First(&schedule, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return types.FeeSchedule{}, fiber.NewError(404, "Tarifa nije pronađena")
+		}
+		return types.FeeSchedule{}, fiber.NewError(500, "Greška pri čitanju tarife: "+err.Error())
+	}
+	return schedule, nil
+}
+
+// GetFeeSchedules godoc
+//
+//	@Summary		Lista tarifa provizije
+//	@Description	Vraća sve tarife provizije sa stepenima, uključujući i one koje još ne važe ili su istekle.
+//	@Tags			Fees
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=[]types.FeeSchedule}	"Lista tarifa"
+//	@Failure		500	{object}	types.Response								"Greška pri čitanju tarifa"
+//	@Router			/fee-schedules [get]
+func (fc *FeeScheduleController) GetFeeSchedules(c *fiber.Ctx) error {
+	var schedules []types.FeeSchedule
+	if err := db.DB.Preload("Tiers").Order("id").Find(&schedules).Error; err != nil {
+		return c.Status(500).JSON(types.Response{
+			Success: false,
+			Error:   "Greška pri čitanju tarifa: " + err.Error(),
+		})
+	}
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    schedules,
+	})
+}
+
+// GetFeeSchedule godoc
+//
+//	@Summary		Tarifa provizije po ID-ju
+//	@Tags			Fees
+//	@Produce		json
+//	@Param			id	path	int	true	"ID tarife"
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=types.FeeSchedule}	"Tarifa"
+//	@Failure		404	{object}	types.Response							"Tarifa nije pronađena"
+//	@Router			/fee-schedules/{id} [get]
+func (fc *FeeScheduleController) GetFeeSchedule(c *fiber.Ctx) error {
+	schedule, ferr := findFeeSchedule(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(types.Response{Success: false, Error: ferr.Message})
+	}
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    schedule,
+	})
+}
+
+// CreateFeeSchedule godoc
+//
+//	@Summary		Kreiranje tarife provizije
+//	@Description	Tarifa se bira po tipu ordera, segmentu klijenta (customer, agent, supervisor), tipu hartije i berzi; prazan kriterijum važi za sve.
+//	@Description	Svaki stepen naplaćuje procenat na deo vrednosti transakcije do svoje gornje granice, zatim se primenjuju minimum i cap.
+//	@Tags			Fees
+//	@Accept			json
+//	@Produce		json
+//	@Param			schedule	body	types.FeeScheduleRequest	true	"Tarifa"
+//	@Security		BearerAuth
+//	@Success		201	{object}	types.Response{data=types.FeeSchedule}	"Tarifa kreirana"
+//	@Failure		400	{object}	types.Response							"Neispravan zahtev"
+//	@Failure		404	{object}	types.Response							"Berza nije pronađena"
+//	@Router			/fee-schedules [post]
+func (fc *FeeScheduleController) CreateFeeSchedule(c *fiber.Ctx) error {
+	schedule, ferr := feeScheduleFromRequest(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(types.Response{Success: false, Error: ferr.Message})
+	}
+
+	if err := db.DB.Create(&schedule).Error; err != nil {
+		return c.Status(500).JSON(types.Response{
+			Success: false,
+			Error:   "Greška pri upisu tarife: " + err.Error(),
+		})
+	}
+	return c.Status(201).JSON(types.Response{
+		Success: true,
+		Data:    schedule,
+	})
+}
+
+// UpdateFeeSchedule godoc
+//
+//	@Summary		Izmena tarife provizije
+//	@Description	Zamenjuje kriterijume, granice i stepene tarife. Već naplaćene provizije se ne menjaju.
+//	@Tags			Fees
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path	int							true	"ID tarife"
+//	@Param			schedule	body	types.FeeScheduleRequest	true	"Tarifa"
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=types.FeeSchedule}	"Tarifa izmenjena"
+//	@Failure		400	{object}	types.Response							"Neispravan zahtev"
+//	@Failure		404	{object}	types.Response							"Tarifa nije pronađena"
+//	@Router			/fee-schedules/{id} [put]
+func (fc *FeeScheduleController) UpdateFeeSchedule(c *fiber.Ctx) error {
+	existing, ferr := findFeeSchedule(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(types.Response{Success: false, Error: ferr.Message})
+	}
+	schedule, ferr := feeScheduleFromRequest(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(types.Response{Success: false, Error: ferr.Message})
+	}
+	schedule.ID = existing.ID
+	schedule.CreatedAt = existing.CreatedAt
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("schedule_id = ?", schedule.ID).Delete(&types.FeeScheduleTier{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Omit("Tiers").Save(&schedule).Error; err != nil {
+			return err
+		}
+		for i := range schedule.Tiers {
+			schedule.Tiers[i].ScheduleID = schedule.ID
+		}
+		return tx.Create(&schedule.Tiers).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(types.Response{
+			Success: false,
+			Error:   "Greška pri izmeni tarife: " + err.Error(),
+		})
+	}
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    schedule,
+	})
+}
+
+// DeleteFeeSchedule godoc
+//
+//	@Summary		Brisanje tarife provizije
+//	@Description	Briše tarifu i njene stepene. Transakcije zadržavaju ID tarife po kojoj su naplaćene.
+//	@Tags			Fees
+//	@Produce		json
+//	@Param			id	path	int	true	"ID tarife"
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response	"Tarifa obrisana"
+//	@Failure		404	{object}	types.Response	"Tarifa nije pronađena"
+//	@Router			/fee-schedules/{id} [delete]
+func (fc *FeeScheduleController) DeleteFeeSchedule(c *fiber.Ctx) error {
+	schedule, ferr := findFeeSchedule(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(types.Response{Success: false, Error: ferr.Message})
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("schedule_id = ?", schedule.ID).Delete(&types.FeeScheduleTier{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&types.FeeSchedule{}, schedule.ID).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(types.Response{
+			Success: false,
+			Error:   "Greška pri brisanju tarife: " + err.Error(),
+		})
+	}
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    schedule.ID,
+	})
+}
+
+func InitFeeScheduleRoutes(app *fiber.App) {
+	feeController := NewFeeScheduleController()
+
+	feeGroup := app.Group("/fee-schedules", middlewares.Auth, middlewares.DepartmentCheck("SUPERVISOR"))
+	feeGroup.Get("", feeController.GetFeeSchedules)
+	feeGroup.Get("/:id", feeController.GetFeeSchedule)
+	feeGroup.Post("", feeController.CreateFeeSchedule)
+	feeGroup.Put("/:id", feeController.UpdateFeeSchedule)
+	feeGroup.Delete("/:id", feeController.DeleteFeeSchedule)
+}
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"errors"
+
+	"banka1.com/controllers/orders"
+	"banka1.com/db"
+	"banka1.com/middlewares"
+	"banka1.com/types"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type MarginController struct {
+}
+
+func NewMarginController() *MarginController {
+	return &MarginController{}
+}
+
+// GetMarginAccount godoc
+//
+//	@Summary		Margin nalog prijavljenog korisnika
+//	@Description	Vraća zaduženje, vrednost portfolija, kapital i zahtev održavanja iz poslednje revalorizacije, zajedno sa margin pozivima.
+//	@Tags			Margin
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=types.MarginAccountResponse}	"Margin nalog"
+//	@Failure		404	{object}	types.Response									"Korisnik nema margin nalog"
+//	@Router			/margin/account [get]
+func (mc *MarginController) GetMarginAccount(c *fiber.Ctx) error {
+	userID := uint(c.Locals("user_id").(float64))
+
+	var account types.MarginAccount
+	if err := db.DB.Where("user_id = ?", userID).First(&account).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(404).JSON(types.Response{Success: false, Error: "Korisnik nema margin nalog"})
+		}
+		return c.Status(500).JSON(types.Response{Success: false, Error: "Greška pri čitanju margin naloga: " + err.Error()})
+	}
+
+	var calls []types.MarginCall
+	if err := db.DB.Where("margin_account_id = ?", account.ID).Order("id desc").Find(&calls).Error; err != nil {
+		return c.Status(500).JSON(types.Response{Success: false, Error: "Greška pri čitanju margin poziva: " + err.Error()})
+	}
+
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    types.MarginAccountResponse{Account: account, Calls: calls},
+	})
+}
+
+// GetMarginCalls godoc
+//
+//	@Summary		Lista margin poziva
+//	@Description	Vraća margin pozive svih korisnika, opciono filtrirane po statusu (open, met, liquidated).
+//	@Tags			Margin
+//	@Produce		json
+//	@Param			status	query	string	false	"Status poziva"
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=[]types.MarginCall}	"Margin pozivi"
+//	@Failure		500	{object}	types.Response							"Greška pri čitanju margin poziva"
+//	@Router			/margin/calls [get]
+func (mc *MarginController) GetMarginCalls(c *fiber.Ctx) error {
+	query := db.DB.Order("id desc")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var calls []types.MarginCall
+	if err := query.Find(&calls).Error; err != nil {
+		return c.Status(500).JSON(types.Response{Success: false, Error: "Greška pri čitanju margin poziva: " + err.Error()})
+	}
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    calls,
+	})
+}
+
+// RevalueMarginAccounts godoc
+//
+//	@Summary		Revalorizacija margin naloga
+//	@Description	Odmah pokreće revalorizaciju koja se inače izvršava periodično: izdaje margin pozive i prinudno prodaje pozicije za pozive kojima je istekao rok.
+//	@Tags			Margin
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=int}	"Broj obrađenih margin naloga"
+//	@Router			/margin/revalue [post]
+func (mc *MarginController) RevalueMarginAccounts(c *fiber.Ctx) error {
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    orders.RevalueMarginAccounts(),
+	})
+}
+
+func InitMarginRoutes(app *fiber.App) {
+	marginController := NewMarginController()
+
+	app.Get("/margin/account", middlewares.Auth, marginController.GetMarginAccount)
+	app.Get("/margin/calls", middlewares.Auth, middlewares.DepartmentCheck("SUPERVISOR"), marginController.GetMarginCalls)
+	app.Post("/margin/revalue", middlewares.Auth, middlewares.DepartmentCheck("SUPERVISOR"), marginController.RevalueMarginAccounts)
+}
@@ -2,8 +2,11 @@ package controllers
 
 import (
 	"banka1.com/broker"
+	"banka1.com/clock"
+	"banka1.com/controllers/orders"
 	"banka1.com/db"
 	"banka1.com/dto"
+	"banka1.com/holds"
 	"banka1.com/middlewares"
 	"banka1.com/saga"
 	"banka1.com/types"
@@ -419,22 +422,15 @@ func (c *OTCTradeController) AcceptOTCTrade(ctx *fiber.Ctx) error {
 			})
 		}
 
-		var existingContracts []types.OptionContract
-		if err := db.DB.
-			Where("seller_id = ? AND portfolio_id = ? AND is_exercised = false AND status = ?",
-				trade.LocalSellerID, portfolio.ID, "active").
-			Find(&existingContracts).Error; err != nil {
+		// Javne hartije koje već pokrivaju aktivni ugovori prodavca
+		usedQuantity, err := holds.HeldShares(db.DB, portfolio.UserID, portfolio.SecurityID, holds.ReasonOTCContract)
+		if err != nil {
 			return ctx.Status(fiber.StatusInternalServerError).JSON(types.Response{
 				Success: false,
 				Error:   "Greška pri proveri postojećih ugovora",
 			})
 		}
 
-		usedQuantity := 0
-		for _, contract := range existingContracts {
-			usedQuantity += contract.Quantity
-		}
-
 		if usedQuantity+trade.Quantity > portfolio.PublicCount {
 			return ctx.Status(fiber.StatusBadRequest).JSON(types.Response{
 				Success: false,
@@ -529,7 +525,13 @@ func (c *OTCTradeController) AcceptOTCTrade(ctx *fiber.Ctx) error {
 			})
 		}
 
-		if err := db.DB.Create(&contract).Error; err != nil {
+		err = db.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&contract).Error; err != nil {
+				return err
+			}
+			return holds.Place(tx, holds.ContractHold(contract))
+		})
+		if err != nil {
 			return ctx.Status(fiber.StatusInternalServerError).JSON(types.Response{
 				Success: false,
 				Error:   "Greška pri kreiranju ugovora " + err.Error(),
@@ -543,6 +545,7 @@ func (c *OTCTradeController) AcceptOTCTrade(ctx *fiber.Ctx) error {
 		}
 
 		if err := broker.SendOTCPremium(premiumDTO); err != nil {
+			_ = holds.Release(nil, holds.ReasonOTCContract, holds.Ref(contract.ID))
 			_ = db.DB.Delete(&contract)
 			return ctx.Status(fiber.StatusInternalServerError).JSON(types.Response{
 				Success: false,
@@ -815,7 +818,7 @@ func (c *OTCTradeController) ExecuteOptionContract(ctx *fiber.Ctx) error {
 		})
 	}
 
-	if contract.SettlementAt.Before(time.Now()) {
+	if contract.SettlementAt.Before(clock.Now()) {
 		return ctx.Status(fiber.StatusBadRequest).JSON(types.Response{
 			Success: false,
 			Error:   "Ugovor je istekao",
@@ -881,7 +884,15 @@ func (c *OTCTradeController) ExecuteOptionContract(ctx *fiber.Ctx) error {
 		})
 	}
 
-	if buyerAccount.Balance < (contract.StrikePrice * float64(contract.Quantity)) {
+	available, err := orders.AvailableCash(*buyerAccount, "", "")
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(types.Response{
+			Success: false,
+			Error:   "Greška pri proveri rezervisanih sredstava kupca",
+		})
+	}
+
+	if available < (contract.StrikePrice * float64(contract.Quantity)) {
 		return ctx.Status(fiber.StatusBadRequest).JSON(types.Response{
 			Success: false,
 			Error:   "Kupčev račun nema dovoljno sredstava za izvršavanje ugovora",
@@ -915,7 +926,24 @@ func (c *OTCTradeController) ExecuteOptionContract(ctx *fiber.Ctx) error {
 		})
 	}
 
-	if err := saga.StateManager.UpdatePhase(db.DB, uid, types.PhaseInit); err != nil {
+	buyerAccountHold := uint(buyerAccountID)
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := saga.StateManager.UpdatePhase(tx, uid, types.PhaseInit); err != nil {
+			return err
+		}
+		// Iznos ostaje zadržan na računu kupca dok se saga ne završi ili povuče
+		return holds.Place(tx, types.Hold{
+			UserID:    *contract.BuyerID,
+			Asset:     holds.AssetCash,
+			AccountID: &buyerAccountHold,
+			Quantity:  contract.Quantity,
+			Amount:    dto.Amount,
+			Reason:    holds.ReasonSaga,
+			OwnerRef:  uid,
+		})
+	})
+	if err != nil {
+		go broker.FailOTC(uid, "Greška prilikom kreiranja OTC transakcije")
 		return ctx.Status(fiber.StatusInternalServerError).JSON(types.Response{
 			Success: false,
 			Error:   "Greška prilikom kreiranja OTC transakcije",
@@ -923,6 +951,11 @@ func (c *OTCTradeController) ExecuteOptionContract(ctx *fiber.Ctx) error {
 	}
 
 	if err := broker.SendOTCTransactionInit(dto); err != nil {
+		// Poruka nije poslata, pa saga listeneri neće osloboditi zadržani iznos
+		if err := holds.Release(nil, holds.ReasonSaga, uid); err != nil {
+			fmt.Printf("Greska pri oslobadjanju holda OTC sage %s: %v\n", uid, err)
+		}
+		go broker.FailOTC(uid, "Greška prilikom slanja OTC transakcije")
 		return ctx.Status(fiber.StatusInternalServerError).JSON(types.Response{
 			Success: false,
 			Error:   "Greška prilikom slanja OTC transakcije",
@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"banka1.com/clock"
+	"banka1.com/controllers/orders"
+	"banka1.com/db"
+	"banka1.com/middlewares"
+	"banka1.com/types"
+	"github.com/gofiber/fiber/v2"
+)
+
+type OrderApprovalController struct {
+}
+
+func NewOrderApprovalController() *OrderApprovalController {
+	return &OrderApprovalController{}
+}
+
+// GetApprovalQueue godoc
+//
+//	@Summary		Red naloga za odobravanje
+//	@Description	Vraća naloge koji čekaju odobrenje supervizora, eskalirane prve pa najstarije, sa procenjenom vrednošću, preostalim limitom agenta i datumom izmirenja hartije.
+//	@Tags			Orders
+//	@Produce		json
+//	@Param			agent_id		query	int		false	"Samo nalozi ovog agenta (user ID)"
+//	@Param			security_id		query	int		false	"Samo nalozi za ovu hartiju"
+//	@Param			min_notional	query	number	false	"Najmanja procenjena vrednost naloga"
+//	@Param			max_notional	query	number	false	"Najveća procenjena vrednost naloga"
+//	@Param			min_age_minutes	query	int		false	"Samo nalozi koji čekaju bar ovoliko minuta"
+//	@Param			escalated		query	bool	false	"Samo eskalirani (true) ili neeskalirani (false) nalozi"
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=[]types.ApprovalQueueItem}	"Nalozi na čekanju"
+//	@Failure		400	{object}	types.Response									"Nevalidan filter"
+//	@Failure		500	{object}	types.Response									"Greška pri čitanju naloga"
+//	@Router			/order-approvals [get]
+func (ac *OrderApprovalController) GetApprovalQueue(c *fiber.Ctx) error {
+	query := db.DB.Where("status = ? AND NOT is_done", "pending")
+	if agentID := c.QueryInt("agent_id", 0); agentID > 0 {
+		query = query.Where("user_id = ?", agentID)
+	}
+	if securityID := c.QueryInt("security_id", 0); securityID > 0 {
+		query = query.Where("security_id = ?", securityID)
+	}
+	if escalated := c.Query("escalated"); escalated != "" {
+		if c.QueryBool("escalated") {
+			query = query.Where("escalated_at IS NOT NULL")
+		} else {
+			query = query.Where("escalated_at IS NULL")
+		}
+	}
+	minNotional := c.QueryFloat("min_notional", 0)
+	maxNotional := c.QueryFloat("max_notional", 0)
+	minAge := c.QueryInt("min_age_minutes", 0)
+	if minNotional < 0 || maxNotional < 0 || minAge < 0 {
+		return c.Status(400).JSON(types.Response{Success: false, Error: "Filteri ne mogu biti negativni"})
+	}
+
+	var pending []types.Order
+	if err := query.Find(&pending).Error; err != nil {
+		return c.Status(500).JSON(types.Response{Success: false, Error: "Greška pri čitanju naloga na čekanju: " + err.Error()})
+	}
+
+	securities := map[uint]types.Security{}
+	actuaries := map[uint]*types.Actuary{}
+	for _, order := range pending {
+		if _, ok := securities[order.SecurityID]; !ok {
+			var security types.Security
+			db.DB.First(&security, order.SecurityID)
+			securities[order.SecurityID] = security
+		}
+		if _, ok := actuaries[order.UserID]; !ok {
+			var actuary types.Actuary
+			if err := db.DB.Where("user_id = ? AND department = ?", order.UserID, "AGENT").First(&actuary).Error; err == nil {
+				actuaries[order.UserID] = &actuary
+			} else {
+				actuaries[order.UserID] = nil
+			}
+		}
+	}
+
+	now := clock.Now()
+	since := orders.PendingSince(pending)
+	queue := make([]types.ApprovalQueueItem, 0, len(pending))
+	for _, order := range pending {
+		security := securities[order.SecurityID]
+		item := types.ApprovalQueueItem{
+			Order:          OrderToOrderResponse(order),
+			Ticker:         security.Ticker,
+			SecurityType:   security.Type,
+			Notional:       orders.ApprovalNotional(order, security),
+			PendingSince:   since[order.ID],
+			AgeMinutes:     int(now.Sub(since[order.ID]) / time.Minute),
+			SettlementDate: security.SettlementDate,
+		}
+		if minNotional > 0 && item.Notional < minNotional {
+			continue
+		}
+		if maxNotional > 0 && item.Notional > maxNotional {
+			continue
+		}
+		if item.AgeMinutes < minAge {
+			continue
+		}
+		if days, ok := orders.DaysToSettlement(security, now); ok {
+			item.DaysToSettlement = &days
+		}
+		if actuary := actuaries[order.UserID]; actuary != nil {
+			remaining := actuary.LimitAmount - actuary.UsedLimit
+			item.AgentLimit = &actuary.LimitAmount
+			item.AgentUsedLimit = &actuary.UsedLimit
+			item.RemainingLimit = &remaining
+		}
+		queue = append(queue, item)
+	}
+
+	sort.SliceStable(queue, func(i, j int) bool {
+		ei, ej := queue[i].Order.EscalatedAt != nil, queue[j].Order.EscalatedAt != nil
+		if ei != ej {
+			return ei
+		}
+		return queue[i].PendingSince.Before(queue[j].PendingSince)
+	})
+
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    queue,
+	})
+}
+
+// BulkApproveOrders godoc
+//
+//	@Summary		Grupno odobravanje naloga
+//	@Description	Odobrava svaki zadati nalog na čekanju uz iste provere kao pojedinačno odobravanje. Nalog koji ne prođe proveru ne sprečava ostale; ishod se vraća po nalogu.
+//	@Tags			Orders
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body	types.BulkApproveRequest	true	"ID-jevi naloga"
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=[]types.BulkApprovalResult}	"Ishod po nalogu"
+//	@Failure		400	{object}	types.Response									"Nevalidan zahtev"
+//	@Router			/order-approvals/approve [post]
+func (ac *OrderApprovalController) BulkApproveOrders(c *fiber.Ctx) error {
+	var request types.BulkApproveRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(400).JSON(types.Response{Success: false, Error: "Neuspelo parsiranje: " + err.Error()})
+	}
+	if err := validate.Struct(request); err != nil {
+		return c.Status(400).JSON(types.Response{Success: false, Error: "Neuspela validacija: " + err.Error()})
+	}
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    bulkApproveDecline(c, request.OrderIDs, false, ""),
+	})
+}
+
+// BulkDeclineOrders godoc
+//
+//	@Summary		Grupno odbijanje naloga
+//	@Description	Odbija svaki zadati nalog na čekanju. Razlog je obavezan i upisuje se na svaki odbijeni nalog. Ishod se vraća po nalogu.
+//	@Tags			Orders
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body	types.BulkDeclineRequest	true	"ID-jevi naloga i razlog odbijanja"
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=[]types.BulkApprovalResult}	"Ishod po nalogu"
+//	@Failure		400	{object}	types.Response									"Nevalidan zahtev ili nedostaje razlog"
+//	@Router			/order-approvals/decline [post]
+func (ac *OrderApprovalController) BulkDeclineOrders(c *fiber.Ctx) error {
+	var request types.BulkDeclineRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(400).JSON(types.Response{Success: false, Error: "Neuspelo parsiranje: " + err.Error()})
+	}
+	request.Reason = strings.TrimSpace(request.Reason)
+	if err := validate.Struct(request); err != nil {
+		return c.Status(400).JSON(types.Response{Success: false, Error: "Neuspela validacija: " + err.Error()})
+	}
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    bulkApproveDecline(c, request.OrderIDs, true, request.Reason),
+	})
+}
+
+func bulkApproveDecline(c *fiber.Ctx, ids []uint, decline bool, reason string) []types.BulkApprovalResult {
+	results := make([]types.BulkApprovalResult, len(ids))
+	for i, id := range ids {
+		results[i].OrderID = id
+		if _, ferr := approveDecline(c, id, decline, reason); ferr != nil {
+			results[i].Error = ferr.Message
+			continue
+		}
+		results[i].Success = true
+	}
+	return results
+}
+
+func InitOrderApprovalRoutes(app *fiber.App) {
+	approvalController := NewOrderApprovalController()
+
+	approvalGroup := app.Group("/order-approvals", middlewares.Auth, middlewares.DepartmentCheck("SUPERVISOR"))
+	approvalGroup.Get("", approvalController.GetApprovalQueue)
+	approvalGroup.Post("/approve", approvalController.BulkApproveOrders)
+	approvalGroup.Post("/decline", approvalController.BulkDeclineOrders)
+}
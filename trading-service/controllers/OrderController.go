@@ -1,16 +1,20 @@
 package controllers
 
 import (
+	"banka1.com/clock"
 	"banka1.com/controllers/orders"
 	"banka1.com/db"
 	"banka1.com/dto"
+	"banka1.com/exchanges"
 	"banka1.com/middlewares"
 	"banka1.com/services"
 	"banka1.com/types"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 	"net/http"
 	"os"
 	"strings"
@@ -45,6 +49,19 @@ func OrderToOrderResponse(order types.Order) types.OrderResponse {
 		AfterHours:        order.AfterHours,
 		AON:               order.AON,
 		Margin:            order.Margin,
+		TimeInForce:       order.TimeInForce,
+		ExpiresAt:         order.ExpiresAt,
+		ExpiryReason:      order.ExpiryReason,
+		ActivatedAt:       order.ActivatedAt,
+		TrailAmount:       order.TrailAmount,
+		TrailPercent:      order.TrailPercent,
+		TrailingStopPrice: order.TrailingStopPrice,
+		DisplayQuantity:   order.DisplayQuantity,
+		GroupID:           order.GroupID,
+		GroupRole:         order.GroupRole,
+		ApprovalRuleID:    order.ApprovalRuleID,
+		DeclineReason:     order.DeclineReason,
+		EscalatedAt:       order.EscalatedAt,
 	}
 }
 
@@ -199,7 +216,6 @@ func (oc *OrderController) GetOrdersPaged(c *fiber.Ctx) error {
 //	@Router			/orders [post]
 func (oc *OrderController) CreateOrder(c *fiber.Ctx) error {
 	var orderRequest types.CreateOrderRequest
-	userId := c.Locals("user_id").(float64)
 
 	if err := c.BodyParser(&orderRequest); err != nil {
 		return c.Status(400).JSON(types.Response{
@@ -213,98 +229,145 @@ func (oc *OrderController) CreateOrder(c *fiber.Ctx) error {
 			Error:   "Neuspela validacija: " + err.Error(),
 		})
 	}
-	if userId != float64(orderRequest.UserID) {
-		return c.Status(403).JSON(types.Response{
+	order, ferr := buildOrder(c, orderRequest)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(types.Response{
+			Success: false,
+			Error:   ferr.Message,
+		})
+	}
+	cost, ferr := checkFunds(&order)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(types.Response{
+			Success: false,
+			Error:   ferr.Message,
+		})
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+		return orders.PlaceOrderHolds(tx, order, cost)
+	})
+	if err != nil {
+		return c.Status(400).JSON(types.Response{
 			Success: false,
-			Error:   "Cannot create order for another user",
+			Error:   "Neuspelo kreiranje: " + err.Error(),
 		})
 	}
 
+	orders.RecordStatus(order, orders.EventCreated, currentUser(c), "")
+	orders.StartOrder(order)
+
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    order.ID,
+	})
+}
+
+// checkFunds proverava kupovnu moć za buy order preko banking servisa i vraća iznos
+// koji treba rezervisati. Ako stanje računa nije dostupno, order se ne odbija već
+// čeka odobrenje supervizora.
+func checkFunds(order *types.Order) (float64, *fiber.Error) {
+	if !orders.NeedsFunds(*order) || !orders.FundsCheckEnabled() {
+		return 0, nil
+	}
+
+	power, err := orders.CheckBuyingPower(*order)
+	switch {
+	case errors.Is(err, orders.ErrInsufficientFunds):
+		return 0, fiber.NewError(400, fmt.Sprintf("Nemate dovoljno raspoloživih sredstava. Potrebno: %.2f, slobodno dostupno: %.2f", power.Cost, power.Available))
+	case err != nil:
+		fmt.Printf("Provera sredstava za korisnika %d nije uspela, order ceka odobrenje: %v\n", order.UserID, err)
+		order.Status = "pending"
+		order.ApprovedBy = nil
+		order.PriorityTime = 0
+	}
+	return power.Cost, nil
+}
+
+// currentUser vraća ID prijavljenog korisnika iz tokena, ili nil ako ga nema.
+func currentUser(c *fiber.Ctx) *uint {
+	uid, ok := c.Locals("user_id").(float64)
+	if !ok {
+		return nil
+	}
+	id := uint(uid)
+	return &id
+}
+
+// buildOrder proverava zahtev i pravi order (status odobrenja, tip, rok važenja) bez upisa u bazu.
+func buildOrder(c *fiber.Ctx, orderRequest types.CreateOrderRequest) (types.Order, *fiber.Error) {
+	userId := c.Locals("user_id").(float64)
+
+	if userId != float64(orderRequest.UserID) {
+		return types.Order{}, fiber.NewError(403, "Cannot create order for another user")
+	}
+
 	// Učitaj hartiju odmah nakon validacije korisnika
 	var security types.Security
 	if err := db.DB.First(&security, orderRequest.SecurityID).Error; err != nil {
-		return c.Status(404).JSON(types.Response{
-			Success: false,
-			Error:   "Hartija nije pronađena",
-		})
+		return types.Order{}, fiber.NewError(404, "Hartija nije pronađena")
 	}
 
 	// Proveri da li je hartija istekla
 	if security.SettlementDate != nil {
 		parsed, err := time.Parse("2006-01-02", *security.SettlementDate)
 		if err != nil {
-			return c.Status(400).JSON(types.Response{
-				Success: false,
-				Error:   "Nevažeći settlement date format",
-			})
+			return types.Order{}, fiber.NewError(400, "Nevažeći settlement date format")
 		}
 
 		// Poredi samo po danima, ne po satu
-		now := time.Now().Truncate(24 * time.Hour)
+		now := clock.Now().Truncate(24 * time.Hour)
 		parsed = parsed.Truncate(24 * time.Hour)
 
 		if parsed.Before(now) {
-			return c.Status(400).JSON(types.Response{
-				Success: false,
-				Error:   "Nije moguće kreirati order za hartiju kojoj je istekao settlement date",
-			})
+			return types.Order{}, fiber.NewError(400, "Nije moguće kreirati order za hartiju kojoj je istekao settlement date")
 		}
 	}
 
-	status := "pending"
-	var approvedBy *uint = nil
-
-	if deptRaw := c.Locals("department"); deptRaw != nil {
-		if department, ok := deptRaw.(string); ok {
-			id := uint(userId)
-			approvedBy = &id
+	// Količina se zadaje u ugovorima, pa veličina ugovora mora odgovarati listingu
+	if contractSize := orders.SecurityContractSize(security); orderRequest.ContractSize != contractSize {
+		return types.Order{}, fiber.NewError(400, fmt.Sprintf("contract_size mora biti jednak veličini ugovora hartije (%d)", contractSize))
+	}
 
-			switch department {
-			case "SUPERVISOR":
-				status = "approved"
-			case "AGENT":
-				var actuary types.Actuary
-				if err := db.DB.Where("user_id = ?", orderRequest.UserID).First(&actuary).Error; err == nil {
-					estimatedUsage := float64(orderRequest.Quantity) * security.LastPrice
-					if actuary.UsedLimit+estimatedUsage <= actuary.LimitAmount {
-						status = "approved"
-					} else {
-						status = "pending"
-					}
-				} else {
-					status = "pending"
-				}
-			}
-		}
+	afterHours := exchanges.IsAfterHours(exchanges.StatusForSecurity(orderRequest.SecurityID, clock.Now()))
+	status, approvedBy, approvalRuleID, ferr := approvalFor(c, types.Order{
+		UserID:            orderRequest.UserID,
+		SecurityID:        orderRequest.SecurityID,
+		Quantity:          orderRequest.Quantity,
+		LimitPricePerUnit: orderRequest.LimitPricePerUnit,
+		StopPricePerUnit:  orderRequest.StopPricePerUnit,
+		Margin:            orderRequest.Margin,
+		AfterHours:        afterHours,
+	}, security)
+	if ferr != nil {
+		return types.Order{}, ferr
 	}
 
-	// Provera dostupnosti unita ako se order odobrava odmah
+	// Provera dostupnosti unita ako se order odobrava odmah; margin order manjak pozajmljuje od banke
 	if status == "approved" && strings.ToLower(orderRequest.Direction) == "sell" {
-		ok, available, err := orders.CanSell(orderRequest.UserID, orderRequest.SecurityID, orderRequest.Quantity)
+		ok, available, err := orders.CanSellOrBorrow(orderRequest.UserID, orderRequest.SecurityID, orderRequest.Quantity, orderRequest.Margin)
 		if err != nil {
-			return c.Status(500).JSON(types.Response{
-				Success: false,
-				Error:   "Greška pri proveri dostupnosti hartija",
-			})
+			return types.Order{}, fiber.NewError(500, "Greška pri proveri dostupnosti hartija")
 		}
 		if !ok {
-			return c.Status(400).JSON(types.Response{
-				Success: false,
-				Error:   fmt.Sprintf("Nemate dovoljno raspoloživih hartija za prodaju. Slobodno dostupno: %d", available),
-			})
+			return types.Order{}, fiber.NewError(400, fmt.Sprintf("Nemate dovoljno raspoloživih hartija za prodaju. Slobodno dostupno: %d", available))
 		}
 	}
 
 	if orderRequest.Margin {
+		if orders.HasOpenMarginCall(orderRequest.UserID) {
+			return types.Order{}, fiber.NewError(403, "Margin order nije dozvoljen dok margin poziv nije ispunjen")
+		}
+
 		var security types.Security
 		if err := db.DB.First(&security, orderRequest.SecurityID).Error; err != nil {
-			return c.Status(404).JSON(types.Response{
-				Success: false,
-				Error:   "Hartija nije pronađena",
-			})
+			return types.Order{}, fiber.NewError(404, "Hartija nije pronađena")
 		}
 
-		maintenanceMargin := security.LastPrice * 0.3
+		maintenanceMargin := security.LastPrice * float64(orders.SecurityContractSize(security)) * orders.MaintenanceMarginRate
 		initialMarginCost := maintenanceMargin * 1.1
 
 		department, hasDepartment := c.Locals("department").(string)
@@ -312,17 +375,11 @@ func (oc *OrderController) CreateOrder(c *fiber.Ctx) error {
 		if hasDepartment && (department == "AGENT" || department == "SUPERVISOR") {
 			var actuary types.Actuary
 			if err := db.DB.Where("user_id = ?", orderRequest.UserID).First(&actuary).Error; err != nil {
-				return c.Status(403).JSON(types.Response{
-					Success: false,
-					Error:   "Korisnik nema margin nalog (nije agent ili nije registrovan kao aktuar)",
-				})
+				return types.Order{}, fiber.NewError(403, "Korisnik nema margin nalog (nije agent ili nije registrovan kao aktuar)")
 			}
 
 			if actuary.LimitAmount < initialMarginCost {
-				return c.Status(403).JSON(types.Response{
-					Success: false,
-					Error:   "Nedovoljan limit za margin order",
-				})
+				return types.Order{}, fiber.NewError(403, "Nedovoljan limit za margin order")
 			}
 		} else {
 			client := &http.Client{}
@@ -334,32 +391,44 @@ func (oc *OrderController) CreateOrder(c *fiber.Ctx) error {
 			}
 			resp, err := client.Do(req)
 			if err != nil || resp.StatusCode != 200 {
-				return c.Status(500).JSON(types.Response{
-					Success: false,
-					Error:   "Greška pri proveri kredita iz banking servisa",
-				})
+				return types.Order{}, fiber.NewError(500, "Greška pri proveri kredita iz banking servisa")
 			}
 
 			var body map[string]any
 			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
-				return c.Status(500).JSON(types.Response{
-					Success: false,
-					Error:   "Neuspešno parsiranje odgovora iz banking servisa",
-				})
+				return types.Order{}, fiber.NewError(500, "Neuspešno parsiranje odgovora iz banking servisa")
 			}
 
 			approved, ok := body["approvedLoan"].(bool)
 			if !ok || !approved {
-				return c.Status(403).JSON(types.Response{
-					Success: false,
-					Error:   "Korisnik nema prava za margin order (nema kredit ni permisiju)",
-				})
+				return types.Order{}, fiber.NewError(403, "Korisnik nema prava za margin order (nema kredit ni permisiju)")
+			}
+			// Matching engine izvršava margin ordere klijenta samo uz margin nalog
+			if err := orders.OpenMarginAccount(orderRequest.UserID, orderRequest.AccountID); err != nil {
+				return types.Order{}, fiber.NewError(500, "Greška pri otvaranju margin naloga")
 			}
 		}
 	}
 
+	trailing := orderRequest.TrailAmount != nil || orderRequest.TrailPercent != nil
+	if trailing && (orderRequest.TrailAmount != nil) == (orderRequest.TrailPercent != nil) {
+		return types.Order{}, fiber.NewError(400, "TRAILING-STOP order mora imati tačno jedno od trail_amount i trail_percent")
+	}
+	if trailing && (orderRequest.StopPricePerUnit != nil || orderRequest.LimitPricePerUnit != nil) {
+		return types.Order{}, fiber.NewError(400, "TRAILING-STOP order ne može imati stop ni limit cenu")
+	}
+	if trailing && !orders.HasTrailingReference(security, orderRequest.Direction) {
+		return types.Order{}, fiber.NewError(400, "TRAILING-STOP order nije moguć jer za hartiju ne postoji cena od koje počinje praćenje")
+	}
+
+	if err := orders.CheckPriceBand(orderRequest.SecurityID, orderRequest.LimitPricePerUnit, orderRequest.StopPricePerUnit); err != nil {
+		return types.Order{}, fiber.NewError(400, "Order odbijen: "+err.Error())
+	}
+
 	var orderType string
 	switch {
+	case trailing:
+		orderType = "TRAILING-STOP"
 	case orderRequest.StopPricePerUnit == nil && orderRequest.LimitPricePerUnit == nil:
 		orderType = "MARKET"
 	case orderRequest.StopPricePerUnit == nil && orderRequest.LimitPricePerUnit != nil:
@@ -382,33 +451,266 @@ func (oc *OrderController) CreateOrder(c *fiber.Ctx) error {
 		Direction:         orderRequest.Direction,
 		Status:            status, // TODO: pribaviti needs approval vrednost preko token-a?
 		ApprovedBy:        approvedBy,
-		LastModified:      time.Now().Unix(),
+		LastModified:      clock.Now().Unix(),
 		IsDone:            false,
 		RemainingParts:    &orderRequest.Quantity,
-		AfterHours:        false, // TODO: dodati check za ovo
+		AfterHours:        afterHours,
 		AON:               orderRequest.AON,
 		Margin:            orderRequest.Margin,
+		TimeInForce:       orders.NormalizeTimeInForce(orderRequest.TimeInForce),
+		TrailAmount:       orderRequest.TrailAmount,
+		TrailPercent:      orderRequest.TrailPercent,
+		ApprovalRuleID:    approvalRuleID,
 	}
 
-	tx := db.DB.Create(&order)
-	if err := tx.Error; err != nil {
+	if orderRequest.DisplayQuantity != nil {
+		if *orderRequest.DisplayQuantity >= orderRequest.Quantity {
+			return types.Order{}, fiber.NewError(400, "display_quantity mora biti manji od ukupne količine")
+		}
+		orders.MakeIceberg(&order, *orderRequest.DisplayQuantity)
+	}
+
+	expiresAt, err := orders.ExpiryFor(order, orderRequest.ExpiresAt, clock.Now())
+	if err != nil {
+		return types.Order{}, fiber.NewError(400, "Nevalidan time-in-force: "+err.Error())
+	}
+	order.ExpiresAt = expiresAt
+
+	if status == "approved" {
+		order.PriorityTime = clock.Now().UnixNano()
+	}
+
+	return order, nil
+}
+
+// approvalFor određuje status novog ili izmenjenog naloga: supervizor ga odobrava
+// sam, o nalogu agenta odlučuje politika odobravanja, a ostali nalozi čekaju
+// odobrenje supervizora. Vraća i pravilo politike koje je odredilo status.
+func approvalFor(c *fiber.Ctx, order types.Order, security types.Security) (string, *uint, *uint, *fiber.Error) {
+	status := "pending"
+	var approvedBy *uint = nil
+	var ruleID *uint = nil
+
+	if deptRaw := c.Locals("department"); deptRaw != nil {
+		if department, ok := deptRaw.(string); ok {
+			id := uint(c.Locals("user_id").(float64))
+			approvedBy = &id
+
+			switch department {
+			case "SUPERVISOR":
+				status = "approved"
+			case "AGENT":
+				var actuary types.Actuary
+				if err := db.DB.Where("user_id = ?", order.UserID).First(&actuary).Error; err == nil {
+					action, rule := orders.EvaluateApproval(orders.ApprovalInput{
+						Actuary:    actuary,
+						Security:   security,
+						Notional:   orders.ApprovalNotional(order, security),
+						Margin:     order.Margin,
+						AfterHours: order.AfterHours,
+					}, clock.Now())
+					if rule != nil {
+						ruleID = &rule.ID
+					}
+					switch action {
+					case orders.ApprovalApprove:
+						status = "approved"
+					case orders.ApprovalReject:
+						return "", nil, ruleID, fiber.NewError(403, fmt.Sprintf("Order odbijen pravilom odobravanja %q", rule.Name))
+					}
+				}
+			}
+		}
+	}
+	return status, approvedBy, ruleID, nil
+}
+
+// AmendOrder godoc
+//
+//	@Summary		Izmena naloga
+//	@Description	Menja količinu, limit ili stop cenu naloga koji nije završen. Smanjenje količine zadržava vremenski prioritet, a promena cene ili povećanje količine ga gube.
+//	@Description	Izmena koja povećava rizik (cena ili veća količina) ponovo prolazi proveru limita agenta, pa nalog može ponovo čekati odobrenje supervizora.
+//	@Tags			Orders
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path	int						true	"ID naloga"
+//	@Param			amendRequest	body	types.AmendOrderRequest	true	"Nova količina i/ili cene"
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=types.OrderResponse}	"Izmenjen nalog"
+//	@Failure		400	{object}	types.Response								"Neispravan zahtev ili nalog nije moguće izmeniti"
+//	@Failure		403	{object}	types.Response								"Nije dozvoljeno menjati tuđi nalog"
+//	@Failure		404	{object}	types.Response								"Nalog sa datim ID-jem ne postoji"
+//	@Failure		409	{object}	types.Response								"Nalog se izvršavao tokom izmene"
+//	@Router			/orders/{id} [put]
+func (oc *OrderController) AmendOrder(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id", -1)
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(types.Response{Success: false, Error: "Nevalidan ID"})
+	}
+
+	var request types.AmendOrderRequest
+	if err := c.BodyParser(&request); err != nil {
 		return c.Status(400).JSON(types.Response{
 			Success: false,
-			Error:   "Neuspelo kreiranje: " + err.Error(),
+			Error:   "Neuspelo parsiranje: " + err.Error(),
+		})
+	}
+	if err := validate.Struct(request); err != nil {
+		return c.Status(400).JSON(types.Response{
+			Success: false,
+			Error:   "Neuspela validacija: " + err.Error(),
 		})
 	}
+	if request.Quantity == nil && request.LimitPricePerUnit == nil && request.StopPricePerUnit == nil {
+		return c.Status(400).JSON(types.Response{Success: false, Error: "Izmena mora sadržati quantity, limit_price_per_unit ili stop_price_per_unit"})
+	}
 
-	if order.Status == "approved" {
-		go orders.MatchOrder(order)
+	var order types.Order
+	if err := db.DB.First(&order, id).Error; err != nil {
+		return c.Status(404).JSON(types.Response{Success: false, Error: "Order nije pronađen"})
 	}
 
-	if strings.ToLower(order.Direction) == "sell" && order.Status == "approved" {
-		_ = orders.UpdateAvailableVolume(order.SecurityID)
+	userID := uint(c.Locals("user_id").(float64))
+	if order.UserID != userID {
+		return c.Status(403).JSON(types.Response{Success: false, Error: "Nije dozvoljeno menjati tuđi order"})
+	}
+	if order.IsDone || (order.Status != "pending" && order.Status != "approved") {
+		return c.Status(400).JSON(types.Response{Success: false, Error: "Order je već izvršen, otkazan ili istekao"})
+	}
+	if order.GroupID != nil {
+		return c.Status(400).JSON(types.Response{Success: false, Error: "Nalog iz OCO/bracket grupe se ne može menjati, otkažite grupu"})
+	}
+
+	amended, losesPriority, err := orders.ApplyAmendment(order, orders.Amendment{
+		Quantity:          request.Quantity,
+		LimitPricePerUnit: request.LimitPricePerUnit,
+		StopPricePerUnit:  request.StopPricePerUnit,
+	})
+	if err != nil {
+		return c.Status(400).JSON(types.Response{Success: false, Error: "Izmena nije moguća: " + err.Error()})
+	}
+	if err := orders.CheckPriceBand(order.SecurityID, request.LimitPricePerUnit, request.StopPricePerUnit); err != nil {
+		return c.Status(400).JSON(types.Response{Success: false, Error: "Izmena nije moguća: " + err.Error()})
+	}
+
+	// Veći rizik ponovo prolazi odobravanje kao novi nalog
+	if losesPriority {
+		var security types.Security
+		if err := db.DB.First(&security, order.SecurityID).Error; err != nil {
+			return c.Status(404).JSON(types.Response{Success: false, Error: "Hartija nije pronađena"})
+		}
+		var ferr *fiber.Error
+		amended.Status, amended.ApprovedBy, amended.ApprovalRuleID, ferr = approvalFor(c, amended, security)
+		if ferr != nil {
+			return c.Status(ferr.Code).JSON(types.Response{Success: false, Error: ferr.Message})
+		}
+
+		if amended.Status == "approved" && strings.ToLower(order.Direction) == "sell" {
+			extra := *amended.RemainingParts - *order.RemainingParts
+			if order.Status != "approved" {
+				extra = *amended.RemainingParts
+			}
+			ok, available, err := orders.CanSellOrBorrow(order.UserID, order.SecurityID, extra, order.Margin)
+			if err != nil {
+				return c.Status(500).JSON(types.Response{Success: false, Error: "Greška pri proveri dostupnosti hartija"})
+			}
+			if !ok {
+				return c.Status(400).JSON(types.Response{
+					Success: false,
+					Error:   fmt.Sprintf("Nemate dovoljno raspoloživih hartija za prodaju. Slobodno dostupno: %d", available),
+				})
+			}
+		}
+
+		amended.PriorityTime = 0
+		if amended.Status == "approved" {
+			amended.PriorityTime = clock.Now().UnixNano()
+		}
 	}
 
+	// Buy order sa novom cenom ili količinom ponovo prolazi proveru sredstava
+	var cost float64
+	if losesPriority {
+		var ferr *fiber.Error
+		if cost, ferr = checkFunds(&amended); ferr != nil {
+			return c.Status(ferr.Code).JSON(types.Response{Success: false, Error: ferr.Message})
+		}
+	} else if orders.NeedsFunds(amended) && orders.FundsCheckEnabled() {
+		cost, _ = orders.EstimateCost(nil, amended)
+	}
+
+	if err := orders.SaveAmendment(order, amended, cost); err != nil {
+		if errors.Is(err, orders.ErrAmendConflict) {
+			return c.Status(409).JSON(types.Response{Success: false, Error: err.Error()})
+		}
+		return c.Status(500).JSON(types.Response{Success: false, Error: "Greška pri izmeni ordera: " + err.Error()})
+	}
+	orders.RecordStatus(amended, orders.EventAmended, &userID, amendmentNote(order, amended))
+
 	return c.JSON(types.Response{
 		Success: true,
-		Data:    order.ID,
+		Data:    OrderToOrderResponse(amended),
+	})
+}
+
+// amendmentNote opisuje izmenu ordera za žurnal događaja.
+func amendmentNote(before, after types.Order) string {
+	changes := []string{}
+	if before.Quantity != after.Quantity {
+		changes = append(changes, fmt.Sprintf("quantity %d → %d", before.Quantity, after.Quantity))
+	}
+	if after.LimitPricePerUnit != nil && (before.LimitPricePerUnit == nil || *before.LimitPricePerUnit != *after.LimitPricePerUnit) {
+		changes = append(changes, fmt.Sprintf("limit → %.2f", *after.LimitPricePerUnit))
+	}
+	if after.StopPricePerUnit != nil && (before.StopPricePerUnit == nil || *before.StopPricePerUnit != *after.StopPricePerUnit) {
+		changes = append(changes, fmt.Sprintf("stop → %.2f", *after.StopPricePerUnit))
+	}
+	if before.PriorityTime != after.PriorityTime {
+		changes = append(changes, "izgubljen vremenski prioritet")
+	}
+	return strings.Join(changes, ", ")
+}
+
+// GetOrderEvents godoc
+//
+//	@Summary		Vremenska linija naloga
+//	@Description	Vraća sve događaje naloga (kreiranje, odobrenje, izvršenja, izmene, otkazivanje, istek) hronološki, sa izvršenom količinom, prosečnom cenom izvršenja i ukupnom provizijom.
+//	@Tags			Orders
+//	@Produce		json
+//	@Param			id	path		int												true	"ID naloga"
+//	@Success		200	{object}	types.Response{data=types.OrderEventsResponse}	"Događaji naloga"
+//	@Failure		400	{object}	types.Response									"Nevalidan ID"
+//	@Failure		404	{object}	types.Response									"Nalog sa datim ID-jem ne postoji"
+//	@Failure		500	{object}	types.Response									"Greška pri čitanju događaja"
+//	@Router			/orders/{id}/events [get]
+func (oc *OrderController) GetOrderEvents(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id", -1)
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(types.Response{Success: false, Error: "Nevalidan ID"})
+	}
+
+	var order types.Order
+	if err := db.DB.First(&order, id).Error; err != nil {
+		return c.Status(404).JSON(types.Response{Success: false, Error: "Order nije pronađen"})
+	}
+
+	var events []types.OrderEvent
+	if err := db.DB.Where("order_id = ?", order.ID).Order("created_at, id").Find(&events).Error; err != nil {
+		return c.Status(500).JSON(types.Response{Success: false, Error: "Greška pri čitanju događaja: " + err.Error()})
+	}
+
+	filled, average, fees := orders.FillSummary(events)
+	return c.JSON(types.Response{
+		Success: true,
+		Data: types.OrderEventsResponse{
+			OrderID:          order.ID,
+			Status:           order.Status,
+			Quantity:         order.Quantity,
+			FilledQuantity:   filled,
+			AverageFillPrice: average,
+			TotalFees:        fees,
+			Events:           events,
+		},
 	})
 }
 
@@ -424,109 +726,171 @@ func ApproveDeclineOrder(c *fiber.Ctx, decline bool) error {
 		}
 		return c.Status(400).JSON(response)
 	}
-	var order types.Order
-	if err := db.DB.First(&order, id).Error; err != nil {
-		return c.Status(404).JSON(types.Response{
+
+	reason := ""
+	if decline {
+		var request types.DeclineOrderRequest
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&request); err != nil {
+				return c.Status(400).JSON(types.Response{
+					Success: false,
+					Error:   "Neuspelo parsiranje: " + err.Error(),
+				})
+			}
+		}
+		request.Reason = strings.TrimSpace(request.Reason)
+		if err := validate.Struct(request); err != nil {
+			return c.Status(400).JSON(types.Response{
+				Success: false,
+				Error:   "Neuspela validacija: " + err.Error(),
+			})
+		}
+		reason = request.Reason
+	}
+
+	data, ferr := approveDecline(c, uint(id), decline, reason)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(types.Response{
 			Success: false,
-			Error:   "Nije pronadjen: " + err.Error(),
+			Error:   ferr.Message,
 		})
 	}
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// approveDecline odobrava ili odbija jedan nalog na čekanju (ili celu njegovu grupu)
+// i vraća podatke za odgovor. Koriste ga pojedinačni i grupni endpoint-i supervizora.
+func approveDecline(c *fiber.Ctx, id uint, decline bool, reason string) (any, *fiber.Error) {
+	var order types.Order
+	if err := db.DB.First(&order, id).Error; err != nil {
+		return nil, fiber.NewError(404, "Nije pronadjen: "+err.Error())
+	}
 	if order.Status != "pending" {
-		return c.Status(400).JSON(types.Response{
-			Success: false,
-			Error:   "Nije na cekanju",
-		})
+		return nil, fiber.NewError(400, "Nije na cekanju")
+	}
+	if order.GroupID != nil {
+		return approveDeclineGroup(c, order, decline, reason)
 	}
 	if decline {
-		order.Status = "declined"
-	} else {
-		// Proveri da li je hartiji istekao settlementDate
-		var security types.Security
-		if err := db.DB.First(&security, order.SecurityID).Error; err != nil {
-			return c.Status(404).JSON(types.Response{
-				Success: false,
-				Error:   "Hartija nije pronađena",
-			})
+		declinedBy := currentUser(c)
+		if declinedBy == nil {
+			declinedBy = new(uint)
 		}
-		if security.SettlementDate != nil {
-			parsed, err := time.Parse("2006-01-02", *security.SettlementDate)
-			if err != nil {
-				return c.Status(400).JSON(types.Response{
-					Success: false,
-					Error:   "Nevažeći settlement date format",
-				})
-			}
-
-			now := time.Now().Truncate(24 * time.Hour)
-			parsed = parsed.Truncate(24 * time.Hour)
+		if err := orders.DeclinePending(order, declinedBy, reason); err != nil {
+			return nil, fiber.NewError(500, "Greška pri odbijanju naloga: "+err.Error())
+		}
+		return order.ID, nil
+	}
 
-			if parsed.Before(now) {
-				return c.Status(400).JSON(types.Response{
-					Success: false,
-					Error:   "Nije moguće odobriti order za hartiju kojoj je istekao settlement date",
-				})
-			}
+	// Proveri da li je hartiji istekao settlementDate
+	var security types.Security
+	if err := db.DB.First(&security, order.SecurityID).Error; err != nil {
+		return nil, fiber.NewError(404, "Hartija nije pronađena")
+	}
+	if security.SettlementDate != nil {
+		parsed, err := time.Parse("2006-01-02", *security.SettlementDate)
+		if err != nil {
+			return nil, fiber.NewError(400, "Nevažeći settlement date format")
 		}
 
-		if strings.ToLower(order.Direction) == "sell" {
-			ok, available, err := orders.CanSell(order.UserID, order.SecurityID, order.Quantity)
-			if err != nil {
-				return c.Status(500).JSON(types.Response{
-					Success: false,
-					Error:   "Greška pri proveri dostupnosti hartija",
-				})
-			}
-			if !ok {
-				return c.Status(400).JSON(types.Response{
-					Success: false,
-					Error:   fmt.Sprintf("Nemate dovoljno raspoloživih hartija za odobravanje prodaje. Slobodno dostupno: %d", available),
-				})
-			}
+		now := clock.Now().Truncate(24 * time.Hour)
+		parsed = parsed.Truncate(24 * time.Hour)
+
+		if parsed.Before(now) {
+			return nil, fiber.NewError(400, "Nije moguće odobriti order za hartiju kojoj je istekao settlement date")
 		}
+	}
 
-		order.Status = "approved"
+	if strings.ToLower(order.Direction) == "sell" {
+		ok, available, err := orders.CanSellOrBorrow(order.UserID, order.SecurityID, order.Quantity, order.Margin)
+		if err != nil {
+			return nil, fiber.NewError(500, "Greška pri proveri dostupnosti hartija")
+		}
+		if !ok {
+			return nil, fiber.NewError(400, fmt.Sprintf("Nemate dovoljno raspoloživih hartija za odobravanje prodaje. Slobodno dostupno: %d", available))
+		}
+	}
 
-		if uidRaw := c.Locals("user_id"); uidRaw != nil {
-			if uid, ok := uidRaw.(float64); ok {
-				id := uint(uid)
-				order.ApprovedBy = &id
-			}
+	order.Status = "approved"
+	order.ApprovedBy = currentUser(c)
+	order.LastModified = clock.Now().Unix()
+	order.PriorityTime = clock.Now().UnixNano()
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&order).Error; err != nil {
+			return err
 		}
-		order.LastModified = time.Now().Unix()
-		db.DB.Save(&order)
+		return orders.PlaceOrderHolds(tx, order, 0)
+	}); err != nil {
+		return nil, fiber.NewError(500, "Greška pri odobravanju naloga: "+err.Error())
+	}
+	orders.RecordStatus(order, orders.EventApproved, currentUser(c), "")
+	orders.AddToBook(order)
 
-		if strings.ToLower(order.Direction) == "sell" {
-			_ = orders.UpdateAvailableVolume(order.SecurityID)
+	if strings.ToLower(order.Direction) == "sell" {
+		_ = orders.UpdateAvailableVolume(order.SecurityID)
+	}
+
+	orders.MatchOrder(order)
+
+	return fmt.Sprintf("Order %d odobren i pokrenuto izvršavanje", order.ID), nil
+}
+
+// approveDeclineGroup odobrava ili odbija celu OCO/bracket grupu kojoj order pripada:
+// nalozi grupe se ne mogu odobravati pojedinačno.
+func approveDeclineGroup(c *fiber.Ctx, order types.Order, decline bool, reason string) (any, *fiber.Error) {
+	supervisor := currentUser(c)
+
+	if decline {
+		if err := orders.DeclineGroup(*order.GroupID, supervisor, reason); err != nil {
+			return nil, fiber.NewError(500, "Greška pri odbijanju grupe naloga: "+err.Error())
 		}
+		return order.ID, nil
+	}
 
-		orders.MatchOrder(order)
+	var security types.Security
+	if err := db.DB.First(&security, order.SecurityID).Error; err != nil {
+		return nil, fiber.NewError(404, "Hartija nije pronađena")
+	}
+	if !orders.IsSettlementDateValid(&order) {
+		return nil, fiber.NewError(400, "Nije moguće odobriti order za hartiju kojoj je istekao settlement date")
+	}
 
-		return c.JSON(types.Response{
-			Success: true,
-			Data:    fmt.Sprintf("Order %d odobren i pokrenuto izvršavanje", order.ID),
-		})
+	// Take-profit i stop-loss prodaju hartije koje će tek kupiti entry, pa se proveravaju samo ostali nalozi
+	var sells []types.Order
+	db.DB.Where("group_id = ? AND status = ? AND LOWER(direction) = ? AND group_role IN ?",
+		*order.GroupID, "pending", "sell", []string{orders.GroupRoleEntry, orders.GroupRoleLeg}).Find(&sells)
+	for _, sell := range sells {
+		ok, available, err := orders.CanSell(sell.UserID, sell.SecurityID, sell.Quantity)
+		if err != nil {
+			return nil, fiber.NewError(500, "Greška pri proveri dostupnosti hartija")
+		}
+		if !ok {
+			return nil, fiber.NewError(400, fmt.Sprintf("Nemate dovoljno raspoloživih hartija za odobravanje prodaje. Slobodno dostupno: %d", available))
+		}
 	}
 
-	order.ApprovedBy = new(uint)
-	*order.ApprovedBy = 0 // TODO: dobavi iz token-a
-	db.DB.Save(&order)
+	if err := orders.ApproveGroup(*order.GroupID, supervisor); err != nil {
+		return nil, fiber.NewError(500, "Greška pri odobravanju grupe naloga: "+err.Error())
+	}
 
-	return c.JSON(types.Response{
-		Success: true,
-		Data:    order.ID,
-	})
+	return fmt.Sprintf("Grupa naloga %d odobrena i pokrenuto izvršavanje", *order.GroupID), nil
 }
 
 // DeclineOrder godoc
 //
 //	@Summary		Odbijanje naloga
-//	@Description	Menja status naloga u 'declined'.
+//	@Description	Menja status naloga u 'declined'. Razlog odbijanja je obavezan i upisuje se na nalog i u žurnal.
 //	@Tags			Orders
+//	@Accept			json
 //	@Produce		json
-//	@Param			id	path	int	true	"ID naloga koji se odbija"
+//	@Param			id		path	int							true	"ID naloga koji se odbija"
+//	@Param			request	body	types.DeclineOrderRequest	true	"Razlog odbijanja"
 //	@Security		BearerAuth
 //	@Success		200	{object}	types.Response{data=uint}	"Nalog uspešno odbijen, vraća ID naloga"
-//	@Failure		400	{object}	types.Response				"Nevalidan ID ili nalog nije u 'pending' statusu"
+//	@Failure		400	{object}	types.Response				"Nevalidan ID, nedostaje razlog ili nalog nije u 'pending' statusu"
 //	@Failure		403	{object}	types.Response				"Nedovoljne privilegije"
 //	@Failure		404	{object}	types.Response				"Nalog sa datim ID-jem ne postoji"
 //	@Failure		500	{object}	types.Response				"Interna Greška Servera"
@@ -584,15 +948,31 @@ func (oc *OrderController) CancelOrder(c *fiber.Ctx) error {
 		return c.Status(403).JSON(types.Response{Success: false, Error: "Nije dozvoljeno otkazati tuđi order"})
 	}
 
-	if order.IsDone || order.Status == "done" || order.Status == "cancelled" {
+	if order.IsDone || order.Status == "done" || order.Status == "cancelled" || order.Status == "expired" {
 		return c.Status(400).JSON(types.Response{Success: false, Error: "Order je već izvršen ili otkazan"})
 	}
 
+	if order.GroupID != nil {
+		cancelled, err := orders.CancelGroup(*order.GroupID)
+		if err != nil {
+			return c.Status(500).JSON(types.Response{Success: false, Error: "Greška pri otkazivanju grupe naloga"})
+		}
+		return c.JSON(types.Response{Success: true, Data: fmt.Sprintf("Otkazana grupa naloga %d (%d naloga)", *order.GroupID, cancelled)})
+	}
+
 	order.Status = "cancelled"
-	order.LastModified = time.Now().Unix()
-	if err := db.DB.Save(&order).Error; err != nil {
+	order.LastModified = clock.Now().Unix()
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&order).Error; err != nil {
+			return err
+		}
+		return orders.ReleaseHolds(tx, order.ID)
+	}); err != nil {
 		return c.Status(500).JSON(types.Response{Success: false, Error: "Greška pri otkazivanju ordera"})
 	}
+	orders.RecordStatus(order, orders.EventCancelled, &userID, "")
+	orders.RemoveFromBook(order)
+	orders.RemoveTrigger(order)
 
 	return c.JSON(types.Response{Success: true, Data: fmt.Sprintf("Order %d je uspešno otkazan", order.ID)})
 }
@@ -744,11 +1124,13 @@ func InitOrderRoutes(app *fiber.App) {
 
 	app.Get("/orders/paged", orderController.GetOrdersPaged)
 	app.Get("/orders/:id", orderController.GetOrderByID)
+	app.Get("/orders/:id/events", orderController.GetOrderEvents)
 	app.Get("/orders", orderController.GetOrders)
 	app.Post("/orders", middlewares.Auth, orderController.CreateOrder)
 	app.Post("/orders/:id/decline", middlewares.Auth, middlewares.DepartmentCheck("SUPERVISOR"), orderController.DeclineOrder)
 	app.Post("/orders/:id/approve", middlewares.Auth, middlewares.DepartmentCheck("SUPERVISOR"), orderController.ApproveOrder)
 	app.Post("/orders/:id/cancel", middlewares.Auth, orderController.CancelOrder)
+	app.Put("/orders/:id", middlewares.Auth, orderController.AmendOrder)
 	app.Get("/profit/bank/total", orderController.GetTotalBankProfit)
 	app.Get("/profit/bank", orderController.GetBankProfit)
 	app.Get("/profit/:id", orderController.GetRealizedProfit)
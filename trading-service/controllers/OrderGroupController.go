@@ -0,0 +1,332 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	"banka1.com/controllers/orders"
+	"banka1.com/db"
+	"banka1.com/middlewares"
+	"banka1.com/types"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type OrderGroupController struct {
+}
+
+func NewOrderGroupController() *OrderGroupController {
+	return &OrderGroupController{}
+}
+
+func OrderGroupToResponse(group types.OrderGroup) types.OrderGroupResponse {
+	responses := make([]types.OrderResponse, len(group.Orders))
+	for i, order := range group.Orders {
+		responses[i] = OrderToOrderResponse(order)
+	}
+	return types.OrderGroupResponse{
+		ID:     group.ID,
+		Type:   group.Type,
+		UserID: group.UserID,
+		Status: group.Status,
+		Orders: responses,
+	}
+}
+
+// CreateOrderGroup godoc
+//
+//	@Summary		Kreiranje OCO ili bracket grupe naloga
+//	@Description	OCO: nalozi iz "legs" se međusobno otkazuju čim se bilo koji izvrši.
+//	@Description	BRACKET: "entry" nalog sa take-profit (LIMIT) i stop-loss (STOP) nalozima suprotnog smera koji se aktiviraju kada se entry izvrši.
+//	@Tags			Orders
+//	@Accept			json
+//	@Produce		json
+//	@Param			groupRequest	body	types.CreateOrderGroupRequest	true	"Podaci o grupi naloga"
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=types.OrderGroupResponse}	"Uspešno kreirana grupa naloga"
+//	@Failure		400	{object}	types.Response									"Neispravan format, neuspela validacija, nedovoljno sredstava ili greška pri upisu u bazu"
+//	@Failure		403	{object}	types.Response									"Nije dozvoljeno kreirati nalog za drugog korisnika"
+//	@Router			/orders/groups [post]
+func (gc *OrderGroupController) CreateOrderGroup(c *fiber.Ctx) error {
+	var request types.CreateOrderGroupRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(400).JSON(types.Response{
+			Success: false,
+			Error:   "Neuspelo parsiranje: " + err.Error(),
+		})
+	}
+	if err := validate.Struct(request); err != nil {
+		return c.Status(400).JSON(types.Response{
+			Success: false,
+			Error:   "Neuspela validacija: " + err.Error(),
+		})
+	}
+
+	var legs []types.Order
+	var ferr *fiber.Error
+	groupType := strings.ToUpper(request.Type)
+	switch groupType {
+	case orders.GroupTypeOCO:
+		legs, ferr = buildOCOLegs(c, request)
+	case orders.GroupTypeBracket:
+		legs, ferr = buildBracketLegs(c, request)
+	}
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(types.Response{
+			Success: false,
+			Error:   ferr.Message,
+		})
+	}
+
+	// Svaki nalog koji troši gotovinu prolazi istu proveru i rezervaciju kao
+	// pojedinačan nalog; ako stanje računa nije dostupno, cela grupa čeka odobrenje.
+	// Nalozi OCO grupe dele jednu rezervaciju, pa se proverava samo najskuplji.
+	costs := make([]float64, len(legs))
+	if groupType == orders.GroupTypeOCO {
+		costs, ferr = checkOCOFunds(legs)
+	} else {
+		for i := range legs {
+			if costs[i], ferr = checkFunds(&legs[i]); ferr != nil {
+				break
+			}
+		}
+	}
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(types.Response{
+			Success: false,
+			Error:   ferr.Message,
+		})
+	}
+	holdGroupForApproval(legs)
+	if groupType == orders.GroupTypeBracket {
+		// Take-profit i stop-loss dele jednu rezervaciju, dovoljnu za skuplji od njih
+		costs[1] = max(costs[1], costs[2])
+		costs[2] = costs[1]
+	}
+
+	group := types.OrderGroup{
+		Type:   groupType,
+		UserID: legs[0].UserID,
+		Status: "active",
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&group).Error; err != nil {
+			return err
+		}
+		for i := range legs {
+			legs[i].GroupID = &group.ID
+			if err := tx.Create(&legs[i]).Error; err != nil {
+				return err
+			}
+			if err := orders.PlaceOrderHolds(tx, legs[i], costs[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(400).JSON(types.Response{
+			Success: false,
+			Error:   "Neuspelo kreiranje: " + err.Error(),
+		})
+	}
+
+	for _, leg := range legs {
+		orders.RecordStatus(leg, orders.EventCreated, currentUser(c), fmt.Sprintf("%s grupa %d", groupType, group.ID))
+		orders.StartOrder(leg)
+	}
+
+	group.Orders = legs
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    OrderGroupToResponse(group),
+	})
+}
+
+// buildOCOLegs pravi naloge OCO grupe. Grupa se odobrava kao celina, pa ako
+// bilo koji nalog čeka odobrenje, čekaju svi.
+func buildOCOLegs(c *fiber.Ctx, request types.CreateOrderGroupRequest) ([]types.Order, *fiber.Error) {
+	if len(request.Legs) < 2 {
+		return nil, fiber.NewError(400, "OCO grupa mora imati bar dva naloga")
+	}
+
+	legs := make([]types.Order, 0, len(request.Legs))
+	for _, legRequest := range request.Legs {
+		if legRequest.SecurityID != request.Legs[0].SecurityID || legRequest.UserID != request.Legs[0].UserID {
+			return nil, fiber.NewError(400, "Svi nalozi OCO grupe moraju biti za istu hartiju i istog korisnika")
+		}
+		// Nalozi grupe dele jednu rezervaciju sredstava, pa moraju koristiti isti račun
+		if legRequest.AccountID != request.Legs[0].AccountID {
+			return nil, fiber.NewError(400, "Svi nalozi OCO grupe moraju koristiti isti račun")
+		}
+		leg, ferr := buildOrder(c, legRequest)
+		if ferr != nil {
+			return nil, ferr
+		}
+		leg.GroupRole = groupRole(orders.GroupRoleLeg)
+		legs = append(legs, leg)
+	}
+
+	holdGroupForApproval(legs)
+	return legs, nil
+}
+
+// checkOCOFunds proverava kupovnu moć za OCO grupu. Izvršava se samo jedan nalog
+// grupe, pa se proverava i rezerviše samo trošak najskupljeg, i to jednom, na
+// zajedničkoj rezervaciji grupe.
+func checkOCOFunds(legs []types.Order) ([]float64, *fiber.Error) {
+	costs := make([]float64, len(legs))
+	costliest := -1
+	highest := 0.0
+	for i := range legs {
+		if !orders.NeedsFunds(legs[i]) {
+			continue
+		}
+		cost, err := orders.EstimateCost(db.DB, legs[i])
+		if err != nil {
+			// Procena nije moguća; checkFunds tada šalje nalog na odobrenje
+			costliest = i
+			break
+		}
+		if costliest < 0 || cost > highest {
+			costliest, highest = i, cost
+		}
+	}
+	if costliest < 0 {
+		return costs, nil
+	}
+
+	cost, ferr := checkFunds(&legs[costliest])
+	if ferr != nil {
+		return nil, ferr
+	}
+	for i := range legs {
+		if orders.NeedsFunds(legs[i]) {
+			costs[i] = cost
+		}
+	}
+	return costs, nil
+}
+
+// buildBracketLegs pravi entry nalog i njegove take-profit i stop-loss naloge.
+// Take-profit i stop-loss su suprotnog smera, za istu količinu, i čekaju
+// (status waiting) dok se entry ne izvrši.
+func buildBracketLegs(c *fiber.Ctx, request types.CreateOrderGroupRequest) ([]types.Order, *fiber.Error) {
+	if request.Entry == nil || request.TakeProfitPrice == nil || request.StopLossPrice == nil {
+		return nil, fiber.NewError(400, "Bracket mora imati entry, take_profit_price i stop_loss_price")
+	}
+
+	entry, ferr := buildOrder(c, *request.Entry)
+	if ferr != nil {
+		return nil, ferr
+	}
+	entry.GroupRole = groupRole(orders.GroupRoleEntry)
+
+	takeProfit, stopLoss := *request.TakeProfitPrice, *request.StopLossPrice
+	exitDirection := "sell"
+	if strings.ToLower(entry.Direction) == "buy" {
+		if takeProfit <= stopLoss {
+			return nil, fiber.NewError(400, "Za kupovinu take_profit_price mora biti veći od stop_loss_price")
+		}
+	} else {
+		exitDirection = "buy"
+		if takeProfit >= stopLoss {
+			return nil, fiber.NewError(400, "Za prodaju take_profit_price mora biti manji od stop_loss_price")
+		}
+	}
+
+	childStatus := orders.StatusWaiting
+	if entry.Status != "approved" {
+		childStatus = entry.Status
+	}
+	exit := func(role, orderType string, stop, limit *float64) types.Order {
+		quantity := entry.Quantity
+		return types.Order{
+			UserID:            entry.UserID,
+			AccountID:         entry.AccountID,
+			SecurityID:        entry.SecurityID,
+			Quantity:          quantity,
+			ContractSize:      entry.ContractSize,
+			StopPricePerUnit:  stop,
+			LimitPricePerUnit: limit,
+			OrderType:         orderType,
+			Direction:         exitDirection,
+			Status:            childStatus,
+			ApprovedBy:        entry.ApprovedBy,
+			LastModified:      entry.LastModified,
+			RemainingParts:    &quantity,
+			TimeInForce:       orders.TimeInForceGTC,
+			GroupRole:         groupRole(role),
+		}
+	}
+
+	return []types.Order{
+		entry,
+		exit(orders.GroupRoleTakeProfit, "LIMIT", nil, &takeProfit),
+		exit(orders.GroupRoleStopLoss, "STOP", &stopLoss, nil),
+	}, nil
+}
+
+// holdGroupForApproval vraća sve naloge grupe na čekanje ako bar jedan mora da bude odobren.
+func holdGroupForApproval(legs []types.Order) {
+	for _, leg := range legs {
+		if leg.Status == "pending" {
+			for i := range legs {
+				legs[i].Status = "pending"
+				legs[i].ApprovedBy = nil
+				legs[i].PriorityTime = 0
+			}
+			return
+		}
+	}
+}
+
+func groupRole(role string) *string {
+	return &role
+}
+
+// GetOrderGroup godoc
+//
+//	@Summary		Preuzimanje grupe naloga
+//	@Description	Vraća OCO ili bracket grupu zajedno sa svim njenim nalozima.
+//	@Tags			Orders
+//	@Produce		json
+//	@Param			id	path		int												true	"ID grupe naloga"
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=types.OrderGroupResponse}	"Uspešno preuzeta grupa"
+//	@Failure		400	{object}	types.Response									"Nevalidan ID grupe"
+//	@Failure		403	{object}	types.Response									"Grupa pripada drugom korisniku"
+//	@Failure		404	{object}	types.Response									"Grupa sa datim ID-jem ne postoji"
+//	@Router			/orders/groups/{id} [get]
+func (gc *OrderGroupController) GetOrderGroup(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id", -1)
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(types.Response{Success: false, Error: "Nevalidan ID"})
+	}
+
+	var group types.OrderGroup
+	if err := db.DB.Preload("Orders").First(&group, id).Error; err != nil {
+		return c.Status(404).JSON(types.Response{
+			Success: false,
+			Error:   "Nije pronadjena: " + err.Error(),
+		})
+	}
+	department, _ := c.Locals("department").(string)
+	if user := currentUser(c); department != "SUPERVISOR" && (user == nil || *user != group.UserID) {
+		return c.Status(403).JSON(types.Response{
+			Success: false,
+			Error:   "Nije dozvoljeno pregledati tuđu grupu naloga",
+		})
+	}
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    OrderGroupToResponse(group),
+	})
+}
+
+func InitOrderGroupRoutes(app *fiber.App) {
+	groupController := NewOrderGroupController()
+
+	app.Post("/orders/groups", middlewares.Auth, groupController.CreateOrderGroup)
+	app.Get("/orders/groups/:id", middlewares.Auth, groupController.GetOrderGroup)
+}
@@ -107,7 +107,7 @@ func (pc *PortfolioController) GetAllPortfolios(c *fiber.Ctx) error {
 // GetAvailableToSell godoc
 //
 //	@Summary		Dohvata broj dostupnih hartija koje korisnik može da proda
-//	@Description	Računa količinu hartija koje korisnik može trenutno da proda, uzimajući u obzir javne hartije i već rezervisane SELL naloge.
+//	@Description	Računa količinu hartija koje korisnik može trenutno da proda: portfolio umanjen za javne hartije i hartije koje u knjizi rezervacija drže otvoreni SELL nalozi.
 //	@Tags			Portfolio
 //	@Produce		json
 //	@Param			user_id		query	int	true	"ID korisnika"
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+
+	"banka1.com/db"
+	"banka1.com/middlewares"
+	"banka1.com/types"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PriceBandController struct {
+}
+
+func NewPriceBandController() *PriceBandController {
+	return &PriceBandController{}
+}
+
+// GetPriceBands godoc
+//
+//	@Summary		Lista opsega cena
+//	@Description	Vraća opsege cena i parametre automatske obustave po tipu instrumenta. Tipovi bez opsega nemaju ograničenje cene.
+//	@Tags			Trading halts
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=[]types.PriceBand}	"Opsezi cena"
+//	@Failure		500	{object}	types.Response							"Greška pri čitanju opsega cena"
+//	@Router			/price-bands [get]
+func (pc *PriceBandController) GetPriceBands(c *fiber.Ctx) error {
+	var bands []types.PriceBand
+	if err := db.DB.Order("instrument_type").Find(&bands).Error; err != nil {
+		return c.Status(500).JSON(types.Response{Success: false, Error: "Greška pri čitanju opsega cena: " + err.Error()})
+	}
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    bands,
+	})
+}
+
+// UpsertPriceBand godoc
+//
+//	@Summary		Postavljanje opsega cena za tip instrumenta
+//	@Description	Orderi sa limit ili stop cenom van opsega oko cene iz listinga se odbijaju, a trgovine van opsega se ne izvršavaju. Ako se cena internih trgovina u prozoru od volatility_window minuta pomeri više od volatility_limit procenata, hartija se obustavlja na halt_minutes minuta.
+//	@Tags			Trading halts
+//	@Accept			json
+//	@Produce		json
+//	@Param			instrumentType	path	string					true	"Tip instrumenta (Stock, Future, Option, Forex)"
+//	@Param			band			body	types.PriceBandRequest	true	"Parametri opsega"
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=types.PriceBand}	"Sačuvan opseg"
+//	@Failure		400	{object}	types.Response							"Nevalidan zahtev"
+//	@Router			/price-bands/{instrumentType} [put]
+func (pc *PriceBandController) UpsertPriceBand(c *fiber.Ctx) error {
+	var request types.PriceBandRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(400).JSON(types.Response{Success: false, Error: "Neuspelo parsiranje: " + err.Error()})
+	}
+	if err := validate.Struct(request); err != nil {
+		return c.Status(400).JSON(types.Response{Success: false, Error: "Neuspela validacija: " + err.Error()})
+	}
+	if request.VolatilityLimit > 0 && request.VolatilityWindow == 0 {
+		return c.Status(400).JSON(types.Response{Success: false, Error: "volatility_window je obavezan uz volatility_limit"})
+	}
+
+	band := types.PriceBand{
+		InstrumentType:   strings.TrimSpace(c.Params("instrumentType")),
+		MaxDeviation:     request.MaxDeviation,
+		VolatilityLimit:  request.VolatilityLimit,
+		VolatilityWindow: request.VolatilityWindow,
+		HaltMinutes:      request.HaltMinutes,
+	}
+	if band.InstrumentType == "" {
+		return c.Status(400).JSON(types.Response{Success: false, Error: "Tip instrumenta je obavezan"})
+	}
+
+	err := db.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "instrument_type"}},
+		DoUpdates: clause.AssignmentColumns([]string{"max_deviation", "volatility_limit", "volatility_window", "halt_minutes", "updated_at"}),
+	}).Create(&band).Error
+	if err != nil {
+		return c.Status(500).JSON(types.Response{Success: false, Error: "Greška pri čuvanju opsega cena: " + err.Error()})
+	}
+	if err := db.DB.Where("instrument_type = ?", band.InstrumentType).First(&band).Error; err != nil {
+		return c.Status(500).JSON(types.Response{Success: false, Error: "Greška pri čitanju opsega cena: " + err.Error()})
+	}
+
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    band,
+	})
+}
+
+// DeletePriceBand godoc
+//
+//	@Summary		Brisanje opsega cena
+//	@Description	Uklanja opseg cena za tip instrumenta; cene tog tipa više nisu ograničene.
+//	@Tags			Trading halts
+//	@Produce		json
+//	@Param			instrumentType	path	string	true	"Tip instrumenta"
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response	"Opseg obrisan"
+//	@Failure		404	{object}	types.Response	"Opseg nije pronađen"
+//	@Router			/price-bands/{instrumentType} [delete]
+func (pc *PriceBandController) DeletePriceBand(c *fiber.Ctx) error {
+	var band types.PriceBand
+	if err := db.DB.Where("instrument_type = ?", c.Params("instrumentType")).First(&band).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(404).JSON(types.Response{Success: false, Error: "Opseg cena nije pronađen"})
+		}
+		return c.Status(500).JSON(types.Response{Success: false, Error: "Greška pri čitanju opsega cena: " + err.Error()})
+	}
+	if err := db.DB.Delete(&band).Error; err != nil {
+		return c.Status(500).JSON(types.Response{Success: false, Error: "Greška pri brisanju opsega cena: " + err.Error()})
+	}
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    "Opseg cena obrisan",
+	})
+}
+
+func InitPriceBandRoutes(app *fiber.App) {
+	bandController := NewPriceBandController()
+
+	bandGroup := app.Group("/price-bands", middlewares.Auth, middlewares.DepartmentCheck("SUPERVISOR"))
+	bandGroup.Get("", bandController.GetPriceBands)
+	bandGroup.Put("/:instrumentType", bandController.UpsertPriceBand)
+	bandGroup.Delete("/:instrumentType", bandController.DeletePriceBand)
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"time"
+
+	"banka1.com/clock"
+	"banka1.com/controllers/orders"
+	"banka1.com/middlewares"
+	"banka1.com/types"
+	"github.com/gofiber/fiber/v2"
+)
+
+type SimulationController struct {
+}
+
+func NewSimulationController() *SimulationController {
+	return &SimulationController{}
+}
+
+func simulationClockResponse() types.SimulationClockResponse {
+	response := types.SimulationClockResponse{Now: clock.Now()}
+	if sim, ok := clock.Simulation(); ok {
+		response.Simulated = true
+		response.Speed = sim.Speed()
+	}
+	return response
+}
+
+// GetClock godoc
+//
+//	@Summary		Vreme servisa
+//	@Description	Vraća trenutno vreme koje koriste matching engine i cron poslovi, i da li je uključena simulacija.
+//	@Tags			Simulation
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=types.SimulationClockResponse}	"Trenutno vreme"
+//	@Router			/simulation/clock [get]
+func (sc *SimulationController) GetClock(c *fiber.Ctx) error {
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    simulationClockResponse(),
+	})
+}
+
+// AdvanceClock godoc
+//
+//	@Summary		Pomeranje virtuelnog vremena
+//	@Description	Pomera virtuelno vreme unapred i odmah, pre odgovora, pokreće poslove koji zavise od vremena (istek ordera, kraj obustava, aukcije, nalozi na čekanju, pozajmice, margin pozivi), pa se obrađuju kao da je vreme zaista prošlo. Odloženi pokušaji izvršenja se bude asinhrono. Dostupno samo u simulaciji.
+//	@Tags			Simulation
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body	types.AdvanceClockRequest	true	"Koliko sekundi pomeriti"
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=types.SimulationClockResponse}	"Vreme pomereno"
+//	@Failure		400	{object}	types.Response										"Neispravan zahtev"
+//	@Failure		409	{object}	types.Response										"Simulacija nije uključena"
+//	@Router			/simulation/advance [post]
+func (sc *SimulationController) AdvanceClock(c *fiber.Ctx) error {
+	sim, ok := clock.Simulation()
+	if !ok {
+		return c.Status(409).JSON(types.Response{
+			Success: false,
+			Error:   "Simulacija nije uključena",
+		})
+	}
+
+	var request types.AdvanceClockRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(400).JSON(types.Response{
+			Success: false,
+			Error:   "Neuspelo parsiranje: " + err.Error(),
+		})
+	}
+	if err := validate.Struct(request); err != nil {
+		return c.Status(400).JSON(types.Response{
+			Success: false,
+			Error:   "Neuspela validacija: " + err.Error(),
+		})
+	}
+
+	sim.Advance(time.Duration(request.Seconds) * time.Second)
+	orders.RunTimedJobs()
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    simulationClockResponse(),
+	})
+}
+
+func InitSimulationRoutes(app *fiber.App) {
+	simulationController := NewSimulationController()
+
+	app.Get("/simulation/clock", middlewares.Auth, simulationController.GetClock)
+	app.Post("/simulation/advance", middlewares.Auth, middlewares.DepartmentCheck("SUPERVISOR"), simulationController.AdvanceClock)
+}
@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"errors"
+
+	"banka1.com/clock"
+	"banka1.com/controllers/orders"
+	"banka1.com/db"
+	"banka1.com/middlewares"
+	"banka1.com/types"
+	"github.com/gofiber/fiber/v2"
+)
+
+type TradingHaltController struct {
+}
+
+func NewTradingHaltController() *TradingHaltController {
+	return &TradingHaltController{}
+}
+
+// haltRequest čita opcioni razlog i trajanje obustave; prazno telo znači obustavu do ručnog nastavka.
+func haltRequest(c *fiber.Ctx) (types.TradingHaltRequest, *fiber.Error) {
+	var request types.TradingHaltRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&request); err != nil {
+			return request, fiber.NewError(400, "Neuspelo parsiranje: "+err.Error())
+		}
+	}
+	if err := validate.Struct(request); err != nil {
+		return request, fiber.NewError(400, "Neuspela validacija: "+err.Error())
+	}
+	return request, nil
+}
+
+// haltResponse prevodi rezultat obustave ili nastavka u odgovor.
+func haltResponse(c *fiber.Ctx, halt types.TradingHalt, err error) error {
+	switch {
+	case errors.Is(err, orders.ErrAlreadyHalted), errors.Is(err, orders.ErrNotHalted):
+		return c.Status(409).JSON(types.Response{Success: false, Error: err.Error()})
+	case err != nil:
+		return c.Status(500).JSON(types.Response{Success: false, Error: "Greška pri obustavi trgovanja: " + err.Error()})
+	}
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    halt,
+	})
+}
+
+// GetTradingHalts godoc
+//
+//	@Summary		Lista obustava trgovanja
+//	@Description	Vraća ručne i automatske obustave hartija i berzi. Sa active=true vraća samo obustave koje trenutno važe.
+//	@Tags			Trading halts
+//	@Produce		json
+//	@Param			active	query	bool	false	"Samo aktivne obustave"
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=[]types.TradingHalt}	"Obustave"
+//	@Failure		500	{object}	types.Response								"Greška pri čitanju obustava"
+//	@Router			/halts [get]
+func (hc *TradingHaltController) GetTradingHalts(c *fiber.Ctx) error {
+	query := db.DB.Order("id desc")
+	if c.QueryBool("active") {
+		query = query.Where("ended_at IS NULL AND (resume_at IS NULL OR resume_at > ?)", clock.Now())
+	}
+
+	var halts []types.TradingHalt
+	if err := query.Find(&halts).Error; err != nil {
+		return c.Status(500).JSON(types.Response{Success: false, Error: "Greška pri čitanju obustava: " + err.Error()})
+	}
+	return c.JSON(types.Response{
+		Success: true,
+		Data:    halts,
+	})
+}
+
+// HaltSecurity godoc
+//
+//	@Summary		Obustava trgovanja hartijom
+//	@Description	Orderi za hartiju se i dalje primaju, ali se ne izvršavaju dok obustava traje. Sa minutes > 0 trgovanje se automatski nastavlja posle tog vremena.
+//	@Tags			Trading halts
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path	int							true	"ID hartije"
+//	@Param			halt	body	types.TradingHaltRequest	false	"Razlog i trajanje"
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=types.TradingHalt}	"Obustava"
+//	@Failure		404	{object}	types.Response							"Hartija nije pronađena"
+//	@Failure		409	{object}	types.Response							"Trgovanje je već obustavljeno"
+//	@Router			/securities/{id}/halt [post]
+func (hc *TradingHaltController) HaltSecurity(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id", -1)
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(types.Response{Success: false, Error: "Nevalidan ID hartije"})
+	}
+	request, ferr := haltRequest(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(types.Response{Success: false, Error: ferr.Message})
+	}
+	var security types.Security
+	if err := db.DB.First(&security, id).Error; err != nil {
+		return c.Status(404).JSON(types.Response{Success: false, Error: "Hartija nije pronađena"})
+	}
+
+	halt, err := orders.HaltSecurity(security.ID, request.Reason, request.Minutes, currentUser(c))
+	return haltResponse(c, halt, err)
+}
+
+// ResumeSecurity godoc
+//
+//	@Summary		Nastavak trgovanja hartijom
+//	@Description	Završava obustavu hartije i vraća njene ordere iz knjige u matching.
+//	@Tags			Trading halts
+//	@Produce		json
+//	@Param			id	path	int	true	"ID hartije"
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=types.TradingHalt}	"Završena obustava"
+//	@Failure		409	{object}	types.Response							"Trgovanje nije obustavljeno"
+//	@Router			/securities/{id}/resume [post]
+func (hc *TradingHaltController) ResumeSecurity(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id", -1)
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(types.Response{Success: false, Error: "Nevalidan ID hartije"})
+	}
+	halt, err := orders.ResumeSecurity(uint(id), currentUser(c))
+	return haltResponse(c, halt, err)
+}
+
+// HaltExchange godoc
+//
+//	@Summary		Obustava trgovanja na berzi
+//	@Description	Obustavlja izvršavanje ordera za sve hartije koje se kotiraju na berzi.
+//	@Tags			Trading halts
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path	int							true	"ID berze"
+//	@Param			halt	body	types.TradingHaltRequest	false	"Razlog i trajanje"
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=types.TradingHalt}	"Obustava"
+//	@Failure		404	{object}	types.Response							"Berza nije pronađena"
+//	@Failure		409	{object}	types.Response							"Trgovanje je već obustavljeno"
+//	@Router			/exchanges/{id}/halt [post]
+func (hc *TradingHaltController) HaltExchange(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id", -1)
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(types.Response{Success: false, Error: "Nevalidan ID berze"})
+	}
+	request, ferr := haltRequest(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(types.Response{Success: false, Error: ferr.Message})
+	}
+	var exchange types.Exchange
+	if err := db.DB.First(&exchange, id).Error; err != nil {
+		return c.Status(404).JSON(types.Response{Success: false, Error: "Berza nije pronađena"})
+	}
+
+	halt, err := orders.HaltExchange(exchange.ID, request.Reason, request.Minutes, currentUser(c))
+	return haltResponse(c, halt, err)
+}
+
+// ResumeExchange godoc
+//
+//	@Summary		Nastavak trgovanja na berzi
+//	@Description	Završava obustavu berze i vraća ordere njenih hartija u matching.
+//	@Tags			Trading halts
+//	@Produce		json
+//	@Param			id	path	int	true	"ID berze"
+//	@Security		BearerAuth
+//	@Success		200	{object}	types.Response{data=types.TradingHalt}	"Završena obustava"
+//	@Failure		409	{object}	types.Response							"Trgovanje nije obustavljeno"
+//	@Router			/exchanges/{id}/resume [post]
+func (hc *TradingHaltController) ResumeExchange(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id", -1)
+	if err != nil || id <= 0 {
+		return c.Status(400).JSON(types.Response{Success: false, Error: "Nevalidan ID berze"})
+	}
+	halt, err := orders.ResumeExchange(uint(id), currentUser(c))
+	return haltResponse(c, halt, err)
+}
+
+func InitTradingHaltRoutes(app *fiber.App) {
+	haltController := NewTradingHaltController()
+
+	app.Get("/halts", middlewares.Auth, middlewares.DepartmentCheck("SUPERVISOR"), haltController.GetTradingHalts)
+	app.Post("/securities/:id/halt", middlewares.Auth, middlewares.DepartmentCheck("SUPERVISOR"), haltController.HaltSecurity)
+	app.Post("/securities/:id/resume", middlewares.Auth, middlewares.DepartmentCheck("SUPERVISOR"), haltController.ResumeSecurity)
+	app.Post("/exchanges/:id/halt", middlewares.Auth, middlewares.DepartmentCheck("SUPERVISOR"), haltController.HaltExchange)
+	app.Post("/exchanges/:id/resume", middlewares.Auth, middlewares.DepartmentCheck("SUPERVISOR"), haltController.ResumeExchange)
+}
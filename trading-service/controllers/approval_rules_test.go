@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"banka1.com/db"
+	"banka1.com/types"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApprovalRules_RecordedOnAgentOrders(t *testing.T) {
+	testApp := fiber.New()
+	rc := NewApprovalRuleController()
+	testApp.Get("/approval-rules", rc.GetApprovalRules)
+	testApp.Post("/approval-rules", rc.CreateApprovalRule)
+	testApp.Put("/approval-rules/:id", rc.UpdateApprovalRule)
+	testApp.Delete("/approval-rules/:id", rc.DeleteApprovalRule)
+
+	send := func(method, url, body string) (*http.Response, types.ApprovalRule) {
+		req := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := testApp.Test(req)
+		assert.NoError(t, err)
+		var parsed struct {
+			Data types.ApprovalRule `json:"data"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&parsed)
+		return resp, parsed.Data
+	}
+
+	_ = db.DB.Create(&types.Security{ID: 43, Ticker: "APRV", Name: "Approval test", Type: "Stock", Volume: 100, LastPrice: 50}).Error
+	actuary := types.Actuary{UserID: 1, Department: "AGENT", LimitAmount: 100000}
+	db.DB.Where("user_id = ?", 1).Delete(&types.Actuary{})
+	assert.NoError(t, db.DB.Create(&actuary).Error)
+	t.Cleanup(func() {
+		db.DB.Delete(&types.Actuary{}, actuary.ID)
+	})
+
+	postAgentOrder := func() *http.Response {
+		payload, _ := json.Marshal(map[string]any{
+			"user_id":              1,
+			"account_id":           1,
+			"security_id":          43,
+			"quantity":             10,
+			"contract_size":        1,
+			"limit_price_per_unit": 50,
+			"direction":            "buy",
+		})
+		req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Test-UserID", "1")
+		req.Header.Set("X-Test-Department", "AGENT")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	resp, _ := send(http.MethodPost, "/approval-rules", `{"name":"Bez akcije"}`)
+	assert.Equal(t, 400, resp.StatusCode)
+	resp, _ = send(http.MethodPost, "/approval-rules", `{"name":"Nepoznato","action":"maybe"}`)
+	assert.Equal(t, 400, resp.StatusCode)
+
+	resp, created := send(http.MethodPost, "/approval-rules", `{"name":"Nalozi od 400","action":"supervisor","min_notional":400}`)
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.True(t, created.Enabled)
+	t.Cleanup(func() {
+		db.DB.Delete(&types.ApprovalRule{}, created.ID)
+	})
+
+	// 10 * 50 = 500 je u okviru limita, ali pravilo traži supervizora
+	resp = postAgentOrder()
+	assert.Equal(t, 200, resp.StatusCode)
+	var body struct {
+		Data uint `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	var order types.Order
+	assert.NoError(t, db.DB.First(&order, body.Data).Error)
+	assert.Equal(t, "pending", order.Status)
+	assert.Equal(t, created.ID, *order.ApprovalRuleID)
+
+	url := fmt.Sprintf("/approval-rules/%d", created.ID)
+	resp, updated := send(http.MethodPut, url, `{"name":"Nalozi od 400","action":"reject","min_notional":400}`)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "reject", updated.Action)
+
+	resp = postAgentOrder()
+	assert.Equal(t, 403, resp.StatusCode)
+
+	resp, updated = send(http.MethodPut, url, `{"name":"Nalozi od 400","action":"reject","enabled":false}`)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.False(t, updated.Enabled)
+
+	// Bez pravila agent u okviru limita prolazi sam
+	resp = postAgentOrder()
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	var approved types.Order
+	assert.NoError(t, db.DB.First(&approved, body.Data).Error)
+	assert.Equal(t, "approved", approved.Status)
+	assert.Nil(t, approved.ApprovalRuleID)
+
+	resp, _ = send(http.MethodDelete, url, "")
+	assert.Equal(t, 200, resp.StatusCode)
+	resp, _ = send(http.MethodDelete, url, "")
+	assert.Equal(t, 404, resp.StatusCode)
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"banka1.com/controllers/orders"
+	"banka1.com/db"
+	"banka1.com/types"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBorrowsListAndRecall(t *testing.T) {
+	testApp := fiber.New()
+	testApp.Use(func(c *fiber.Ctx) error {
+		c.Locals("user_id", 41.0)
+		return c.Next()
+	})
+	bc := NewBorrowController()
+	testApp.Get("/borrows", bc.GetBorrows)
+	testApp.Post("/borrows/:id/recall", bc.RecallBorrow)
+
+	own := types.SecurityBorrow{UserID: 41, AccountID: 5, SecurityID: 42, OrderID: 1, Borrowed: 3, Quantity: 3, Price: 10, FeeRate: 3, AccruedThrough: time.Now(), Status: orders.BorrowStatusActive}
+	other := types.SecurityBorrow{UserID: 42, AccountID: 6, SecurityID: 42, OrderID: 2, Borrowed: 1, Quantity: 1, Price: 10, FeeRate: 3, AccruedThrough: time.Now(), Status: orders.BorrowStatusActive}
+	assert.NoError(t, db.DB.Create(&own).Error)
+	assert.NoError(t, db.DB.Create(&other).Error)
+	t.Cleanup(func() {
+		db.DB.Delete(&own)
+		db.DB.Delete(&other)
+	})
+
+	resp, err := testApp.Test(httptest.NewRequest(http.MethodGet, "/borrows", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	var list struct {
+		Data []types.SecurityBorrow `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	assert.Len(t, list.Data, 1)
+	assert.Equal(t, own.ID, list.Data[0].ID)
+
+	resp, err = testApp.Test(httptest.NewRequest(http.MethodPost, fmt.Sprintf("/borrows/%d/recall", own.ID), nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	var recalled struct {
+		Data types.SecurityBorrow `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&recalled))
+	assert.Equal(t, orders.BorrowStatusRecalled, recalled.Data.Status)
+	assert.NotNil(t, recalled.Data.RecallDeadline)
+
+	// Već opozvana pozajmica se ne opoziva ponovo
+	resp, err = testApp.Test(httptest.NewRequest(http.MethodPost, fmt.Sprintf("/borrows/%d/recall", own.ID), nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 400, resp.StatusCode)
+
+	resp, err = testApp.Test(httptest.NewRequest(http.MethodPost, "/borrows/999999/recall", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+}
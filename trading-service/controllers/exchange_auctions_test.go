@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"banka1.com/db"
+	"banka1.com/exchanges"
+	"banka1.com/types"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExchangeAuctions_ToggleAndList(t *testing.T) {
+	exchange := types.Exchange{Name: "Test berza", Acronym: "TST", MicCode: "XAUT", Country: "USA", Currency: "USD",
+		Timezone: "America/New_York", OpenTime: " 09:30", CloseTime: " 16:00"}
+	assert.NoError(t, db.DB.Create(&exchange).Error)
+	t.Cleanup(func() {
+		db.DB.Where("exchange_id = ?", exchange.ID).Delete(&types.Auction{})
+		db.DB.Delete(&exchange)
+	})
+
+	testApp := fiber.New()
+	ec := NewExchangeController()
+	testApp.Put("/exchanges/:id/auction", ec.SetExchangeAuctionMode)
+	testApp.Get("/exchanges/:id/auctions", ec.GetExchangeAuctions)
+	base := fmt.Sprintf("/exchanges/%d", exchange.ID)
+
+	put := func(path, body string) int {
+		req := httptest.NewRequest(http.MethodPut, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := testApp.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+	assert.Equal(t, 400, put(base+"/auction", `{}`))
+	assert.Equal(t, 404, put("/exchanges/999999/auction", `{"enabled": true}`))
+	assert.Equal(t, 200, put(base+"/auction", `{"enabled": true}`))
+	assert.NoError(t, db.DB.First(&exchange, exchange.ID).Error)
+	assert.True(t, exchange.AuctionMode)
+
+	now := time.Now()
+	assert.NoError(t, db.DB.Create(&types.Auction{ExchangeID: exchange.ID, SecurityID: 1, Session: "2025-06-16", Kind: exchanges.AuctionOpening, Price: 10, Volume: 3, Trades: 1, UncrossedAt: now}).Error)
+	assert.NoError(t, db.DB.Create(&types.Auction{ExchangeID: exchange.ID, SecurityID: 1, Session: "2025-06-17", Kind: exchanges.AuctionOpening, UncrossedAt: now.Add(24 * time.Hour)}).Error)
+
+	resp, err := testApp.Test(httptest.NewRequest(http.MethodGet, base+"/auctions?session=2025-06-16", nil))
+	assert.NoError(t, err)
+	var body struct {
+		Data []types.Auction `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	if assert.Len(t, body.Data, 1) {
+		assert.Equal(t, 10.0, body.Data[0].Price)
+	}
+
+	assert.Equal(t, 200, put(base+"/auction", `{"enabled": false}`))
+	assert.NoError(t, db.DB.First(&exchange, exchange.ID).Error)
+	assert.False(t, exchange.AuctionMode)
+}
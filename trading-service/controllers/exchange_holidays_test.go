@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"banka1.com/db"
+	"banka1.com/exchanges"
+	"banka1.com/types"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExchangeHolidays_CreateListDelete(t *testing.T) {
+	exchange := types.Exchange{Name: "Test berza", Acronym: "TST", MicCode: "XHOL", Country: "USA", Currency: "USD",
+		Timezone: "America/New_York", OpenTime: " 09:30", CloseTime: " 16:00"}
+	assert.NoError(t, db.DB.Create(&exchange).Error)
+	t.Cleanup(func() {
+		db.DB.Where("mic_code = ?", exchange.MicCode).Delete(&types.ExchangeHoliday{})
+		db.DB.Delete(&exchange)
+		_ = exchanges.ReloadHolidays()
+	})
+
+	testApp := fiber.New()
+	ec := NewExchangeController()
+	testApp.Get("/exchanges/:id/holidays", ec.GetExchangeHolidays)
+	testApp.Post("/exchanges/:id/holidays", ec.CreateExchangeHoliday)
+	testApp.Delete("/exchanges/:id/holidays/:date", ec.DeleteExchangeHoliday)
+	base := fmt.Sprintf("/exchanges/%d/holidays", exchange.ID)
+
+	post := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, base, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := testApp.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+	assert.Equal(t, 400, post(`{"date":"04.07.2025"}`))
+	assert.Equal(t, 201, post(`{"date":"2025-07-04","name":"Independence Day"}`))
+	assert.Equal(t, 409, post(`{"date":"2025-07-04"}`))
+
+	loc, _ := time.LoadLocation("America/New_York")
+	assert.True(t, exchanges.IsHoliday("XHOL", time.Date(2025, 7, 4, 12, 0, 0, 0, loc)))
+
+	resp, err := testApp.Test(httptest.NewRequest(http.MethodGet, base, nil))
+	assert.NoError(t, err)
+	var body struct {
+		Data []types.ExchangeHoliday `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Len(t, body.Data, 1)
+	assert.Equal(t, "Independence Day", body.Data[0].Name)
+
+	resp, err = testApp.Test(httptest.NewRequest(http.MethodDelete, base+"/2025-07-04", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.False(t, exchanges.IsHoliday("XHOL", time.Date(2025, 7, 4, 12, 0, 0, 0, loc)))
+
+	resp, err = testApp.Test(httptest.NewRequest(http.MethodDelete, base+"/2025-07-04", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+}
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"banka1.com/db"
+	"banka1.com/types"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeeSchedules_CRUD(t *testing.T) {
+	testApp := fiber.New()
+	fc := NewFeeScheduleController()
+	testApp.Get("/fee-schedules", fc.GetFeeSchedules)
+	testApp.Post("/fee-schedules", fc.CreateFeeSchedule)
+	testApp.Put("/fee-schedules/:id", fc.UpdateFeeSchedule)
+	testApp.Delete("/fee-schedules/:id", fc.DeleteFeeSchedule)
+
+	send := func(method, url, body string) (*http.Response, types.FeeSchedule) {
+		req := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := testApp.Test(req)
+		assert.NoError(t, err)
+		var parsed struct {
+			Data types.FeeSchedule `json:"data"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&parsed)
+		return resp, parsed.Data
+	}
+
+	resp, _ := send(http.MethodPost, "/fee-schedules", `{"name":"Bez stepena","tiers":[]}`)
+	assert.Equal(t, 400, resp.StatusCode)
+	resp, _ = send(http.MethodPost, "/fee-schedules", `{"name":"Los cap","minimum":5,"cap":2,"tiers":[{"percent":1}]}`)
+	assert.Equal(t, 400, resp.StatusCode)
+
+	resp, created := send(http.MethodPost, "/fee-schedules",
+		`{"name":"MARKET agenti","order_type":"market","segment":"Agent","cap":7,"tiers":[{"up_to":1000,"percent":1},{"percent":0.5}]}`)
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.Equal(t, "MARKET", created.OrderType)
+	assert.Equal(t, "agent", created.Segment)
+	assert.Len(t, created.Tiers, 2)
+	t.Cleanup(func() {
+		db.DB.Where("schedule_id = ?", created.ID).Delete(&types.FeeScheduleTier{})
+		db.DB.Delete(&types.FeeSchedule{}, created.ID)
+	})
+
+	url := fmt.Sprintf("/fee-schedules/%d", created.ID)
+	resp, updated := send(http.MethodPut, url, `{"name":"MARKET agenti","order_type":"MARKET","tiers":[{"percent":2}]}`)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "", updated.Segment)
+
+	var tiers []types.FeeScheduleTier
+	db.DB.Where("schedule_id = ?", created.ID).Find(&tiers)
+	assert.Len(t, tiers, 1)
+	assert.Equal(t, 2.0, tiers[0].Percent)
+
+	resp, _ = send(http.MethodDelete, url, "")
+	assert.Equal(t, 200, resp.StatusCode)
+	resp, _ = send(http.MethodDelete, url, "")
+	assert.Equal(t, 404, resp.StatusCode)
+}
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"banka1.com/db"
+	"banka1.com/dto"
+	"banka1.com/types"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriceBand_RejectsOrderOutsideBand(t *testing.T) {
+	stubFunds(t, []dto.Account{{ID: 1, OwnerID: 1, Balance: 100000}}, nil)
+	_ = db.DB.Create(&types.Security{ID: 42, Ticker: "FUND", Name: "Funds test", Type: "Stock", Volume: 100, LastPrice: 50}).Error
+
+	testApp := fiber.New()
+	pc := NewPriceBandController()
+	testApp.Get("/price-bands", pc.GetPriceBands)
+	testApp.Put("/price-bands/:instrumentType", pc.UpsertPriceBand)
+	testApp.Delete("/price-bands/:instrumentType", pc.DeletePriceBand)
+	t.Cleanup(func() {
+		db.DB.Where("instrument_type = ?", "Stock").Delete(&types.PriceBand{})
+	})
+
+	put := func(body string) int {
+		req := httptest.NewRequest(http.MethodPut, "/price-bands/Stock", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := testApp.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+	assert.Equal(t, 400, put(`{"max_deviation": 0}`))
+	assert.Equal(t, 400, put(`{"max_deviation": 5, "volatility_limit": 3}`))
+	assert.Equal(t, 200, put(`{"max_deviation": 5}`))
+	// Ponovni upis menja postojeći opseg
+	assert.Equal(t, 200, put(`{"max_deviation": 10, "volatility_limit": 3, "volatility_window": 5}`))
+
+	var bands []types.PriceBand
+	assert.NoError(t, db.DB.Where("instrument_type = ?", "Stock").Find(&bands).Error)
+	assert.Len(t, bands, 1)
+	assert.Equal(t, 10.0, bands[0].MaxDeviation)
+
+	post := func(limit float64) *http.Response {
+		payload, _ := json.Marshal(map[string]any{
+			"user_id":              1,
+			"account_id":           1,
+			"security_id":          42,
+			"quantity":             1,
+			"contract_size":        1,
+			"limit_price_per_unit": limit,
+			"direction":            "buy",
+		})
+		req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Test-UserID", "1")
+		req.Header.Set("X-Test-Department", "SUPERVISOR")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	// Opseg oko poslednje cene 50 je 45 - 55
+	resp := post(80)
+	assert.Equal(t, 400, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(body), "van dozvoljenog opsega")
+
+	resp, err := testApp.Test(httptest.NewRequest(http.MethodDelete, "/price-bands/Stock", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	resp, err = testApp.Test(httptest.NewRequest(http.MethodDelete, "/price-bands/Stock", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+}
+
+func TestTradingHalts_HaltAndResumeSecurity(t *testing.T) {
+	_ = db.DB.Create(&types.Security{ID: 42, Ticker: "FUND", Name: "Funds test", Type: "Stock", Volume: 100, LastPrice: 50}).Error
+	t.Cleanup(func() {
+		db.DB.Where("security_id = ?", 42).Delete(&types.TradingHalt{})
+	})
+
+	testApp := fiber.New()
+	testApp.Use(func(c *fiber.Ctx) error {
+		c.Locals("user_id", 7.0)
+		return c.Next()
+	})
+	hc := NewTradingHaltController()
+	testApp.Get("/halts", hc.GetTradingHalts)
+	testApp.Post("/securities/:id/halt", hc.HaltSecurity)
+	testApp.Post("/securities/:id/resume", hc.ResumeSecurity)
+	testApp.Post("/exchanges/:id/halt", hc.HaltExchange)
+
+	post := func(path, body string) int {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := testApp.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, 200, post("/securities/42/halt", `{"reason": "vest", "minutes": 30}`))
+	assert.Equal(t, 409, post("/securities/42/halt", ""))
+	assert.Equal(t, 404, post("/securities/999999/halt", ""))
+	assert.Equal(t, 404, post("/exchanges/999999/halt", ""))
+	assert.Equal(t, 400, post("/securities/42/halt", `{"minutes": -1}`))
+
+	resp, err := testApp.Test(httptest.NewRequest(http.MethodGet, "/halts?active=true", nil))
+	assert.NoError(t, err)
+	var halts struct {
+		Data []types.TradingHalt `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&halts))
+	if assert.Len(t, halts.Data, 1) {
+		assert.Equal(t, "vest", halts.Data[0].Reason)
+		assert.Equal(t, uint(7), *halts.Data[0].HaltedBy)
+	}
+
+	assert.Equal(t, 200, post("/securities/42/resume", ""))
+	assert.Equal(t, 409, post("/securities/42/resume", ""))
+}
@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"banka1.com/db"
+	"banka1.com/types"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarginAccountAndCalls(t *testing.T) {
+	testApp := fiber.New()
+	testApp.Use(func(c *fiber.Ctx) error {
+		c.Locals("user_id", 31.0)
+		return c.Next()
+	})
+	mc := NewMarginController()
+	testApp.Get("/margin/account", mc.GetMarginAccount)
+	testApp.Get("/margin/calls", mc.GetMarginCalls)
+
+	resp, err := testApp.Test(httptest.NewRequest(http.MethodGet, "/margin/account", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+
+	account := types.MarginAccount{UserID: 31, AccountID: 5, LoanBalance: 500, MarketValue: 700, Equity: 200, MaintenanceRequirement: 210, Status: "call"}
+	assert.NoError(t, db.DB.Create(&account).Error)
+	call := types.MarginCall{MarginAccountID: account.ID, UserID: 31, Status: "open", Equity: 200, MaintenanceRequirement: 210, Deficit: 10, Deadline: time.Now().Add(time.Hour)}
+	assert.NoError(t, db.DB.Create(&call).Error)
+	t.Cleanup(func() {
+		db.DB.Delete(&call)
+		db.DB.Delete(&account)
+	})
+
+	resp, err = testApp.Test(httptest.NewRequest(http.MethodGet, "/margin/account", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	var body struct {
+		Data types.MarginAccountResponse `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, 500.0, body.Data.Account.LoanBalance)
+	assert.Len(t, body.Data.Calls, 1)
+
+	resp, err = testApp.Test(httptest.NewRequest(http.MethodGet, "/margin/calls?status=met", nil))
+	assert.NoError(t, err)
+	var calls struct {
+		Data []types.MarginCall `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&calls))
+	assert.Empty(t, calls.Data)
+}
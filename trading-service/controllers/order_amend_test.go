@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"banka1.com/db"
+	"banka1.com/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func putOrder(t *testing.T, id uint, body string, department string) *http.Response {
+	req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/orders/%d", id), bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-UserID", "1")
+	if department != "" {
+		req.Header.Set("X-Test-Department", department)
+	}
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	return resp
+}
+
+func TestAmendOrder_DecreaseQuantity(t *testing.T) {
+	order := createTestOrder(t, false)
+
+	resp := putOrder(t, order.ID, `{"quantity": 3}`, "")
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var updated types.Order
+	assert.NoError(t, db.DB.First(&updated, order.ID).Error)
+	assert.Equal(t, 3, updated.Quantity)
+	assert.Equal(t, 3, *updated.RemainingParts)
+	assert.Equal(t, "pending", updated.Status)
+}
+
+func TestAmendOrder_Rejected(t *testing.T) {
+	order := createTestOrder(t, false)
+
+	assert.Equal(t, 400, putOrder(t, order.ID, `{}`, "").StatusCode)
+	assert.Equal(t, 400, putOrder(t, order.ID, `{"quantity": 0}`, "").StatusCode)
+
+	db.DB.Model(&types.Order{}).Where("id = ?", order.ID).Update("user_id", 2)
+	assert.Equal(t, 403, putOrder(t, order.ID, `{"quantity": 3}`, "").StatusCode)
+
+	db.DB.Model(&types.Order{}).Where("id = ?", order.ID).Updates(map[string]any{"user_id": 1, "status": "cancelled"})
+	assert.Equal(t, 400, putOrder(t, order.ID, `{"quantity": 3}`, "").StatusCode)
+}
+
+func TestAmendOrder_PriceChangeGoesBackToApproval(t *testing.T) {
+	order := createTestOrder(t, true)
+	limit := 10.0
+	db.DB.Model(&types.Order{}).Where("id = ?", order.ID).Updates(map[string]any{"limit_price_per_unit": limit, "priority_time": 1})
+
+	// Agent bez aktuarskog limita ne može sam da odobri izmenu
+	resp := putOrder(t, order.ID, `{"limit_price_per_unit": 11}`, "AGENT")
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var updated types.Order
+	assert.NoError(t, db.DB.First(&updated, order.ID).Error)
+	assert.Equal(t, "pending", updated.Status)
+	assert.Equal(t, 11.0, *updated.LimitPricePerUnit)
+	assert.Equal(t, int64(0), updated.PriorityTime)
+}
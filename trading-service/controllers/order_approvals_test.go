@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"banka1.com/controllers/orders"
+	"banka1.com/db"
+	"banka1.com/types"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderApprovals_QueueAndBulkActions(t *testing.T) {
+	testApp := fiber.New()
+	testApp.Use(func(c *fiber.Ctx) error {
+		c.Locals("user_id", 7.0)
+		return c.Next()
+	})
+	ac := NewOrderApprovalController()
+	testApp.Get("/order-approvals", ac.GetApprovalQueue)
+	testApp.Post("/order-approvals/approve", ac.BulkApproveOrders)
+	testApp.Post("/order-approvals/decline", ac.BulkDeclineOrders)
+
+	_ = db.DB.Create(&types.Security{ID: 44, Ticker: "QUEU", Name: "Queue test", Type: "Stock", Volume: 1000, LastPrice: 10}).Error
+	pendingOrder := func(quantity int, age time.Duration, escalated bool) types.Order {
+		order := types.Order{UserID: 1, AccountID: 1, SecurityID: 44, OrderType: "MARKET", Direction: "buy", Quantity: quantity, RemainingParts: ptr(quantity), Status: "pending"}
+		if escalated {
+			now := time.Now()
+			order.EscalatedAt = &now
+		}
+		assert.NoError(t, db.DB.Create(&order).Error)
+		assert.NoError(t, db.DB.Create(&types.OrderEvent{OrderID: order.ID, Type: orders.EventCreated, Status: "pending", CreatedAt: time.Now().Add(-age)}).Error)
+		return order
+	}
+	old := pendingOrder(10, 2*time.Hour, false)
+	fresh := pendingOrder(100, 0, false)
+	escalated := pendingOrder(20, 3*time.Hour, true)
+	t.Cleanup(func() {
+		db.DB.Where("security_id = ?", 44).Delete(&types.Order{})
+	})
+
+	queue := func(filter string) []types.ApprovalQueueItem {
+		resp, err := testApp.Test(httptest.NewRequest(http.MethodGet, "/order-approvals?security_id=44"+filter, nil))
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+		var parsed struct {
+			Data []types.ApprovalQueueItem `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&parsed))
+		return parsed.Data
+	}
+	ids := func(items []types.ApprovalQueueItem) []uint {
+		result := make([]uint, len(items))
+		for i, item := range items {
+			result[i] = item.Order.ID
+		}
+		return result
+	}
+
+	// Eskalirani prvi, zatim najstariji
+	all := queue("")
+	assert.Equal(t, []uint{escalated.ID, old.ID, fresh.ID}, ids(all))
+	assert.Equal(t, 100.0, all[1].Notional)
+	assert.GreaterOrEqual(t, all[1].AgeMinutes, 119)
+	assert.Equal(t, "QUEU", all[1].Ticker)
+
+	assert.Equal(t, []uint{fresh.ID}, ids(queue("&min_notional=500")))
+	assert.Equal(t, []uint{old.ID}, ids(queue("&min_age_minutes=60&escalated=false")))
+
+	send := func(url, body string) (*http.Response, []types.BulkApprovalResult) {
+		req := httptest.NewRequest(http.MethodPost, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := testApp.Test(req)
+		assert.NoError(t, err)
+		var parsed struct {
+			Data []types.BulkApprovalResult `json:"data"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&parsed)
+		return resp, parsed.Data
+	}
+
+	resp, _ := send("/order-approvals/decline", fmt.Sprintf(`{"order_ids":[%d]}`, old.ID))
+	assert.Equal(t, 400, resp.StatusCode)
+	resp, _ = send("/order-approvals/decline", fmt.Sprintf(`{"order_ids":[%d],"reason":"   "}`, old.ID))
+	assert.Equal(t, 400, resp.StatusCode)
+	resp, _ = send("/order-approvals/approve", `{"order_ids":[]}`)
+	assert.Equal(t, 400, resp.StatusCode)
+
+	resp, results := send("/order-approvals/decline", fmt.Sprintf(`{"order_ids":[%d,%d,999999],"reason":"Prevelik rizik"}`, old.ID, escalated.ID))
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Len(t, results, 3)
+	assert.True(t, results[0].Success)
+	assert.True(t, results[1].Success)
+	assert.False(t, results[2].Success)
+	assert.NotEmpty(t, results[2].Error)
+
+	var declined types.Order
+	assert.NoError(t, db.DB.First(&declined, old.ID).Error)
+	assert.Equal(t, "declined", declined.Status)
+	assert.Equal(t, "Prevelik rizik", *declined.DeclineReason)
+	assert.Equal(t, uint(7), *declined.ApprovedBy)
+
+	resp, results = send("/order-approvals/approve", fmt.Sprintf(`{"order_ids":[%d,%d]}`, fresh.ID, old.ID))
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.True(t, results[0].Success)
+	assert.Equal(t, "Nije na cekanju", results[1].Error)
+
+	var approved types.Order
+	assert.NoError(t, db.DB.First(&approved, fresh.ID).Error)
+	assert.Equal(t, "approved", approved.Status)
+	assert.Empty(t, queue(""))
+}
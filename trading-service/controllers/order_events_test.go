@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"banka1.com/controllers/orders"
+	"banka1.com/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetOrderEvents_Timeline(t *testing.T) {
+	order := createTestOrder(t, false)
+	orders.RecordStatus(order, orders.EventCreated, nil, "")
+
+	price, fee := 100.0, 7.0
+	orders.RecordEvent(nil, types.OrderEvent{OrderID: order.ID, Type: orders.EventFill, Status: "approved", Quantity: 2, Price: &price, Fee: &fee})
+
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/orders/%d/cancel", order.ID), nil)
+	req.Header.Set("X-Test-UserID", "1")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, fmt.Sprintf("/orders/%d/events", order.ID), nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var body struct {
+		Data types.OrderEventsResponse `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "cancelled", body.Data.Status)
+	assert.Equal(t, 2, body.Data.FilledQuantity)
+	assert.Equal(t, 100.0, *body.Data.AverageFillPrice)
+	assert.Equal(t, 7.0, body.Data.TotalFees)
+
+	eventTypes := []string{}
+	for _, event := range body.Data.Events {
+		eventTypes = append(eventTypes, event.Type)
+	}
+	assert.Equal(t, []string{orders.EventCreated, orders.EventFill, orders.EventCancelled}, eventTypes)
+	assert.Equal(t, uint(1), *body.Data.Events[2].ActorID)
+}
+
+func TestGetOrderEvents_NotFound(t *testing.T) {
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/orders/999999/events", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+}
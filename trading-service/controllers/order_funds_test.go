@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"banka1.com/controllers/orders"
+	"banka1.com/db"
+	"banka1.com/dto"
+	"banka1.com/holds"
+	"banka1.com/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func stubFunds(t *testing.T, accounts []dto.Account, err error) {
+	lookup, enabled := orders.AccountLookup, orders.FundsCheckEnabled
+	orders.AccountLookup = func(uint) ([]dto.Account, error) { return accounts, err }
+	orders.FundsCheckEnabled = func() bool { return true }
+	t.Cleanup(func() {
+		orders.AccountLookup, orders.FundsCheckEnabled = lookup, enabled
+	})
+}
+
+func postFundedOrder(t *testing.T) *http.Response {
+	_ = db.DB.Create(&types.Security{ID: 42, Ticker: "FUND", Name: "Funds test", Type: "Stock", Volume: 100, LastPrice: 50}).Error
+
+	payload, _ := json.Marshal(map[string]any{
+		"user_id":              1,
+		"account_id":           1,
+		"security_id":          42,
+		"quantity":             10,
+		"contract_size":        1,
+		"limit_price_per_unit": 50,
+		"direction":            "buy",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-UserID", "1")
+	req.Header.Set("X-Test-Department", "SUPERVISOR")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	return resp
+}
+
+func TestCreateOrder_InsufficientFunds(t *testing.T) {
+	// 10 * 50 + provizija 12 = 512
+	stubFunds(t, []dto.Account{{ID: 1, OwnerID: 1, Balance: 700, ReservedBalance: 300}}, nil)
+
+	resp := postFundedOrder(t)
+	assert.Equal(t, 400, resp.StatusCode)
+
+	var body types.Response
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Contains(t, body.Error, "512.00")
+	assert.Contains(t, body.Error, "400.00")
+}
+
+func TestCreateOrder_FundsReservedOrQueued(t *testing.T) {
+	stubFunds(t, nil, errors.New("banking servis ne odgovara"))
+
+	resp := postFundedOrder(t)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var body struct {
+		Data uint `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	var order types.Order
+	assert.NoError(t, db.DB.First(&order, body.Data).Error)
+	assert.Equal(t, "pending", order.Status)
+	assert.Nil(t, order.ApprovedBy)
+
+	var reservation types.Hold
+	assert.NoError(t, db.DB.Where("reason = ? AND owner_ref = ?", holds.ReasonOrder, holds.Ref(order.ID)).First(&reservation).Error)
+	assert.Equal(t, holds.AssetCash, reservation.Asset)
+	assert.Equal(t, holds.StatusActive, reservation.Status)
+	assert.InDelta(t, 512.0, reservation.Amount, 1e-9)
+
+	cancel := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/orders/%d/cancel", order.ID), nil)
+	cancel.Header.Set("X-Test-UserID", "1")
+	cancelResp, err := app.Test(cancel)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, cancelResp.StatusCode)
+
+	var released types.Hold
+	assert.NoError(t, db.DB.Where("reason = ? AND owner_ref = ?", holds.ReasonOrder, holds.Ref(order.ID)).First(&released).Error)
+	assert.Equal(t, holds.StatusReleased, released.Status)
+}
@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"banka1.com/controllers/orders"
+	"banka1.com/db"
+	"banka1.com/dto"
+	"banka1.com/holds"
+	"banka1.com/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func postOrderGroup(t *testing.T, body map[string]any) *http.Response {
+	payload, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/orders/groups", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-UserID", "1")
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	return resp
+}
+
+func TestCreateOrderGroup_OCOPendingTogether(t *testing.T) {
+	_ = db.DB.Create(&types.Security{ID: 1, Ticker: "TSLA", Volume: 100, LastPrice: 50.0, Name: "Tesla"}).Error
+
+	leg := func(limit float64) map[string]any {
+		return map[string]any{
+			"user_id":              1,
+			"account_id":           1,
+			"security_id":          1,
+			"quantity":             2,
+			"contract_size":        1,
+			"direction":            "buy",
+			"limit_price_per_unit": limit,
+		}
+	}
+	resp := postOrderGroup(t, map[string]any{"type": "OCO", "legs": []any{leg(40), leg(45)}})
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var body struct {
+		Data types.OrderGroupResponse `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, orders.GroupTypeOCO, body.Data.Type)
+	assert.Len(t, body.Data.Orders, 2)
+	for _, order := range body.Data.Orders {
+		assert.Equal(t, "pending", order.Status)
+		assert.Equal(t, orders.GroupRoleLeg, *order.GroupRole)
+	}
+}
+
+func TestCreateOrderGroup_BracketRejectsInvertedPrices(t *testing.T) {
+	_ = db.DB.Create(&types.Security{ID: 1, Ticker: "TSLA", Volume: 100, LastPrice: 50.0, Name: "Tesla"}).Error
+
+	resp := postOrderGroup(t, map[string]any{
+		"type": "BRACKET",
+		"entry": map[string]any{
+			"user_id":       1,
+			"account_id":    1,
+			"security_id":   1,
+			"quantity":      2,
+			"contract_size": 1,
+			"direction":     "buy",
+		},
+		"take_profit_price": 40,
+		"stop_loss_price":   60,
+	})
+	assert.Equal(t, 400, resp.StatusCode)
+}
+
+func TestCancelOrder_CancelsWholeGroup(t *testing.T) {
+	group := types.OrderGroup{Type: orders.GroupTypeBracket, UserID: 1, Status: "active"}
+	assert.NoError(t, db.DB.Create(&group).Error)
+
+	legs := []types.Order{
+		{UserID: 1, AccountID: 1, SecurityID: 1, Quantity: 2, RemainingParts: ptr(2), Direction: "buy", OrderType: "MARKET", Status: "pending", GroupID: &group.ID, GroupRole: groupRole(orders.GroupRoleEntry)},
+		{UserID: 1, AccountID: 1, SecurityID: 1, Quantity: 2, RemainingParts: ptr(2), Direction: "sell", OrderType: "LIMIT", Status: "pending", GroupID: &group.ID, GroupRole: groupRole(orders.GroupRoleTakeProfit)},
+	}
+	for i := range legs {
+		assert.NoError(t, db.DB.Create(&legs[i]).Error)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/orders/%d/cancel", legs[1].ID), nil)
+	req.Header.Set("X-Test-UserID", "1")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var count int64
+	db.DB.Model(&types.Order{}).Where("group_id = ? AND status = ?", group.ID, "cancelled").Count(&count)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestGetOrderGroup_OwnerOrSupervisorOnly(t *testing.T) {
+	group := types.OrderGroup{Type: orders.GroupTypeOCO, UserID: 2, Status: "active"}
+	assert.NoError(t, db.DB.Create(&group).Error)
+
+	get := func(department string) int {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/orders/groups/%d", group.ID), nil)
+		req.Header.Set("X-Test-UserID", "1")
+		if department != "" {
+			req.Header.Set("X-Test-Department", department)
+		}
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+	assert.Equal(t, 403, get(""))
+	assert.Equal(t, 403, get("AGENT"))
+	assert.Equal(t, 200, get("SUPERVISOR"))
+
+	assert.NoError(t, db.DB.Model(&group).Update("user_id", 1).Error)
+	assert.Equal(t, 200, get(""))
+}
+
+func TestCreateOrderGroup_ChecksAndReservesFunds(t *testing.T) {
+	_ = db.DB.Create(&types.Security{ID: 1, Ticker: "TSLA", Volume: 100, LastPrice: 50.0, Name: "Tesla"}).Error
+
+	entry := map[string]any{
+		"user_id":              1,
+		"account_id":           1,
+		"security_id":          1,
+		"quantity":             2,
+		"contract_size":        1,
+		"direction":            "buy",
+		"limit_price_per_unit": 50,
+	}
+	bracket := map[string]any{"type": "BRACKET", "entry": entry, "take_profit_price": 60, "stop_loss_price": 40}
+
+	stubFunds(t, []dto.Account{{ID: 1, OwnerID: 1, Balance: 50}}, nil)
+	resp := postOrderGroup(t, bracket)
+	assert.Equal(t, 400, resp.StatusCode)
+
+	stubFunds(t, []dto.Account{{ID: 1, OwnerID: 1, Balance: 10000}}, nil)
+	resp = postOrderGroup(t, bracket)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var body struct {
+		Data types.OrderGroupResponse `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Len(t, body.Data.Orders, 3)
+
+	// Gotovinu troši samo entry; izlazni nalozi su prodaja
+	var reservations []types.Hold
+	for _, order := range body.Data.Orders {
+		var hold types.Hold
+		if err := db.DB.Where("reason = ? AND owner_ref = ? AND asset = ?", holds.ReasonOrder, holds.Ref(order.ID), holds.AssetCash).First(&hold).Error; err == nil {
+			reservations = append(reservations, hold)
+			assert.Equal(t, orders.GroupRoleEntry, *order.GroupRole)
+		}
+	}
+	assert.Len(t, reservations, 1)
+	assert.Greater(t, reservations[0].Amount, 100.0)
+}
+
+func TestCreateOrderGroup_OCOReservesCostliestLegOnce(t *testing.T) {
+	_ = db.DB.Create(&types.Security{ID: 1, Ticker: "TSLA", Volume: 100, LastPrice: 50.0, Name: "Tesla"}).Error
+
+	leg := func(limit float64) map[string]any {
+		return map[string]any{
+			"user_id":              1,
+			"account_id":           5,
+			"security_id":          1,
+			"quantity":             2,
+			"contract_size":        1,
+			"direction":            "buy",
+			"limit_price_per_unit": limit,
+		}
+	}
+	oco := map[string]any{"type": "OCO", "legs": []any{leg(40), leg(45)}}
+
+	// Skuplji nalog (2 x 45 uz proviziju) ne staje u stanje računa
+	stubFunds(t, []dto.Account{{ID: 5, OwnerID: 1, Balance: 85}}, nil)
+	resp := postOrderGroup(t, oco)
+	assert.Equal(t, 400, resp.StatusCode)
+
+	// Zbir oba naloga premašuje stanje, ali se izvršava samo jedan
+	stubFunds(t, []dto.Account{{ID: 5, OwnerID: 1, Balance: 150}}, nil)
+	resp = postOrderGroup(t, oco)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var body struct {
+		Data types.OrderGroupResponse `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+	var reservations []types.Hold
+	assert.NoError(t, db.DB.Where("reason = ? AND owner_ref = ? AND asset = ?", holds.ReasonOrder, "group-"+holds.Ref(body.Data.ID), holds.AssetCash).Find(&reservations).Error)
+	assert.Len(t, reservations, 1)
+	assert.Greater(t, reservations[0].Amount, 90.0)
+	assert.Less(t, reservations[0].Amount, 150.0)
+}
+
+func TestCreateOrderGroup_OCORejectsMixedAccounts(t *testing.T) {
+	_ = db.DB.Create(&types.Security{ID: 1, Ticker: "TSLA", Volume: 100, LastPrice: 50.0, Name: "Tesla"}).Error
+
+	leg := func(accountID uint) map[string]any {
+		return map[string]any{
+			"user_id":              1,
+			"account_id":           accountID,
+			"security_id":          1,
+			"quantity":             2,
+			"contract_size":        1,
+			"direction":            "buy",
+			"limit_price_per_unit": 40,
+		}
+	}
+	resp := postOrderGroup(t, map[string]any{"type": "OCO", "legs": []any{leg(1), leg(2)}})
+	assert.Equal(t, 400, resp.StatusCode)
+}
@@ -0,0 +1,119 @@
+package orders
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"banka1.com/clock"
+	"banka1.com/db"
+	"banka1.com/types"
+	"gorm.io/gorm"
+)
+
+// ErrAmendConflict znači da se order promenio (izvršio se deo ili je otkazan)
+// između čitanja i upisa izmene.
+var ErrAmendConflict = errors.New("order je promenjen u međuvremenu, pokušajte ponovo")
+
+// Amendment je izmena količine i cena ordera. Nil polja ostaju nepromenjena.
+type Amendment struct {
+	Quantity          *int
+	LimitPricePerUnit *float64
+	StopPricePerUnit  *float64
+}
+
+// ApplyAmendment proverava izmenu i vraća izmenjen order i podatak da li order
+// gubi vremenski prioritet. Smanjenje količine zadržava prioritet, a promena cene
+// ili povećanje količine ga gubi.
+func ApplyAmendment(order types.Order, amendment Amendment) (types.Order, bool, error) {
+	orderType := strings.ToUpper(order.OrderType)
+	losesPriority := false
+
+	if amendment.LimitPricePerUnit != nil {
+		if bookPrice(order) == nil {
+			return order, false, fmt.Errorf("%s order nema limit cenu", orderType)
+		}
+		if *amendment.LimitPricePerUnit != *order.LimitPricePerUnit {
+			order.LimitPricePerUnit = amendment.LimitPricePerUnit
+			losesPriority = true
+		}
+	}
+
+	if amendment.StopPricePerUnit != nil {
+		if !isPendingTrigger(order) || isTrailing(order) {
+			return order, false, errors.New("stop cena se može menjati samo stop orderu koji još nije aktiviran")
+		}
+		if order.StopPricePerUnit == nil || *amendment.StopPricePerUnit != *order.StopPricePerUnit {
+			order.StopPricePerUnit = amendment.StopPricePerUnit
+			losesPriority = true
+		}
+	}
+
+	if amendment.Quantity != nil {
+		remaining := ptrSafe(order.RemainingParts)
+		filled := order.Quantity - remaining
+		if *amendment.Quantity <= filled {
+			return order, false, fmt.Errorf("količina mora biti veća od već izvršenih %d", filled)
+		}
+		if isIceberg(order) && *order.DisplayQuantity >= *amendment.Quantity {
+			return order, false, errors.New("display_quantity mora biti manji od ukupne količine")
+		}
+
+		newRemaining := *amendment.Quantity - filled
+		if newRemaining > remaining {
+			losesPriority = true
+		}
+		order.Quantity = *amendment.Quantity
+		order.RemainingParts = &newRemaining
+		if isIceberg(order) {
+			visible := min(visibleQuantity(order), newRemaining)
+			order.VisibleRemaining = &visible
+		}
+	}
+
+	order.LastModified = clock.Now().Unix()
+	return order, losesPriority, nil
+}
+
+// SaveAmendment upisuje izmenjen order samo ako se remaining_parts i status nisu
+// promenili od čitanja (before), zajedno sa njegovim holdovima (cash je gotovina
+// koju buy order zadržava), pa zatim vraća order u knjigu i izvršavanje.
+func SaveAmendment(before, amended types.Order, cash float64) error {
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&types.Order{}).
+			Where("id = ? AND status = ? AND NOT is_done AND remaining_parts = ?", before.ID, before.Status, ptrSafe(before.RemainingParts)).
+			Updates(map[string]any{
+				"quantity":             amended.Quantity,
+				"remaining_parts":      ptrSafe(amended.RemainingParts),
+				"visible_remaining":    amended.VisibleRemaining,
+				"limit_price_per_unit": amended.LimitPricePerUnit,
+				"stop_price_per_unit":  amended.StopPricePerUnit,
+				"status":               amended.Status,
+				"approved_by":          amended.ApprovedBy,
+				"approval_rule_id":     amended.ApprovalRuleID,
+				"priority_time":        amended.PriorityTime,
+				"last_modified":        amended.LastModified,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrAmendConflict
+		}
+		return PlaceOrderHolds(tx, amended, cash)
+	})
+	if err != nil {
+		return err
+	}
+
+	RemoveFromBook(before)
+	RemoveTrigger(before)
+	fmt.Printf("Order %d izmenjen: quantity=%d remaining=%d status=%s\n", amended.ID, amended.Quantity, ptrSafe(amended.RemainingParts), amended.Status)
+
+	if amended.Status == "approved" {
+		StartOrder(amended)
+	} else if strings.ToLower(before.Direction) == "sell" {
+		_ = UpdateAvailableVolume(before.SecurityID)
+	}
+	return nil
+}
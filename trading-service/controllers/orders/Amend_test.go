@@ -0,0 +1,66 @@
+package orders
+
+import (
+	"testing"
+
+	"banka1.com/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func limitOrder(quantity, remaining int, limit float64) types.Order {
+	return types.Order{ID: 1, Direction: "buy", OrderType: "LIMIT", Status: "approved", Quantity: quantity,
+		RemainingParts: ptr(remaining), LimitPricePerUnit: fptr(limit), PriorityTime: 42}
+}
+
+func TestApplyAmendment_DecreaseKeepsPriority(t *testing.T) {
+	amended, losesPriority, err := ApplyAmendment(limitOrder(10, 8, 100), Amendment{Quantity: ptr(6)})
+	assert.NoError(t, err)
+	assert.False(t, losesPriority)
+	assert.Equal(t, 6, amended.Quantity)
+	assert.Equal(t, 4, *amended.RemainingParts, "2 su već izvršena")
+}
+
+func TestApplyAmendment_IncreaseOrPriceLosesPriority(t *testing.T) {
+	_, losesPriority, err := ApplyAmendment(limitOrder(10, 8, 100), Amendment{Quantity: ptr(12)})
+	assert.NoError(t, err)
+	assert.True(t, losesPriority)
+
+	_, losesPriority, err = ApplyAmendment(limitOrder(10, 8, 100), Amendment{LimitPricePerUnit: fptr(101)})
+	assert.NoError(t, err)
+	assert.True(t, losesPriority)
+
+	// Ista cena nije promena
+	_, losesPriority, err = ApplyAmendment(limitOrder(10, 8, 100), Amendment{LimitPricePerUnit: fptr(100), Quantity: ptr(9)})
+	assert.NoError(t, err)
+	assert.False(t, losesPriority)
+}
+
+func TestApplyAmendment_Rejects(t *testing.T) {
+	_, _, err := ApplyAmendment(limitOrder(10, 8, 100), Amendment{Quantity: ptr(2)})
+	assert.Error(t, err, "ne može ispod izvršene količine")
+
+	market := types.Order{OrderType: "MARKET", Direction: "buy", Quantity: 5, RemainingParts: ptr(5)}
+	_, _, err = ApplyAmendment(market, Amendment{LimitPricePerUnit: fptr(10)})
+	assert.Error(t, err)
+
+	_, _, err = ApplyAmendment(limitOrder(10, 10, 100), Amendment{StopPricePerUnit: fptr(90)})
+	assert.Error(t, err, "LIMIT nema stop cenu")
+
+	stop := types.Order{OrderType: "STOP", Direction: "sell", Quantity: 5, RemainingParts: ptr(5), StopPricePerUnit: fptr(90)}
+	amended, losesPriority, err := ApplyAmendment(stop, Amendment{StopPricePerUnit: fptr(85)})
+	assert.NoError(t, err)
+	assert.True(t, losesPriority)
+	assert.Equal(t, 85.0, *amended.StopPricePerUnit)
+}
+
+func TestApplyAmendment_IcebergVisibleShrinks(t *testing.T) {
+	order := limitOrder(10, 10, 100)
+	MakeIceberg(&order, 4)
+
+	amended, _, err := ApplyAmendment(order, Amendment{Quantity: ptr(5)})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, *amended.VisibleRemaining)
+
+	_, _, err = ApplyAmendment(order, Amendment{Quantity: ptr(4)})
+	assert.Error(t, err)
+}
@@ -0,0 +1,115 @@
+package orders
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"banka1.com/db"
+	"banka1.com/types"
+)
+
+const (
+	ApprovalApprove    = "approve"
+	ApprovalSupervisor = "supervisor"
+	ApprovalReject     = "reject"
+)
+
+// ApprovalInput su podaci o nalogu agenta na osnovu kojih politika odobravanja bira pravilo.
+type ApprovalInput struct {
+	Actuary    types.Actuary
+	Security   types.Security
+	Notional   float64
+	Margin     bool
+	AfterHours bool
+}
+
+// exceedsLimit proverava da li nalog prelazi preostali dnevni limit agenta.
+func (in ApprovalInput) exceedsLimit() bool {
+	return in.Actuary.UsedLimit+in.Notional > in.Actuary.LimitAmount
+}
+
+// ApprovalNotional procenjuje vrednost naloga za politiku odobravanja: po limit
+// ceni, pa po stop ceni, a za nalog bez cene po poslednjoj ceni hartije.
+func ApprovalNotional(order types.Order, security types.Security) float64 {
+	price := security.LastPrice
+	switch {
+	case order.LimitPricePerUnit != nil:
+		price = *order.LimitPricePerUnit
+	case order.StopPricePerUnit != nil:
+		price = *order.StopPricePerUnit
+	}
+	return price * float64(order.Quantity*SecurityContractSize(security))
+}
+
+// EvaluateApproval vraća akciju (approve, supervisor ili reject) i pravilo koje ju
+// je odredilo. Ako nijedno pravilo ne odgovara, nalog čeka supervizora kada agent
+// ima NeedApproval ili bi prešao limit, a inače se odobrava; pravilo je tada nil.
+func EvaluateApproval(in ApprovalInput, now time.Time) (string, *types.ApprovalRule) {
+	var rules []types.ApprovalRule
+	if err := db.DB.Where("enabled = ?", true).Order("priority").Order("id").Find(&rules).Error; err != nil {
+		fmt.Printf("Greska pri citanju pravila odobravanja: %v\n", err)
+		return ApprovalSupervisor, nil
+	}
+
+	for i := range rules {
+		if ruleMatches(rules[i], in, now) {
+			return rules[i].Action, &rules[i]
+		}
+	}
+
+	if in.Actuary.NeedApproval || in.exceedsLimit() {
+		return ApprovalSupervisor, nil
+	}
+	return ApprovalApprove, nil
+}
+
+func ruleMatches(rule types.ApprovalRule, in ApprovalInput, now time.Time) bool {
+	if rule.NeedApproval != nil && *rule.NeedApproval != in.Actuary.NeedApproval {
+		return false
+	}
+	if rule.ExceedsLimit != nil && *rule.ExceedsLimit != in.exceedsLimit() {
+		return false
+	}
+	if rule.MinNotional != nil && in.Notional < *rule.MinNotional {
+		return false
+	}
+	if rule.Margin != nil && *rule.Margin != in.Margin {
+		return false
+	}
+	if rule.AfterHours != nil && *rule.AfterHours != in.AfterHours {
+		return false
+	}
+	if strings.TrimSpace(rule.InstrumentTypes) != "" && !instrumentListed(rule.InstrumentTypes, in.Security.Type) {
+		return false
+	}
+	if rule.SettlementWithinDays != nil {
+		days, ok := DaysToSettlement(in.Security, now)
+		if !ok || days > *rule.SettlementWithinDays {
+			return false
+		}
+	}
+	return true
+}
+
+func instrumentListed(list, instrumentType string) bool {
+	for _, item := range strings.Split(list, ",") {
+		if strings.EqualFold(strings.TrimSpace(item), strings.TrimSpace(instrumentType)) {
+			return true
+		}
+	}
+	return false
+}
+
+// DaysToSettlement vraća broj dana do datuma izmirenja hartije; hartije bez
+// datuma izmirenja (akcije, forex) ga nemaju.
+func DaysToSettlement(security types.Security, now time.Time) (int, bool) {
+	if security.SettlementDate == nil {
+		return 0, false
+	}
+	parsed, err := time.Parse("2006-01-02", *security.SettlementDate)
+	if err != nil {
+		return 0, false
+	}
+	return int(parsed.Sub(now.Truncate(24*time.Hour)).Hours() / 24), true
+}
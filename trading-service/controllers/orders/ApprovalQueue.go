@@ -0,0 +1,152 @@
+package orders
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"banka1.com/clock"
+	"banka1.com/db"
+	"banka1.com/types"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultApprovalTimeout je rok u kome supervizor treba da obradi nalog na čekanju.
+	DefaultApprovalTimeout = 24 * time.Hour
+
+	ApprovalTimeoutEscalate = "escalate"
+	ApprovalTimeoutDecline  = "decline"
+)
+
+// ApprovalTimeout vraća rok za obradu naloga na čekanju iz APPROVAL_TIMEOUT
+// (npr. "4h"), podrazumevano 24 sata.
+func ApprovalTimeout() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("APPROVAL_TIMEOUT")); err == nil && d > 0 {
+		return d
+	}
+	return DefaultApprovalTimeout
+}
+
+// ApprovalTimeoutAction vraća šta se radi sa nalogom koji niko nije obradio na
+// vreme iz APPROVAL_TIMEOUT_ACTION: escalate (podrazumevano) ili decline.
+func ApprovalTimeoutAction() string {
+	if strings.ToLower(strings.TrimSpace(os.Getenv("APPROVAL_TIMEOUT_ACTION"))) == ApprovalTimeoutDecline {
+		return ApprovalTimeoutDecline
+	}
+	return ApprovalTimeoutEscalate
+}
+
+// PendingSince vraća od kada nalozi čekaju odobrenje: vreme poslednjeg created ili
+// amended događaja, a za naloge bez žurnala vreme poslednje izmene.
+func PendingSince(pending []types.Order) map[uint]time.Time {
+	since := make(map[uint]time.Time, len(pending))
+	if len(pending) == 0 {
+		return since
+	}
+	ids := make([]uint, len(pending))
+	for i, order := range pending {
+		ids[i] = order.ID
+		since[order.ID] = time.Unix(order.LastModified, 0)
+	}
+
+	var events []types.OrderEvent
+	if err := db.DB.Where("order_id IN ? AND type IN ?", ids, []string{EventCreated, EventAmended}).
+		Find(&events).Error; err != nil {
+		fmt.Printf("Greska pri citanju dogadjaja ordera na cekanju: %v\n", err)
+		return since
+	}
+	seen := make(map[uint]bool, len(events))
+	for _, event := range events {
+		if !seen[event.OrderID] || event.CreatedAt.After(since[event.OrderID]) {
+			since[event.OrderID] = event.CreatedAt
+			seen[event.OrderID] = true
+		}
+	}
+	return since
+}
+
+// DeclinePending odbija nalog na čekanju i beleži ko ga je odbio i zašto.
+func DeclinePending(order types.Order, declinedBy *uint, reason string) error {
+	updates := map[string]any{
+		"status":        "declined",
+		"approved_by":   declinedBy,
+		"last_modified": clock.Now().Unix(),
+	}
+	if reason != "" {
+		updates["decline_reason"] = reason
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&types.Order{}).Where("id = ? AND status = ?", order.ID, "pending").Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("order %d nije na cekanju", order.ID)
+		}
+		return releaseHolds(tx, order.ID)
+	})
+	if err != nil {
+		return err
+	}
+	order.Status = "declined"
+	RecordStatus(order, EventDeclined, declinedBy, reason)
+	return nil
+}
+
+// HandleStaleApprovals obrađuje naloge koji čekaju odobrenje duže od ApprovalTimeout:
+// eskalira ih (jednom, beleži se u žurnalu) ili ih automatski odbija, zavisno od
+// ApprovalTimeoutAction. OCO/bracket grupa se odbija cela. Vraća broj obrađenih naloga.
+func HandleStaleApprovals() int {
+	var pending []types.Order
+	if err := db.DB.Where("status = ? AND NOT is_done", "pending").Find(&pending).Error; err != nil {
+		fmt.Printf("Greska pri dohvatanju ordera na cekanju: %v\n", err)
+		return 0
+	}
+
+	timeout := ApprovalTimeout()
+	action := ApprovalTimeoutAction()
+	now := clock.Now()
+	since := PendingSince(pending)
+	declinedGroups := map[uint]bool{}
+	handled := 0
+
+	for _, order := range pending {
+		if now.Sub(since[order.ID]) < timeout {
+			continue
+		}
+		note := fmt.Sprintf("nije obradjen u roku od %s", timeout)
+
+		if action == ApprovalTimeoutDecline {
+			reason := "Automatski odbijen: " + note
+			var err error
+			switch {
+			case order.GroupID != nil && declinedGroups[*order.GroupID]:
+				continue
+			case order.GroupID != nil:
+				declinedGroups[*order.GroupID] = true
+				err = DeclineGroup(*order.GroupID, nil, reason)
+			default:
+				err = DeclinePending(order, nil, reason)
+			}
+			if err != nil {
+				fmt.Printf("Greska pri automatskom odbijanju ordera %d: %v\n", order.ID, err)
+				continue
+			}
+			handled++
+			continue
+		}
+
+		if order.EscalatedAt != nil {
+			continue
+		}
+		if err := db.DB.Model(&order).Update("escalated_at", now).Error; err != nil {
+			fmt.Printf("Greska pri eskalaciji ordera %d: %v\n", order.ID, err)
+			continue
+		}
+		RecordStatus(order, EventEscalated, nil, note)
+		handled++
+	}
+	return handled
+}
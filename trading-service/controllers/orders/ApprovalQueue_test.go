@@ -0,0 +1,68 @@
+package orders
+
+import (
+	"testing"
+	"time"
+
+	"banka1.com/clock"
+	"banka1.com/db"
+	"banka1.com/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleStaleApprovals_EscalatesOnce(t *testing.T) {
+	assert.NoError(t, db.InitTestDatabase())
+	sim := clock.NewSimulated(time.Date(2025, 6, 16, 12, 0, 0, 0, time.UTC), 0)
+	defer clock.Use(sim)()
+	t.Setenv("APPROVAL_TIMEOUT", "1h")
+
+	order := types.Order{UserID: 1, AccountID: 7, SecurityID: 1, OrderType: "MARKET", Direction: "buy", Quantity: 2, RemainingParts: ptr(2), Status: "pending"}
+	assert.NoError(t, db.DB.Create(&order).Error)
+	assert.NoError(t, db.DB.Create(&types.OrderEvent{OrderID: order.ID, Type: EventCreated, Status: "pending", CreatedAt: sim.Now()}).Error)
+	t.Cleanup(func() {
+		db.DB.Delete(&types.Order{}, order.ID)
+		db.DB.Where("order_id = ?", order.ID).Delete(&types.OrderEvent{})
+	})
+
+	assert.Equal(t, 0, HandleStaleApprovals())
+
+	sim.Advance(61 * time.Minute)
+	assert.Equal(t, 1, HandleStaleApprovals())
+	assert.Equal(t, 0, HandleStaleApprovals())
+
+	assert.NoError(t, db.DB.First(&order, order.ID).Error)
+	assert.Equal(t, "pending", order.Status)
+	assert.NotNil(t, order.EscalatedAt)
+	var events int64
+	db.DB.Model(&types.OrderEvent{}).Where("order_id = ? AND type = ?", order.ID, EventEscalated).Count(&events)
+	assert.Equal(t, int64(1), events)
+}
+
+func TestHandleStaleApprovals_DeclinesWithReason(t *testing.T) {
+	assert.NoError(t, db.InitTestDatabase())
+	sim := clock.NewSimulated(time.Date(2025, 6, 16, 12, 0, 0, 0, time.UTC), 0)
+	defer clock.Use(sim)()
+	t.Setenv("APPROVAL_TIMEOUT", "30m")
+	t.Setenv("APPROVAL_TIMEOUT_ACTION", "decline")
+
+	order := types.Order{UserID: 1, AccountID: 7, SecurityID: 1, OrderType: "MARKET", Direction: "buy", Quantity: 2, RemainingParts: ptr(2), Status: "pending"}
+	assert.NoError(t, db.DB.Create(&order).Error)
+	assert.NoError(t, db.DB.Create(&types.OrderEvent{OrderID: order.ID, Type: EventCreated, Status: "pending", CreatedAt: sim.Now()}).Error)
+	t.Cleanup(func() {
+		db.DB.Delete(&types.Order{}, order.ID)
+		db.DB.Where("order_id = ?", order.ID).Delete(&types.OrderEvent{})
+	})
+
+	// Izmena naloga ponovo pokreće rok
+	sim.Advance(20 * time.Minute)
+	assert.NoError(t, db.DB.Create(&types.OrderEvent{OrderID: order.ID, Type: EventAmended, Status: "pending", CreatedAt: sim.Now()}).Error)
+	sim.Advance(20 * time.Minute)
+	assert.Equal(t, 0, HandleStaleApprovals())
+
+	sim.Advance(15 * time.Minute)
+	assert.Equal(t, 1, HandleStaleApprovals())
+	assert.NoError(t, db.DB.First(&order, order.ID).Error)
+	assert.Equal(t, "declined", order.Status)
+	assert.Nil(t, order.ApprovedBy)
+	assert.Contains(t, *order.DeclineReason, "30m0s")
+}
@@ -0,0 +1,89 @@
+package orders
+
+import (
+	"testing"
+	"time"
+
+	"banka1.com/db"
+	"banka1.com/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func bptr(b bool) *bool { return &b }
+
+func addApprovalRule(t *testing.T, rule types.ApprovalRule) types.ApprovalRule {
+	rule.Enabled = true
+	assert.NoError(t, db.DB.Create(&rule).Error)
+	t.Cleanup(func() {
+		db.DB.Delete(&types.ApprovalRule{}, rule.ID)
+	})
+	return rule
+}
+
+func TestEvaluateApproval_DefaultsToLimitAndNeedApproval(t *testing.T) {
+	assert.NoError(t, db.InitTestDatabase())
+	now := time.Date(2025, 6, 16, 12, 0, 0, 0, time.UTC)
+	in := ApprovalInput{
+		Actuary:  types.Actuary{LimitAmount: 1000, UsedLimit: 400},
+		Security: types.Security{Type: "Stock", LastPrice: 100},
+		Notional: 500,
+	}
+
+	action, rule := EvaluateApproval(in, now)
+	assert.Equal(t, ApprovalApprove, action)
+	assert.Nil(t, rule)
+
+	in.Notional = 700
+	action, _ = EvaluateApproval(in, now)
+	assert.Equal(t, ApprovalSupervisor, action)
+
+	in.Notional = 500
+	in.Actuary.NeedApproval = true
+	action, rule = EvaluateApproval(in, now)
+	assert.Equal(t, ApprovalSupervisor, action)
+	assert.Nil(t, rule)
+}
+
+func TestEvaluateApproval_FirstMatchingRuleWins(t *testing.T) {
+	assert.NoError(t, db.InitTestDatabase())
+	now := time.Date(2025, 6, 16, 12, 0, 0, 0, time.UTC)
+	settlement := "2025-06-20"
+	future := types.Security{Type: "Future", LastPrice: 100, SettlementDate: &settlement}
+
+	reject := addApprovalRule(t, types.ApprovalRule{Name: "Futures pred izmirenje", Priority: 10, Action: ApprovalReject, InstrumentTypes: "Future, Option", SettlementWithinDays: ptr(3)})
+	margin := addApprovalRule(t, types.ApprovalRule{Name: "Margin", Priority: 20, Action: ApprovalSupervisor, Margin: bptr(true)})
+	big := addApprovalRule(t, types.ApprovalRule{Name: "Veliki nalozi", Priority: 30, Action: ApprovalSupervisor, MinNotional: fptr(10000)})
+	disabled := types.ApprovalRule{Name: "Isključeno", Priority: 1, Action: ApprovalReject}
+	assert.NoError(t, db.DB.Create(&disabled).Error)
+	assert.NoError(t, db.DB.Model(&disabled).Update("enabled", false).Error)
+	t.Cleanup(func() { db.DB.Delete(&types.ApprovalRule{}, disabled.ID) })
+
+	in := ApprovalInput{Actuary: types.Actuary{LimitAmount: 1e6}, Security: future, Notional: 500}
+
+	// Do izmirenja su ostala 4 dana
+	action, rule := EvaluateApproval(in, now)
+	assert.Equal(t, ApprovalApprove, action)
+	assert.Nil(t, rule)
+
+	action, rule = EvaluateApproval(in, now.Add(24*time.Hour))
+	assert.Equal(t, ApprovalReject, action)
+	assert.Equal(t, reject.ID, rule.ID)
+
+	in.Security = types.Security{Type: "Stock", LastPrice: 100}
+	in.Margin = true
+	in.Notional = 20000
+	action, rule = EvaluateApproval(in, now)
+	assert.Equal(t, ApprovalSupervisor, action)
+	assert.Equal(t, margin.ID, rule.ID)
+
+	in.Margin = false
+	_, rule = EvaluateApproval(in, now)
+	assert.Equal(t, big.ID, rule.ID)
+}
+
+func TestApprovalNotional_UsesOrderPrice(t *testing.T) {
+	security := types.Security{Type: "Stock", LastPrice: 100}
+	assert.Equal(t, 300.0, ApprovalNotional(types.Order{Quantity: 3}, security))
+	assert.Equal(t, 150.0, ApprovalNotional(types.Order{Quantity: 3, StopPricePerUnit: fptr(50)}, security))
+	assert.Equal(t, 60.0, ApprovalNotional(types.Order{Quantity: 3, LimitPricePerUnit: fptr(20), StopPricePerUnit: fptr(50)}, security))
+}
@@ -0,0 +1,367 @@
+package orders
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"banka1.com/broker"
+	"banka1.com/clock"
+	"banka1.com/db"
+	"banka1.com/dto"
+	"banka1.com/exchanges"
+	"banka1.com/types"
+	"gorm.io/gorm"
+)
+
+var ErrAuctionDone = errors.New("aukcija je već izvršena")
+
+// inCallPhase proverava da li se orderi hartije trenutno samo prikupljaju za
+// aukciju otvaranja ili zatvaranja. Faza traje dok aukcija sesije nije izvršena.
+func inCallPhase(securityID uint, now time.Time) bool {
+	exchange, err := exchanges.ForSecurity(securityID)
+	if err != nil || !exchange.AuctionMode {
+		return false
+	}
+	phase, session, _, err := exchanges.AuctionWindow(*exchange, now)
+	if err != nil {
+		fmt.Printf("Greska pri odredjivanju aukcije berze %s: %v\n", exchange.MicCode, err)
+		return false
+	}
+	if phase == "" {
+		return false
+	}
+	var count int64
+	db.DB.Model(&types.Auction{}).
+		Where("security_id = ? AND session = ? AND kind = ?", securityID, session, phase).
+		Count(&count)
+	return count == 0
+}
+
+// RunDueAuctions izvršava aukcije čiji je trenutak nastupio na berzama u
+// aukcijskom režimu i vraća broj izvršenih aukcija. Poziva se iz cron-a.
+func RunDueAuctions() int {
+	var auctionExchanges []types.Exchange
+	if err := db.DB.Where("auction_mode = ?", true).Find(&auctionExchanges).Error; err != nil {
+		fmt.Printf("Greska pri citanju berzi u aukcijskom rezimu: %v\n", err)
+		return 0
+	}
+
+	now := clock.Now()
+	count := 0
+	for _, exchange := range auctionExchanges {
+		phase, session, uncrossAt, err := exchanges.AuctionWindow(exchange, now)
+		if err != nil {
+			fmt.Printf("Greska pri odredjivanju aukcije berze %s: %v\n", exchange.MicCode, err)
+			continue
+		}
+		if phase == "" || now.Before(uncrossAt) {
+			continue
+		}
+
+		var securityIDs []uint
+		db.DB.Model(&types.Security{}).
+			Where("ticker IN (?)", db.DB.Model(&types.Listing{}).Select("ticker").Where("exchange_id = ?", exchange.ID)).
+			Pluck("id", &securityIDs)
+
+		for _, securityID := range securityIDs {
+			if ActiveHalt(securityID) != nil {
+				continue
+			}
+			auction, err := RunAuction(exchange.ID, securityID, session, phase)
+			if errors.Is(err, ErrAuctionDone) {
+				continue
+			}
+			if err != nil {
+				fmt.Printf("Greska pri aukciji hartije %d: %v\n", securityID, err)
+				continue
+			}
+			fmt.Printf("Aukcija %s hartije %d: %d @ %.2f\n", phase, securityID, auction.Volume, auction.Price)
+			count++
+		}
+	}
+	return count
+}
+
+// auctionCandidate je order prikupljen za aukciju sa limitom (nil za ordere bez limita).
+type auctionCandidate struct {
+	order types.Order
+	limit *float64
+}
+
+// auctionOrders prikuplja ordere jedne strane knjige koji učestvuju u aukciji,
+// redosledom izvršenja: prvo orderi bez limita, pa po limitu, pa po vremenu.
+// AON orderi ne učestvuju, jer aukcija ne garantuje izvršenje cele količine.
+func auctionOrders(tx *gorm.DB, securityID uint, direction string) ([]auctionCandidate, error) {
+	var rows []types.Order
+	if err := tx.Where("security_id = ? AND lower(direction) = ? AND status = 'approved' AND NOT is_done AND account_id <> 0", securityID, direction).
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	candidates := []auctionCandidate{}
+	for _, order := range rows {
+		if !isResting(order) || isAllOrNone(order) {
+			continue
+		}
+		candidates = append(candidates, auctionCandidate{order: order, limit: bookPrice(order)})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if (a.limit == nil) != (b.limit == nil) {
+			return a.limit == nil
+		}
+		if a.limit != nil && *a.limit != *b.limit {
+			if direction == "buy" {
+				return *a.limit > *b.limit
+			}
+			return *a.limit < *b.limit
+		}
+		return orderPriority(a.order) < orderPriority(b.order)
+	})
+	return candidates, nil
+}
+
+// auctionCrosses proverava da li order učestvuje u aukciji po ceni price.
+func auctionCrosses(c auctionCandidate, direction string, price float64) bool {
+	if c.limit == nil {
+		return true
+	}
+	if direction == "buy" {
+		return *c.limit >= price
+	}
+	return *c.limit <= price
+}
+
+func auctionVolume(candidates []auctionCandidate, direction string, price float64) int {
+	total := 0
+	for _, c := range candidates {
+		if auctionCrosses(c, direction, price) {
+			total += ptrSafe(c.order.RemainingParts)
+		}
+	}
+	return total
+}
+
+// equilibriumPrice bira cenu aukcije: najveća izvršena količina, zatim najmanji
+// neizvršeni višak, zatim cena najbliža referentnoj. Kandidati su limiti iz knjige;
+// ako limita nema, koristi se referentna cena. Vraća 0 ako se ponude ne ukrštaju.
+func equilibriumPrice(buys, sells []auctionCandidate, reference float64, limits priceLimits) (float64, int) {
+	candidates := []float64{}
+	for _, side := range [][]auctionCandidate{buys, sells} {
+		for _, c := range side {
+			if c.limit != nil {
+				candidates = append(candidates, *c.limit)
+			}
+		}
+	}
+	if len(candidates) == 0 && reference > 0 {
+		candidates = append(candidates, reference)
+	}
+
+	bestPrice, bestVolume, bestImbalance := 0.0, 0, 0
+	for _, price := range candidates {
+		if !limits.allows(price) {
+			continue
+		}
+		demand := auctionVolume(buys, "buy", price)
+		supply := auctionVolume(sells, "sell", price)
+		volume := min(demand, supply)
+		if volume == 0 {
+			continue
+		}
+		imbalance := demand - supply
+		if imbalance < 0 {
+			imbalance = -imbalance
+		}
+
+		better := volume > bestVolume ||
+			(volume == bestVolume && imbalance < bestImbalance) ||
+			(volume == bestVolume && imbalance == bestImbalance && math.Abs(price-reference) < math.Abs(bestPrice-reference))
+		if better {
+			bestPrice, bestVolume, bestImbalance = price, volume, imbalance
+		}
+	}
+	return bestPrice, bestVolume
+}
+
+// RunAuction ukršta prikupljene ordere hartije po jedinstvenoj ceni i beleži
+// rezultat aukcije. Rezultat se beleži i kada se ponude ne ukrštaju, da bi se
+// kontinualno trgovanje nastavilo.
+func RunAuction(exchangeID, securityID uint, session, kind string) (types.Auction, error) {
+	lock := getLock(securityID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	auction := types.Auction{ExchangeID: exchangeID, SecurityID: securityID, Session: session, Kind: kind}
+	var existing int64
+	db.DB.Model(&types.Auction{}).
+		Where("security_id = ? AND session = ? AND kind = ?", securityID, session, kind).
+		Count(&existing)
+	if existing > 0 {
+		return auction, ErrAuctionDone
+	}
+
+	note := "aukcija otvaranja"
+	if kind == exchanges.AuctionClosing {
+		note = "aukcija zatvaranja"
+	}
+
+	sent := []dto.OrderTransactionInitiationDTO{}
+	sentFor := []uint{}
+	touched := []uint{}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		buys, err := auctionOrders(tx, securityID, "buy")
+		if err != nil {
+			return err
+		}
+		sells, err := auctionOrders(tx, securityID, "sell")
+		if err != nil {
+			return err
+		}
+
+		price, volume := equilibriumPrice(buys, sells, referencePrice(securityID, tx), bandFor(tx, securityID))
+		auction.UncrossedAt = clock.Now()
+		if volume > 0 {
+			auction.Price = price
+			for i := range buys {
+				buy := &buys[i].order
+				if !auctionCrosses(buys[i], "buy", price) {
+					break
+				}
+				for j := range sells {
+					sell := &sells[j].order
+					if ptrSafe(buy.RemainingParts) == 0 {
+						break
+					}
+					if ptrSafe(sell.RemainingParts) == 0 || sell.UserID == buy.UserID {
+						continue
+					}
+					if !auctionCrosses(sells[j], "sell", price) {
+						break
+					}
+
+					quantity := min(ptrSafe(buy.RemainingParts), ptrSafe(sell.RemainingParts))
+					leg, err := auctionFill(tx, buy, sell, quantity, price, note)
+					if err != nil {
+						return err
+					}
+					sent = append(sent, leg)
+					sentFor = append(sentFor, buy.ID)
+					touched = append(touched, buy.ID, sell.ID)
+					auction.Volume += quantity
+					auction.Trades++
+				}
+			}
+		}
+
+		if err := tx.Create(&auction).Error; err != nil {
+			return err
+		}
+		return UpdateAvailableVolumeTx(tx, securityID)
+	})
+	if err != nil {
+		for i, leg := range sent {
+			compensateLegs(sentFor[i], []dto.OrderTransactionInitiationDTO{leg}, "rollback aukcije: "+err.Error())
+		}
+		return auction, err
+	}
+
+	if len(touched) > 0 {
+		syncBook(securityID, touched...)
+		onGroupFill(touched...)
+	}
+	wakeBook(securityID)
+	return auction, nil
+}
+
+// auctionFill izvršava jedan par ordera aukcije. Proviziju plaća kupac, jer u
+// aukciji nema ordera koji je uzeo likvidnost.
+func auctionFill(tx *gorm.DB, buy, sell *types.Order, quantity int, price float64, note string) (dto.OrderTransactionInitiationDTO, error) {
+	total := Notional(*buy, price, quantity)
+	fee, scheduleID := FeeFor(tx, *buy, total)
+
+	txn := types.Transaction{
+		OrderID:       buy.ID,
+		BuyerID:       buy.UserID,
+		SellerID:      sell.UserID,
+		SecurityID:    buy.SecurityID,
+		Quantity:      quantity,
+		PricePerUnit:  price,
+		TotalPrice:    total,
+		Fee:           fee,
+		FeeScheduleID: scheduleID,
+	}
+	if err := tx.Create(&txn).Error; err != nil {
+		return dto.OrderTransactionInitiationDTO{}, fmt.Errorf("kreiranje transakcije: %w", err)
+	}
+
+	for _, order := range []*types.Order{buy, sell} {
+		visibleBefore := visibleQuantity(*order)
+		*order.RemainingParts -= quantity
+		consumeVisible(order, visibleBefore, min(visibleBefore, quantity))
+		if *order.RemainingParts == 0 {
+			order.IsDone = true
+			order.Status = "done"
+		}
+		if err := tx.Save(order).Error; err != nil {
+			return dto.OrderTransactionInitiationDTO{}, fmt.Errorf("save za order %d: %w", order.ID, err)
+		}
+	}
+
+	if err := updatePortfolio(buy.UserID, buy.SecurityID, quantity, price, tx); err != nil {
+		return dto.OrderTransactionInitiationDTO{}, err
+	}
+	if err := sellFromPortfolio(tx, *sell, quantity, price); err != nil {
+		return dto.OrderTransactionInitiationDTO{}, err
+	}
+
+	if isAgent(buy.UserID) {
+		var actuary types.Actuary
+		if err := tx.Where("user_id = ?", buy.UserID).First(&actuary).Error; err == nil {
+			actuary.UsedLimit += total
+			if err := tx.Save(&actuary).Error; err != nil {
+				return dto.OrderTransactionInitiationDTO{}, fmt.Errorf("save UsedLimit za agenta: %w", err)
+			}
+		}
+	}
+
+	for _, order := range []*types.Order{buy, sell} {
+		if err := settleFill(tx, order.ID, *order.RemainingParts, quantity, price); err != nil {
+			return dto.OrderTransactionInitiationDTO{}, err
+		}
+	}
+
+	initiationDto := dto.OrderTransactionInitiationDTO{
+		Uid:             fmt.Sprintf("AUCTION-%d-%d-%d", buy.ID, sell.ID, time.Now().UnixNano()),
+		SellerAccountId: getSellerAccountID(*buy, *sell),
+		BuyerAccountId:  getBuyerAccountID(*buy, *sell),
+		Amount:          total,
+		Fee:             fee,
+		Direction:       strings.ToLower(buy.Direction),
+	}
+	if err := broker.SendOrderTransactionInit(&initiationDto); err != nil {
+		return initiationDto, fmt.Errorf("slanje OrderTransactionInitiationDTO: %w", err)
+	}
+
+	recordFill(tx, buy.ID, *buy.RemainingParts, quantity, price, &fee, fmt.Sprintf("%s, druga strana order %d", note, sell.ID))
+	recordFill(tx, sell.ID, *sell.RemainingParts, quantity, price, nil, fmt.Sprintf("%s, druga strana order %d", note, buy.ID))
+	return initiationDto, nil
+}
+
+// OfficialClose vraća cenu poslednje aukcije zatvaranja hartije posle since u
+// kojoj je bilo izvršenja; ta cena je zvanična cena zatvaranja.
+func OfficialClose(securityID uint, since time.Time) (float64, bool) {
+	var auction types.Auction
+	err := db.DB.Where("security_id = ? AND kind = ? AND volume > 0 AND uncrossed_at >= ?", securityID, exchanges.AuctionClosing, since).
+		Order("uncrossed_at desc").
+		First(&auction).Error
+	if err != nil {
+		return 0, false
+	}
+	return auction.Price, true
+}
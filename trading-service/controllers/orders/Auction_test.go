@@ -0,0 +1,139 @@
+package orders
+
+import (
+	"testing"
+	"time"
+
+	"banka1.com/clock"
+	"banka1.com/db"
+	"banka1.com/exchanges"
+	"banka1.com/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupAuctionExchange(t *testing.T, security types.Security) types.Exchange {
+	exchange := types.Exchange{Name: "Aukcijska berza", Acronym: "AUC", MicCode: "XAUC", Country: "USA", Currency: "USD",
+		Timezone: "America/New_York", OpenTime: " 09:30", CloseTime: " 16:00", AuctionMode: true}
+	assert.NoError(t, db.DB.Create(&exchange).Error)
+	assert.NoError(t, db.DB.Model(&types.Listing{}).Where("ticker = ?", security.Ticker).Update("exchange_id", exchange.ID).Error)
+	t.Cleanup(func() {
+		db.DB.Where("exchange_id = ?", exchange.ID).Delete(&types.Auction{})
+		db.DB.Delete(&exchange)
+	})
+	return exchange
+}
+
+func TestEquilibriumPrice(t *testing.T) {
+	limit := func(direction string, price float64, quantity int) auctionCandidate {
+		return auctionCandidate{order: types.Order{Direction: direction, RemainingParts: ptr(quantity)}, limit: fptr(price)}
+	}
+	market := func(direction string, quantity int) auctionCandidate {
+		return auctionCandidate{order: types.Order{Direction: direction, RemainingParts: ptr(quantity)}}
+	}
+	open := priceLimits{}
+
+	// Na 101 i 102 se izvršava 5 uz isti višak, bira se cena bliža referentnoj
+	buys := []auctionCandidate{limit("buy", 102, 5), limit("buy", 100, 5)}
+	sells := []auctionCandidate{limit("sell", 99, 4), limit("sell", 101, 4)}
+	price, volume := equilibriumPrice(buys, sells, 100, open)
+	assert.Equal(t, 101.0, price)
+	assert.Equal(t, 5, volume)
+
+	// Samo orderi bez limita se ukrštaju po referentnoj ceni
+	price, volume = equilibriumPrice([]auctionCandidate{market("buy", 3)}, []auctionCandidate{market("sell", 2)}, 100, open)
+	assert.Equal(t, 100.0, price)
+	assert.Equal(t, 2, volume)
+
+	price, volume = equilibriumPrice([]auctionCandidate{limit("buy", 98, 3)}, sells, 100, open)
+	assert.Equal(t, 0, volume)
+	assert.Equal(t, 0.0, price)
+
+	// Cena van opsega se ne može izabrati
+	price, volume = equilibriumPrice(buys, sells, 100, priceLimits{low: 99.5, high: 100.5, banded: true})
+	assert.Equal(t, 100.0, price)
+	assert.Equal(t, 4, volume)
+}
+
+func TestAuction_OpeningUncrossAndClosingPrice(t *testing.T) {
+	security := setupBankMarket(t)
+	exchange := setupAuctionExchange(t, security)
+	// 09:25 u Njujorku, pre-market pred otvaranje
+	sim := clock.NewSimulated(time.Date(2025, 6, 16, 13, 25, 0, 0, time.UTC), 0)
+	defer clock.Use(sim)()
+
+	for _, userID := range []uint{3, 4} {
+		assert.NoError(t, db.DB.Create(&types.Portfolio{UserID: userID, SecurityID: security.ID, Quantity: 10, PurchasePrice: 90}).Error)
+	}
+	place := func(userID uint, direction string, price float64, quantity int) types.Order {
+		order := types.Order{UserID: userID, AccountID: 10 + userID, SecurityID: security.ID, Direction: direction, OrderType: "LIMIT",
+			LimitPricePerUnit: fptr(price), Quantity: quantity, ContractSize: 1, RemainingParts: ptr(quantity), Status: "approved"}
+		assert.NoError(t, db.DB.Create(&order).Error)
+		return order
+	}
+	buyHigh := place(1, "buy", 102, 5)
+	buyLow := place(2, "buy", 100, 5)
+	sellLow := place(3, "sell", 99, 4)
+	sellHigh := place(4, "sell", 101, 4)
+
+	// Tokom prikupljanja orderi se ne izvršavaju kontinualno
+	assert.True(t, inCallPhase(security.ID, sim.Now()))
+	assert.False(t, marketAllows(&buyHigh))
+	assert.Equal(t, 0, RunDueAuctions())
+
+	sim.Advance(5 * time.Minute)
+	assert.Equal(t, 1, RunDueAuctions())
+	assert.Equal(t, 0, RunDueAuctions())
+	assert.False(t, inCallPhase(security.ID, sim.Now()))
+
+	var auction types.Auction
+	assert.NoError(t, db.DB.Where("security_id = ? AND kind = ?", security.ID, exchanges.AuctionOpening).First(&auction).Error)
+	assert.Equal(t, "2025-06-16", auction.Session)
+	assert.Equal(t, 101.0, auction.Price)
+	assert.Equal(t, 5, auction.Volume)
+	assert.Equal(t, 2, auction.Trades)
+
+	var txns []types.Transaction
+	assert.NoError(t, db.DB.Where("security_id = ?", security.ID).Order("id").Find(&txns).Error)
+	if assert.Len(t, txns, 2) {
+		for _, txn := range txns {
+			assert.Equal(t, 101.0, txn.PricePerUnit)
+			assert.Equal(t, buyHigh.ID, txn.OrderID)
+		}
+		assert.Equal(t, 4, txns[0].Quantity)
+		assert.Equal(t, uint(3), txns[0].SellerID)
+		assert.Equal(t, 1, txns[1].Quantity)
+	}
+
+	remaining := func(order types.Order) int {
+		assert.NoError(t, db.DB.First(&order, order.ID).Error)
+		return *order.RemainingParts
+	}
+	assert.Equal(t, 0, remaining(buyHigh))
+	assert.Equal(t, 0, remaining(sellLow))
+	assert.Equal(t, 3, remaining(sellHigh))
+	assert.Equal(t, 5, remaining(buyLow))
+
+	var portfolio types.Portfolio
+	assert.NoError(t, db.DB.Where("user_id = ? AND security_id = ?", 1, security.ID).First(&portfolio).Error)
+	assert.Equal(t, 5, portfolio.Quantity)
+
+	// Pred zatvaranje se ponude ne ukrštaju; aukcija se beleži bez cene
+	sim.Advance(6*time.Hour + 25*time.Minute)
+	assert.True(t, inCallPhase(security.ID, sim.Now()))
+	sim.Advance(5 * time.Minute)
+	assert.Equal(t, 1, RunDueAuctions())
+	_, ok := OfficialClose(security.ID, sim.Now().Add(-24*time.Hour))
+	assert.False(t, ok)
+
+	// Sledećeg dana zatvaranje se ukršta i postaje zvanična cena zatvaranja
+	sim.Advance(24*time.Hour - 5*time.Minute)
+	place(5, "buy", 101, 2)
+	assert.True(t, inCallPhase(security.ID, sim.Now()))
+	sim.Advance(5 * time.Minute)
+	assert.Equal(t, 1, RunDueAuctions())
+	closePrice, ok := OfficialClose(security.ID, sim.Now().Add(-24*time.Hour))
+	assert.True(t, ok)
+	assert.Equal(t, 101.0, closePrice)
+	assert.Equal(t, 1, remaining(sellHigh))
+	assert.Equal(t, exchange.ID, auction.ExchangeID)
+}
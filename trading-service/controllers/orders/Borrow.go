@@ -0,0 +1,410 @@
+package orders
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"banka1.com/clock"
+	"banka1.com/db"
+	"banka1.com/exchanges"
+	"banka1.com/types"
+	"gorm.io/gorm"
+)
+
+const (
+	BorrowStatusActive   = "active"
+	BorrowStatusRecalled = "recalled"
+	BorrowStatusBuyIn    = "buy_in"
+	BorrowStatusClosed   = "closed"
+
+	// DefaultBorrowFeeRate je godišnja naknada za pozajmicu hartija u procentima.
+	DefaultBorrowFeeRate = 3.0
+	// DefaultBorrowRecallGrace je rok za vraćanje opozvane pozajmice pre buy-in-a.
+	DefaultBorrowRecallGrace = 24 * time.Hour
+)
+
+// openBorrowStatuses su statusi pozajmica koje još nisu vraćene.
+var openBorrowStatuses = []string{BorrowStatusActive, BorrowStatusRecalled, BorrowStatusBuyIn}
+
+// BorrowFeeRate vraća godišnju naknadu za pozajmicu iz BORROW_FEE_RATE (procenti),
+// podrazumevano 3%.
+func BorrowFeeRate() float64 {
+	if rate, err := strconv.ParseFloat(os.Getenv("BORROW_FEE_RATE"), 64); err == nil && rate >= 0 {
+		return rate
+	}
+	return DefaultBorrowFeeRate
+}
+
+// BorrowRecallGrace vraća rok za vraćanje opozvane pozajmice iz BORROW_RECALL_GRACE
+// (npr. "24h"), podrazumevano 24 sata.
+func BorrowRecallGrace() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("BORROW_RECALL_GRACE")); err == nil && d > 0 {
+		return d
+	}
+	return DefaultBorrowRecallGrace
+}
+
+// portfolioQuantity vraća poziciju korisnika u hartiji; short pozicija je negativna.
+func portfolioQuantity(tx *gorm.DB, userID, securityID uint) (int, error) {
+	var portfolio types.Portfolio
+	err := tx.Where("user_id = ? AND security_id = ?", userID, securityID).First(&portfolio).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	return portfolio.Quantity, err
+}
+
+// borrowedShares vraća koliko hartija korisnik trenutno duguje banci.
+func borrowedShares(tx *gorm.DB, userID, securityID uint) (int, error) {
+	var borrowed int64
+	err := tx.Model(&types.SecurityBorrow{}).
+		Select("COALESCE(SUM(quantity), 0)").
+		Where("user_id = ? AND security_id = ? AND status IN ?", userID, securityID, openBorrowStatuses).
+		Scan(&borrowed).Error
+	return int(borrowed), err
+}
+
+// lentShares vraća koliko hartija je banka pozajmila korisnicima.
+func lentShares(tx *gorm.DB, securityID uint) (int, error) {
+	var lent int64
+	err := tx.Model(&types.SecurityBorrow{}).
+		Select("COALESCE(SUM(quantity), 0)").
+		Where("security_id = ? AND status IN ?", securityID, openBorrowStatuses).
+		Scan(&lent).Error
+	return int(lent), err
+}
+
+// BankLendable vraća koliko hartija banka može da pozajmi za short prodaju: poziciju
+// umanjenu za sopstvene sell ordere, već pozajmljeno i minimalni inventar.
+func BankLendable(tx *gorm.DB, securityID uint) (int, error) {
+	position, reserved, limit, err := BankInventory(tx, securityID)
+	if err != nil {
+		return 0, err
+	}
+	lent, err := lentShares(tx, securityID)
+	if err != nil {
+		return 0, err
+	}
+	return max(position-reserved-lent-limit.MinPosition, 0), nil
+}
+
+// CanSellOrBorrow proverava da li korisnik može da proda traženu količinu. Za margin
+// ordere manjak u portfoliju može da se pozajmi iz inventara banke (short prodaja).
+// Vraćena količina je i dalje slobodno dostupna količina iz portfolija.
+func CanSellOrBorrow(userID, securityID uint, requestedQty int, margin bool) (bool, int, error) {
+	ok, available, err := CanSell(userID, securityID, requestedQty)
+	if err != nil || ok || !margin {
+		return ok, available, err
+	}
+	lendable, err := BankLendable(db.DB, securityID)
+	if err != nil {
+		return false, available, err
+	}
+	return requestedQty-max(available, 0) <= lendable, available, nil
+}
+
+// sellFromPortfolio skida prodatu količinu iz portfolija prodavca. Kada margin prodaja
+// prelazi poziciju, razlika se pozajmljuje iz inventara banke i pozicija postaje negativna.
+func sellFromPortfolio(tx *gorm.DB, seller types.Order, quantity int, price float64) error {
+	if seller.Margin && seller.UserID != BankUserID {
+		if err := borrowForShort(tx, seller, quantity, price); err != nil {
+			return err
+		}
+	}
+	return updatePortfolio(seller.UserID, seller.SecurityID, -quantity, price, tx)
+}
+
+func borrowForShort(tx *gorm.DB, seller types.Order, quantity int, price float64) error {
+	position, err := portfolioQuantity(tx, seller.UserID, seller.SecurityID)
+	if err != nil {
+		return err
+	}
+	borrowed, err := borrowedShares(tx, seller.UserID, seller.SecurityID)
+	if err != nil {
+		return err
+	}
+	// Pozajmljuje se samo deo nove short pozicije koji nije pokriven postojećim pozajmicama
+	need := max(quantity-position, 0) - borrowed
+	if need <= 0 {
+		return nil
+	}
+
+	lendable, err := BankLendable(tx, seller.SecurityID)
+	if err != nil {
+		return err
+	}
+	if lendable < need {
+		return fmt.Errorf("banka nema dovoljno hartija %d za pozajmicu: potrebno %d, dostupno %d", seller.SecurityID, need, lendable)
+	}
+
+	var borrow types.SecurityBorrow
+	err = tx.Where("order_id = ? AND status = ?", seller.ID, BorrowStatusActive).First(&borrow).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if err == nil {
+		borrow.Borrowed += need
+		borrow.Quantity += need
+	} else {
+		borrow = types.SecurityBorrow{
+			UserID:         seller.UserID,
+			AccountID:      seller.AccountID,
+			SecurityID:     seller.SecurityID,
+			OrderID:        seller.ID,
+			Borrowed:       need,
+			Quantity:       need,
+			Price:          price,
+			FeeRate:        BorrowFeeRate(),
+			AccruedThrough: clock.Now(),
+			Status:         BorrowStatusActive,
+		}
+	}
+	if err := tx.Save(&borrow).Error; err != nil {
+		return fmt.Errorf("save pozajmice za order %d: %w", seller.ID, err)
+	}
+	fmt.Printf("Pozajmica %d: korisnik %d pozajmio %d hartija %d za short prodaju\n", borrow.ID, seller.UserID, need, seller.SecurityID)
+	return nil
+}
+
+// settleBorrows vraća pozajmice posle kupovine tako da nevraćena količina odgovara
+// preostaloj short poziciji. Opozvane pozajmice se vraćaju prve, zatim najstarije.
+func settleBorrows(tx *gorm.DB, userID, securityID uint, position int) error {
+	var borrows []types.SecurityBorrow
+	if err := tx.Where("user_id = ? AND security_id = ? AND status IN ?", userID, securityID, openBorrowStatuses).
+		Order("CASE WHEN status = 'active' THEN 1 ELSE 0 END, id").
+		Find(&borrows).Error; err != nil {
+		return err
+	}
+
+	// Višak pozajmljenog u odnosu na preostalu short poziciju je vraćen banci
+	excess := -max(-position, 0)
+	for _, borrow := range borrows {
+		excess += borrow.Quantity
+	}
+	for i := range borrows {
+		if excess <= 0 {
+			break
+		}
+		borrow := &borrows[i]
+		returned := min(borrow.Quantity, excess)
+		borrow.Quantity -= returned
+		excess -= returned
+		if borrow.Quantity == 0 {
+			now := clock.Now()
+			borrow.Status = BorrowStatusClosed
+			borrow.ClosedAt = &now
+		}
+		if err := tx.Save(borrow).Error; err != nil {
+			return fmt.Errorf("save pozajmice %d: %w", borrow.ID, err)
+		}
+		fmt.Printf("Pozajmica %d: vraćeno %d hartija, preostalo %d\n", borrow.ID, returned, borrow.Quantity)
+	}
+	return nil
+}
+
+// markPrice vraća cenu po kojoj se hartija vrednuje: trenutnu cenu iz listinga, a ako
+// listing ne postoji poslednju cenu hartije.
+func markPrice(tx *gorm.DB, security types.Security) float64 {
+	var listing types.Listing
+	if err := tx.Where("ticker = ?", security.Ticker).First(&listing).Error; err == nil && listing.Price > 0 {
+		return float64(listing.Price)
+	}
+	return security.LastPrice
+}
+
+// AccrueBorrowFees obračunava naknadu za svaki pun dan od poslednjeg obračuna i
+// dodaje je na zaduženje margin naloga korisnika. Vraća broj obrađenih pozajmica.
+func AccrueBorrowFees() int {
+	var borrows []types.SecurityBorrow
+	if err := db.DB.Where("status IN ? AND quantity > 0", openBorrowStatuses).Find(&borrows).Error; err != nil {
+		fmt.Printf("Greska pri citanju pozajmica: %v\n", err)
+		return 0
+	}
+
+	now := clock.Now()
+	accrued := 0
+	for _, borrow := range borrows {
+		days := int(now.Sub(borrow.AccruedThrough) / (24 * time.Hour))
+		if days < 1 {
+			continue
+		}
+		var security types.Security
+		if err := db.DB.First(&security, borrow.SecurityID).Error; err != nil {
+			fmt.Printf("Greska pri citanju hartije %d za pozajmicu %d: %v\n", borrow.SecurityID, borrow.ID, err)
+			continue
+		}
+		value := float64(borrow.Quantity) * markPrice(db.DB, security) * float64(SecurityContractSize(security))
+		fee := value * borrow.FeeRate / 100 / 360 * float64(days)
+
+		err := db.DB.Transaction(func(tx *gorm.DB) error {
+			borrow.AccruedFee += fee
+			borrow.AccruedThrough = borrow.AccruedThrough.Add(time.Duration(days) * 24 * time.Hour)
+			if err := tx.Save(&borrow).Error; err != nil {
+				return err
+			}
+			var account types.MarginAccount
+			err := tx.Where("user_id = ?", borrow.UserID).First(&account).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				account = types.MarginAccount{UserID: borrow.UserID, AccountID: borrow.AccountID, Status: MarginStatusOK}
+			} else if err != nil {
+				return err
+			}
+			account.LoanBalance += fee
+			return tx.Save(&account).Error
+		})
+		if err != nil {
+			fmt.Printf("Greska pri obracunu naknade za pozajmicu %d: %v\n", borrow.ID, err)
+			continue
+		}
+		accrued++
+	}
+	return accrued
+}
+
+// RecallBorrow opoziva pozajmicu; korisnik mora da je vrati do roka, inače se
+// pozicija zatvara prinudnom kupovinom.
+func RecallBorrow(tx *gorm.DB, borrow *types.SecurityBorrow) error {
+	if borrow.Status != BorrowStatusActive {
+		return fmt.Errorf("pozajmica %d nije aktivna (status %s)", borrow.ID, borrow.Status)
+	}
+	deadline := clock.Now().Add(BorrowRecallGrace())
+	borrow.Status = BorrowStatusRecalled
+	borrow.RecallDeadline = &deadline
+	if err := tx.Save(borrow).Error; err != nil {
+		return err
+	}
+	fmt.Printf("Pozajmica %d korisnika %d opozvana, rok za vraćanje %s\n", borrow.ID, borrow.UserID, deadline.Format(time.RFC3339))
+	return nil
+}
+
+// ProcessBorrowRecalls opoziva najnovije pozajmice kada banci pozajmljeno pređe ono
+// što sme da pozajmi, i šalje prinudne kupovine za opozvane pozajmice kojima je istekao
+// rok. Vraća broj poslatih buy-in naloga.
+func ProcessBorrowRecalls() int {
+	recallOverLent()
+
+	var borrows []types.SecurityBorrow
+	if err := db.DB.Where("status IN ? AND quantity > 0", []string{BorrowStatusRecalled, BorrowStatusBuyIn}).Find(&borrows).Error; err != nil {
+		fmt.Printf("Greska pri citanju opozvanih pozajmica: %v\n", err)
+		return 0
+	}
+
+	now := clock.Now()
+	sent := 0
+	for _, borrow := range borrows {
+		if borrow.Status == BorrowStatusRecalled && (borrow.RecallDeadline == nil || now.Before(*borrow.RecallDeadline)) {
+			continue
+		}
+		if borrow.Status == BorrowStatusBuyIn && !buyInFinished(borrow) {
+			continue
+		}
+		if err := buyIn(&borrow); err != nil {
+			fmt.Printf("Greska pri prinudnoj kupovini za pozajmicu %d: %v\n", borrow.ID, err)
+			continue
+		}
+		sent++
+	}
+	return sent
+}
+
+// recallOverLent opoziva pozajmice hartija čiji inventar banke više ne pokriva
+// pozajmljeno (npr. posle prodaje banke ili promene minimalnog inventara).
+func recallOverLent() {
+	var securityIDs []uint
+	if err := db.DB.Model(&types.SecurityBorrow{}).Distinct("security_id").
+		Where("status = ?", BorrowStatusActive).Pluck("security_id", &securityIDs).Error; err != nil {
+		fmt.Printf("Greska pri citanju pozajmica: %v\n", err)
+		return
+	}
+
+	for _, securityID := range securityIDs {
+		position, _, limit, err := BankInventory(db.DB, securityID)
+		if err != nil {
+			continue
+		}
+		lent, err := lentShares(db.DB, securityID)
+		if err != nil {
+			continue
+		}
+		over := lent - max(position-limit.MinPosition, 0)
+		if over <= 0 {
+			continue
+		}
+
+		var active []types.SecurityBorrow
+		db.DB.Where("security_id = ? AND status = ?", securityID, BorrowStatusActive).Order("id desc").Find(&active)
+		for _, borrow := range active {
+			if over <= 0 {
+				break
+			}
+			if err := RecallBorrow(db.DB, &borrow); err != nil {
+				fmt.Printf("Greska pri opozivu pozajmice %d: %v\n", borrow.ID, err)
+				continue
+			}
+			over -= borrow.Quantity
+		}
+	}
+}
+
+// buyInFinished proverava da li je prethodni buy-in nalog završen ili otkazan, pa
+// za preostalu količinu treba poslati novi.
+func buyInFinished(borrow types.SecurityBorrow) bool {
+	if borrow.BuyInOrderID == nil {
+		return true
+	}
+	var order types.Order
+	if err := db.DB.First(&order, *borrow.BuyInOrderID).Error; err != nil {
+		return true
+	}
+	return order.IsDone || order.Status != "approved"
+}
+
+// buyIn šalje MARKET buy order za nevraćenu količinu pozajmice kroz matching engine.
+func buyIn(borrow *types.SecurityBorrow) error {
+	var security types.Security
+	if err := db.DB.First(&security, borrow.SecurityID).Error; err != nil {
+		return err
+	}
+
+	now := clock.Now()
+	quantity := borrow.Quantity
+	order := types.Order{
+		UserID:         borrow.UserID,
+		AccountID:      borrow.AccountID,
+		SecurityID:     borrow.SecurityID,
+		Quantity:       quantity,
+		ContractSize:   SecurityContractSize(security),
+		OrderType:      "MARKET",
+		Direction:      "buy",
+		Status:         "approved",
+		LastModified:   now.Unix(),
+		RemainingParts: &quantity,
+		AfterHours:     exchanges.IsAfterHours(exchanges.StatusForSecurity(borrow.SecurityID, now)),
+		Margin:         true,
+		TimeInForce:    NormalizeTimeInForce(""),
+		PriorityTime:   now.UnixNano(),
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+		if err := holdShares(tx, order, order.Status); err != nil {
+			return err
+		}
+		borrow.Status = BorrowStatusBuyIn
+		borrow.BuyInOrderID = &order.ID
+		return tx.Save(borrow).Error
+	})
+	if err != nil {
+		return err
+	}
+	RecordStatus(order, EventCreated, nil, fmt.Sprintf("prinudni buy-in, pozajmica %d", borrow.ID))
+	fmt.Printf("Pozajmica %d korisnika %d nije vraćena u roku, poslat buy-in order %d za %d hartija\n",
+		borrow.ID, borrow.UserID, order.ID, quantity)
+
+	StartOrder(order)
+	return nil
+}
@@ -0,0 +1,123 @@
+package orders
+
+import (
+	"testing"
+	"time"
+
+	"banka1.com/clock"
+	"banka1.com/db"
+	"banka1.com/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShortSellBorrowFeesAndBuyIn(t *testing.T) {
+	security := setupBankMarket(t)
+	t.Setenv("BORROW_FEE_RATE", "3")
+	t.Setenv("BORROW_RECALL_GRACE", "24h")
+	sim := clock.NewSimulated(time.Date(2025, 6, 16, 12, 0, 0, 0, time.UTC), 0)
+	defer clock.Use(sim)()
+
+	const userID = 40
+	t.Cleanup(func() {
+		db.DB.Where("user_id = ?", userID).Delete(&types.SecurityBorrow{})
+		db.DB.Where("user_id = ?", userID).Delete(&types.MarginAccount{})
+		db.DB.Where("user_id = ?", userID).Delete(&types.Hold{})
+	})
+	assert.NoError(t, db.DB.Create(&types.Portfolio{UserID: BankUserID, SecurityID: security.ID, Quantity: 20, PurchasePrice: 90}).Error)
+
+	// Bez margine nema short prodaje, sa marginom manjak pozajmljuje banka
+	ok, available, err := CanSellOrBorrow(userID, security.ID, 5, false)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 0, available)
+	ok, _, err = CanSellOrBorrow(userID, security.ID, 5, true)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, _, _ = CanSellOrBorrow(userID, security.ID, 21, true)
+	assert.False(t, ok)
+
+	short := types.Order{UserID: userID, AccountID: 80, SecurityID: security.ID, Direction: "sell", OrderType: "MARKET", Quantity: 5, RemainingParts: ptr(5), Status: "approved", Margin: true}
+	assert.NoError(t, db.DB.Create(&short).Error)
+	filled, _ := fillFromBank(&short, db.DB)
+	assert.Equal(t, 5, filled)
+	assert.NoError(t, db.DB.Model(&short).Updates(map[string]any{"is_done": true, "status": "done"}).Error)
+
+	position, err := portfolioQuantity(db.DB, userID, security.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, -5, position)
+
+	var borrow types.SecurityBorrow
+	assert.NoError(t, db.DB.Where("user_id = ?", userID).First(&borrow).Error)
+	assert.Equal(t, 5, borrow.Quantity)
+	assert.Equal(t, short.ID, borrow.OrderID)
+	assert.Equal(t, BorrowStatusActive, borrow.Status)
+
+	// Pozajmljeno više nije na raspolaganju za pozajmicu ni za prodaju banke
+	lendable, err := BankLendable(db.DB, security.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 20, lendable) // 25 u poziciji posle kupovine od prodavca, minus 5 pozajmljenih
+
+	// Priliv od short prodaje je kolateral, a short pozicija ulazi u zahtev održavanja
+	var account types.MarginAccount
+	assert.NoError(t, db.DB.Where("user_id = ?", userID).First(&account).Error)
+	assert.InDelta(t, 495.0, account.CashBalance, 1e-9)
+	_, err = revalueMarginAccount(db.DB, &account)
+	assert.NoError(t, err)
+	assert.InDelta(t, 500.0, account.ShortValue, 1e-9)
+	assert.InDelta(t, -5.0, account.Equity, 1e-9)
+	assert.InDelta(t, 150.0, account.MaintenanceRequirement, 1e-9)
+
+	// Naknada se obračunava samo za pune dane
+	sim.Advance(12 * time.Hour)
+	assert.Equal(t, 0, AccrueBorrowFees())
+	sim.Advance(36 * time.Hour)
+	assert.Equal(t, 1, AccrueBorrowFees())
+	assert.NoError(t, db.DB.First(&borrow, borrow.ID).Error)
+	fee := 5 * 100 * 0.03 / 360 * 2
+	assert.InDelta(t, fee, borrow.AccruedFee, 1e-9)
+	assert.NoError(t, db.DB.First(&account, account.ID).Error)
+	assert.InDelta(t, fee, account.LoanBalance, 1e-9)
+
+	// Opozvana pozajmica se pre roka ne zatvara
+	t.Setenv("MARKET_ACCESS_MODE", MarketAccessInternal)
+	assert.NoError(t, RecallBorrow(db.DB, &borrow))
+	assert.Error(t, RecallBorrow(db.DB, &borrow))
+	sim.Advance(23 * time.Hour)
+	assert.Equal(t, 0, ProcessBorrowRecalls())
+
+	sim.Advance(2 * time.Hour)
+	assert.Equal(t, 1, ProcessBorrowRecalls())
+	assert.NoError(t, db.DB.First(&borrow, borrow.ID).Error)
+	assert.Equal(t, BorrowStatusBuyIn, borrow.Status)
+	var buyIn types.Order
+	assert.NoError(t, db.DB.First(&buyIn, *borrow.BuyInOrderID).Error)
+	assert.Equal(t, "buy", buyIn.Direction)
+	assert.Equal(t, "MARKET", buyIn.OrderType)
+	assert.Equal(t, 5, buyIn.Quantity)
+	assert.True(t, buyIn.Margin)
+
+	// Dok je buy-in u knjizi, novi se ne šalje
+	assert.Equal(t, 0, ProcessBorrowRecalls())
+
+	// Izvršen buy-in zatvara short poziciju i vraća pozajmicu
+	assert.NoError(t, updatePortfolio(userID, security.ID, 5, 100, db.DB))
+	assert.NoError(t, db.DB.Model(&buyIn).Updates(map[string]any{"remaining_parts": 0, "is_done": true, "status": "done"}).Error)
+	assert.NoError(t, settleFill(nil, buyIn.ID, 0, 5, 100))
+
+	assert.NoError(t, db.DB.First(&borrow, borrow.ID).Error)
+	assert.Equal(t, BorrowStatusClosed, borrow.Status)
+	assert.Equal(t, 0, borrow.Quantity)
+	var count int64
+	db.DB.Model(&types.Portfolio{}).Where("user_id = ? AND security_id = ?", userID, security.ID).Count(&count)
+	assert.Zero(t, count)
+
+	// Kupovina se prvo plaća iz priliva short prodaje
+	assert.NoError(t, db.DB.First(&account, account.ID).Error)
+	assert.InDelta(t, 0.0, account.CashBalance, 1e-9)
+	assert.InDelta(t, fee+5*(1-InitialMarginRate), account.LoanBalance, 1e-9)
+	assert.Equal(t, 0, ProcessBorrowRecalls())
+
+	t.Cleanup(func() {
+		db.DB.Where("order_id IN ?", []uint{short.ID, buyIn.ID}).Delete(&types.OrderEvent{})
+	})
+}
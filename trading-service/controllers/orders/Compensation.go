@@ -0,0 +1,105 @@
+package orders
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"banka1.com/broker"
+	"banka1.com/db"
+	"banka1.com/dto"
+	"banka1.com/types"
+)
+
+// DefaultCompensationMaxAttempts je broj slanja kompenzacije posle kog se ona
+// prebacuje u dead-letter stanje.
+const DefaultCompensationMaxAttempts = 10
+
+// CompensationMaxAttempts vraća najveći broj slanja kompenzacije iz
+// COMPENSATION_MAX_ATTEMPTS, podrazumevano DefaultCompensationMaxAttempts.
+func CompensationMaxAttempts() int {
+	if n, err := strconv.Atoi(os.Getenv("COMPENSATION_MAX_ATTEMPTS")); err == nil && n > 0 {
+		return n
+	}
+	return DefaultCompensationMaxAttempts
+}
+
+// compensateLegs poništava plaćanja koja su već poslata banking servisu za
+// izvršenje koje je vraćeno rollback-om. Svaka kompenzacija se beleži van
+// transakcije matchovanja, da bi zapis ostao i kada ona ne prođe. Kompenzacija
+// je izmirena (Sent) tek kada je banking servis potvrdi; ostale ponovo šalje
+// RetryCompensations. Banking servis primenjuje svaki uid najviše jednom, pa
+// ponovno slanje posle izgubljenog odgovora ne vraća novac dvaput.
+func compensateLegs(orderID uint, legs []dto.OrderTransactionInitiationDTO, reason string) {
+	for _, leg := range legs {
+		record := types.OrderCompensation{
+			OrderID:         orderID,
+			Uid:             leg.Uid,
+			BuyerAccountID:  leg.BuyerAccountId,
+			SellerAccountID: leg.SellerAccountId,
+			Amount:          leg.Amount,
+			Fee:             leg.Fee,
+			Reason:          reason,
+		}
+		sendCompensation(&record)
+
+		if err := db.DB.Create(&record).Error; err != nil {
+			fmt.Printf("Greska pri upisu kompenzacije %s za order %d: %v\n", leg.Uid, orderID, err)
+		}
+	}
+}
+
+// sendCompensation šalje kompenzaciju i upisuje ishod u zapis: Sent ako je banking
+// servis potvrdio, inače grešku. Kada potroši sve pokušaje, kompenzacija prelazi
+// u dead-letter stanje i više se ne šalje automatski.
+func sendCompensation(record *types.OrderCompensation) {
+	record.Attempts++
+	err := broker.SendOrderTransactionCompensation(&dto.OrderTransactionCompensationDTO{
+		Uid:             record.Uid,
+		SellerAccountId: record.SellerAccountID,
+		BuyerAccountId:  record.BuyerAccountID,
+		Amount:          record.Amount,
+		Fee:             record.Fee,
+		Reason:          record.Reason,
+	})
+	if err != nil {
+		fmt.Printf("Kompenzacija %s za order %d nije potvrdjena (pokusaj %d): %v\n", record.Uid, record.OrderID, record.Attempts, err)
+		msg := err.Error()
+		record.Error = &msg
+		if record.Attempts >= CompensationMaxAttempts() {
+			record.DeadLetter = true
+			fmt.Printf("UPOZORENJE: kompenzacija %s za order %d odbacena posle %d pokusaja, potrebna rucna obrada\n", record.Uid, record.OrderID, record.Attempts)
+		}
+		return
+	}
+	record.Sent = true
+	record.Error = nil
+}
+
+// RetryCompensations ponovo šalje kompenzacije koje banking servis još nije
+// potvrdio, osim onih u dead-letter stanju, i vraća broj potvrđenih.
+func RetryCompensations() int {
+	var pending []types.OrderCompensation
+	if err := db.DB.Where("NOT sent AND NOT dead_letter").Find(&pending).Error; err != nil {
+		fmt.Printf("Greska pri citanju nepotvrdjenih kompenzacija: %v\n", err)
+		return 0
+	}
+
+	settled := 0
+	for i := range pending {
+		sendCompensation(&pending[i])
+		if err := db.DB.Model(&pending[i]).Updates(map[string]any{
+			"sent":        pending[i].Sent,
+			"attempts":    pending[i].Attempts,
+			"dead_letter": pending[i].DeadLetter,
+			"error":       pending[i].Error,
+		}).Error; err != nil {
+			fmt.Printf("Greska pri upisu kompenzacije %s: %v\n", pending[i].Uid, err)
+			continue
+		}
+		if pending[i].Sent {
+			settled++
+		}
+	}
+	return settled
+}
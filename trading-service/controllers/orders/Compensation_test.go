@@ -0,0 +1,42 @@
+package orders
+
+import (
+	"testing"
+
+	"banka1.com/db"
+	"banka1.com/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryCompensations_SettlesOnlyAcknowledged(t *testing.T) {
+	assert.NoError(t, db.InitTestDatabase())
+
+	msg := "banking servis ne odgovara"
+	pending := types.OrderCompensation{OrderID: 1, Uid: "ORDER-1-1", BuyerAccountID: 7, SellerAccountID: 8, Amount: 100, Reason: "rollback", Error: &msg}
+	assert.NoError(t, db.DB.Create(&pending).Error)
+	settled := types.OrderCompensation{OrderID: 1, Uid: "ORDER-1-2", BuyerAccountID: 7, SellerAccountID: 8, Amount: 50, Reason: "rollback", Sent: true}
+	assert.NoError(t, db.DB.Create(&settled).Error)
+	t.Cleanup(func() { db.DB.Delete(&types.OrderCompensation{}, []uint{pending.ID, settled.ID}) })
+
+	// Bez brokera nijedno plaćanje nije ni inicirano, pa se kompenzacija smatra potvrđenom
+	assert.Equal(t, 1, RetryCompensations())
+	assert.NoError(t, db.DB.First(&pending, pending.ID).Error)
+	assert.True(t, pending.Sent)
+	assert.Nil(t, pending.Error)
+	assert.Equal(t, 1, pending.Attempts)
+	assert.Equal(t, 0, RetryCompensations())
+}
+
+func TestRetryCompensations_SkipsDeadLetter(t *testing.T) {
+	assert.NoError(t, db.InitTestDatabase())
+
+	msg := "Insufficient funds"
+	dead := types.OrderCompensation{OrderID: 2, Uid: "ORDER-2-1", BuyerAccountID: 7, SellerAccountID: 8, Amount: 100, Reason: "rollback", Attempts: DefaultCompensationMaxAttempts, DeadLetter: true, Error: &msg}
+	assert.NoError(t, db.DB.Create(&dead).Error)
+	t.Cleanup(func() { db.DB.Delete(&types.OrderCompensation{}, dead.ID) })
+
+	assert.Equal(t, 0, RetryCompensations())
+	assert.NoError(t, db.DB.First(&dead, dead.ID).Error)
+	assert.False(t, dead.Sent)
+	assert.Equal(t, DefaultCompensationMaxAttempts, dead.Attempts)
+}
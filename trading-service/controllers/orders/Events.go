@@ -0,0 +1,91 @@
+package orders
+
+import (
+	"fmt"
+
+	"banka1.com/db"
+	"banka1.com/types"
+	"gorm.io/gorm"
+)
+
+const (
+	EventCreated   = "created"
+	EventApproved  = "approved"
+	EventDeclined  = "declined"
+	EventFill      = "fill"
+	EventAmended   = "amended"
+	EventActivated = "activated"
+	EventCancelled = "cancelled"
+	EventExpired   = "expired"
+	EventEscalated = "escalated"
+)
+
+// RecordEvent upisuje događaj ordera. Kada je tx zadat, događaj deli sudbinu
+// transakcije (npr. fill se briše zajedno sa rollback-om izvršenja). Greška pri
+// upisu se samo loguje, jer žurnal ne sme da obori samu operaciju.
+func RecordEvent(tx *gorm.DB, event types.OrderEvent) {
+	if tx == nil {
+		tx = db.DB
+	}
+	if err := tx.Create(&event).Error; err != nil {
+		fmt.Printf("Greska pri upisu dogadjaja %s za order %d: %v\n", event.Type, event.OrderID, err)
+	}
+}
+
+// recordStatus upisuje događaj promene stanja ordera bez izvršene količine. Holdove
+// i margin nalog menja pozivalac, uz samu promenu stanja.
+func recordStatus(tx *gorm.DB, order types.Order, eventType, status string, actorID *uint, note string) {
+	RecordEvent(tx, types.OrderEvent{
+		OrderID:  order.ID,
+		Type:     eventType,
+		Status:   status,
+		Quantity: ptrSafe(order.RemainingParts),
+		ActorID:  actorID,
+		Note:     note,
+	})
+}
+
+// RecordStatus je recordStatus za kontrolere, van transakcije izvršenja.
+func RecordStatus(order types.Order, eventType string, actorID *uint, note string) {
+	recordStatus(nil, order, eventType, order.Status, actorID, note)
+}
+
+// recordFill upisuje izvršenje dela ordera; remaining je količina preostala posle
+// izvršenja. Fee je nil za stranu koja ne plaća proviziju.
+func recordFill(tx *gorm.DB, orderID uint, remaining, quantity int, price float64, fee *float64, note string) {
+	status := "approved"
+	if remaining == 0 {
+		status = "done"
+	}
+	RecordEvent(tx, types.OrderEvent{
+		OrderID:  orderID,
+		Type:     EventFill,
+		Status:   status,
+		Quantity: quantity,
+		Price:    &price,
+		Fee:      fee,
+		Note:     note,
+	})
+}
+
+// FillSummary računa izvršenu količinu, prosečnu cenu izvršenja (ponderisanu
+// količinom) i ukupnu proviziju iz fill događaja.
+func FillSummary(events []types.OrderEvent) (int, *float64, float64) {
+	filled := 0
+	notional, fees := 0.0, 0.0
+	for _, event := range events {
+		if event.Type != EventFill || event.Price == nil {
+			continue
+		}
+		filled += event.Quantity
+		notional += *event.Price * float64(event.Quantity)
+		if event.Fee != nil {
+			fees += *event.Fee
+		}
+	}
+	if filled == 0 {
+		return 0, nil, fees
+	}
+	average := notional / float64(filled)
+	return filled, &average, fees
+}
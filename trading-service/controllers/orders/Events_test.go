@@ -0,0 +1,29 @@
+package orders
+
+import (
+	"testing"
+
+	"banka1.com/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFillSummary_WeightedAverage(t *testing.T) {
+	events := []types.OrderEvent{
+		{Type: EventCreated, Quantity: 10},
+		{Type: EventFill, Quantity: 4, Price: fptr(100), Fee: fptr(2)},
+		{Type: EventFill, Quantity: 6, Price: fptr(110), Fee: fptr(3)},
+		{Type: EventCancelled},
+	}
+
+	filled, average, fees := FillSummary(events)
+	assert.Equal(t, 10, filled)
+	assert.InDelta(t, 106.0, *average, 1e-9)
+	assert.Equal(t, 5.0, fees)
+}
+
+func TestFillSummary_NoFills(t *testing.T) {
+	filled, average, fees := FillSummary([]types.OrderEvent{{Type: EventCreated, Quantity: 3}})
+	assert.Equal(t, 0, filled)
+	assert.Nil(t, average)
+	assert.Equal(t, 0.0, fees)
+}
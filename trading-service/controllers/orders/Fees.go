@@ -0,0 +1,172 @@
+package orders
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"banka1.com/clock"
+	"banka1.com/types"
+	"gorm.io/gorm"
+)
+
+const (
+	SegmentCustomer   = "customer"
+	SegmentAgent      = "agent"
+	SegmentSupervisor = "supervisor"
+)
+
+// feeContext su kriterijumi po kojima se bira tarifa za izvršenje ordera.
+type feeContext struct {
+	OrderType      string
+	Segment        string
+	InstrumentType string
+	ExchangeID     *uint
+}
+
+// ClientSegment određuje segment klijenta: aktuari su agent ili supervisor, ostali su customer.
+func ClientSegment(tx *gorm.DB, userID uint) string {
+	var actuary types.Actuary
+	if err := tx.Where("user_id = ?", userID).First(&actuary).Error; err != nil {
+		return SegmentCustomer
+	}
+	switch strings.ToLower(actuary.Department) {
+	case SegmentAgent:
+		return SegmentAgent
+	case SegmentSupervisor:
+		return SegmentSupervisor
+	}
+	return SegmentCustomer
+}
+
+func feeContextFor(tx *gorm.DB, order types.Order) feeContext {
+	ctx := feeContext{
+		OrderType: strings.ToUpper(order.OrderType),
+		Segment:   ClientSegment(tx, order.UserID),
+	}
+
+	var security types.Security
+	if err := tx.First(&security, order.SecurityID).Error; err != nil {
+		return ctx
+	}
+	ctx.InstrumentType = security.Type
+
+	var listing types.Listing
+	if err := tx.Where("ticker = ?", security.Ticker).First(&listing).Error; err == nil && listing.ExchangeID != 0 {
+		exchangeID := listing.ExchangeID
+		ctx.ExchangeID = &exchangeID
+	}
+	return ctx
+}
+
+// specificity broji kriterijume koje tarifa postavlja; uža tarifa ima prednost.
+func specificity(schedule types.FeeSchedule) int {
+	n := 0
+	for _, set := range []bool{schedule.OrderType != "", schedule.Segment != "", schedule.InstrumentType != "", schedule.ExchangeID != nil} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+// activeFeeSchedule vraća najspecifičniju tarifu koja važi u trenutku izvršenja,
+// ili nil ako nijedna ne odgovara.
+func activeFeeSchedule(tx *gorm.DB, ctx feeContext) (*types.FeeSchedule, error) {
+	now := clock.Now()
+	query := tx.Preload("Tiers").
+		Where("effective_from <= ? AND (effective_to IS NULL OR effective_to > ?)", now, now).
+		Where("order_type IN ?", []string{"", ctx.OrderType}).
+		Where("segment IN ?", []string{"", ctx.Segment}).
+		Where("(instrument_type = '' OR lower(instrument_type) = ?)", strings.ToLower(ctx.InstrumentType))
+	if ctx.ExchangeID != nil {
+		query = query.Where("(exchange_id IS NULL OR exchange_id = ?)", *ctx.ExchangeID)
+	} else {
+		query = query.Where("exchange_id IS NULL")
+	}
+
+	var schedules []types.FeeSchedule
+	if err := query.Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	if len(schedules) == 0 {
+		return nil, nil
+	}
+
+	best := schedules[0]
+	for _, schedule := range schedules[1:] {
+		if s, b := specificity(schedule), specificity(best); s > b ||
+			(s == b && schedule.EffectiveFrom.After(best.EffectiveFrom)) ||
+			(s == b && schedule.EffectiveFrom.Equal(best.EffectiveFrom) && schedule.ID > best.ID) {
+			best = schedule
+		}
+	}
+	return &best, nil
+}
+
+// sortTiers ređa stepene po gornjoj granici; stepen bez granice ide na kraj.
+func sortTiers(tiers []types.FeeScheduleTier) {
+	sort.SliceStable(tiers, func(i, j int) bool {
+		if tiers[i].UpTo == nil || tiers[j].UpTo == nil {
+			return tiers[j].UpTo == nil && tiers[i].UpTo != nil
+		}
+		return *tiers[i].UpTo < *tiers[j].UpTo
+	})
+}
+
+// ApplyFeeSchedule računa proviziju po tarifi: svaki stepen naplaćuje svoj procenat
+// na deo vrednosti u svom opsegu, a zatim se primenjuju minimum i gornja granica.
+func ApplyFeeSchedule(schedule types.FeeSchedule, total float64) float64 {
+	tiers := append([]types.FeeScheduleTier(nil), schedule.Tiers...)
+	sortTiers(tiers)
+
+	fee, lower := 0.0, 0.0
+	for _, tier := range tiers {
+		upper := total
+		if tier.UpTo != nil && *tier.UpTo < total {
+			upper = *tier.UpTo
+		}
+		if upper > lower {
+			fee += (upper - lower) * tier.Percent / 100
+		}
+		if tier.UpTo == nil || *tier.UpTo >= total {
+			break
+		}
+		lower = *tier.UpTo
+	}
+
+	fee = max(fee, schedule.Minimum)
+	if schedule.Cap != nil {
+		fee = min(fee, *schedule.Cap)
+	}
+	return fee
+}
+
+// FeeFor vraća proviziju za izvršenje vrednosti total i ID primenjene tarife.
+// Kada nijedna tarifa ne važi, koristi se podrazumevani CalculateFee i ID je nil.
+func FeeFor(tx *gorm.DB, order types.Order, total float64) (float64, *uint) {
+	schedule, err := activeFeeSchedule(tx, feeContextFor(tx, order))
+	if err != nil {
+		fmt.Printf("Greska pri citanju tarife provizije za order %d: %v\n", order.ID, err)
+	}
+	if schedule == nil {
+		return CalculateFee(order, total), nil
+	}
+	return ApplyFeeSchedule(*schedule, total), &schedule.ID
+}
+
+// ValidateFeeTiers proverava da stepeni rastu i da samo poslednji nema gornju granicu.
+func ValidateFeeTiers(tiers []types.FeeScheduleTier) error {
+	sorted := append([]types.FeeScheduleTier(nil), tiers...)
+	sortTiers(sorted)
+	for i, tier := range sorted {
+		if tier.UpTo == nil && i != len(sorted)-1 {
+			return errors.New("samo jedan stepen može biti bez gornje granice")
+		}
+		if i > 0 && tier.UpTo != nil && *sorted[i-1].UpTo == *tier.UpTo {
+			return fmt.Errorf("dva stepena imaju istu gornju granicu %.2f", *tier.UpTo)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package orders
+
+import (
+	"testing"
+	"time"
+
+	"banka1.com/clock"
+	"banka1.com/db"
+	"banka1.com/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyFeeSchedule_TiersMinimumAndCap(t *testing.T) {
+	schedule := types.FeeSchedule{
+		Minimum: 2,
+		Tiers: []types.FeeScheduleTier{
+			{Percent: 0.5},
+			{UpTo: fptr(1000), Percent: 1},
+		},
+	}
+	// 1% na prvih 1000, 0.5% na ostatak
+	assert.Equal(t, 15.0, ApplyFeeSchedule(schedule, 2000))
+	assert.Equal(t, 5.0, ApplyFeeSchedule(schedule, 500))
+	assert.Equal(t, 2.0, ApplyFeeSchedule(schedule, 100), "minimum")
+
+	schedule.Cap = fptr(12)
+	assert.Equal(t, 12.0, ApplyFeeSchedule(schedule, 2000), "cap")
+}
+
+func TestValidateFeeTiers(t *testing.T) {
+	assert.NoError(t, ValidateFeeTiers([]types.FeeScheduleTier{{UpTo: fptr(100), Percent: 1}, {Percent: 0.5}}))
+	assert.Error(t, ValidateFeeTiers([]types.FeeScheduleTier{{Percent: 1}, {Percent: 0.5}}))
+	assert.Error(t, ValidateFeeTiers([]types.FeeScheduleTier{{UpTo: fptr(100), Percent: 1}, {UpTo: fptr(100), Percent: 0.5}}))
+}
+
+func TestFeeFor_PicksMostSpecificActiveSchedule(t *testing.T) {
+	security := setupBankMarket(t)
+	now := time.Date(2025, 5, 5, 12, 0, 0, 0, time.UTC)
+	defer clock.Use(clock.NewSimulated(now, 0))()
+
+	order := types.Order{ID: 77, UserID: 1, SecurityID: security.ID, OrderType: "MARKET"}
+	fee, scheduleID := FeeFor(db.DB, order, 100)
+	assert.Equal(t, 7.0, fee, "bez tarife važi podrazumevana provizija")
+	assert.Nil(t, scheduleID)
+
+	general := types.FeeSchedule{Name: "Sve", EffectiveFrom: now.Add(-time.Hour), Tiers: []types.FeeScheduleTier{{Percent: 1}}}
+	market := types.FeeSchedule{Name: "MARKET akcije", OrderType: "MARKET", InstrumentType: "stock", EffectiveFrom: now.Add(-time.Hour), Tiers: []types.FeeScheduleTier{{Percent: 2}}}
+	future := types.FeeSchedule{Name: "Buduća", OrderType: "MARKET", InstrumentType: "Stock", Segment: SegmentCustomer, EffectiveFrom: now.Add(time.Hour), Tiers: []types.FeeScheduleTier{{Percent: 3}}}
+	agents := types.FeeSchedule{Name: "Agenti", Segment: SegmentAgent, EffectiveFrom: now.Add(-time.Hour), Tiers: []types.FeeScheduleTier{{Percent: 4}}}
+	for _, schedule := range []*types.FeeSchedule{&general, &market, &future, &agents} {
+		assert.NoError(t, db.DB.Create(schedule).Error)
+	}
+	t.Cleanup(func() {
+		db.DB.Where("1 = 1").Delete(&types.FeeScheduleTier{})
+		db.DB.Where("1 = 1").Delete(&types.FeeSchedule{})
+	})
+
+	fee, scheduleID = FeeFor(db.DB, order, 100)
+	assert.Equal(t, 2.0, fee)
+	assert.Equal(t, market.ID, *scheduleID)
+
+	order.OrderType = "LIMIT"
+	fee, scheduleID = FeeFor(db.DB, order, 100)
+	assert.Equal(t, 1.0, fee)
+	assert.Equal(t, general.ID, *scheduleID)
+}
+
+func TestFillFromBank_RecordsFeeSchedule(t *testing.T) {
+	security := setupBankMarket(t)
+	assert.NoError(t, db.DB.Create(&types.Portfolio{UserID: BankUserID, SecurityID: security.ID, Quantity: 10, PurchasePrice: 90}).Error)
+
+	schedule := types.FeeSchedule{Name: "Ravna", EffectiveFrom: clock.Now().Add(-time.Hour), Minimum: 1, Tiers: []types.FeeScheduleTier{{Percent: 0.5}}}
+	assert.NoError(t, db.DB.Create(&schedule).Error)
+	t.Cleanup(func() {
+		db.DB.Where("schedule_id = ?", schedule.ID).Delete(&types.FeeScheduleTier{})
+		db.DB.Delete(&schedule)
+	})
+
+	order := types.Order{UserID: 1, AccountID: 7, SecurityID: security.ID, Direction: "buy", OrderType: "MARKET", Quantity: 4, RemainingParts: ptr(4), Status: "approved"}
+	assert.NoError(t, db.DB.Create(&order).Error)
+
+	_, sent := fillFromBank(&order, db.DB)
+	assert.Len(t, sent, 1)
+	assert.InDelta(t, 2.02, sent[0].Fee, 1e-9)
+
+	var txn types.Transaction
+	assert.NoError(t, db.DB.Where("order_id = ?", order.ID).First(&txn).Error)
+	assert.InDelta(t, 2.02, txn.Fee, 1e-9)
+	assert.Equal(t, schedule.ID, *txn.FeeScheduleID)
+}
@@ -0,0 +1,256 @@
+package orders
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"banka1.com/broker"
+	"banka1.com/db"
+	"banka1.com/dto"
+	"banka1.com/holds"
+	"banka1.com/types"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInsufficientFunds znači da banking servis potvrđuje da na računu nema dovoljno sredstava.
+	ErrInsufficientFunds = errors.New("nedovoljno raspoloživih sredstava na računu")
+	// ErrFundsUnknown znači da stanje računa nije moglo da se proveri.
+	ErrFundsUnknown = errors.New("stanje računa nije dostupno")
+)
+
+// AccountLookup dohvata račune korisnika iz banking servisa. Promenljiva je da bi
+// testovi mogli da je zamene.
+var AccountLookup = func(userID uint) ([]dto.Account, error) {
+	return broker.GetAccountsForUser(int64(userID))
+}
+
+// FundsCheckEnabled je false kada servis nije povezan sa brokerom (lokalni razvoj,
+// testovi), pa se provera kupovne moći preskače.
+var FundsCheckEnabled = broker.Connected
+
+// BuyingPower je rezultat provere: procenjena cena ordera i slobodna sredstva na računu.
+type BuyingPower struct {
+	Cost      float64
+	Available float64
+}
+
+// estimatePrice vraća cenu jedinice po kojoj se očekuje izvršenje buy ordera:
+// limit cena, za stop ordere veća od stop cene i Ask-a, inače Ask ili poslednja cena.
+func estimatePrice(tx *gorm.DB, order types.Order) (float64, error) {
+	if order.LimitPricePerUnit != nil {
+		return *order.LimitPricePerUnit, nil
+	}
+
+	var security types.Security
+	if err := tx.First(&security, order.SecurityID).Error; err != nil {
+		return 0, err
+	}
+	price := security.LastPrice
+	var listing types.Listing
+	if err := tx.Where("ticker = ?", security.Ticker).First(&listing).Error; err == nil && listing.Ask > 0 {
+		price = float64(listing.Ask)
+	}
+	if order.StopPricePerUnit != nil {
+		price = max(price, *order.StopPricePerUnit)
+	}
+	return price, nil
+}
+
+// EstimateCost procenjuje ukupan trošak preostalog dela buy ordera sa provizijom.
+func EstimateCost(tx *gorm.DB, order types.Order) (float64, error) {
+	if tx == nil {
+		tx = db.DB
+	}
+	price, err := estimatePrice(tx, order)
+	if err != nil {
+		return 0, err
+	}
+	notional := Notional(order, price, ptrSafe(order.RemainingParts))
+	fee, _ := FeeFor(tx, order, notional)
+	return notional + fee, nil
+}
+
+// CheckBuyingPower proverava da li račun ordera pokriva procenjeni trošak, uzimajući
+// u obzir sredstva rezervisana u banking servisu i gotovinu zadržanu u knjizi rezervacija.
+// Vraća ErrFundsUnknown ako banking servis nije odgovorio, a ErrInsufficientFunds
+// ako sredstava nema dovoljno.
+func CheckBuyingPower(order types.Order) (BuyingPower, error) {
+	cost, err := EstimateCost(db.DB, order)
+	if err != nil {
+		return BuyingPower{}, fmt.Errorf("%w: %v", ErrFundsUnknown, err)
+	}
+	result := BuyingPower{Cost: cost}
+
+	accounts, err := AccountLookup(order.UserID)
+	if err != nil {
+		return result, fmt.Errorf("%w: %v", ErrFundsUnknown, err)
+	}
+	var account *dto.Account
+	for i := range accounts {
+		if uint(accounts[i].ID) == order.AccountID {
+			account = &accounts[i]
+			break
+		}
+	}
+	if account == nil {
+		return result, fmt.Errorf("%w: račun %d ne pripada korisniku %d", ErrFundsUnknown, order.AccountID, order.UserID)
+	}
+
+	result.Available, err = AvailableCash(*account, holds.ReasonOrder, holds.OrderRef(order))
+	if err != nil {
+		return result, fmt.Errorf("%w: %v", ErrFundsUnknown, err)
+	}
+	if result.Available < cost {
+		return result, ErrInsufficientFunds
+	}
+	return result, nil
+}
+
+// AvailableCash vraća slobodna sredstva na računu: stanje umanjeno za sredstva
+// rezervisana u banking servisu i za gotovinu zadržanu u knjizi rezervacija, ne
+// računajući hold vlasnika (reason, ownerRef) koji se upravo proverava.
+func AvailableCash(account dto.Account, reason, ownerRef string) (float64, error) {
+	held, err := holds.HeldCash(db.DB, uint(account.ID), reason, ownerRef)
+	if err != nil {
+		return 0, err
+	}
+	return account.Balance - account.ReservedBalance - held, nil
+}
+
+// ReserveFunds zadržava procenjeni trošak buy ordera na računu, za njegovu preostalu količinu.
+func ReserveFunds(tx *gorm.DB, order types.Order, amount float64) error {
+	accountID := order.AccountID
+	return holds.Place(tx, types.Hold{
+		UserID:    order.UserID,
+		Asset:     holds.AssetCash,
+		AccountID: &accountID,
+		Quantity:  ptrSafe(order.RemainingParts),
+		Amount:    amount,
+		Reason:    holds.ReasonOrder,
+		OwnerRef:  holds.OrderRef(order),
+	})
+}
+
+// holdShares usklađuje hold hartija sell ordera sa njegovim statusom: odobren order
+// drži svoju preostalu količinu, a order koji čeka odobrenje ne drži ništa. Poziva
+// se pri kreiranju, odobravanju, izmeni i aktivaciji ordera.
+func holdShares(tx *gorm.DB, order types.Order, status string) error {
+	if strings.ToLower(order.Direction) != "sell" {
+		return nil
+	}
+	if status != "approved" {
+		return releaseHolds(tx, order.ID)
+	}
+	quantity, err := sharedHoldQuantity(tx, order)
+	if err != nil {
+		return err
+	}
+	securityID := order.SecurityID
+	return holds.Place(tx, types.Hold{
+		UserID:     order.UserID,
+		Asset:      holds.AssetSecurity,
+		SecurityID: &securityID,
+		Quantity:   quantity,
+		Reason:     holds.ReasonOrder,
+		OwnerRef:   holds.OrderRef(order),
+	})
+}
+
+// sharedGroupRoles su uloge naloga grupe koji dele jedan hold (vidi holds.OrderRef).
+var sharedGroupRoles = []string{GroupRoleTakeProfit, GroupRoleStopLoss, GroupRoleLeg}
+
+// sharedHoldQuantity vraća količinu koju hold sell ordera treba da drži: preostali
+// deo ordera, a za naloge koji dele hold grupe najveći preostali deo odobrenih
+// sell naloga te grupe.
+func sharedHoldQuantity(tx *gorm.DB, order types.Order) (int, error) {
+	quantity := ptrSafe(order.RemainingParts)
+	if holds.OrderRef(order) == holds.Ref(order.ID) {
+		return quantity, nil
+	}
+	if tx == nil {
+		tx = db.DB
+	}
+	var largest *int
+	if err := tx.Model(&types.Order{}).
+		Where("group_id = ? AND group_role IN ? AND lower(direction) = 'sell' AND status = ? AND NOT is_done",
+			*order.GroupID, sharedGroupRoles, "approved").
+		Select("MAX(remaining_parts)").Scan(&largest).Error; err != nil {
+		return 0, err
+	}
+	if largest != nil && *largest > quantity {
+		quantity = *largest
+	}
+	return quantity, nil
+}
+
+// PlaceOrderHolds usklađuje hold hartija ordera sa njegovim statusom i, kada je
+// cash veći od nule, zadržava toliko gotovine za buy order. Kontroleri ga pozivaju
+// u istoj transakciji u kojoj upisuju order.
+func PlaceOrderHolds(tx *gorm.DB, order types.Order, cash float64) error {
+	if err := holdShares(tx, order, order.Status); err != nil {
+		return err
+	}
+	if cash > 0 {
+		return ReserveFunds(tx, order, cash)
+	}
+	return nil
+}
+
+// releaseHolds oslobađa sve što order drži kada je otkazan, istekao ili odbijen.
+// Zajednički hold grupe (take-profit i stop-loss bracket-a, nalozi OCO grupe)
+// oslobađa se tek kada nijedan od naloga koji ga dele više nije otvoren.
+func releaseHolds(tx *gorm.DB, orderID uint) error {
+	if tx == nil {
+		tx = db.DB
+	}
+	var order types.Order
+	if err := tx.First(&order, orderID).Error; err != nil {
+		return err
+	}
+	ref := holds.OrderRef(order)
+	if ref != holds.Ref(order.ID) {
+		var open int64
+		if err := tx.Model(&types.Order{}).
+			Where("group_id = ? AND id <> ? AND group_role IN ? AND NOT is_done AND status IN ?",
+				*order.GroupID, order.ID, sharedGroupRoles, openStatuses).
+			Count(&open).Error; err != nil {
+			return err
+		}
+		if open > 0 {
+			return nil
+		}
+	}
+	return holds.Release(tx, holds.ReasonOrder, ref)
+}
+
+// ReleaseHolds je releaseHolds za kontrolere.
+func ReleaseHolds(tx *gorm.DB, orderID uint) error {
+	return releaseHolds(tx, orderID)
+}
+
+// settleFill usklađuje holdove i margin nalog ordera sa izvršenjem dela količine;
+// remaining je količina preostala posle izvršenja. Poziva se u transakciji
+// izvršenja, posle ažuriranja portfolija.
+func settleFill(tx *gorm.DB, orderID uint, remaining, quantity int, price float64) error {
+	if tx == nil {
+		tx = db.DB
+	}
+	var order types.Order
+	if err := tx.First(&order, orderID).Error; err != nil {
+		return fmt.Errorf("order %d: %w", orderID, err)
+	}
+	if err := holds.Reduce(tx, holds.ReasonOrder, holds.OrderRef(order), remaining); err != nil {
+		return fmt.Errorf("holdovi ordera %d: %w", orderID, err)
+	}
+	if err := settleMargin(tx, orderID, quantity, price); err != nil {
+		return fmt.Errorf("margin nalog za order %d: %w", orderID, err)
+	}
+	return nil
+}
+
+// NeedsFunds proverava da li order troši gotovinu sa računa: buy bez margine.
+func NeedsFunds(order types.Order) bool {
+	return strings.ToLower(order.Direction) == "buy" && !order.Margin
+}
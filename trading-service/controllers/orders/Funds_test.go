@@ -0,0 +1,163 @@
+package orders
+
+import (
+	"errors"
+	"testing"
+
+	"banka1.com/db"
+	"banka1.com/dto"
+	"banka1.com/holds"
+	"banka1.com/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func stubAccounts(t *testing.T, accounts []dto.Account, err error) {
+	previous := AccountLookup
+	AccountLookup = func(uint) ([]dto.Account, error) { return accounts, err }
+	t.Cleanup(func() {
+		AccountLookup = previous
+		db.DB.Where("1 = 1").Delete(&types.Hold{})
+	})
+}
+
+func TestCheckBuyingPower(t *testing.T) {
+	security := setupBankMarket(t)
+	stubAccounts(t, []dto.Account{{ID: 7, OwnerID: 1, Balance: 1500, ReservedBalance: 100}}, nil)
+
+	order := types.Order{ID: 900, UserID: 1, AccountID: 7, SecurityID: security.ID, Direction: "buy", OrderType: "MARKET", Quantity: 10, RemainingParts: ptr(10)}
+
+	// 10 * Ask 101 + provizija 7
+	power, err := CheckBuyingPower(order)
+	assert.NoError(t, err)
+	assert.InDelta(t, 1017.0, power.Cost, 1e-9)
+	assert.InDelta(t, 1400.0, power.Available, 1e-9)
+
+	other := types.Order{ID: 901, UserID: 1, AccountID: 7, RemainingParts: ptr(5)}
+	assert.NoError(t, ReserveFunds(nil, other, 500))
+
+	power, err = CheckBuyingPower(order)
+	assert.ErrorIs(t, err, ErrInsufficientFunds)
+	assert.InDelta(t, 900.0, power.Available, 1e-9)
+
+	order.LimitPricePerUnit = fptr(80)
+	_, err = CheckBuyingPower(order)
+	assert.NoError(t, err)
+}
+
+func TestCheckBuyingPower_BankingUnavailable(t *testing.T) {
+	security := setupBankMarket(t)
+	stubAccounts(t, nil, errors.New("timeout"))
+
+	order := types.Order{UserID: 1, AccountID: 7, SecurityID: security.ID, Direction: "buy", OrderType: "MARKET", Quantity: 1, RemainingParts: ptr(1)}
+	_, err := CheckBuyingPower(order)
+	assert.ErrorIs(t, err, ErrFundsUnknown)
+}
+
+func TestReservation_ConsumedByFillsAndReleasedOnCancel(t *testing.T) {
+	assert.NoError(t, db.InitTestDatabase())
+	stubAccounts(t, nil, nil)
+
+	order := types.Order{ID: 910, UserID: 1, AccountID: 7, SecurityID: 1, Direction: "buy", OrderType: "MARKET", Quantity: 10, RemainingParts: ptr(10), Status: "approved"}
+	assert.NoError(t, db.DB.Create(&order).Error)
+	assert.NoError(t, ReserveFunds(nil, order, 1000))
+	t.Cleanup(func() { db.DB.Delete(&types.Order{}, []uint{910, 911}) })
+
+	assert.NoError(t, settleFill(nil, order.ID, 4, 6, 100))
+	var reservation types.Hold
+	assert.NoError(t, db.DB.Where("owner_ref = ?", holds.Ref(order.ID)).First(&reservation).Error)
+	assert.Equal(t, holds.StatusActive, reservation.Status)
+	assert.InDelta(t, 400.0, reservation.Amount, 1e-9)
+
+	assert.NoError(t, releaseHolds(nil, order.ID))
+	assert.NoError(t, db.DB.Where("owner_ref = ?", holds.Ref(order.ID)).First(&reservation).Error)
+	assert.Equal(t, holds.StatusReleased, reservation.Status)
+	assert.Zero(t, reservation.Amount)
+
+	filled := types.Order{ID: 911, UserID: 1, AccountID: 7, SecurityID: 1, Direction: "buy", OrderType: "MARKET", Quantity: 2, RemainingParts: ptr(2), Status: "approved"}
+	assert.NoError(t, db.DB.Create(&filled).Error)
+	assert.NoError(t, ReserveFunds(nil, filled, 200))
+	assert.NoError(t, settleFill(nil, filled.ID, 0, 2, 100))
+	var consumed types.Hold
+	assert.NoError(t, db.DB.Where("owner_ref = ?", holds.Ref(filled.ID)).First(&consumed).Error)
+	assert.Equal(t, holds.StatusConsumed, consumed.Status)
+	assert.Zero(t, consumed.Amount)
+}
+
+func TestSellOrderHoldsShares(t *testing.T) {
+	security := setupBankMarket(t)
+	stubAccounts(t, nil, nil)
+	assert.NoError(t, db.DB.Create(&types.Portfolio{UserID: 1, SecurityID: security.ID, Quantity: 20, PublicCount: 5}).Error)
+
+	order := types.Order{UserID: 1, AccountID: 7, SecurityID: security.ID, Direction: "sell", OrderType: "MARKET", Quantity: 8, RemainingParts: ptr(8), Status: "pending"}
+	assert.NoError(t, db.DB.Create(&order).Error)
+	t.Cleanup(func() { db.DB.Where("order_id = ?", order.ID).Delete(&types.OrderEvent{}) })
+
+	// Order koji čeka odobrenje ne drži hartije
+	assert.NoError(t, PlaceOrderHolds(nil, order, 0))
+	_, available, err := CanSell(1, security.ID, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 15, available)
+
+	order.Status = "approved"
+	assert.NoError(t, PlaceOrderHolds(nil, order, 0))
+	_, available, _ = CanSell(1, security.ID, 0)
+	assert.Equal(t, 7, available)
+
+	assert.NoError(t, settleFill(nil, order.ID, 3, 5, 99))
+	_, available, _ = CanSell(1, security.ID, 0)
+	assert.Equal(t, 12, available)
+
+	assert.NoError(t, releaseHolds(nil, order.ID))
+	_, available, _ = CanSell(1, security.ID, 0)
+	assert.Equal(t, 15, available)
+}
+
+func TestBracketExitsShareOneHold(t *testing.T) {
+	security := setupBankMarket(t)
+	stubAccounts(t, nil, nil)
+	assert.NoError(t, db.DB.Create(&types.Portfolio{UserID: 1, SecurityID: security.ID, Quantity: 10}).Error)
+
+	group := types.OrderGroup{Type: GroupTypeBracket, UserID: 1, Status: "active"}
+	assert.NoError(t, db.DB.Create(&group).Error)
+	t.Cleanup(func() { db.DB.Delete(&group) })
+
+	exit := func(role, orderType string) types.Order {
+		order := types.Order{UserID: 1, AccountID: 7, SecurityID: security.ID, Direction: "sell", OrderType: orderType, Quantity: 5, RemainingParts: ptr(5), Status: "approved", GroupID: &group.ID, GroupRole: &role}
+		assert.NoError(t, db.DB.Create(&order).Error)
+		return order
+	}
+	takeProfit, stopLoss := exit(GroupRoleTakeProfit, "LIMIT"), exit(GroupRoleStopLoss, "STOP")
+
+	assert.NoError(t, PlaceOrderHolds(nil, takeProfit, 0))
+	assert.NoError(t, PlaceOrderHolds(nil, stopLoss, 0))
+	_, available, err := CanSell(1, security.ID, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, available)
+
+	// Delimično izvršen take-profit otkazuje stop-loss, a hold grupe ostaje za njegov ostatak
+	assert.NoError(t, settleFill(nil, takeProfit.ID, 3, 2, 110))
+	assert.NoError(t, db.DB.Model(&stopLoss).Update("status", "cancelled").Error)
+	assert.NoError(t, releaseHolds(nil, stopLoss.ID))
+	_, available, _ = CanSell(1, security.ID, 0)
+	assert.Equal(t, 7, available)
+
+	assert.NoError(t, db.DB.Model(&takeProfit).Update("status", "cancelled").Error)
+	assert.NoError(t, releaseHolds(nil, takeProfit.ID))
+	_, available, _ = CanSell(1, security.ID, 0)
+	assert.Equal(t, 10, available)
+}
+
+func TestAvailableCash_SubtractsReservedAndHeld(t *testing.T) {
+	assert.NoError(t, db.InitTestDatabase())
+	stubAccounts(t, nil, nil)
+	accountID := uint(7)
+	assert.NoError(t, holds.Place(nil, types.Hold{UserID: 1, Asset: holds.AssetCash, AccountID: &accountID, Quantity: 1, Amount: 150, Reason: holds.ReasonSaga, OwnerRef: "OTC-1-1"}))
+
+	available, err := AvailableCash(dto.Account{ID: 7, Balance: 1000, ReservedBalance: 200}, "", "")
+	assert.NoError(t, err)
+	assert.InDelta(t, 650.0, available, 1e-9)
+
+	// Hold koji se upravo proverava se ne računa
+	available, _ = AvailableCash(dto.Account{ID: 7, Balance: 1000, ReservedBalance: 200}, holds.ReasonSaga, "OTC-1-1")
+	assert.InDelta(t, 800.0, available, 1e-9)
+}
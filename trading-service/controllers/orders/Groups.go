@@ -0,0 +1,258 @@
+package orders
+
+import (
+	"fmt"
+
+	"banka1.com/clock"
+	"banka1.com/db"
+	"banka1.com/types"
+	"gorm.io/gorm"
+)
+
+const (
+	GroupTypeOCO     = "OCO"
+	GroupTypeBracket = "BRACKET"
+
+	GroupRoleEntry      = "ENTRY"
+	GroupRoleTakeProfit = "TAKE_PROFIT"
+	GroupRoleStopLoss   = "STOP_LOSS"
+	GroupRoleLeg        = "LEG"
+
+	// StatusWaiting je status take-profit i stop-loss naloga bracket-a dok entry nema izvršenja.
+	StatusWaiting = "waiting"
+)
+
+// openStatuses su statusi naloga grupe koji se još mogu otkazati.
+var openStatuses = []string{"pending", "approved", StatusWaiting}
+
+func groupRole(order types.Order) string {
+	if order.GroupRole == nil {
+		return ""
+	}
+	return *order.GroupRole
+}
+
+// onGroupFill obrađuje izvršenja naloga koji pripadaju grupama: svako izvršenje
+// entry-ja aktivira take-profit i stop-loss za do tada izvršenu količinu, a
+// izvršenje bilo kog drugog naloga grupe otkazuje njegove sestrinske naloge (i
+// delimično izvršene).
+func onGroupFill(orderIDs ...uint) {
+	var filled []types.Order
+	if err := db.DB.Where("id IN ? AND group_id IS NOT NULL", orderIDs).Find(&filled).Error; err != nil {
+		fmt.Printf("Greska pri proveri grupa naloga: %v\n", err)
+		return
+	}
+
+	for _, order := range filled {
+		if groupRole(order) == GroupRoleEntry {
+			activateBracket(order)
+			continue
+		}
+		cancelSiblings(order)
+	}
+}
+
+// activateBracket usklađuje take-profit i stop-loss bracket-a sa izvršenim delom
+// entry-ja: naloge u waiting statusu pušta u izvršavanje, a aktivnima povećava
+// količinu kako se entry dalje izvršava. Tako izlazi štite i poziciju entry-ja
+// koji posle delimičnog izvršenja istekne ili bude otkazan.
+func activateBracket(entry types.Order) {
+	groupID := *entry.GroupID
+	filled := entry.Quantity - ptrSafe(entry.RemainingParts)
+	if filled <= 0 {
+		return
+	}
+
+	var exits []types.Order
+	if err := db.DB.Where("group_id = ? AND group_role <> ? AND NOT is_done AND status IN ?",
+		groupID, GroupRoleEntry, []string{StatusWaiting, "approved"}).Find(&exits).Error; err != nil {
+		fmt.Printf("Greska pri ucitavanju bracket grupe %d: %v\n", groupID, err)
+		return
+	}
+
+	var activated, resized []types.Order
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var changed []types.Order
+		for _, exit := range exits {
+			executed := 0
+			if exit.Status == "approved" {
+				if exit.Quantity >= filled {
+					continue
+				}
+				executed = exit.Quantity - ptrSafe(exit.RemainingParts)
+			}
+			remaining := filled - executed
+			updates := map[string]any{
+				"quantity":        filled,
+				"remaining_parts": remaining,
+			}
+			waiting := exit.Status == StatusWaiting
+			if waiting {
+				exit.PriorityTime = clock.Now().UnixNano()
+				updates["status"] = "approved"
+				updates["priority_time"] = exit.PriorityTime
+			}
+			if err := tx.Model(&types.Order{}).Where("id = ?", exit.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+			exit.Quantity = filled
+			exit.RemainingParts = &remaining
+			exit.Status = "approved"
+			changed = append(changed, exit)
+			if waiting {
+				activated = append(activated, exit)
+			} else {
+				resized = append(resized, exit)
+			}
+		}
+		// Take-profit i stop-loss dele jedan hold grupe, pa se hartije zadržavaju jednom
+		if len(changed) > 0 {
+			return holdShares(tx, changed[0], "approved")
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Greska pri aktivaciji bracket grupe %d: %v\n", groupID, err)
+		return
+	}
+
+	for _, exit := range resized {
+		fmt.Printf("Bracket grupa %d: %s order %d povecan na %d\n", groupID, groupRole(exit), exit.ID, filled)
+		syncBook(exit.SecurityID, exit.ID)
+	}
+	for _, exit := range activated {
+		fmt.Printf("Bracket grupa %d: aktiviran %s order %d za %d\n", groupID, groupRole(exit), exit.ID, filled)
+		StartOrder(exit)
+	}
+}
+
+// cancelSiblings otkazuje ostale naloge grupe (osim entry-ja) nakon izvršenja naloga order.
+func cancelSiblings(order types.Order) {
+	var siblings []types.Order
+	if err := db.DB.Where("group_id = ? AND id <> ? AND group_role <> ? AND NOT is_done AND status IN ?",
+		*order.GroupID, order.ID, GroupRoleEntry, openStatuses).Find(&siblings).Error; err != nil {
+		fmt.Printf("Greska pri ucitavanju naloga grupe %d: %v\n", *order.GroupID, err)
+		return
+	}
+	if len(siblings) == 0 {
+		return
+	}
+
+	fmt.Printf("Order %d iz grupe %d je izvršen, otkazujem %d sestrinskih naloga\n", order.ID, *order.GroupID, len(siblings))
+	cancelOrders(siblings)
+	db.DB.Model(&types.OrderGroup{}).Where("id = ?", *order.GroupID).Update("status", "done")
+}
+
+// CancelGroup otkazuje sve naloge grupe koji još nisu izvršeni i vraća njihov broj.
+func CancelGroup(groupID uint) (int, error) {
+	var open []types.Order
+	if err := db.DB.Where("group_id = ? AND NOT is_done AND status IN ?", groupID, openStatuses).Find(&open).Error; err != nil {
+		return 0, err
+	}
+	cancelOrders(open)
+	if err := db.DB.Model(&types.OrderGroup{}).Where("id = ?", groupID).Update("status", "cancelled").Error; err != nil {
+		return 0, err
+	}
+	return len(open), nil
+}
+
+func cancelOrders(list []types.Order) {
+	for _, order := range list {
+		if err := db.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&types.Order{}).
+				Where("id = ? AND NOT is_done AND status IN ?", order.ID, openStatuses).
+				Updates(map[string]any{
+					"status":        "cancelled",
+					"last_modified": clock.Now().Unix(),
+				}).Error; err != nil {
+				return err
+			}
+			return releaseHolds(tx, order.ID)
+		}); err != nil {
+			fmt.Printf("Greska pri otkazivanju ordera %d: %v\n", order.ID, err)
+			continue
+		}
+		recordStatus(nil, order, EventCancelled, "cancelled", nil, fmt.Sprintf("otkazan uz grupu %d", *order.GroupID))
+		RemoveFromBook(order)
+		RemoveTrigger(order)
+	}
+}
+
+// ApproveGroup odobrava sve naloge grupe koji čekaju odobrenje. Take-profit i
+// stop-loss bracket-a prelaze u waiting dok se entry ne izvrši.
+func ApproveGroup(groupID uint, approvedBy *uint) error {
+	var pending []types.Order
+	if err := db.DB.Where("group_id = ? AND status = ?", groupID, "pending").Find(&pending).Error; err != nil {
+		return err
+	}
+
+	var group types.OrderGroup
+	if err := db.DB.First(&group, groupID).Error; err != nil {
+		return err
+	}
+
+	var started []types.Order
+	for _, order := range pending {
+		order.ApprovedBy = approvedBy
+		order.LastModified = clock.Now().Unix()
+		if group.Type == GroupTypeBracket && groupRole(order) != GroupRoleEntry {
+			order.Status = StatusWaiting
+		} else {
+			order.Status = "approved"
+			order.PriorityTime = clock.Now().UnixNano()
+			started = append(started, order)
+		}
+		if err := db.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&order).Error; err != nil {
+				return err
+			}
+			return holdShares(tx, order, order.Status)
+		}); err != nil {
+			return err
+		}
+		RecordStatus(order, EventApproved, approvedBy, fmt.Sprintf("odobrena grupa %d", groupID))
+	}
+
+	for _, order := range started {
+		StartOrder(order)
+	}
+	return nil
+}
+
+// DeclineGroup odbija sve naloge grupe koji čekaju odobrenje. Razlog, ako je
+// zadat, upisuje se na svaki nalog grupe.
+func DeclineGroup(groupID uint, declinedBy *uint, reason string) error {
+	var pending []types.Order
+	if err := db.DB.Where("group_id = ? AND status = ?", groupID, "pending").Find(&pending).Error; err != nil {
+		return err
+	}
+	updates := map[string]any{
+		"status":        "declined",
+		"approved_by":   declinedBy,
+		"last_modified": clock.Now().Unix(),
+	}
+	note := fmt.Sprintf("odbijena grupa %d", groupID)
+	if reason != "" {
+		updates["decline_reason"] = reason
+		note += ": " + reason
+	}
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&types.Order{}).
+			Where("group_id = ? AND status = ?", groupID, "pending").
+			Updates(updates).Error; err != nil {
+			return err
+		}
+		for _, order := range pending {
+			if err := releaseHolds(tx, order.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, order := range pending {
+		recordStatus(nil, order, EventDeclined, "declined", declinedBy, note)
+	}
+	return db.DB.Model(&types.OrderGroup{}).Where("id = ?", groupID).Update("status", "cancelled").Error
+}
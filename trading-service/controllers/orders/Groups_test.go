@@ -0,0 +1,46 @@
+package orders
+
+import (
+	"testing"
+
+	"banka1.com/db"
+	"banka1.com/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActivateBracket_SizesExitsToFilledEntry(t *testing.T) {
+	security := setupBankMarket(t)
+	stubAccounts(t, nil, nil)
+	assert.NoError(t, db.DB.Create(&types.Portfolio{UserID: 1, SecurityID: security.ID, Quantity: 10}).Error)
+
+	group := types.OrderGroup{Type: GroupTypeBracket, UserID: 1, Status: "active"}
+	assert.NoError(t, db.DB.Create(&group).Error)
+	t.Cleanup(func() { db.DB.Delete(&group) })
+
+	role := func(r string) *string { return &r }
+	entry := types.Order{UserID: 1, AccountID: 7, SecurityID: security.ID, Direction: "buy", OrderType: "MARKET", Quantity: 10, RemainingParts: ptr(4), Status: "approved", GroupID: &group.ID, GroupRole: role(GroupRoleEntry)}
+	assert.NoError(t, db.DB.Create(&entry).Error)
+	// Izlazi su aktivirani pri prvom izvršenju entry-ja (4 komada), a take-profit je od tada prodao 1
+	takeProfit := types.Order{UserID: 1, AccountID: 7, SecurityID: security.ID, Direction: "sell", OrderType: "LIMIT", LimitPricePerUnit: fptr(1000), Quantity: 4, RemainingParts: ptr(3), Status: "approved", GroupID: &group.ID, GroupRole: role(GroupRoleTakeProfit)}
+	stopLoss := types.Order{UserID: 1, AccountID: 7, SecurityID: security.ID, Direction: "sell", OrderType: "STOP", StopPricePerUnit: fptr(1), Quantity: 4, RemainingParts: ptr(4), Status: "approved", GroupID: &group.ID, GroupRole: role(GroupRoleStopLoss)}
+	assert.NoError(t, db.DB.Create(&takeProfit).Error)
+	assert.NoError(t, db.DB.Create(&stopLoss).Error)
+	assert.NoError(t, PlaceOrderHolds(nil, stopLoss, 0))
+
+	activateBracket(entry)
+
+	assert.NoError(t, db.DB.First(&takeProfit, takeProfit.ID).Error)
+	assert.NoError(t, db.DB.First(&stopLoss, stopLoss.ID).Error)
+	assert.Equal(t, 6, takeProfit.Quantity)
+	assert.Equal(t, 5, *takeProfit.RemainingParts)
+	assert.Equal(t, 6, stopLoss.Quantity)
+	assert.Equal(t, 6, *stopLoss.RemainingParts)
+	_, available, err := CanSell(1, security.ID, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, available)
+
+	// Bez novog izvršenja entry-ja izlazi ostaju nepromenjeni
+	activateBracket(entry)
+	assert.NoError(t, db.DB.First(&stopLoss, stopLoss.ID).Error)
+	assert.Equal(t, 6, stopLoss.Quantity)
+}
@@ -0,0 +1,267 @@
+package orders
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"banka1.com/clock"
+	"banka1.com/db"
+	"banka1.com/exchanges"
+	"banka1.com/types"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAlreadyHalted = errors.New("trgovanje je već obustavljeno")
+	ErrNotHalted     = errors.New("trgovanje nije obustavljeno")
+)
+
+// priceLimits je dozvoljeni opseg cena hartije; bez konfigurisanog opsega sve cene su dozvoljene.
+type priceLimits struct {
+	low, high float64
+	banded    bool
+}
+
+func (l priceLimits) allows(price float64) bool {
+	return !l.banded || (price >= l.low && price <= l.high)
+}
+
+// PriceBandFor vraća opseg cena za tip instrumenta hartije, ako je konfigurisan.
+func PriceBandFor(tx *gorm.DB, security types.Security) (types.PriceBand, bool) {
+	var band types.PriceBand
+	if err := tx.Where("lower(instrument_type) = ?", strings.ToLower(security.Type)).First(&band).Error; err != nil {
+		return band, false
+	}
+	return band, true
+}
+
+// bandFor računa granice opsega oko referentne cene iz listinga.
+func bandFor(tx *gorm.DB, securityID uint) priceLimits {
+	var security types.Security
+	if err := tx.First(&security, securityID).Error; err != nil {
+		return priceLimits{}
+	}
+	band, ok := PriceBandFor(tx, security)
+	if !ok || band.MaxDeviation <= 0 {
+		return priceLimits{}
+	}
+	reference := markPrice(tx, security)
+	if reference <= 0 {
+		return priceLimits{}
+	}
+	deviation := reference * band.MaxDeviation / 100
+	return priceLimits{low: reference - deviation, high: reference + deviation, banded: true}
+}
+
+// CheckPriceBand proverava da li su limit i stop cena ordera unutar opsega cena hartije.
+func CheckPriceBand(securityID uint, prices ...*float64) error {
+	limits := bandFor(db.DB, securityID)
+	for _, price := range prices {
+		if price != nil && !limits.allows(*price) {
+			return fmt.Errorf("cena %.2f je van dozvoljenog opsega %.2f - %.2f", *price, limits.low, limits.high)
+		}
+	}
+	return nil
+}
+
+// ActiveHalt vraća obustavu koja trenutno važi za hartiju ili berzu na kojoj se
+// hartija kotira, ili nil ako se hartijom trguje.
+func ActiveHalt(securityID uint) *types.TradingHalt {
+	query := db.DB.Where("ended_at IS NULL AND (resume_at IS NULL OR resume_at > ?)", clock.Now())
+	if exchange, err := exchanges.ForSecurity(securityID); err == nil {
+		query = query.Where("security_id = ? OR exchange_id = ?", securityID, exchange.ID)
+	} else {
+		query = query.Where("security_id = ?", securityID)
+	}
+
+	var halt types.TradingHalt
+	if err := query.Order("id").First(&halt).Error; err != nil {
+		return nil
+	}
+	return &halt
+}
+
+// HaltSecurity obustavlja trgovanje hartijom. Sa minutes > 0 obustava se sama
+// završava posle tog vremena, inače traje do ručnog nastavka.
+func HaltSecurity(securityID uint, reason string, minutes int, haltedBy *uint) (types.TradingHalt, error) {
+	return placeHalt(types.TradingHalt{SecurityID: &securityID, Reason: reason, HaltedBy: haltedBy}, minutes)
+}
+
+// HaltExchange obustavlja trgovanje svim hartijama na berzi.
+func HaltExchange(exchangeID uint, reason string, minutes int, haltedBy *uint) (types.TradingHalt, error) {
+	return placeHalt(types.TradingHalt{ExchangeID: &exchangeID, Reason: reason, HaltedBy: haltedBy}, minutes)
+}
+
+func placeHalt(halt types.TradingHalt, minutes int) (types.TradingHalt, error) {
+	now := clock.Now()
+	var count int64
+	if err := haltsFor(db.DB.Model(&types.TradingHalt{}), halt, now).Count(&count).Error; err != nil {
+		return halt, err
+	}
+	if count > 0 {
+		return halt, ErrAlreadyHalted
+	}
+
+	halt.StartedAt = now
+	if minutes > 0 {
+		resumeAt := now.Add(time.Duration(minutes) * time.Minute)
+		halt.ResumeAt = &resumeAt
+	}
+	if err := db.DB.Create(&halt).Error; err != nil {
+		return halt, err
+	}
+	fmt.Printf("Trgovanje obustavljeno (%s): %s\n", haltTarget(halt), halt.Reason)
+	return halt, nil
+}
+
+// ResumeSecurity završava obustavu hartije i vraća njene ordere u matching.
+func ResumeSecurity(securityID uint, resumedBy *uint) (types.TradingHalt, error) {
+	return endHalt(types.TradingHalt{SecurityID: &securityID}, resumedBy)
+}
+
+// ResumeExchange završava obustavu berze i vraća ordere njenih hartija u matching.
+func ResumeExchange(exchangeID uint, resumedBy *uint) (types.TradingHalt, error) {
+	return endHalt(types.TradingHalt{ExchangeID: &exchangeID}, resumedBy)
+}
+
+func endHalt(target types.TradingHalt, resumedBy *uint) (types.TradingHalt, error) {
+	now := clock.Now()
+	var halt types.TradingHalt
+	if err := haltsFor(db.DB, target, now).First(&halt).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return halt, ErrNotHalted
+		}
+		return halt, err
+	}
+
+	halt.EndedAt = &now
+	halt.ResumedBy = resumedBy
+	if err := db.DB.Save(&halt).Error; err != nil {
+		return halt, err
+	}
+	fmt.Printf("Trgovanje nastavljeno (%s)\n", haltTarget(halt))
+	wakeHalted(halt)
+	return halt, nil
+}
+
+// ResumeExpiredHalts zatvara obustave kojima je isteklo vreme i vraća ordere
+// pogođenih hartija u matching. Vraća broj zatvorenih obustava.
+func ResumeExpiredHalts() int {
+	var halts []types.TradingHalt
+	if err := db.DB.Where("ended_at IS NULL AND resume_at <= ?", clock.Now()).Find(&halts).Error; err != nil {
+		fmt.Printf("Greska pri citanju obustava trgovanja: %v\n", err)
+		return 0
+	}
+	for _, halt := range halts {
+		halt.EndedAt = halt.ResumeAt
+		if err := db.DB.Save(&halt).Error; err != nil {
+			fmt.Printf("Greska pri zatvaranju obustave %d: %v\n", halt.ID, err)
+			continue
+		}
+		fmt.Printf("Istekla obustava trgovanja (%s)\n", haltTarget(halt))
+		wakeHalted(halt)
+	}
+	return len(halts)
+}
+
+// haltsFor filtrira aktivne obustave iste hartije ili berze kao target.
+func haltsFor(query *gorm.DB, target types.TradingHalt, now time.Time) *gorm.DB {
+	query = query.Where("ended_at IS NULL AND (resume_at IS NULL OR resume_at > ?)", now)
+	if target.SecurityID != nil {
+		return query.Where("security_id = ?", *target.SecurityID)
+	}
+	return query.Where("exchange_id = ?", *target.ExchangeID)
+}
+
+func haltTarget(halt types.TradingHalt) string {
+	if halt.SecurityID != nil {
+		return fmt.Sprintf("hartija %d", *halt.SecurityID)
+	}
+	return fmt.Sprintf("berza %d", *halt.ExchangeID)
+}
+
+// wakeHalted vraća u matching sve ordere iz knjiga hartija pogođenih obustavom,
+// uključujući i one koji čekaju odloženi pokušaj.
+func wakeHalted(halt types.TradingHalt) {
+	securityIDs := []uint{}
+	if halt.SecurityID != nil {
+		securityIDs = append(securityIDs, *halt.SecurityID)
+	} else {
+		db.DB.Model(&types.Security{}).
+			Where("ticker IN (?)", db.DB.Model(&types.Listing{}).Select("ticker").Where("exchange_id = ?", *halt.ExchangeID)).
+			Pluck("id", &securityIDs)
+	}
+
+	for _, securityID := range securityIDs {
+		wakeBook(securityID)
+	}
+}
+
+// wakeBook vraća u matching sve ordere iz knjige hartije, uključujući i one
+// koji čekaju odloženi pokušaj.
+func wakeBook(securityID uint) {
+	startScheduler()
+	book := getBook(securityID)
+	book.mu.RLock()
+	ids := make([]uint, 0, len(book.index))
+	for _, side := range [][]*bookEntry{book.bids, book.asks} {
+		for _, e := range side {
+			ids = append(ids, e.OrderID)
+		}
+	}
+	book.mu.RUnlock()
+
+	for _, orderID := range ids {
+		retries.clear(orderID)
+		enqueue(securityID, orderID)
+	}
+}
+
+// checkVolatility automatski obustavlja hartiju kada se cena internih trgovina u
+// prozoru opsega pomeri više od dozvoljenog procenta.
+func checkVolatility(securityID uint) {
+	var security types.Security
+	if err := db.DB.First(&security, securityID).Error; err != nil {
+		return
+	}
+	band, ok := PriceBandFor(db.DB, security)
+	if !ok || band.VolatilityLimit <= 0 || band.VolatilityWindow <= 0 {
+		return
+	}
+
+	now := clock.Now()
+	var prices struct {
+		Low  float64
+		High float64
+	}
+	if err := db.DB.Model(&types.Transaction{}).
+		Select("COALESCE(MIN(price_per_unit), 0) AS low, COALESCE(MAX(price_per_unit), 0) AS high").
+		Where("security_id = ? AND COALESCE(bank_principal, false) = false AND created_at >= ?", securityID, now.Add(-time.Duration(band.VolatilityWindow)*time.Minute)).
+		Scan(&prices).Error; err != nil {
+		fmt.Printf("Greska pri proveri volatilnosti hartije %d: %v\n", securityID, err)
+		return
+	}
+	if prices.Low <= 0 {
+		return
+	}
+	move := (prices.High - prices.Low) / prices.Low * 100
+	if move <= band.VolatilityLimit {
+		return
+	}
+
+	minutes := band.HaltMinutes
+	if minutes <= 0 {
+		minutes = band.VolatilityWindow
+	}
+	reason := fmt.Sprintf("volatilnost: cena se pomerila %.2f%% za %d min", move, band.VolatilityWindow)
+	halt, err := placeHalt(types.TradingHalt{SecurityID: &securityID, Reason: reason, Automatic: true}, minutes)
+	if err != nil && !errors.Is(err, ErrAlreadyHalted) {
+		fmt.Printf("Greska pri automatskoj obustavi hartije %d: %v\n", securityID, err)
+		return
+	}
+	if err == nil {
+		fmt.Printf("Automatska obustava %d hartije %d do %s\n", halt.ID, securityID, halt.ResumeAt.Format(time.RFC3339))
+	}
+}
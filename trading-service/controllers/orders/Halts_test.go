@@ -0,0 +1,139 @@
+package orders
+
+import (
+	"testing"
+	"time"
+
+	"banka1.com/clock"
+	"banka1.com/db"
+	"banka1.com/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func setPriceBand(t *testing.T, band types.PriceBand) {
+	band.InstrumentType = "Stock"
+	db.DB.Where("instrument_type = ?", band.InstrumentType).Delete(&types.PriceBand{})
+	assert.NoError(t, db.DB.Create(&band).Error)
+	t.Cleanup(func() {
+		db.DB.Where("instrument_type = ?", band.InstrumentType).Delete(&types.PriceBand{})
+	})
+}
+
+func TestPriceBand_RejectsPricesAndBlocksFills(t *testing.T) {
+	security := setupBankMarket(t)
+	assert.NoError(t, db.DB.Create(&types.Portfolio{UserID: BankUserID, SecurityID: security.ID, Quantity: 10, PurchasePrice: 90}).Error)
+
+	// Bez opsega nema ograničenja
+	assert.NoError(t, CheckPriceBand(security.ID, fptr(1000)))
+
+	setPriceBand(t, types.PriceBand{MaxDeviation: 0.5})
+	assert.NoError(t, CheckPriceBand(security.ID, fptr(100.4), nil))
+	assert.Error(t, CheckPriceBand(security.ID, fptr(100), fptr(99)))
+
+	// Kotacija banke (Ask 101) je van opsega 99.5 - 100.5
+	order := types.Order{UserID: 1, AccountID: 7, SecurityID: security.ID, Direction: "buy", OrderType: "MARKET", Quantity: 2, RemainingParts: ptr(2), Status: "approved"}
+	assert.NoError(t, db.DB.Create(&order).Error)
+	filled, _, _ := executePartial(&order, db.DB)
+	assert.Equal(t, 0, filled)
+
+	assert.NoError(t, db.DB.Model(&types.PriceBand{}).Where("instrument_type = ?", "Stock").Update("max_deviation", 5).Error)
+	filled, _, _ = executePartial(&order, db.DB)
+	assert.Equal(t, 2, filled)
+}
+
+func TestTradingHalt_BlocksUntilResumed(t *testing.T) {
+	security := setupBankMarket(t)
+	sim := clock.NewSimulated(time.Date(2025, 6, 16, 12, 0, 0, 0, time.UTC), 0)
+	defer clock.Use(sim)()
+	t.Cleanup(func() {
+		db.DB.Where("security_id = ?", security.ID).Delete(&types.TradingHalt{})
+	})
+	assert.NoError(t, db.DB.Create(&types.Portfolio{UserID: BankUserID, SecurityID: security.ID, Quantity: 10, PurchasePrice: 90}).Error)
+
+	halt, err := HaltSecurity(security.ID, "vest", 10, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, sim.Now().Add(10*time.Minute), halt.ResumeAt.UTC())
+	_, err = HaltSecurity(security.ID, "vest", 0, nil)
+	assert.ErrorIs(t, err, ErrAlreadyHalted)
+	assert.NotNil(t, ActiveHalt(security.ID))
+
+	order := types.Order{UserID: 1, AccountID: 7, SecurityID: security.ID, Direction: "buy", OrderType: "MARKET", Quantity: 2, RemainingParts: ptr(2), Status: "approved"}
+	assert.NoError(t, db.DB.Create(&order).Error)
+	filled, _, _ := executePartial(&order, db.DB)
+	assert.Equal(t, 0, filled)
+	assert.False(t, marketAllows(&order))
+
+	// Vremenska obustava se sama završava
+	sim.Advance(11 * time.Minute)
+	assert.Nil(t, ActiveHalt(security.ID))
+	assert.Equal(t, 1, ResumeExpiredHalts())
+	assert.NoError(t, db.DB.First(&halt, halt.ID).Error)
+	assert.NotNil(t, halt.EndedAt)
+
+	// Ručna obustava traje do nastavka
+	_, err = HaltSecurity(security.ID, "", 0, nil)
+	assert.NoError(t, err)
+	sim.Advance(24 * time.Hour)
+	assert.NotNil(t, ActiveHalt(security.ID))
+	_, err = ResumeSecurity(security.ID, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, ActiveHalt(security.ID))
+	_, err = ResumeSecurity(security.ID, nil)
+	assert.ErrorIs(t, err, ErrNotHalted)
+
+	filled, _, _ = executePartial(&order, db.DB)
+	assert.Equal(t, 2, filled)
+}
+
+func TestTradingHalt_ExchangeHaltCoversListedSecurities(t *testing.T) {
+	security := setupBankMarket(t)
+	exchange := types.Exchange{Name: "Halt berza", Acronym: "HLT", MicCode: "XHLT", Country: "USA", Currency: "USD",
+		Timezone: "America/New_York", OpenTime: " 09:30", CloseTime: " 16:00"}
+	assert.NoError(t, db.DB.Create(&exchange).Error)
+	assert.NoError(t, db.DB.Model(&types.Listing{}).Where("ticker = ?", security.Ticker).Update("exchange_id", exchange.ID).Error)
+	t.Cleanup(func() {
+		db.DB.Where("exchange_id = ?", exchange.ID).Delete(&types.TradingHalt{})
+		db.DB.Delete(&exchange)
+	})
+
+	_, err := HaltExchange(exchange.ID, "tehnički problem", 0, nil)
+	assert.NoError(t, err)
+	halt := ActiveHalt(security.ID)
+	if assert.NotNil(t, halt) {
+		assert.Equal(t, exchange.ID, *halt.ExchangeID)
+	}
+
+	_, err = ResumeExchange(exchange.ID, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, ActiveHalt(security.ID))
+}
+
+func TestCheckVolatility_HaltsSecurity(t *testing.T) {
+	security := setupBankMarket(t)
+	t.Cleanup(func() {
+		db.DB.Where("security_id = ?", security.ID).Delete(&types.TradingHalt{})
+	})
+	setPriceBand(t, types.PriceBand{MaxDeviation: 50, VolatilityLimit: 5, VolatilityWindow: 10, HaltMinutes: 15})
+
+	trade := func(price float64, bank bool) {
+		assert.NoError(t, db.DB.Create(&types.Transaction{BuyerID: 1, SellerID: 2, SecurityID: security.ID, Quantity: 1, PricePerUnit: price, TotalPrice: price, BankPrincipal: bank}).Error)
+	}
+
+	// Trgovine sa bankom se ne računaju u volatilnost
+	trade(100, false)
+	trade(120, true)
+	checkVolatility(security.ID)
+	assert.Nil(t, ActiveHalt(security.ID))
+
+	trade(104, false)
+	checkVolatility(security.ID)
+	assert.Nil(t, ActiveHalt(security.ID))
+
+	trade(106, false)
+	checkVolatility(security.ID)
+	halt := ActiveHalt(security.ID)
+	if assert.NotNil(t, halt) {
+		assert.True(t, halt.Automatic)
+		assert.WithinDuration(t, clock.Now().Add(15*time.Minute), *halt.ResumeAt, time.Minute)
+	}
+}
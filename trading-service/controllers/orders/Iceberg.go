@@ -0,0 +1,50 @@
+package orders
+
+import (
+	"banka1.com/clock"
+	"banka1.com/types"
+)
+
+// MakeIceberg pretvara order u iceberg: u knjizi i objavljenom volumenu se vidi
+// samo displayQuantity, a ostatak je skrivena rezerva.
+func MakeIceberg(order *types.Order, displayQuantity int) {
+	visible := min(displayQuantity, ptrSafe(order.RemainingParts))
+	order.DisplayQuantity = &displayQuantity
+	order.VisibleRemaining = &visible
+}
+
+func isIceberg(order types.Order) bool {
+	return order.DisplayQuantity != nil && *order.DisplayQuantity > 0
+}
+
+// visibleQuantity vraća količinu ordera koja je vidljiva drugima i dostupna za matchovanje.
+func visibleQuantity(order types.Order) int {
+	remaining := ptrSafe(order.RemainingParts)
+	if !isIceberg(order) {
+		return remaining
+	}
+	if order.VisibleRemaining == nil {
+		return min(*order.DisplayQuantity, remaining)
+	}
+	return min(*order.VisibleRemaining, remaining)
+}
+
+// consumeVisible umanjuje vidljivi deo iceberg ordera za izvršenu količinu
+// (RemainingParts mora već biti umanjen). Kada se vidljivi deo potroši, puni se
+// iz rezerve i order gubi vremenski prioritet.
+func consumeVisible(order *types.Order, visibleBefore, filled int) {
+	if !isIceberg(*order) {
+		return
+	}
+
+	remaining := ptrSafe(order.RemainingParts)
+	visible := min(visibleBefore-filled, remaining)
+	if visible <= 0 && remaining > 0 {
+		visible = min(*order.DisplayQuantity, remaining)
+		order.PriorityTime = clock.Now().UnixNano()
+	}
+	if visible < 0 {
+		visible = 0
+	}
+	order.VisibleRemaining = &visible
+}
@@ -0,0 +1,50 @@
+package orders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIceberg_OnlyDisplayedPartIsVisible(t *testing.T) {
+	order := restingOrder(1, 10, "sell", "LIMIT", fptr(100), 50, 1)
+	MakeIceberg(&order, 10)
+
+	assert.Equal(t, 10, visibleQuantity(order))
+
+	book := newOrderBook(1)
+	book.upsert(order)
+	assert.Equal(t, 10, book.Depth("sell"))
+}
+
+func TestIceberg_PartialFillKeepsPriority(t *testing.T) {
+	order := restingOrder(1, 10, "sell", "LIMIT", fptr(100), 50, 1)
+	MakeIceberg(&order, 10)
+
+	*order.RemainingParts -= 4
+	consumeVisible(&order, 10, 4)
+
+	assert.Equal(t, 6, visibleQuantity(order))
+	assert.Equal(t, int64(1), order.PriorityTime)
+}
+
+func TestIceberg_RefreshLosesPriority(t *testing.T) {
+	order := restingOrder(1, 10, "sell", "LIMIT", fptr(100), 50, 1)
+	MakeIceberg(&order, 10)
+
+	*order.RemainingParts -= 10
+	consumeVisible(&order, 10, 10)
+
+	assert.Equal(t, 10, visibleQuantity(order))
+	assert.Greater(t, order.PriorityTime, int64(1))
+}
+
+func TestIceberg_LastSliceIsWhatRemains(t *testing.T) {
+	order := restingOrder(1, 10, "sell", "LIMIT", fptr(100), 15, 1)
+	MakeIceberg(&order, 10)
+
+	*order.RemainingParts -= 10
+	consumeVisible(&order, 10, 10)
+
+	assert.Equal(t, 5, visibleQuantity(order))
+}
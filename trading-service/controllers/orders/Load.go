@@ -4,8 +4,8 @@ import (
 	"banka1.com/broker"
 	"fmt"
 	"log"
-	"time"
 
+	"banka1.com/clock"
 	"banka1.com/db"
 	"banka1.com/types"
 )
@@ -55,6 +55,7 @@ func LoadOrders() {
 	}).Error; err != nil {
 		log.Println("Error while adding order1:", err)
 	} else {
+		AddToBook(order1)
 		log.Printf("Order succesfully added to Security ID %d (order1)\n", order1.SecurityID)
 	}
 
@@ -65,6 +66,7 @@ func LoadOrders() {
 	}).Error; err != nil {
 		log.Println("Error while adding order2:", err)
 	} else {
+		AddToBook(order2)
 		log.Printf("Order succesfully added to Security ID %d (order2)\n", order2.SecurityID)
 	}
 }
@@ -170,6 +172,7 @@ func LoadPortfolios() {
 
 func CreateInitialSellOrdersFromBank() {
 	const InitialQuantity = 50
+	const DisplayQuantity = 10 // Bankini orderi su iceberg, da ne otkrivaju celu ponudu
 	const BankUserId = 5
 	orderTypes := []string{"LIMIT", "MARKET", "STOP", "STOP-LIMIT"}
 
@@ -230,7 +233,7 @@ func CreateInitialSellOrdersFromBank() {
 					RemainingParts: ptr(InitialQuantity),
 					Status:         "approved",
 					IsDone:         false,
-					LastModified:   time.Now().Unix(),
+					LastModified:   clock.Now().Unix(),
 				}
 			} else {
 
@@ -244,7 +247,7 @@ func CreateInitialSellOrdersFromBank() {
 					RemainingParts: ptr(InitialQuantity),
 					Status:         "approved",
 					IsDone:         false,
-					LastModified:   time.Now().Unix(),
+					LastModified:   clock.Now().Unix(),
 				}
 			}
 			switch ot {
@@ -270,8 +273,14 @@ func CreateInitialSellOrdersFromBank() {
 			}
 
 			fmt.Printf("Kreiram SELL order za %s (TIP=%s)\n", sec.Ticker, ot)
+			MakeIceberg(&order, DisplayQuantity)
+			order.PriorityTime = clock.Now().UnixNano()
 			if err := db.DB.Create(&order).Error; err != nil {
 				log.Printf("Greska pri kreiranju SELL ordera za %s (tip=%s): %v\n", sec.Ticker, ot, err)
+			} else if isPendingTrigger(order) {
+				AddTrigger(order)
+			} else {
+				AddToBook(order)
 			}
 
 			_ = UpdateAvailableVolume(sec.ID)
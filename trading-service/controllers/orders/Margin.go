@@ -0,0 +1,382 @@
+package orders
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"banka1.com/clock"
+	"banka1.com/db"
+	"banka1.com/exchanges"
+	"banka1.com/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// MaintenanceMarginRate je deo vrednosti portfolija koji kapital mora da pokrije.
+	MaintenanceMarginRate = 0.3
+	// InitialMarginRate je deo vrednosti kupovine koji klijent plaća sam; ostatak je zaduženje.
+	InitialMarginRate = MaintenanceMarginRate * 1.1
+
+	DefaultMarginCallDeadline = 24 * time.Hour
+)
+
+const (
+	MarginStatusOK          = "ok"
+	MarginStatusCall        = "call"
+	MarginStatusLiquidating = "liquidating"
+
+	MarginCallOpen       = "open"
+	MarginCallMet        = "met"
+	MarginCallLiquidated = "liquidated"
+)
+
+// MarginCallDeadline vraća rok za ispunjenje margin poziva iz MARGIN_CALL_DEADLINE
+// (npr. "24h"), podrazumevano 24 sata.
+func MarginCallDeadline() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("MARGIN_CALL_DEADLINE")); err == nil && d > 0 {
+		return d
+	}
+	return DefaultMarginCallDeadline
+}
+
+// settleMargin ažurira zaduženje margin naloga posle izvršenja margin ordera: kupovina
+// povećava zaduženje za deo koji klijent nije platio, a prodaja ga otplaćuje. Priliv
+// od short prodaje ostaje na nalogu kao kolateral i prvi pokriva kupovinu kojom se
+// short zatvara. Poziva se posle ažuriranja portfolija.
+func settleMargin(tx *gorm.DB, orderID uint, quantity int, price float64) error {
+	if tx == nil {
+		tx = db.DB
+	}
+	var order types.Order
+	if err := tx.First(&order, orderID).Error; err != nil {
+		return err
+	}
+	if !order.Margin {
+		return nil
+	}
+	notional := Notional(order, price, quantity)
+
+	var account types.MarginAccount
+	err := tx.Where("user_id = ?", order.UserID).First(&account).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("citanje margin naloga korisnika %d: %w", order.UserID, err)
+	}
+	position, err := portfolioQuantity(tx, order.UserID, order.SecurityID)
+	if err != nil {
+		return fmt.Errorf("citanje pozicije korisnika %d: %w", order.UserID, err)
+	}
+	if account.ID == 0 {
+		account = types.MarginAccount{UserID: order.UserID, AccountID: order.AccountID, Status: MarginStatusOK}
+	}
+
+	if strings.ToLower(order.Direction) == "buy" {
+		covered := min(quantity, max(quantity-position, 0))
+		fromCash := min(account.CashBalance, Notional(order, price, covered))
+		account.CashBalance -= fromCash
+		account.LoanBalance += (notional - fromCash) * (1 - InitialMarginRate)
+	} else {
+		shorted := min(quantity, max(-position, 0))
+		if shorted == 0 && account.ID == 0 {
+			return nil
+		}
+		proceeds := Notional(order, price, shorted)
+		account.CashBalance += proceeds
+		account.LoanBalance -= min(account.LoanBalance, notional-proceeds)
+	}
+	return tx.Save(&account).Error
+}
+
+// OpenMarginAccount otvara margin nalog klijentu kome je banking servis potvrdio
+// odobren kredit; postojeći nalog ostaje nepromenjen.
+func OpenMarginAccount(userID, accountID uint) error {
+	return db.DB.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&types.MarginAccount{UserID: userID, AccountID: accountID, Status: MarginStatusOK}).Error
+}
+
+// marginAllowed proverava da li margin strane meča smeju da se izvrše po ceni price.
+// Aktuar mora imati slobodan limit za inicijalnu marginu preostalog dela ordera.
+// Klijent mora imati margin nalog, otvoren kada je pri kreiranju ordera potvrđen
+// odobren kredit; njegov order se zaustavlja i kada bi povećao izloženost dok je
+// margin nalog u margin pozivu ili likvidaciji.
+func marginAllowed(tx *gorm.DB, price float64, sides ...types.Order) error {
+	for _, order := range sides {
+		if !order.Margin {
+			continue
+		}
+		quantity := ptrSafe(order.RemainingParts)
+
+		var actuary types.Actuary
+		err := tx.Where("user_id = ?", order.UserID).First(&actuary).Error
+		if err == nil {
+			if actuary.LimitAmount-actuary.UsedLimit < Notional(order, price, quantity)*InitialMarginRate {
+				return fmt.Errorf("order %d: aktuar nema dovoljno limita", order.ID)
+			}
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("order %d: %w", order.ID, err)
+		}
+
+		var account types.MarginAccount
+		err = tx.Where("user_id = ?", order.UserID).First(&account).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("order %d: korisnik %d nema margin nalog", order.ID, order.UserID)
+		}
+		if err != nil {
+			return fmt.Errorf("order %d: %w", order.ID, err)
+		}
+		if account.Status == MarginStatusOK {
+			continue
+		}
+		// Prodaja pokrivena pozicijom (i prinudna prodaja) smanjuje izloženost
+		if strings.ToLower(order.Direction) == "sell" {
+			position, err := portfolioQuantity(tx, order.UserID, order.SecurityID)
+			if err != nil {
+				return fmt.Errorf("order %d: %w", order.ID, err)
+			}
+			if position >= quantity {
+				continue
+			}
+		}
+		return fmt.Errorf("order %d: margin nalog korisnika %d je u statusu %s", order.ID, order.UserID, account.Status)
+	}
+	return nil
+}
+
+// marginPosition je pozicija u portfoliju sa cenom po kojoj se vrednuje.
+type marginPosition struct {
+	SecurityID   uint
+	Quantity     int
+	Price        float64
+	ContractSize int
+}
+
+// value vraća vrednost pozicije; short pozicija ima negativnu vrednost.
+func (p marginPosition) value() float64 {
+	return float64(p.Quantity) * p.Price * float64(p.ContractSize)
+}
+
+// marginPositions vrednuje duge i short pozicije korisnika po trenutnoj ceni iz
+// listinga, a ako listing ne postoji po poslednjoj ceni hartije.
+func marginPositions(tx *gorm.DB, userID uint) ([]marginPosition, error) {
+	var portfolios []types.Portfolio
+	if err := tx.Where("user_id = ? AND quantity <> 0", userID).Find(&portfolios).Error; err != nil {
+		return nil, err
+	}
+
+	positions := make([]marginPosition, 0, len(portfolios))
+	for _, portfolio := range portfolios {
+		var security types.Security
+		if err := tx.First(&security, portfolio.SecurityID).Error; err != nil {
+			continue
+		}
+		positions = append(positions, marginPosition{
+			SecurityID:   portfolio.SecurityID,
+			Quantity:     portfolio.Quantity,
+			Price:        markPrice(tx, security),
+			ContractSize: SecurityContractSize(security),
+		})
+	}
+	return positions, nil
+}
+
+// revalueMarginAccount računa vrednost dugih i short pozicija, kapital i zahtev
+// održavanja margin naloga po trenutnim cenama i upisuje ih.
+func revalueMarginAccount(tx *gorm.DB, account *types.MarginAccount) ([]marginPosition, error) {
+	positions, err := marginPositions(tx, account.UserID)
+	if err != nil {
+		return nil, err
+	}
+	marketValue, shortValue := 0.0, 0.0
+	for _, position := range positions {
+		if value := position.value(); value > 0 {
+			marketValue += value
+		} else {
+			shortValue -= value
+		}
+	}
+
+	now := clock.Now()
+	account.MarketValue = marketValue
+	account.ShortValue = shortValue
+	account.Equity = marketValue + account.CashBalance - account.LoanBalance - shortValue
+	account.MaintenanceRequirement = (marketValue + shortValue) * MaintenanceMarginRate
+	account.RevaluedAt = &now
+	return positions, tx.Save(account).Error
+}
+
+// HasOpenMarginCall proverava da li korisnik ima neispunjen margin poziv.
+func HasOpenMarginCall(userID uint) bool {
+	var count int64
+	db.DB.Model(&types.MarginCall{}).Where("user_id = ? AND status = ?", userID, MarginCallOpen).Count(&count)
+	return count > 0
+}
+
+// RevalueMarginAccounts revalorizuje sve margin naloge sa zaduženjem ili short
+// pozicijama, izdaje margin
+// pozive kada kapital padne ispod zahteva održavanja, zatvara ispunjene pozive i
+// prinudno prodaje pozicije kada rok poziva istekne. Vraća broj obrađenih naloga.
+func RevalueMarginAccounts() int {
+	var accounts []types.MarginAccount
+	if err := db.DB.Where("loan_balance > 0 OR short_value > 0 OR cash_balance > 0 OR status <> ?", MarginStatusOK).Find(&accounts).Error; err != nil {
+		fmt.Printf("Greska pri citanju margin naloga: %v\n", err)
+		return 0
+	}
+
+	for _, account := range accounts {
+		if err := reviewMarginAccount(account); err != nil {
+			fmt.Printf("Greska pri revalorizaciji margin naloga korisnika %d: %v\n", account.UserID, err)
+		}
+	}
+	return len(accounts)
+}
+
+func reviewMarginAccount(account types.MarginAccount) error {
+	positions, err := revalueMarginAccount(db.DB, &account)
+	if err != nil {
+		return err
+	}
+
+	var call types.MarginCall
+	err = db.DB.Where("margin_account_id = ? AND status = ?", account.ID, MarginCallOpen).First(&call).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	hasCall := err == nil
+	now := clock.Now()
+	deficient := (account.LoanBalance > 0 || account.ShortValue > 0) && account.Equity < account.MaintenanceRequirement
+
+	switch {
+	case !deficient:
+		if hasCall {
+			call.Status = MarginCallMet
+			call.ResolvedAt = &now
+			if err := db.DB.Save(&call).Error; err != nil {
+				return err
+			}
+			fmt.Printf("Margin poziv %d korisnika %d je ispunjen\n", call.ID, account.UserID)
+		}
+		return db.DB.Model(&account).Update("status", MarginStatusOK).Error
+
+	case !hasCall && account.Status == MarginStatusLiquidating && openLiquidations(account.UserID) > 0:
+		// Prinudne prodaje su još u knjizi; novi poziv tek kada se završe
+		return nil
+
+	case !hasCall:
+		call = types.MarginCall{
+			MarginAccountID:        account.ID,
+			UserID:                 account.UserID,
+			Status:                 MarginCallOpen,
+			Equity:                 account.Equity,
+			MaintenanceRequirement: account.MaintenanceRequirement,
+			Deficit:                account.MaintenanceRequirement - account.Equity,
+			Deadline:               now.Add(MarginCallDeadline()),
+		}
+		if err := db.DB.Create(&call).Error; err != nil {
+			return err
+		}
+		fmt.Printf("Margin poziv %d za korisnika %d: kapital %.2f ispod zahteva %.2f, rok %s\n",
+			call.ID, account.UserID, account.Equity, account.MaintenanceRequirement, call.Deadline.Format(time.RFC3339))
+		return db.DB.Model(&account).Update("status", MarginStatusCall).Error
+
+	case now.After(call.Deadline):
+		return liquidate(account, call, positions)
+	}
+	return nil
+}
+
+// openLiquidations broji neizvršene margin sell ordere korisnika.
+func openLiquidations(userID uint) int64 {
+	var count int64
+	db.DB.Model(&types.Order{}).
+		Where("user_id = ? AND margin AND lower(direction) = 'sell' AND status = ? AND NOT is_done", userID, "approved").
+		Count(&count)
+	return count
+}
+
+// liquidationTarget je vrednost koju treba prodati da bi kapital ponovo pokrio zahtev
+// održavanja: prodaja vrednosti V smanjuje i zaduženje i vrednost portfolija za V, pa
+// zahtev pada za V×MaintenanceMarginRate dok kapital ostaje isti.
+func liquidationTarget(account types.MarginAccount) float64 {
+	return account.MarketValue + account.ShortValue - account.Equity/MaintenanceMarginRate
+}
+
+// liquidate šalje MARKET sell ordere kroz matching engine, počevši od najvrednijih
+// pozicija, dok se ne pokrije iznos potreban za povratak iznad zahteva održavanja.
+func liquidate(account types.MarginAccount, call types.MarginCall, positions []marginPosition) error {
+	target := liquidationTarget(account)
+	sort.SliceStable(positions, func(i, j int) bool { return positions[i].value() > positions[j].value() })
+
+	var created []types.Order
+	for _, position := range positions {
+		if target <= 0 {
+			break
+		}
+		unit := position.Price * float64(position.ContractSize)
+		// Short pozicije se zatvaraju kroz opoziv pozajmice, ne prodajom
+		if unit <= 0 || position.Quantity <= 0 {
+			continue
+		}
+		_, available, err := CanSell(account.UserID, position.SecurityID, 0)
+		if err != nil {
+			continue
+		}
+		quantity := min(int(math.Ceil(target/unit)), available)
+		if quantity <= 0 {
+			continue
+		}
+
+		now := clock.Now()
+		order := types.Order{
+			UserID:         account.UserID,
+			AccountID:      account.AccountID,
+			SecurityID:     position.SecurityID,
+			Quantity:       quantity,
+			ContractSize:   position.ContractSize,
+			OrderType:      "MARKET",
+			Direction:      "sell",
+			Status:         "approved",
+			LastModified:   now.Unix(),
+			RemainingParts: &quantity,
+			AfterHours:     exchanges.IsAfterHours(exchanges.StatusForSecurity(position.SecurityID, now)),
+			Margin:         true,
+			TimeInForce:    NormalizeTimeInForce(""),
+			PriorityTime:   now.UnixNano(),
+		}
+		if err := db.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&order).Error; err != nil {
+				return err
+			}
+			return holdShares(tx, order, order.Status)
+		}); err != nil {
+			return err
+		}
+		RecordStatus(order, EventCreated, nil, fmt.Sprintf("prinudna prodaja, margin poziv %d", call.ID))
+		created = append(created, order)
+		target -= float64(quantity) * unit
+	}
+
+	now := clock.Now()
+	call.Status = MarginCallLiquidated
+	call.ResolvedAt = &now
+	call.LiquidationOrders = len(created)
+	if err := db.DB.Save(&call).Error; err != nil {
+		return err
+	}
+	if err := db.DB.Model(&account).Update("status", MarginStatusLiquidating).Error; err != nil {
+		return err
+	}
+	fmt.Printf("Margin poziv %d korisnika %d nije ispunjen u roku, poslato %d naloga za prinudnu prodaju\n",
+		call.ID, account.UserID, len(created))
+
+	for _, order := range created {
+		StartOrder(order)
+	}
+	return nil
+}
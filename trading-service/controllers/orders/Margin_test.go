@@ -0,0 +1,136 @@
+package orders
+
+import (
+	"testing"
+	"time"
+
+	"banka1.com/clock"
+	"banka1.com/db"
+	"banka1.com/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarginCallAndLiquidation(t *testing.T) {
+	security := setupBankMarket(t)
+	// Prinudne prodaje ostaju u knjizi umesto da ih odmah preuzme banka
+	t.Setenv("MARKET_ACCESS_MODE", MarketAccessInternal)
+	t.Setenv("MARGIN_CALL_DEADLINE", "24h")
+	sim := clock.NewSimulated(time.Date(2025, 6, 16, 12, 0, 0, 0, time.UTC), 0)
+	defer clock.Use(sim)()
+
+	const userID = 30
+	t.Cleanup(func() {
+		db.DB.Where("user_id = ?", userID).Delete(&types.MarginCall{})
+		db.DB.Where("user_id = ?", userID).Delete(&types.MarginAccount{})
+		db.DB.Where("user_id = ?", userID).Delete(&types.Hold{})
+		db.DB.Where("user_id = ?", userID).Delete(&types.Portfolio{})
+	})
+
+	buy := types.Order{UserID: userID, AccountID: 70, SecurityID: security.ID, Direction: "buy", OrderType: "MARKET", Quantity: 10, RemainingParts: ptr(0), Status: "done", IsDone: true, Margin: true}
+	assert.NoError(t, db.DB.Create(&buy).Error)
+	assert.NoError(t, db.DB.Create(&types.Portfolio{UserID: userID, SecurityID: security.ID, Quantity: 10, PurchasePrice: 100}).Error)
+
+	// Klijent plaća 33%, ostatak je zaduženje
+	assert.NoError(t, settleFill(nil, buy.ID, 0, 10, 100))
+	var account types.MarginAccount
+	assert.NoError(t, db.DB.Where("user_id = ?", userID).First(&account).Error)
+	assert.InDelta(t, 670.0, account.LoanBalance, 1e-9)
+	assert.Equal(t, uint(70), account.AccountID)
+
+	RevalueMarginAccounts()
+	assert.NoError(t, db.DB.First(&account, account.ID).Error)
+	assert.InDelta(t, 1000.0, account.MarketValue, 1e-9)
+	assert.InDelta(t, 330.0, account.Equity, 1e-9)
+	assert.InDelta(t, 300.0, account.MaintenanceRequirement, 1e-9)
+	assert.Equal(t, MarginStatusOK, account.Status)
+	assert.False(t, HasOpenMarginCall(userID))
+
+	// Pad cene: kapital 230 ispod zahteva 270
+	assert.NoError(t, db.DB.Model(&types.Listing{}).Where("ticker = ?", security.Ticker).Update("price", 90).Error)
+	RevalueMarginAccounts()
+	assert.True(t, HasOpenMarginCall(userID))
+	var call types.MarginCall
+	assert.NoError(t, db.DB.Where("user_id = ?", userID).First(&call).Error)
+	assert.InDelta(t, 40.0, call.Deficit, 1e-9)
+	assert.Equal(t, sim.Now().Add(24*time.Hour), call.Deadline.UTC())
+
+	// Pre roka se ništa ne prodaje
+	sim.Advance(23 * time.Hour)
+	RevalueMarginAccounts()
+	assert.NoError(t, db.DB.First(&call, call.ID).Error)
+	assert.Equal(t, MarginCallOpen, call.Status)
+
+	sim.Advance(2 * time.Hour)
+	RevalueMarginAccounts()
+	assert.NoError(t, db.DB.First(&call, call.ID).Error)
+	assert.Equal(t, MarginCallLiquidated, call.Status)
+	assert.Equal(t, 1, call.LiquidationOrders)
+
+	var liquidation types.Order
+	assert.NoError(t, db.DB.Where("user_id = ? AND direction = ?", userID, "sell").First(&liquidation).Error)
+	assert.True(t, liquidation.Margin)
+	assert.Equal(t, "MARKET", liquidation.OrderType)
+	// Treba prodati 900 - 230/0.3 ≈ 133.33, tj. 2 komada po 90
+	assert.Equal(t, 2, liquidation.Quantity)
+	assert.NoError(t, db.DB.First(&account, account.ID).Error)
+	assert.Equal(t, MarginStatusLiquidating, account.Status)
+
+	// Dok je prinudna prodaja u knjizi, novi poziv se ne izdaje
+	RevalueMarginAccounts()
+	assert.False(t, HasOpenMarginCall(userID))
+
+	// Izvršena prodaja otplaćuje zaduženje i nalog se vraća iznad zahteva
+	assert.NoError(t, db.DB.Model(&liquidation).Updates(map[string]any{"remaining_parts": 0, "is_done": true, "status": "done"}).Error)
+	assert.NoError(t, db.DB.Model(&types.Portfolio{}).Where("user_id = ?", userID).Update("quantity", 8).Error)
+	assert.NoError(t, settleFill(nil, liquidation.ID, 0, 2, 90))
+	RevalueMarginAccounts()
+	assert.NoError(t, db.DB.First(&account, account.ID).Error)
+	assert.InDelta(t, 490.0, account.LoanBalance, 1e-9)
+	assert.Equal(t, MarginStatusOK, account.Status)
+
+	t.Cleanup(func() {
+		db.DB.Where("order_id IN ?", []uint{buy.ID, liquidation.ID}).Delete(&types.OrderEvent{})
+	})
+}
+
+func TestCustomerShortSellMatchesRestingBuy(t *testing.T) {
+	security := setupBankMarket(t)
+	t.Setenv("MARKET_ACCESS_MODE", MarketAccessInternal)
+	stubAccounts(t, nil, nil)
+
+	// Klijent bez aktuar zapisa, sa pravom na margin proverenim pri kreiranju ordera
+	const userID = 41
+	t.Cleanup(func() {
+		db.DB.Where("user_id = ?", userID).Delete(&types.SecurityBorrow{})
+		db.DB.Where("user_id = ?", userID).Delete(&types.MarginAccount{})
+		booksMu.Lock()
+		delete(books, security.ID)
+		booksMu.Unlock()
+	})
+	assert.NoError(t, db.DB.Create(&types.Portfolio{UserID: BankUserID, SecurityID: security.ID, Quantity: 20, PurchasePrice: 90}).Error)
+
+	resting := types.Order{UserID: 2, AccountID: 8, SecurityID: security.ID, Direction: "buy", OrderType: "LIMIT", LimitPricePerUnit: fptr(100), Quantity: 5, RemainingParts: ptr(5), Status: "approved", PriorityTime: 1}
+	assert.NoError(t, db.DB.Create(&resting).Error)
+
+	// Dok je margin nalog u margin pozivu, short prodaja se ne izvršava
+	account := types.MarginAccount{UserID: userID, AccountID: 81, Status: MarginStatusCall}
+	assert.NoError(t, db.DB.Create(&account).Error)
+	short := types.Order{UserID: userID, AccountID: 81, SecurityID: security.ID, Direction: "sell", OrderType: "MARKET", Quantity: 3, RemainingParts: ptr(3), Status: "approved", Margin: true}
+	assert.NoError(t, db.DB.Create(&short).Error)
+	filled, _, _ := executePartial(&short, db.DB)
+	assert.Equal(t, 0, filled)
+
+	assert.NoError(t, db.DB.Model(&account).Update("status", MarginStatusOK).Error)
+	filled, touched, _ := executePartial(&short, db.DB)
+	assert.Equal(t, 3, filled)
+	assert.Equal(t, []uint{resting.ID}, touched)
+
+	position, err := portfolioQuantity(db.DB, userID, security.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, -3, position)
+	var borrow types.SecurityBorrow
+	assert.NoError(t, db.DB.Where("user_id = ?", userID).First(&borrow).Error)
+	assert.Equal(t, 3, borrow.Quantity)
+	assert.NoError(t, db.DB.First(&resting, resting.ID).Error)
+	assert.Equal(t, 2, *resting.RemainingParts)
+}
@@ -0,0 +1,247 @@
+package orders
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"banka1.com/broker"
+	"banka1.com/db"
+	"banka1.com/dto"
+	"banka1.com/types"
+	"gorm.io/gorm"
+)
+
+const (
+	MarketAccessInternal = "internal" // Orderi se izvršavaju samo međusobno
+	MarketAccessBank     = "bank"     // Banka preuzima drugu stranu kada nema internog matcha
+
+	BankUserID    uint = 5
+	BankAccountID uint = 112
+
+	// DefaultBankMaxPosition važi za hartije bez podešenog BankInventoryLimit
+	DefaultBankMaxPosition = 500
+)
+
+// MarketAccessMode vraća režim pristupa tržištu iz MARKET_ACCESS_MODE, podrazumevano internal.
+func MarketAccessMode() string {
+	if strings.ToLower(strings.TrimSpace(os.Getenv("MARKET_ACCESS_MODE"))) == MarketAccessBank {
+		return MarketAccessBank
+	}
+	return MarketAccessInternal
+}
+
+func bankProvidesLiquidity(order types.Order) bool {
+	return MarketAccessMode() == MarketAccessBank && order.UserID != BankUserID
+}
+
+// bankSide je order banke suprotnog smera, koristi se za određivanje kupca i prodavca.
+func bankSide(order types.Order) types.Order {
+	direction := "sell"
+	if strings.ToLower(order.Direction) == "sell" {
+		direction = "buy"
+	}
+	return types.Order{UserID: BankUserID, AccountID: BankAccountID, SecurityID: order.SecurityID, Direction: direction}
+}
+
+// bankQuote vraća cenu po kojoj banka trguje sa orderom: kupac plaća Ask, prodavac
+// dobija Bid iz listinga. Drugi rezultat je false ako kotacija ne postoji ili je
+// van limita ordera.
+func bankQuote(order types.Order, tx *gorm.DB) (float64, bool) {
+	var security types.Security
+	if err := tx.First(&security, order.SecurityID).Error; err != nil {
+		return 0, false
+	}
+	var listing types.Listing
+	if err := tx.Where("ticker = ?", security.Ticker).First(&listing).Error; err != nil {
+		return 0, false
+	}
+
+	price := float64(listing.Bid)
+	if strings.ToLower(order.Direction) == "buy" {
+		price = float64(listing.Ask)
+	}
+	if price <= 0 {
+		return 0, false
+	}
+	return price, crosses(order, price)
+}
+
+// BankInventory vraća poziciju banke u hartiji, količinu rezervisanu u otvorenim
+// sell orderima banke i važeći limit inventara.
+func BankInventory(tx *gorm.DB, securityID uint) (int, int, types.BankInventoryLimit, error) {
+	limit := types.BankInventoryLimit{SecurityID: securityID, MaxPosition: DefaultBankMaxPosition}
+	if err := tx.Where("security_id = ?", securityID).First(&limit).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, 0, limit, err
+	}
+
+	var portfolio types.Portfolio
+	if err := tx.Where("user_id = ? AND security_id = ?", BankUserID, securityID).First(&portfolio).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, 0, limit, err
+	}
+
+	var reserved int64
+	if err := tx.Model(&types.Order{}).
+		Select("COALESCE(SUM(remaining_parts), 0)").
+		Where("user_id = ? AND security_id = ? AND lower(direction) = 'sell' AND lower(status) = 'approved' AND COALESCE(is_done, false) = false", BankUserID, securityID).
+		Scan(&reserved).Error; err != nil {
+		return 0, 0, limit, err
+	}
+
+	return portfolio.Quantity, int(reserved), limit, nil
+}
+
+// bankCapacity vraća koliko jedinica banka može da preuzme kao druga strana ordera
+// bez prelaska limita inventara. Banka prodaje samo ono što drži van sopstvenih
+// sell ordera i pozajmica za short prodaju, a kupuje do MaxPosition.
+func bankCapacity(order types.Order, tx *gorm.DB) int {
+	position, reserved, limit, err := BankInventory(tx, order.SecurityID)
+	if err != nil {
+		fmt.Printf("Greska pri citanju inventara banke za hartiju %d: %v\n", order.SecurityID, err)
+		return 0
+	}
+
+	capacity := limit.MaxPosition - position
+	if strings.ToLower(order.Direction) == "buy" {
+		// Pozajmljene hartije nisu u inventaru iako su još u poziciji banke
+		lent, err := lentShares(tx, order.SecurityID)
+		if err != nil {
+			fmt.Printf("Greska pri citanju pozajmica za hartiju %d: %v\n", order.SecurityID, err)
+			return 0
+		}
+		capacity = position - reserved - lent - limit.MinPosition
+	}
+	return max(capacity, 0)
+}
+
+// bankExecutableParts vraća koliko ordera banka može odmah da izvrši po trenutnoj kotaciji.
+func bankExecutableParts(order types.Order, tx *gorm.DB) int {
+	if !bankProvidesLiquidity(order) {
+		return 0
+	}
+	if _, ok := bankQuote(order, tx); !ok {
+		return 0
+	}
+	return bankCapacity(order, tx)
+}
+
+// fillFromBank izvršava order (ili njegov deo) protiv banke po Ask/Bid kotaciji
+// kada u knjizi nema internog matcha. AON orderi se izvršavaju samo u celosti.
+func fillFromBank(order1 *types.Order, tx *gorm.DB) (int, []dto.OrderTransactionInitiationDTO) {
+	if !bankProvidesLiquidity(*order1) {
+		return 0, nil
+	}
+	price, ok := bankQuote(*order1, tx)
+	if !ok {
+		return 0, nil
+	}
+	if !bandFor(tx, order1.SecurityID).allows(price) {
+		fmt.Printf("Kotacija banke %.2f za order %d je van dozvoljenog opsega\n", price, order1.ID)
+		return 0, nil
+	}
+
+	remaining := ptrSafe(order1.RemainingParts)
+	capacity := bankCapacity(*order1, tx)
+	quantity := min(remaining, capacity)
+	if quantity <= 0 || (isAllOrNone(*order1) && quantity < remaining) {
+		fmt.Printf("Banka nema dovoljno inventara za order %d (kapacitet %d)\n", order1.ID, capacity)
+		return 0, nil
+	}
+
+	order := *order1
+	order.RemainingParts = ptr(remaining)
+	bank := bankSide(order)
+	buyerID, sellerID := getBuyerID(order, bank), getSellerID(order, bank)
+
+	var sent dto.OrderTransactionInitiationDTO
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		total := Notional(order, price, quantity)
+		fee, scheduleID := FeeFor(tx, order, total)
+
+		txn := types.Transaction{
+			OrderID:       order.ID,
+			BuyerID:       buyerID,
+			SellerID:      sellerID,
+			SecurityID:    order.SecurityID,
+			Quantity:      quantity,
+			PricePerUnit:  price,
+			TotalPrice:    total,
+			Fee:           fee,
+			FeeScheduleID: scheduleID,
+			TaxPaid:       false,
+			BankPrincipal: true,
+		}
+		if err := tx.Create(&txn).Error; err != nil {
+			return fmt.Errorf("kreiranje transakcije: %w", err)
+		}
+
+		visibleBefore := visibleQuantity(order)
+		*order.RemainingParts -= quantity
+		consumeVisible(&order, visibleBefore, quantity)
+		if err := tx.Save(&order).Error; err != nil {
+			return fmt.Errorf("save za order: %w", err)
+		}
+
+		if err := updatePortfolio(buyerID, order.SecurityID, quantity, price, tx); err != nil {
+			return err
+		}
+		if err := sellFromPortfolio(tx, getSellerOrder(order, bank), quantity, price); err != nil {
+			return err
+		}
+
+		if order.Margin || isAgent(buyerID) {
+			var actuary types.Actuary
+			if err := tx.Where("user_id = ?", order.UserID).First(&actuary).Error; err == nil {
+				used := Notional(order, price, quantity)
+				if order.Margin {
+					used *= InitialMarginRate
+				}
+				actuary.UsedLimit += used
+				if err := tx.Save(&actuary).Error; err != nil {
+					return fmt.Errorf("save UsedLimit: %w", err)
+				}
+			}
+		}
+
+		if err := settleFill(tx, order.ID, *order.RemainingParts, quantity, price); err != nil {
+			return err
+		}
+
+		initiationDto := dto.OrderTransactionInitiationDTO{
+			Uid:             fmt.Sprintf("ORDER-bank-%d-%d", order.ID, time.Now().UnixNano()),
+			SellerAccountId: getSellerAccountID(order, bank),
+			BuyerAccountId:  getBuyerAccountID(order, bank),
+			Amount:          total,
+			Fee:             fee,
+			Direction:       order.Direction,
+		}
+		if err := broker.SendOrderTransactionInit(&initiationDto); err != nil {
+			return fmt.Errorf("slanje OrderTransactionInitiationDTO: %w", err)
+		}
+		sent = initiationDto
+
+		recordFill(tx, order.ID, *order.RemainingParts, quantity, price, &fee, "druga strana banka (principal)")
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Banka nije izvršila order %d: %v\n", order.ID, err)
+		return 0, nil
+	}
+
+	fmt.Printf("Banka je druga strana: Order %d za %d @ %.2f\n", order.ID, quantity, price)
+	*order1 = order
+	return quantity, []dto.OrderTransactionInitiationDTO{sent}
+}
+
+// SetBankInventoryLimit upisuje limit inventara banke za hartiju.
+func SetBankInventoryLimit(securityID uint, maxPosition, minPosition int) (types.BankInventoryLimit, error) {
+	limit := types.BankInventoryLimit{SecurityID: securityID}
+	if err := db.DB.Where("security_id = ?", securityID).FirstOrInit(&limit).Error; err != nil {
+		return limit, err
+	}
+	limit.MaxPosition = maxPosition
+	limit.MinPosition = minPosition
+	return limit, db.DB.Save(&limit).Error
+}
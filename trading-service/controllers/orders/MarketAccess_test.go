@@ -0,0 +1,127 @@
+package orders
+
+import (
+	"testing"
+
+	"banka1.com/db"
+	"banka1.com/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupBankMarket(t *testing.T) types.Security {
+	t.Setenv("MARKET_ACCESS_MODE", MarketAccessBank)
+	assert.NoError(t, db.InitTestDatabase())
+
+	security := types.Security{Ticker: "BNKT", Name: "Bank test", Type: "Stock", LastPrice: 100}
+	assert.NoError(t, db.DB.Create(&security).Error)
+	assert.NoError(t, db.DB.Create(&types.Listing{Ticker: "BNKT", Name: "Bank test", ExchangeID: 1, Price: 100, Ask: 101, Bid: 99, Type: "Stock"}).Error)
+	t.Cleanup(func() {
+		db.DB.Where("security_id = ?", security.ID).Delete(&types.Transaction{})
+		db.DB.Where("security_id = ?", security.ID).Delete(&types.Portfolio{})
+		db.DB.Where("security_id = ?", security.ID).Delete(&types.Order{})
+		db.DB.Where("security_id = ?", security.ID).Delete(&types.BankInventoryLimit{})
+		db.DB.Where("ticker = ?", "BNKT").Delete(&types.Listing{})
+		db.DB.Delete(&security)
+	})
+	return security
+}
+
+func TestFillFromBank_BuyLimitedByInventory(t *testing.T) {
+	security := setupBankMarket(t)
+	assert.NoError(t, db.DB.Create(&types.Portfolio{UserID: BankUserID, SecurityID: security.ID, Quantity: 10, PurchasePrice: 90}).Error)
+
+	order := types.Order{UserID: 1, AccountID: 7, SecurityID: security.ID, Direction: "buy", OrderType: "MARKET", Quantity: 15, RemainingParts: ptr(15), Status: "approved"}
+	assert.NoError(t, db.DB.Create(&order).Error)
+
+	filled, sent := fillFromBank(&order, db.DB)
+	assert.Equal(t, 10, filled)
+	assert.Equal(t, 5, *order.RemainingParts)
+	assert.Len(t, sent, 1)
+	assert.Equal(t, BankAccountID, sent[0].SellerAccountId)
+	assert.Equal(t, 1010.0, sent[0].Amount)
+
+	var txn types.Transaction
+	assert.NoError(t, db.DB.Where("order_id = ?", order.ID).First(&txn).Error)
+	assert.True(t, txn.BankPrincipal)
+	assert.Equal(t, BankUserID, txn.SellerID)
+	assert.Equal(t, 101.0, txn.PricePerUnit)
+
+	// Banka je prodala sve što je imala
+	filled, _ = fillFromBank(&order, db.DB)
+	assert.Equal(t, 0, filled)
+}
+
+func TestFillFromBank_SellRespectsLimitAndMaxPosition(t *testing.T) {
+	security := setupBankMarket(t)
+	_, err := SetBankInventoryLimit(security.ID, 4, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, db.DB.Create(&types.Portfolio{UserID: 1, SecurityID: security.ID, Quantity: 10, PurchasePrice: 90}).Error)
+
+	tooHigh := types.Order{UserID: 1, AccountID: 7, SecurityID: security.ID, Direction: "sell", OrderType: "LIMIT", LimitPricePerUnit: fptr(100), Quantity: 3, RemainingParts: ptr(3), Status: "approved"}
+	assert.NoError(t, db.DB.Create(&tooHigh).Error)
+	filled, _ := fillFromBank(&tooHigh, db.DB)
+	assert.Equal(t, 0, filled, "Bid 99 je ispod limita 100")
+
+	aon := types.Order{UserID: 1, AccountID: 7, SecurityID: security.ID, Direction: "sell", OrderType: "MARKET", AON: true, Quantity: 6, RemainingParts: ptr(6), Status: "approved"}
+	assert.NoError(t, db.DB.Create(&aon).Error)
+	filled, _ = fillFromBank(&aon, db.DB)
+	assert.Equal(t, 0, filled, "AON prelazi MaxPosition banke")
+
+	sell := types.Order{UserID: 1, AccountID: 7, SecurityID: security.ID, Direction: "sell", OrderType: "MARKET", Quantity: 6, RemainingParts: ptr(6), Status: "approved"}
+	assert.NoError(t, db.DB.Create(&sell).Error)
+	filled, _ = fillFromBank(&sell, db.DB)
+	assert.Equal(t, 4, filled)
+
+	position, _, _, err := BankInventory(db.DB, security.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, position)
+}
+
+func TestFillFromBank_InternalModeDoesNothing(t *testing.T) {
+	security := setupBankMarket(t)
+	t.Setenv("MARKET_ACCESS_MODE", MarketAccessInternal)
+
+	order := types.Order{UserID: 1, AccountID: 7, SecurityID: security.ID, Direction: "sell", OrderType: "MARKET", Quantity: 1, RemainingParts: ptr(1), Status: "approved"}
+	filled, _ := fillFromBank(&order, db.DB)
+	assert.Equal(t, 0, filled)
+}
+
+func TestExecutePartial_AONTakesShortfallFromBank(t *testing.T) {
+	security := setupBankMarket(t)
+	assert.NoError(t, db.DB.Create(&types.Portfolio{UserID: BankUserID, SecurityID: security.ID, Quantity: 10, PurchasePrice: 90}).Error)
+	assert.NoError(t, db.DB.Create(&types.Portfolio{UserID: 2, SecurityID: security.ID, Quantity: 4, PurchasePrice: 90}).Error)
+	assert.NoError(t, db.DB.Create(&types.Portfolio{UserID: 3, SecurityID: security.ID, Quantity: 10, PurchasePrice: 90}).Error)
+
+	// LIMIT order bez limita ne sme da uđe u AON izvršenje
+	invalid := types.Order{UserID: 3, AccountID: 9, SecurityID: security.ID, Direction: "sell", OrderType: "LIMIT", Quantity: 10, RemainingParts: ptr(10), Status: "approved", PriorityTime: 1}
+	resting := types.Order{UserID: 2, AccountID: 8, SecurityID: security.ID, Direction: "sell", OrderType: "LIMIT", LimitPricePerUnit: fptr(100), Quantity: 4, RemainingParts: ptr(4), Status: "approved", PriorityTime: 2}
+	assert.NoError(t, db.DB.Create(&invalid).Error)
+	assert.NoError(t, db.DB.Create(&resting).Error)
+	t.Cleanup(func() {
+		db.DB.Where("order_id IN ?", []uint{invalid.ID, resting.ID}).Delete(&types.OrderEvent{})
+		booksMu.Lock()
+		delete(books, security.ID)
+		booksMu.Unlock()
+	})
+
+	aon := types.Order{UserID: 1, AccountID: 7, SecurityID: security.ID, Direction: "buy", OrderType: "LIMIT", LimitPricePerUnit: fptr(101), AON: true, Quantity: 6, RemainingParts: ptr(6), Status: "approved"}
+	assert.NoError(t, db.DB.Create(&aon).Error)
+
+	filled, touched, sent := executePartial(&aon, db.DB)
+	assert.Equal(t, 6, filled)
+	assert.Equal(t, []uint{resting.ID}, touched)
+	assert.Len(t, sent, 2)
+
+	var txns []types.Transaction
+	assert.NoError(t, db.DB.Where("order_id = ?", aon.ID).Order("id").Find(&txns).Error)
+	assert.Len(t, txns, 2)
+	assert.Equal(t, 4, txns[0].Quantity)
+	assert.Equal(t, 100.0, txns[0].PricePerUnit)
+	assert.Equal(t, 2, txns[1].Quantity)
+	assert.True(t, txns[1].BankPrincipal)
+
+	assert.NoError(t, db.DB.First(&invalid, invalid.ID).Error)
+	assert.Equal(t, 10, *invalid.RemainingParts)
+	assert.NoError(t, db.DB.First(&aon, aon.ID).Error)
+	assert.True(t, aon.IsDone)
+}
@@ -2,11 +2,13 @@ package orders
 
 import (
 	"banka1.com/broker"
+	"banka1.com/clock"
 	"banka1.com/dto"
+	"banka1.com/exchanges"
+	"banka1.com/holds"
 	"database/sql"
 	"errors"
 	"fmt"
-	"math/rand"
 	"strings"
 	"sync"
 	"time"
@@ -23,6 +25,7 @@ var (
 	orderLocksMu  sync.Mutex
 )
 
+// CalculateFee je podrazumevana provizija kada za izvršenje ne važi nijedna tarifa iz baze (FeeFor).
 func CalculateFee(order types.Order, total float64) float64 {
 	switch strings.ToUpper(order.OrderType) {
 	case "MARKET":
@@ -63,208 +66,301 @@ func getOrderLock(orderID uint) *sync.Mutex {
 	return orderLocks[orderID]
 }
 
-func MatchOrder(order types.Order) {
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				fmt.Printf("Gorutina pukla u MatchOrder! Panic: %v\n", r)
-			}
-		}()
-		// Zaključavanje po ORDER ID – sprečava paralelno izvršavanje istog ordera
-		orderLock := getOrderLock(order.ID)
-		orderLock.Lock()
-		defer orderLock.Unlock()
-
-		if err := db.DB.First(&order, order.ID).Error; err != nil {
-			fmt.Printf("Greska pri refetch ordera %d: %v\n", order.ID, err)
-			return
+// processOrder je jedan pokušaj izvršenja ordera na radniku šarda. Umesto da
+// spava između krugova, order se vraća u red odloženih pokušaja.
+func processOrder(orderID uint) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("Radnik pukao u processOrder! Panic: %v\n", r)
 		}
+	}()
+	// Zaključavanje po ORDER ID – sprečava paralelno izvršavanje istog ordera
+	orderLock := getOrderLock(orderID)
+	orderLock.Lock()
+	defer orderLock.Unlock()
+
+	var order types.Order
+	if err := db.DB.First(&order, orderID).Error; err != nil {
+		fmt.Printf("Greska pri refetch ordera %d: %v\n", order.ID, err)
+		return
+	}
 
-		if order.AON {
-			if !CanExecuteAll(order) {
-				fmt.Println("AON: Nema dovoljno za celokupan order")
-				return
-			}
+	if isPendingTrigger(order) {
+		fmt.Printf("Stop order %d čeka aktivaciju\n", order.ID)
+		AddTrigger(order)
+		return
+	}
+
+	if order.Status != "approved" || order.IsDone {
+		return
+	}
+
+	if !marketAllows(&order) {
+		if isImmediate(order) {
+			expireImmediate(order)
+		} else {
+			scheduleRetry(order, closedMarketRetry)
 		}
+		return
+	}
 
-		if !canPreExecute(order) {
-			fmt.Println("Nije ispunjen uslov za order")
+	if isAllOrNone(order) {
+		if !CanExecuteAll(order) {
+			fmt.Println("AON: Nema dovoljno za celokupan order")
+			expireImmediate(order)
 			return
 		}
+	}
 
-		for order.RemainingParts != nil && *order.RemainingParts > 0 {
-			fmt.Printf("Novi krug matchovanja za Order %d | Remaining: %d\n", order.ID, *order.RemainingParts)
-
-			tx := db.DB.Begin()
+	if !canPreExecute(order) {
+		fmt.Println("Nije ispunjen uslov za order")
+		expireImmediate(order)
+		return
+	}
 
-			// Ponovo proveri order iz baze unutar transakcije
-			if err := tx.First(&order, order.ID).Error; err != nil {
-				fmt.Printf("Order nije pronađen u transakciji: %v\n", err)
-				tx.Rollback()
-				break
-			}
+	filled := false
+	for order.RemainingParts != nil && *order.RemainingParts > 0 {
+		fmt.Printf("Novi krug matchovanja za Order %d | Remaining: %d\n", order.ID, *order.RemainingParts)
 
-			price := getOrderPrice(order)
+		tx := db.DB.Begin()
 
-			orderCopy := order
+		// Ponovo proveri order iz baze unutar transakcije
+		if err := tx.First(&order, order.ID).Error; err != nil {
+			fmt.Printf("Order nije pronađen u transakciji: %v\n", err)
+			tx.Rollback()
+			break
+		}
+		if order.Status != "approved" || order.IsDone {
+			fmt.Printf("Order %d više nije aktivan (status: %s)\n", order.ID, order.Status)
+			tx.Rollback()
+			break
+		}
 
-			matchQuantity := executePartial(&orderCopy, price, tx)
-			if order.RemainingParts != nil {
-				fmt.Printf("Nakon executePartial: remaining=%d\n", *orderCopy.RemainingParts)
-			} else {
-				fmt.Printf("order.RemainingParts je NIL nakon executePartial\n")
-			}
+		orderCopy := order
 
-			if matchQuantity == 0 {
-				fmt.Printf("BREAK: Nije pronađen validan match za Order %d, remaining=%d\n", order.ID, *order.RemainingParts)
-				tx.Rollback()
-				break
-			}
-			//TO-DO PROVERITI OVO ISPOD
-			//executePartial(order, quantityToExecute, price, tx)
-
-			//if order.RemainingParts == nil || *order.RemainingParts == 0 {
-			//	order.IsDone = true
-			//	order.Status = "done"
-			//}
-
-			// SKIDANJE unita ako je kupovina (smanjuje se dostupnost hartija)
-			//if order.Direction == "buy" {
-			//	var security types.Security
-			//	if err := tx.First(&security, order.SecurityID).Error; err == nil {
-			//		security.Volume -= int64(quantityToExecute)
-			//		if security.Volume < 0 {
-			//			security.Volume = 0
-			//		}
-			//		tx.Save(&security)
-			//	}
-			//}
-
-			// Ažuriraj order u bazi (unutar transakcije)
-			if err := tx.Model(&types.Order{}).Where("id = ?", order.ID).Update("remaining_parts", *orderCopy.RemainingParts).Error; err != nil {
-				fmt.Printf("Greska pri upisu remaining_parts: %v\n", err)
-				tx.Rollback()
-				break
-			}
+		matchQuantity, touched, sent := executePartial(&orderCopy, tx)
+		if order.RemainingParts != nil {
+			fmt.Printf("Nakon executePartial: remaining=%d\n", *orderCopy.RemainingParts)
+		} else {
+			fmt.Printf("order.RemainingParts je NIL nakon executePartial\n")
+		}
 
-			//if err := tx.Commit().Error; err != nil {
-			//	fmt.Printf("Nalog %v nije izvršen: %v\n", order.ID, err)
-			//	tx.Rollback()
-			//	break
-			//}
+		if matchQuantity == 0 {
+			fmt.Printf("BREAK: Nije pronađen validan match za Order %d, remaining=%d\n", order.ID, *order.RemainingParts)
+			tx.Rollback()
+			break
+		}
+		//TO-DO PROVERITI OVO ISPOD
+		//executePartial(order, quantityToExecute, price, tx)
+
+		//if order.RemainingParts == nil || *order.RemainingParts == 0 {
+		//	order.IsDone = true
+		//	order.Status = "done"
+		//}
+
+		// SKIDANJE unita ako je kupovina (smanjuje se dostupnost hartija)
+		//if order.Direction == "buy" {
+		//	var security types.Security
+		//	if err := tx.First(&security, order.SecurityID).Error; err == nil {
+		//		security.Volume -= int64(quantityToExecute)
+		//		if security.Volume < 0 {
+		//			security.Volume = 0
+		//		}
+		//		tx.Save(&security)
+		//	}
+		//}
+
+		// Ažuriraj order u bazi (unutar transakcije)
+		if err := tx.Model(&types.Order{}).Where("id = ?", order.ID).Update("remaining_parts", *orderCopy.RemainingParts).Error; err != nil {
+			fmt.Printf("Greska pri upisu remaining_parts: %v\n", err)
+			tx.Rollback()
+			compensateLegs(order.ID, sent, "rollback: "+err.Error())
+			break
+		}
 
-			// Ažuriraj volume preko helper funkcije
-			if err := UpdateAvailableVolumeTx(tx, order.SecurityID); err != nil {
-				fmt.Printf("Greska pri UpdateAvailableVolume: %v\n", err)
-				tx.Rollback()
-				break
-			}
+		//if err := tx.Commit().Error; err != nil {
+		//	fmt.Printf("Nalog %v nije izvršen: %v\n", order.ID, err)
+		//	tx.Rollback()
+		//	break
+		//}
+
+		// Ažuriraj volume preko helper funkcije
+		if err := UpdateAvailableVolumeTx(tx, order.SecurityID); err != nil {
+			fmt.Printf("Greska pri UpdateAvailableVolume: %v\n", err)
+			tx.Rollback()
+			compensateLegs(order.ID, sent, "rollback: "+err.Error())
+			break
+		}
 
-			//// SKIDANJE unita ako je kupovina (smanjuje se dostupnost hartija)
-			//if order.Direction == "buy" {
-			//	var security types.Security
-			//	if err := tx.First(&security, order.SecurityID).Error; err == nil {
-			//		security.Volume -= int64(matchQuantity)
-			//		if security.Volume < 0 {
-			//			security.Volume = 0
-			//		}
-			//		tx.Save(&security)
-			//	}
-			//}
-
-			//// Ažuriraj RemainingParts u bazi (bez is_done/status!)
-			//if err := tx.Model(&types.Order{}).Where("id = ?", order.ID).Update("remaining_parts", *order.RemainingParts).Error; err != nil {
-			//	fmt.Printf("Greska pri upisu remaining_parts: %v\n", err)
-			//	tx.Rollback()
-			//	break
-			//}
-
-			if err := tx.Commit().Error; err != nil {
-				fmt.Printf("Nalog %v nije izvršen: %v\n", order.ID, err)
-				tx.Rollback()
-				break
-			}
+		//// SKIDANJE unita ako je kupovina (smanjuje se dostupnost hartija)
+		//if order.Direction == "buy" {
+		//	var security types.Security
+		//	if err := tx.First(&security, order.SecurityID).Error; err == nil {
+		//		security.Volume -= int64(matchQuantity)
+		//		if security.Volume < 0 {
+		//			security.Volume = 0
+		//		}
+		//		tx.Save(&security)
+		//	}
+		//}
+
+		//// Ažuriraj RemainingParts u bazi (bez is_done/status!)
+		//if err := tx.Model(&types.Order{}).Where("id = ?", order.ID).Update("remaining_parts", *order.RemainingParts).Error; err != nil {
+		//	fmt.Printf("Greska pri upisu remaining_parts: %v\n", err)
+		//	tx.Rollback()
+		//	break
+		//}
+
+		if err := tx.Commit().Error; err != nil {
+			fmt.Printf("Nalog %v nije izvršen: %v\n", order.ID, err)
+			tx.Rollback()
+			compensateLegs(order.ID, sent, "rollback: "+err.Error())
+			break
+		}
 
-			// Refetch ponovo da zna koliko još ima
-			if err := db.DB.First(&order, order.ID).Error; err != nil {
-				fmt.Printf("Greska pri refetch ordera %d nakon commit-a: %v\n", order.ID, err)
-				break
-			}
+		syncBook(order.SecurityID, append(touched, order.ID)...)
+		onGroupFill(append(touched, order.ID)...)
+		checkVolatility(order.SecurityID)
+		filled = true
 
-			if order.RemainingParts == nil {
-				fmt.Printf("RemainingParts je NIL nakon commita, orderID = %d\n", order.ID)
-			} else {
-				fmt.Printf("Order %d refetch: remaining = %d\n", order.ID, *order.RemainingParts)
-			}
+		// Refetch ponovo da zna koliko još ima
+		if err := db.DB.First(&order, order.ID).Error; err != nil {
+			fmt.Printf("Greska pri refetch ordera %d nakon commit-a: %v\n", order.ID, err)
+			break
+		}
 
-			if order.RemainingParts == nil || *order.RemainingParts <= 0 {
-				fmt.Printf("Order %d je već izvršen ili nema više delova za obradu\n", order.ID)
-				break
-			}
+		if order.RemainingParts == nil {
+			fmt.Printf("RemainingParts je NIL nakon commita, orderID = %d\n", order.ID)
+		} else {
+			fmt.Printf("Order %d refetch: remaining = %d\n", order.ID, *order.RemainingParts)
+		}
 
-			fmt.Printf("Pauza pre sledećeg pokušaja za Order %d\n", order.ID)
-			delay := calculateDelay(order)
-			time.Sleep(delay)
+		if order.RemainingParts == nil || *order.RemainingParts <= 0 {
+			fmt.Printf("Order %d je već izvršen ili nema više delova za obradu\n", order.ID)
+			break
 		}
 
-		// Konačna provera na kraju svih mečeva
-		if order.RemainingParts != nil && *order.RemainingParts == 0 {
-			db.DB.Model(&types.Order{}).Where("id = ?", order.ID).Updates(map[string]interface{}{
-				"is_done": true,
-				"status":  "done",
-			})
-			fmt.Printf("Order %d označen kao završen nakon svih mečeva\n", order.ID)
-		} else {
-			fmt.Printf("Order %d ostaje neizvršen | Remaining: %d\n", order.ID, *order.RemainingParts)
+		// IOC i FOK ne čekaju, pokušavaju odmah sa sledećim nivoom knjige
+		if isImmediate(order) {
+			continue
 		}
-	}()
+
+		scheduleRetry(order, calculateDelay(order))
+		break
+	}
+
+	// Konačna provera na kraju svih mečeva
+	if order.RemainingParts != nil && *order.RemainingParts == 0 {
+		db.DB.Model(&types.Order{}).Where("id = ?", order.ID).Updates(map[string]interface{}{
+			"is_done": true,
+			"status":  "done",
+		})
+		syncBook(order.SecurityID, order.ID)
+		onGroupFill(order.ID)
+		fmt.Printf("Order %d označen kao završen nakon svih mečeva\n", order.ID)
+	} else if isImmediate(order) {
+		expireImmediate(order)
+	} else {
+		fmt.Printf("Order %d ostaje neizvršen | Remaining: %d\n", order.ID, *order.RemainingParts)
+	}
+
+	// Izvršenje je promenilo knjigu, pa se bude ostali orderi iste hartije
+	if filled {
+		WakeSecurity(order.SecurityID)
+	}
 }
 
-func getListingPrice(order types.Order) float64 {
-	var security types.Security
-	err := db.DB.First(&security, order.SecurityID).Error
-	if err != nil {
-		fmt.Printf("Security nije pronadjen za ID %d: %v\n", order.SecurityID, err)
-		return -1.0
+// marketAllows proverava radno vreme berze hartije. Dok je berza zatvorena order
+// ostaje u knjizi i izvršava se kada se berza otvori. U pre-market i post-market
+// periodu order se označava kao after-hours, pa se delovi izvršavaju sporije.
+func marketAllows(order *types.Order) bool {
+	if halt := ActiveHalt(order.SecurityID); halt != nil {
+		fmt.Printf("Trgovanje hartijom %d je obustavljeno (%s), order %d čeka nastavak\n", order.SecurityID, halt.Reason, order.ID)
+		return false
+	}
+	if inCallPhase(order.SecurityID, clock.Now()) {
+		fmt.Printf("Aukcija hartije %d je u toku, order %d čeka ukrštanje\n", order.SecurityID, order.ID)
+		return false
+	}
+	status := exchanges.StatusForSecurity(order.SecurityID, clock.Now())
+	switch status {
+	case exchanges.StatusClosed:
+		fmt.Printf("Berza zatvorena, order %d čeka otvaranje\n", order.ID)
+		return false
+	case exchanges.StatusPreMarket, exchanges.StatusPostMarket:
+		if !order.AfterHours {
+			if err := db.DB.Model(&types.Order{}).Where("id = ?", order.ID).Update("after_hours", true).Error; err != nil {
+				fmt.Printf("Greska pri oznacavanju after-hours ordera %d: %v\n", order.ID, err)
+			}
+			order.AfterHours = true
+		}
 	}
+	return true
+}
 
-	var listing types.Listing
-	err = db.DB.Where("ticker = ?", security.Ticker).First(&listing).Error
-	if err != nil {
-		fmt.Printf("Listing nije pronadjen za Ticker %s: %v\n", security.Ticker, err)
-		return -1.0
+// expireImmediate otkazuje neizvršeni ostatak IOC i FOK ordera; ostale ordere ne dira.
+func expireImmediate(order types.Order) {
+	reason := ""
+	switch NormalizeTimeInForce(order.TimeInForce) {
+	case TimeInForceIOC:
+		reason = ExpiryReasonIOC
+	case TimeInForceFOK:
+		reason = ExpiryReasonFOK
+	default:
+		return
+	}
+	if err := ExpireOrder(order, reason); err != nil {
+		fmt.Printf("Greska pri otkazivanju ordera %d: %v\n", order.ID, err)
 	}
+}
 
-	if order.Direction == "sell" {
-		return float64(listing.Bid)
-	} else {
-		return float64(listing.Ask)
+// referencePrice je poslednja cena hartije; koristi se samo kada se mečuju
+// dva ordera bez limita, pa cenu nije moguće odrediti iz knjige.
+func referencePrice(securityID uint, tx *gorm.DB) float64 {
+	var security types.Security
+	if err := tx.First(&security, securityID).Error; err != nil {
+		fmt.Printf("Security nije pronadjen za ID %d: %v\n", securityID, err)
+		return 0
 	}
+	return security.LastPrice
 }
 
-func getOrderPrice(order types.Order) float64 {
-	if strings.ToUpper(order.OrderType) == "MARKET" {
-		var security types.Security
-		db.DB.First(&security, order.SecurityID)
-		return security.LastPrice
+// crosses proverava da li limit incoming ordera dozvoljava trgovinu po datoj ceni.
+func crosses(incoming types.Order, price float64) bool {
+	limit := bookPrice(incoming)
+	if limit == nil {
+		return true
+	}
+	if strings.ToLower(incoming.Direction) == "buy" {
+		return *limit >= price
 	}
-	if order.StopPricePerUnit != nil {
-		return *order.StopPricePerUnit
+	return *limit <= price
+}
+
+// tradePrice određuje cenu izvršenja: trguje se po limitu resting ordera, a ako
+// ga nema po limitu incoming ordera. Tek kada nijedan nema limit koristi se
+// referentna cena hartije. Drugi rezultat je false ako se cene ne ukrštaju.
+func tradePrice(incoming, resting types.Order, reference float64) (float64, bool) {
+	if limit := bookPrice(resting); limit != nil {
+		return *limit, crosses(incoming, *limit)
 	}
-	if order.LimitPricePerUnit != nil {
-		return *order.LimitPricePerUnit
+	if limit := bookPrice(incoming); limit != nil {
+		return *limit, true
 	}
-	return 0.0
+	return reference, reference > 0
 }
 
-func executePartial(order1 *types.Order, price float64, tx *gorm.DB) int {
+// executePartial izvršava jedan krug matchovanja i vraća izvršenu količinu,
+// ID-jeve matchovanih ordera čije stavke u knjizi treba osvežiti i plaćanja
+// poslata banking servisu (koja treba kompenzovati ako transakcija ne prođe).
+func executePartial(order1 *types.Order, tx *gorm.DB) (int, []uint, []dto.OrderTransactionInitiationDTO) {
 	lock := getLock(order1.SecurityID)
 	lock.Lock()
 	defer lock.Unlock()
 
 	if order1.Status == "done" || order1.RemainingParts == nil || *order1.RemainingParts <= 0 {
 		fmt.Printf("Order %d je već završen ili nema remaining parts\n", order1.ID)
-		return 0
+		return 0, nil, nil
 	}
 
 	direction := "buy"
@@ -274,133 +370,169 @@ func executePartial(order1 *types.Order, price float64, tx *gorm.DB) int {
 		direction = "buy"
 	}
 
-	fmt.Printf("Pokušavam da pronađem match za Order %d...\n", order1.ID)
+	fmt.Printf("Pokušavam da pronađem match za Order %d (%s strana knjige)...\n", order1.ID, direction)
 
-	var matches []types.Order
+	// Obustava može nastupiti i između dva kruga matchovanja
+	if halt := ActiveHalt(order1.SecurityID); halt != nil {
+		fmt.Printf("Trgovanje hartijom %d je obustavljeno, order %d se ne izvršava\n", order1.SecurityID, order1.ID)
+		return 0, nil, nil
+	}
 
-	err := tx.Debug().
-		Model(&types.Order{}).
-		Where("security_id = ? AND direction = ? AND user_id != ? AND status = 'approved' AND NOT is_done",
-			order1.SecurityID, direction, order1.UserID).
-		Order("last_modified").
-		Find(&matches).Error
+	matches := bookOrders(*order1)
+	reference := referencePrice(order1.SecurityID, tx)
+	limits := bandFor(tx, order1.SecurityID)
 
-	if err != nil {
-		fmt.Printf("Neuspelo dohvatanje matching order-a: %v", err)
-		return 0
-	}
-	if order1.AON {
+	if isAllOrNone(*order1) {
 		totalAvailable := 0
 		selectedMatches := []types.Order{}
+		legPrices := []float64{}
 
 		for _, match := range matches {
 			if match.RemainingParts == nil || *match.RemainingParts <= 0 {
 				continue
 			}
-			if match.AccountID == 0 {
+			if match.AccountID == 0 || !canPreExecute(match) {
+				continue
+			}
+			if _, ok := tradePrice(*order1, match, reference); !ok {
+				// Knjiga je sortirana po ceni, dalji nivoi se sigurno ne ukrštaju
+				break
+			}
+			// Order koji ulazi u izvršenje se čita iz baze, knjiga je samo snapshot
+			current, ok, err := refreshMatch(tx, match)
+			if err != nil {
+				fmt.Printf("Neuspelo citanje matching ordera %d: %v\n", match.ID, err)
+				return 0, nil, nil
+			}
+			if !ok {
 				continue
 			}
-			totalAvailable += *match.RemainingParts
+			match = current
+			legPrice, ok := tradePrice(*order1, match, reference)
+			if !ok {
+				break
+			}
+			if !limits.allows(legPrice) {
+				fmt.Printf("Cena %.2f za order %d je van dozvoljenog opsega\n", legPrice, order1.ID)
+				break
+			}
+			totalAvailable += visibleQuantity(match)
 			selectedMatches = append(selectedMatches, match)
+			legPrices = append(legPrices, legPrice)
 			if totalAvailable >= *order1.RemainingParts {
 				break
 			}
 		}
 
-		if totalAvailable < *order1.RemainingParts {
+		// Ono što knjiga ne pokriva banka može da preuzme kao druga strana
+		shortfall := *order1.RemainingParts - totalAvailable
+		if shortfall > 0 && bankExecutableParts(*order1, tx) < shortfall {
 			fmt.Println("Nema dovoljno available matches za AON order", order1.ID)
-			return 0
+			return 0, nil, nil
 		}
 
 		fmt.Printf("Pronađeno dovoljno match-eva za AON order %d\n", order1.ID)
 
+		// Svi delovi AON ordera se izvršavaju u istoj (spoljnoj) transakciji: ako bilo
+		// koji deo ne uspe, vraća se 0 i MatchOrder radi rollback celog izvršenja.
+		// Plaćanja koja su već poslata banking servisu se tada kompenzuju.
 		matchQty := *order1.RemainingParts
 		remainingToFill := matchQty
+		sent := []dto.OrderTransactionInitiationDTO{}
+		touched := []uint{}
 
-		for _, match := range selectedMatches {
-			currentMatchQty := min(ptrSafe(match.RemainingParts), remainingToFill)
+		fail := func(err error) (int, []uint, []dto.OrderTransactionInitiationDTO) {
+			fmt.Printf("AON order %d nije izvršen, radi se rollback: %v\n", order1.ID, err)
+			compensateLegs(order1.ID, sent, "AON rollback: "+err.Error())
+			return 0, nil, nil
+		}
 
-			err := tx.Debug().Transaction(func(tx *gorm.DB) error {
-				txn := types.Transaction{
-					OrderID:      order1.ID,
-					BuyerID:      getBuyerID(*order1, match),
-					SellerID:     getSellerID(*order1, match),
-					SecurityID:   order1.SecurityID,
-					Quantity:     currentMatchQty,
-					TaxPaid:      false,
-					PricePerUnit: price,
-					TotalPrice:   price * float64(currentMatchQty),
-				}
-				if err := tx.Debug().Create(&txn).Error; err != nil {
-					fmt.Printf("Greska pri kreiranju transakcije: %v\n", err)
-					return err
-				}
+		for i, match := range selectedMatches {
+			price := legPrices[i]
+			visibleBefore := visibleQuantity(match)
+			currentMatchQty := min(visibleBefore, remainingToFill)
+			total := Notional(*order1, price, currentMatchQty)
+			fee, scheduleID := FeeFor(tx, *order1, total)
+
+			txn := types.Transaction{
+				OrderID:       order1.ID,
+				BuyerID:       getBuyerID(*order1, match),
+				SellerID:      getSellerID(*order1, match),
+				SecurityID:    order1.SecurityID,
+				Quantity:      currentMatchQty,
+				TaxPaid:       false,
+				PricePerUnit:  price,
+				TotalPrice:    total,
+				Fee:           fee,
+				FeeScheduleID: scheduleID,
+			}
+			if err := tx.Create(&txn).Error; err != nil {
+				return fail(fmt.Errorf("kreiranje transakcije: %w", err))
+			}
 
-				if match.RemainingParts == nil {
-					tmp := match.Quantity
-					match.RemainingParts = &tmp
-				}
-				*match.RemainingParts -= currentMatchQty
+			if match.RemainingParts == nil {
+				tmp := match.Quantity
+				match.RemainingParts = &tmp
+			}
+			*match.RemainingParts -= currentMatchQty
+			consumeVisible(&match, visibleBefore, currentMatchQty)
 
-				if *match.RemainingParts == 0 {
-					match.IsDone = true
-					match.Status = "done"
-				}
+			if *match.RemainingParts == 0 {
+				match.IsDone = true
+				match.Status = "done"
+			}
 
-				if err := tx.Save(&match).Error; err != nil {
-					return err
-				}
+			if err := tx.Save(&match).Error; err != nil {
+				return fail(fmt.Errorf("save za match %d: %w", match.ID, err))
+			}
 
-				if err := updatePortfolio(getBuyerID(*order1, match), order1.SecurityID, currentMatchQty, price, tx); err != nil {
-					return err
-				}
+			if err := updatePortfolio(getBuyerID(*order1, match), order1.SecurityID, currentMatchQty, price, tx); err != nil {
+				return fail(err)
+			}
 
-				if err := updatePortfolio(getSellerID(*order1, match), order1.SecurityID, -currentMatchQty, price, tx); err != nil {
-					return err
-				}
+			if err := sellFromPortfolio(tx, getSellerOrder(*order1, match), currentMatchQty, price); err != nil {
+				return fail(err)
+			}
 
-				if isAgent(getBuyerID(*order1, match)) {
-					var actuary types.Actuary
-					if err := tx.Where("user_id = ?", order1.UserID).First(&actuary).Error; err == nil {
-						initialMargin := price * float64(matchQty)
-						actuary.UsedLimit += initialMargin
-						if err := tx.Save(&actuary).Error; err != nil {
-							fmt.Printf("Greska pri save UsedLimit za order agenta: %v\n", err)
-						} else {
-							fmt.Printf("Agent order.UserID=%d - povećan UsedLimit za %.2f\n", order1.UserID, initialMargin)
-						}
+			if isAgent(getBuyerID(*order1, match)) {
+				var actuary types.Actuary
+				if err := tx.Where("user_id = ?", order1.UserID).First(&actuary).Error; err == nil {
+					initialMargin := Notional(*order1, price, currentMatchQty)
+					actuary.UsedLimit += initialMargin
+					if err := tx.Save(&actuary).Error; err != nil {
+						return fail(fmt.Errorf("save UsedLimit za agenta: %w", err))
 					}
+					fmt.Printf("Agent order.UserID=%d - povećan UsedLimit za %.2f\n", order1.UserID, initialMargin)
 				}
+			}
 
-				uid := fmt.Sprintf("ORDER-match-%d-%d", order1.ID, time.Now().UnixNano())
-				total := price * float64(currentMatchQty)
-				fee := CalculateFee(*order1, total)
-				initiationDto := dto.OrderTransactionInitiationDTO{
-					Uid:             uid,
-					SellerAccountId: getSellerAccountID(*order1, match),
-					BuyerAccountId:  getBuyerAccountID(*order1, match),
-					Amount:          total,
-					Fee:             fee,
-					Direction:       order1.Direction,
-				}
-
-				fmt.Println("Šaljem OrderTransactionInitiationDTO za svakog seller-a...")
-				fmt.Printf("Order Transaction Init: BuyerAccountID=%d, SellerAccountID=%d, Amount=%.2f, Fee=%.2f\n",
-					initiationDto.BuyerAccountId, initiationDto.SellerAccountId, initiationDto.Amount, initiationDto.Fee)
+			if err := settleFill(tx, order1.ID, remainingToFill-currentMatchQty, currentMatchQty, price); err != nil {
+				return fail(err)
+			}
+			if err := settleFill(tx, match.ID, *match.RemainingParts, currentMatchQty, price); err != nil {
+				return fail(err)
+			}
 
-				err := broker.SendOrderTransactionInit(&initiationDto)
-				if err != nil {
-					fmt.Printf("Greska pri slanju OrderTransactionInitiationDTO: %v\n", err)
-					return err
-				}
+			uid := fmt.Sprintf("ORDER-match-%d-%d", order1.ID, time.Now().UnixNano())
+			initiationDto := dto.OrderTransactionInitiationDTO{
+				Uid:             uid,
+				SellerAccountId: getSellerAccountID(*order1, match),
+				BuyerAccountId:  getBuyerAccountID(*order1, match),
+				Amount:          total,
+				Fee:             fee,
+				Direction:       order1.Direction,
+			}
 
-				return nil
-			})
+			fmt.Printf("Order Transaction Init: BuyerAccountID=%d, SellerAccountID=%d, Amount=%.2f, Fee=%.2f\n",
+				initiationDto.BuyerAccountId, initiationDto.SellerAccountId, initiationDto.Amount, initiationDto.Fee)
 
-			if err != nil {
-				fmt.Printf("Greška pri izvršavanju transakcije za match: %v\n", err)
-				continue
+			if err := broker.SendOrderTransactionInit(&initiationDto); err != nil {
+				return fail(fmt.Errorf("slanje OrderTransactionInitiationDTO: %w", err))
 			}
+			recordFill(tx, order1.ID, remainingToFill-currentMatchQty, currentMatchQty, price, &fee, fmt.Sprintf("AON, druga strana order %d", match.ID))
+			recordFill(tx, match.ID, *match.RemainingParts, currentMatchQty, price, nil, fmt.Sprintf("druga strana order %d", order1.ID))
+			sent = append(sent, initiationDto)
+			touched = append(touched, match.ID)
 
 			remainingToFill -= currentMatchQty
 			if remainingToFill <= 0 {
@@ -408,11 +540,16 @@ func executePartial(order1 *types.Order, price float64, tx *gorm.DB) int {
 			}
 		}
 
-		if order1.RemainingParts == nil {
-			tmp := order1.Quantity
-			order1.RemainingParts = &tmp
+		if remainingToFill > 0 {
+			bankOrder := *order1
+			bankOrder.RemainingParts = ptr(remainingToFill)
+			filled, bankSent := fillFromBank(&bankOrder, tx)
+			sent = append(sent, bankSent...)
+			if filled < remainingToFill {
+				return fail(fmt.Errorf("ostalo neizvršeno %d", remainingToFill-filled))
+			}
+			remainingToFill = 0
 		}
-		*order1.RemainingParts = 0
 
 		if err := tx.Model(&types.Order{}).
 			Where("id = ?", order1.ID).
@@ -421,10 +558,16 @@ func executePartial(order1 *types.Order, price float64, tx *gorm.DB) int {
 				"is_done":         true,
 				"status":          "done",
 			}).Error; err != nil {
-			fmt.Printf("Greska pri upisu remaining_parts za AON order: %v\n", err)
+			return fail(fmt.Errorf("upis remaining_parts: %w", err))
+		}
+
+		if order1.RemainingParts == nil {
+			tmp := order1.Quantity
+			order1.RemainingParts = &tmp
 		}
+		*order1.RemainingParts = 0
 
-		return matchQty
+		return matchQty, touched, sent
 	} else {
 		for _, match := range matches {
 			order := *order1
@@ -442,31 +585,40 @@ func executePartial(order1 *types.Order, price float64, tx *gorm.DB) int {
 				}
 			}
 
-			marginOrder := order
-			if !order.Margin && match.Margin == true {
-				marginOrder = match
+			price, ok := tradePrice(order, match, reference)
+			if !ok {
+				fmt.Printf("Cena ordera %d se ne ukršta sa najboljom ponudom u knjizi\n", order.ID)
+				break
+			}
+			if !limits.allows(price) {
+				fmt.Printf("Cena %.2f za order %d je van dozvoljenog opsega\n", price, order.ID)
+				break
 			}
 
-			if marginOrder.Margin {
-				var actuary types.Actuary
-				if err := tx.Where("user_id = ?", marginOrder.UserID).First(&actuary).Error; err != nil {
-					fmt.Println("Matchovani margin order nema validnog aktuara")
-					continue
-				}
-				if marginOrder.RemainingParts == nil {
-					fmt.Println("RemainingParts je nil u margin logici")
-					continue
-				}
-				initialMargin := price * float64(*marginOrder.RemainingParts) * 0.3 * 1.1
-				if actuary.LimitAmount-actuary.UsedLimit < initialMargin {
-					fmt.Println("Matchovani margin order nema dovoljno limita")
-					continue
-				}
+			// Order koji ulazi u izvršenje se čita iz baze, knjiga je samo snapshot
+			current, ok, err := refreshMatch(tx, match)
+			if err != nil {
+				fmt.Printf("Neuspelo citanje matching ordera %d: %v\n", match.ID, err)
+				break
+			}
+			if !ok {
+				continue
+			}
+			match = current
+			if price, ok = tradePrice(order, match, reference); !ok || !limits.allows(price) {
+				continue
 			}
 
+			if err := marginAllowed(tx, price, order, match); err != nil {
+				fmt.Printf("Matchovani margin order se ne izvršava: %v\n", err)
+				continue
+			}
+
+			// Kod iceberg ordera u knjizi može se uzeti samo vidljivi deo
+			orderVisible, matchVisible := visibleQuantity(order), visibleQuantity(match)
 			matchQty := min(
 				ptrSafe(order.RemainingParts),
-				ptrSafe(match.RemainingParts),
+				matchVisible,
 			)
 
 			if matchQty <= 0 {
@@ -474,16 +626,22 @@ func executePartial(order1 *types.Order, price float64, tx *gorm.DB) int {
 				continue
 			}
 
+			var sentDto dto.OrderTransactionInitiationDTO
 			err = tx.Debug().Transaction(func(tx *gorm.DB) error {
+				total := Notional(order, price, matchQty)
+				fee, scheduleID := FeeFor(tx, order, total)
+
 				txn := types.Transaction{
-					OrderID:      order.ID,
-					BuyerID:      getBuyerID(order, match),
-					SellerID:     getSellerID(order, match),
-					SecurityID:   order.SecurityID,
-					Quantity:     matchQty,
-					PricePerUnit: price,
-					TaxPaid:      false,
-					TotalPrice:   price * float64(matchQty),
+					OrderID:       order.ID,
+					BuyerID:       getBuyerID(order, match),
+					SellerID:      getSellerID(order, match),
+					SecurityID:    order.SecurityID,
+					Quantity:      matchQty,
+					PricePerUnit:  price,
+					TaxPaid:       false,
+					TotalPrice:    total,
+					Fee:           fee,
+					FeeScheduleID: scheduleID,
 				}
 				if err := tx.Debug().Create(&txn).Error; err != nil {
 					fmt.Printf("Greska pri kreiranju transakcije: %v\n", err)
@@ -502,6 +660,9 @@ func executePartial(order1 *types.Order, price float64, tx *gorm.DB) int {
 				}
 				*match.RemainingParts -= matchQty
 
+				consumeVisible(&order, orderVisible, matchQty)
+				consumeVisible(&match, matchVisible, matchQty)
+
 				if *match.RemainingParts == 0 {
 					match.IsDone = true
 					match.Status = "done"
@@ -521,7 +682,7 @@ func executePartial(order1 *types.Order, price float64, tx *gorm.DB) int {
 					return err
 				}
 
-				if err := updatePortfolio(getSellerID(order, match), order.SecurityID, -matchQty, price, tx); err != nil {
+				if err := sellFromPortfolio(tx, getSellerOrder(order, match), matchQty, price); err != nil {
 					fmt.Printf("Greska pri updatePortfolio za seller-a: %v\n", err)
 					return err
 				}
@@ -529,7 +690,7 @@ func executePartial(order1 *types.Order, price float64, tx *gorm.DB) int {
 				if order.Margin {
 					var actuary types.Actuary
 					if err := tx.Where("user_id = ?", order.UserID).First(&actuary).Error; err == nil {
-						initialMargin := price * float64(matchQty) * 0.3 * 1.1
+						initialMargin := Notional(order, price, matchQty) * InitialMarginRate
 						actuary.UsedLimit += initialMargin
 						tx.Save(&actuary)
 					}
@@ -538,7 +699,7 @@ func executePartial(order1 *types.Order, price float64, tx *gorm.DB) int {
 				if isAgent(getBuyerID(*order1, match)) {
 					var actuary types.Actuary
 					if err := tx.Where("user_id = ?", order.UserID).First(&actuary).Error; err == nil {
-						initialMargin := price * float64(matchQty)
+						initialMargin := Notional(order, price, matchQty)
 						actuary.UsedLimit += initialMargin
 						if err := tx.Save(&actuary).Error; err != nil {
 							fmt.Printf("Greska pri save UsedLimit za order agenta: %v\n", err)
@@ -548,9 +709,16 @@ func executePartial(order1 *types.Order, price float64, tx *gorm.DB) int {
 					}
 				}
 
+				if err := settleFill(tx, order.ID, *order.RemainingParts, matchQty, price); err != nil {
+					fmt.Printf("Greska pri uskladjivanju holdova i margine: %v\n", err)
+					return err
+				}
+				if err := settleFill(tx, match.ID, *match.RemainingParts, matchQty, price); err != nil {
+					fmt.Printf("Greska pri uskladjivanju holdova i margine: %v\n", err)
+					return err
+				}
+
 				uid := fmt.Sprintf("ORDER-match-%d-%d", order.ID, time.Now().Unix())
-				total := price * float64(matchQty)
-				fee := CalculateFee(order, total)
 				initiationDto := dto.OrderTransactionInitiationDTO{
 					Uid:             uid,
 					SellerAccountId: getSellerAccountID(order, match),
@@ -567,7 +735,10 @@ func executePartial(order1 *types.Order, price float64, tx *gorm.DB) int {
 					fmt.Printf("Greska pri slanju OrderTransactionInitiationDTO preko brokera: %v\n", err)
 					return err
 				}
+				sentDto = initiationDto
 
+				recordFill(tx, order.ID, *order.RemainingParts, matchQty, price, &fee, fmt.Sprintf("druga strana order %d", match.ID))
+				recordFill(tx, match.ID, *match.RemainingParts, matchQty, price, nil, fmt.Sprintf("druga strana order %d", order.ID))
 				return nil
 			})
 
@@ -577,10 +748,13 @@ func executePartial(order1 *types.Order, price float64, tx *gorm.DB) int {
 
 			fmt.Printf("Match success: Order %d ↔ Order %d za %d @ %.2f\n", order.ID, match.ID, matchQty, price)
 			*order1 = order
-			return matchQty
+			return matchQty, []uint{match.ID}, []dto.OrderTransactionInitiationDTO{sentDto}
 		}
 	}
-	return 0
+
+	// Nema internog matcha: u MARKET_ACCESS_MODE=bank drugu stranu preuzima banka
+	matchQty, sent := fillFromBank(order1, tx)
+	return matchQty, nil, sent
 }
 
 func updatePortfolio(userID uint, securityID uint, delta int, price float64, tx *gorm.DB) error {
@@ -588,7 +762,7 @@ func updatePortfolio(userID uint, securityID uint, delta int, price float64, tx
 	err := tx.Where("user_id = ? AND security_id = ?", userID, securityID).First(&portfolio).Error
 
 	if errors.Is(err, gorm.ErrRecordNotFound) {
-		if delta > 0 {
+		if delta > 0 || coveredByBorrow(tx, userID, securityID, delta) {
 			portfolio = types.Portfolio{
 				UserID:        userID,
 				SecurityID:    securityID,
@@ -603,6 +777,9 @@ func updatePortfolio(userID uint, securityID uint, delta int, price float64, tx
 		} else {
 			return fmt.Errorf("Nema postojeći portfolio za korisnika %d i security %d, a pokušaj da se oduzme delta=%d\n", userID, securityID, delta)
 		}
+		if delta > 0 {
+			return settleBorrows(tx, userID, securityID, portfolio.Quantity)
+		}
 		return nil
 	}
 
@@ -612,7 +789,8 @@ func updatePortfolio(userID uint, securityID uint, delta int, price float64, tx
 	}
 
 	portfolio.Quantity += delta
-	if portfolio.Quantity <= 0 {
+	// Negativna (short) pozicija ostaje samo ako je pokrivena pozajmicom banke
+	if portfolio.Quantity == 0 || (portfolio.Quantity < 0 && !coveredByBorrow(tx, userID, securityID, portfolio.Quantity)) {
 		err = tx.Delete(&portfolio).Error
 		if err != nil {
 			fmt.Printf("Portfolio greška pri brisanju: user=%d, security=%d | %v\n", userID, securityID, err)
@@ -627,11 +805,20 @@ func updatePortfolio(userID uint, securityID uint, delta int, price float64, tx
 			fmt.Printf("Portfolio ažuriran: user=%d, security=%d, quantity=%d\n", userID, securityID, portfolio.Quantity)
 		}
 	}
+	if err == nil && delta > 0 {
+		err = settleBorrows(tx, userID, securityID, portfolio.Quantity)
+	}
 	return err
 }
 
+// coveredByBorrow proverava da li pozajmice korisnika pokrivaju short poziciju.
+func coveredByBorrow(tx *gorm.DB, userID, securityID uint, quantity int) bool {
+	borrowed, err := borrowedShares(tx, userID, securityID)
+	return err == nil && borrowed >= -quantity
+}
+
 func calculateDelay(order types.Order) time.Duration {
-	delaySeconds := rand.Intn(10) + 1
+	delaySeconds := clock.Intn(10) + 1
 	if order.AfterHours {
 		return time.Duration(delaySeconds+1800) * time.Second
 	}
@@ -639,23 +826,20 @@ func calculateDelay(order types.Order) time.Duration {
 }
 
 func getExecutableParts(order types.Order) int {
-	var matchingOrders []types.Order
-	direction := "buy"
-	if strings.ToLower(order.Direction) == "buy" {
-		direction = "sell"
-	} else {
-		direction = "buy"
-	}
+	matchingOrders := bookOrders(order)
 
-	db.DB.Where("security_id = ? AND direction = ? AND status = ? AND is_done = ?", order.SecurityID, direction, "approved", false).Find(&matchingOrders)
+	reference := referencePrice(order.SecurityID, db.DB)
 	totalAvailable := 0
 	for _, o := range matchingOrders {
+		if _, ok := tradePrice(order, o, reference); !ok {
+			break
+		}
 		if o.RemainingParts != nil && canPreExecute(o) {
-			totalAvailable += *o.RemainingParts
+			totalAvailable += visibleQuantity(o)
 		}
 	}
 
-	return totalAvailable
+	return totalAvailable + bankExecutableParts(order, db.DB)
 }
 
 func CanExecuteAll(order types.Order) bool {
@@ -676,43 +860,12 @@ func canPreExecute(order types.Order) bool {
 			fmt.Println("LIMIT order bez LimitPricePerUnit")
 			return false
 		}
-		price := getListingPrice(order)
-		if price < 0 {
-			return false
-		}
-		if order.Direction == "sell" {
-			return price >= *order.LimitPricePerUnit
-		} else {
-			return price <= *order.LimitPricePerUnit
-		}
-	} else if strings.ToUpper(order.OrderType) == "STOP" {
-		if order.StopPricePerUnit == nil {
-			fmt.Println("STOP order bez StopPricePerUnit")
-			return false
-		}
-		price := getListingPrice(order)
-		if price < 0 {
-			return false
-		}
-		if order.Direction == "sell" {
-			return price <= *order.StopPricePerUnit
-		} else {
-			return price >= *order.StopPricePerUnit
-		}
-	} else if strings.ToUpper(order.OrderType) == "STOP-LIMIT" {
-		if order.LimitPricePerUnit == nil || order.StopPricePerUnit == nil {
-			fmt.Println("STOP-LIMIT order bez neophodnih cena")
-			return false
-		}
-		price := getListingPrice(order)
-		if price < 0 {
-			return false
-		}
-		if order.Direction == "sell" {
-			return price <= *order.StopPricePerUnit && price >= *order.LimitPricePerUnit
-		} else {
-			return price >= *order.StopPricePerUnit && price <= *order.LimitPricePerUnit
-		}
+		// Cena izvršenja se određuje iz knjige naloga (tradePrice), ne iz listinga
+		return true
+	} else if isPendingTrigger(order) {
+		// Stop orderi ne ulaze u matching dok ih trigger engine ne aktivira
+		// (tada postaju MARKET, odnosno LIMIT)
+		return false
 	} else if strings.ToUpper(order.OrderType) == "MARKET" {
 		return true
 	}
@@ -726,6 +879,14 @@ func getBuyerID(a, b types.Order) uint {
 	return b.UserID
 }
 
+// getSellerOrder vraća sell stranu para ordera.
+func getSellerOrder(a, b types.Order) types.Order {
+	if strings.ToLower(a.Direction) == "sell" {
+		return a
+	}
+	return b
+}
+
 func getSellerID(a, b types.Order) uint {
 	if strings.ToLower(a.Direction) == "sell" {
 		return a.UserID
@@ -758,7 +919,7 @@ func IsSettlementDateValid(order *types.Order) bool {
 		}
 
 		// Poredi samo po danima, ne po satu
-		now := time.Now().Truncate(24 * time.Hour)
+		now := clock.Now().Truncate(24 * time.Hour)
 		parsed = parsed.Truncate(24 * time.Hour)
 
 		if parsed.Before(now) {
@@ -779,7 +940,7 @@ func UpdateAvailableVolumeTx(tx *gorm.DB, securityID uint) error {
 
 	// Direktno koristi RAW SQL da izbegnemo GORM probleme sa pointerima i imenovanjem
 	query := `
-		SELECT SUM(remaining_parts)
+		SELECT SUM(COALESCE(visible_remaining, remaining_parts))
 		FROM "order"
 		WHERE security_id = ?
 		  AND lower(direction) = 'sell'
@@ -842,22 +1003,18 @@ func CanSell(userID, securityID uint, requestedQty int) (bool, int, error) {
 	}
 
 	var portfolio types.Portfolio
-	if err := db.DB.Where("user_id = ? AND security_id = ?", userID, securityID).First(&portfolio).Error; err != nil {
+	if err := db.DB.Where("user_id = ? AND security_id = ?", userID, securityID).First(&portfolio).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return false, 0, err
 	}
 
-	// Suma svih već POSTOJEĆIH neizvršenih SELL naloga
-	var reserved int64
-	err := db.DB.Model(&types.Order{}).
-		Select("COALESCE(SUM(remaining_parts), 0)").
-		Where("user_id = ? AND security_id = ? AND lower(direction) = 'sell' AND lower(status) = 'approved' AND COALESCE(is_done, false) = false", userID, securityID).
-		Scan(&reserved).Error
+	// Hartije zadržane u otvorenim SELL nalozima
+	reserved, err := holds.HeldShares(db.DB, userID, securityID, holds.ReasonOrder)
 	if err != nil {
 		return false, 0, err
 	}
 
 	// Izračunaj slobodno dostupne privatne hartije
-	available := portfolio.Quantity - portfolio.PublicCount - int(reserved)
+	available := portfolio.Quantity - portfolio.PublicCount - reserved
 
 	if requestedQty > available {
 		return false, available, nil
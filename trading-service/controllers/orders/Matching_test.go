@@ -0,0 +1,49 @@
+package orders
+
+import (
+	"testing"
+
+	"banka1.com/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTradePrice_UsesRestingLimit(t *testing.T) {
+	buy := types.Order{Direction: "buy", OrderType: "LIMIT", LimitPricePerUnit: fptr(105)}
+	sell := types.Order{Direction: "sell", OrderType: "LIMIT", LimitPricePerUnit: fptr(101)}
+
+	price, ok := tradePrice(buy, sell, 100)
+	assert.True(t, ok)
+	assert.Equal(t, 101.0, price)
+}
+
+func TestTradePrice_MarketTakesRestingLimit(t *testing.T) {
+	buy := types.Order{Direction: "buy", OrderType: "MARKET"}
+	sell := types.Order{Direction: "sell", OrderType: "LIMIT", LimitPricePerUnit: fptr(101)}
+
+	price, ok := tradePrice(buy, sell, 90)
+	assert.True(t, ok)
+	assert.Equal(t, 101.0, price)
+}
+
+func TestTradePrice_RestingMarketTakesIncomingLimit(t *testing.T) {
+	sell := types.Order{Direction: "sell", OrderType: "LIMIT", LimitPricePerUnit: fptr(99)}
+	buy := types.Order{Direction: "buy", OrderType: "MARKET"}
+
+	price, ok := tradePrice(sell, buy, 100)
+	assert.True(t, ok)
+	assert.Equal(t, 99.0, price)
+}
+
+func TestTradePrice_BothMarketUseReference(t *testing.T) {
+	price, ok := tradePrice(types.Order{Direction: "buy"}, types.Order{Direction: "sell"}, 100)
+	assert.True(t, ok)
+	assert.Equal(t, 100.0, price)
+}
+
+func TestTradePrice_NoCross(t *testing.T) {
+	buy := types.Order{Direction: "buy", OrderType: "LIMIT", LimitPricePerUnit: fptr(99)}
+	sell := types.Order{Direction: "sell", OrderType: "LIMIT", LimitPricePerUnit: fptr(101)}
+
+	_, ok := tradePrice(buy, sell, 100)
+	assert.False(t, ok)
+}
@@ -0,0 +1,27 @@
+package orders
+
+import "banka1.com/types"
+
+// OrderContractSize vraća veličinu ugovora ordera. Stari orderi bez upisane
+// veličine (0) računaju se kao jedna jedinica po ugovoru.
+func OrderContractSize(order types.Order) int {
+	if order.ContractSize > 0 {
+		return order.ContractSize
+	}
+	return 1
+}
+
+// SecurityContractSize vraća veličinu ugovora hartije iz listinga (akcije 1,
+// opcije 100, futures i forex po ugovoru).
+func SecurityContractSize(security types.Security) int {
+	if security.ContractSize > 0 {
+		return int(security.ContractSize)
+	}
+	return 1
+}
+
+// Notional pretvara količinu ugovora po ceni jedinice u ukupnu vrednost:
+// cena * količina * veličina ugovora.
+func Notional(order types.Order, price float64, quantity int) float64 {
+	return price * float64(quantity) * float64(OrderContractSize(order))
+}
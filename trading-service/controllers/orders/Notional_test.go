@@ -0,0 +1,39 @@
+package orders
+
+import (
+	"testing"
+
+	"banka1.com/db"
+	"banka1.com/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotional_UsesContractSize(t *testing.T) {
+	assert.Equal(t, 500.0, Notional(types.Order{}, 50, 10), "order bez veličine ugovora se računa kao 1")
+	assert.Equal(t, 75000.0, Notional(types.Order{ContractSize: 1000}, 75, 1))
+	assert.Equal(t, 1200.0, Notional(types.Order{ContractSize: 100}, 4, 3))
+
+	assert.Equal(t, 1, SecurityContractSize(types.Security{}))
+	assert.Equal(t, 100, SecurityContractSize(types.Security{ContractSize: 100}))
+}
+
+func TestFillFromBank_ContractSizeNotional(t *testing.T) {
+	security := setupBankMarket(t)
+	assert.NoError(t, db.DB.Create(&types.Portfolio{UserID: BankUserID, SecurityID: security.ID, Quantity: 10, PurchasePrice: 90}).Error)
+
+	order := types.Order{UserID: 1, AccountID: 7, SecurityID: security.ID, Direction: "buy", OrderType: "LIMIT", LimitPricePerUnit: fptr(110), ContractSize: 100, Quantity: 2, RemainingParts: ptr(2), Status: "approved"}
+	assert.NoError(t, db.DB.Create(&order).Error)
+
+	filled, sent := fillFromBank(&order, db.DB)
+	assert.Equal(t, 2, filled)
+	assert.Len(t, sent, 1)
+	// 2 ugovora po 100 jedinica po Ask 101
+	assert.Equal(t, 20200.0, sent[0].Amount)
+	assert.Equal(t, 12.0, sent[0].Fee)
+
+	var txn types.Transaction
+	assert.NoError(t, db.DB.Where("order_id = ?", order.ID).First(&txn).Error)
+	assert.Equal(t, 2, txn.Quantity)
+	assert.Equal(t, 101.0, txn.PricePerUnit)
+	assert.Equal(t, 20200.0, txn.TotalPrice)
+}
@@ -0,0 +1,329 @@
+package orders
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"banka1.com/db"
+	"banka1.com/types"
+	"gorm.io/gorm"
+)
+
+// bookEntry je snapshot jednog ordera koji stoji u knjizi naloga.
+type bookEntry struct {
+	OrderID   uint
+	UserID    uint
+	Direction string
+	Price     *float64 // nil za ordere bez limita (MARKET, STOP)
+	Remaining int
+	Priority  int64
+	Order     types.Order // Stanje ordera kada je upisan u knjigu, sa hartijom knjige
+}
+
+// OrderBook drži bid i ask strane jedne hartije sortirane po ceni, pa po vremenu.
+type OrderBook struct {
+	SecurityID uint
+
+	// Hartija se čita jednom, pri učitavanju knjige, da provera settlement datuma
+	// kandidata za matchovanje ne bi išla u bazu
+	security types.Security
+
+	mu    sync.RWMutex
+	bids  []*bookEntry
+	asks  []*bookEntry
+	index map[uint]*bookEntry
+}
+
+var (
+	books   = make(map[uint]*OrderBook)
+	booksMu sync.Mutex
+)
+
+func newOrderBook(securityID uint) *OrderBook {
+	return &OrderBook{
+		SecurityID: securityID,
+		index:      make(map[uint]*bookEntry),
+	}
+}
+
+// LoadOrderBooks ponovo gradi sve knjige naloga iz odobrenih, neizvršenih ordera.
+func LoadOrderBooks() {
+	var resting []types.Order
+	if err := db.DB.Preload("Security").Where("status = ? AND NOT is_done", "approved").Find(&resting).Error; err != nil {
+		fmt.Printf("Greska pri ucitavanju knjige naloga: %v\n", err)
+		return
+	}
+
+	loaded := make(map[uint]*OrderBook)
+	for _, order := range resting {
+		book, ok := loaded[order.SecurityID]
+		if !ok {
+			book = newOrderBook(order.SecurityID)
+			book.security = order.Security
+			loaded[order.SecurityID] = book
+		}
+		book.upsert(order)
+	}
+
+	booksMu.Lock()
+	books = loaded
+	booksMu.Unlock()
+
+	fmt.Printf("Knjiga naloga ucitana: %d ordera za %d hartija\n", len(resting), len(loaded))
+}
+
+// getBook vraća knjigu naloga za hartiju, a ako ne postoji učitava je iz baze.
+// Učitavanje ide van booksMu, da ne bi blokiralo pristup knjigama ostalih hartija.
+func getBook(securityID uint) *OrderBook {
+	booksMu.Lock()
+	book, exists := books[securityID]
+	booksMu.Unlock()
+	if exists {
+		return book
+	}
+
+	loaded := loadBook(securityID)
+
+	booksMu.Lock()
+	defer booksMu.Unlock()
+	// Druga gorutina je u međuvremenu mogla da upiše knjigu; ostaje njena
+	if book, exists := books[securityID]; exists {
+		return book
+	}
+	books[securityID] = loaded
+	return loaded
+}
+
+// loadBook gradi knjigu naloga hartije iz baze.
+func loadBook(securityID uint) *OrderBook {
+	book := newOrderBook(securityID)
+	if err := db.DB.First(&book.security, securityID).Error; err != nil {
+		fmt.Printf("Greska pri ucitavanju hartije %d za knjigu naloga: %v\n", securityID, err)
+	}
+	var resting []types.Order
+	if err := db.DB.Where("security_id = ? AND status = ? AND NOT is_done", securityID, "approved").Find(&resting).Error; err != nil {
+		fmt.Printf("Greska pri ucitavanju knjige naloga za hartiju %d: %v\n", securityID, err)
+	}
+	for _, order := range resting {
+		book.upsert(order)
+	}
+	return book
+}
+
+// AddToBook ubacuje (ili osvežava) order u knjigu naloga njegove hartije.
+func AddToBook(order types.Order) {
+	getBook(order.SecurityID).upsert(order)
+}
+
+// RemoveFromBook uklanja order iz knjige naloga.
+func RemoveFromBook(order types.Order) {
+	getBook(order.SecurityID).remove(order.ID)
+}
+
+// StartOrder ubacuje odobren order u knjigu naloga, pokreće izvršavanje i budi
+// ostale ordere iste hartije.
+func StartOrder(order types.Order) {
+	if order.Status != "approved" {
+		return
+	}
+
+	AddToBook(order)
+	MatchOrder(order)
+	WakeSecurity(order.SecurityID)
+
+	if strings.ToLower(order.Direction) == "sell" {
+		_ = UpdateAvailableVolume(order.SecurityID)
+	}
+}
+
+// syncBook osvežava stavke knjige iz baze nakon commit-a (izvršenja, otkazivanja...).
+func syncBook(securityID uint, orderIDs ...uint) {
+	if len(orderIDs) == 0 {
+		return
+	}
+	var current []types.Order
+	if err := db.DB.Where("id IN ?", orderIDs).Find(&current).Error; err != nil {
+		fmt.Printf("Greska pri osvezavanju knjige naloga: %v\n", err)
+		return
+	}
+
+	book := getBook(securityID)
+	found := make(map[uint]bool, len(current))
+	for _, order := range current {
+		found[order.ID] = true
+		book.upsert(order)
+	}
+	for _, id := range orderIDs {
+		if !found[id] {
+			book.remove(id)
+		}
+	}
+}
+
+// isResting označava ordere koji stoje u knjizi; neaktivirani stop orderi čekaju u trigger engine-u.
+func isResting(order types.Order) bool {
+	return order.Status == "approved" && !order.IsDone && order.RemainingParts != nil && *order.RemainingParts > 0 &&
+		!isPendingTrigger(order)
+}
+
+func bookPrice(order types.Order) *float64 {
+	switch strings.ToUpper(order.OrderType) {
+	case "LIMIT", "STOP-LIMIT":
+		return order.LimitPricePerUnit
+	default:
+		return nil
+	}
+}
+
+func orderPriority(order types.Order) int64 {
+	if order.PriorityTime != 0 {
+		return order.PriorityTime
+	}
+	return order.LastModified * 1e9
+}
+
+// upsert dodaje order u knjigu ili ga pomera na ispravno mesto; ordere koji
+// više ne stoje u knjizi (izvršeni, otkazani...) uklanja.
+func (b *OrderBook) upsert(order types.Order) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.removeLocked(order.ID)
+	if !isResting(order) {
+		return
+	}
+
+	// Knjiga drži sopstvenu kopiju preostale količine, jer je pozivaoci menjaju
+	snapshot := order
+	snapshot.RemainingParts = ptr(*order.RemainingParts)
+	if snapshot.Security.ID == 0 {
+		snapshot.Security = b.security
+	}
+	entry := &bookEntry{
+		OrderID:   order.ID,
+		UserID:    order.UserID,
+		Direction: strings.ToLower(order.Direction),
+		Price:     bookPrice(order),
+		Remaining: visibleQuantity(order), // Iceberg u knjizi prikazuje samo vidljivi deo
+		Priority:  orderPriority(order),
+		Order:     snapshot,
+	}
+
+	side := b.side(entry.Direction)
+	pos := sort.Search(len(*side), func(i int) bool {
+		return before(entry, (*side)[i])
+	})
+	*side = append(*side, nil)
+	copy((*side)[pos+1:], (*side)[pos:])
+	(*side)[pos] = entry
+	b.index[order.ID] = entry
+}
+
+func (b *OrderBook) remove(orderID uint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.removeLocked(orderID)
+}
+
+func (b *OrderBook) removeLocked(orderID uint) {
+	entry, exists := b.index[orderID]
+	if !exists {
+		return
+	}
+	delete(b.index, orderID)
+
+	side := b.side(entry.Direction)
+	for i, e := range *side {
+		if e.OrderID == orderID {
+			*side = append((*side)[:i], (*side)[i+1:]...)
+			return
+		}
+	}
+}
+
+func (b *OrderBook) side(direction string) *[]*bookEntry {
+	if direction == "buy" {
+		return &b.bids
+	}
+	return &b.asks
+}
+
+// before određuje da li stavka a ima prednost nad b na istoj strani knjige:
+// ordere bez limita prvo, zatim bolja cena, zatim raniji ulazak u knjigu.
+func before(a, b *bookEntry) bool {
+	if (a.Price == nil) != (b.Price == nil) {
+		return a.Price == nil
+	}
+	if a.Price != nil && *a.Price != *b.Price {
+		if a.Direction == "buy" {
+			return *a.Price > *b.Price
+		}
+		return *a.Price < *b.Price
+	}
+	if a.Priority != b.Priority {
+		return a.Priority < b.Priority
+	}
+	return a.OrderID < b.OrderID
+}
+
+// Opposite vraća kopiju suprotne strane knjige za dati smer, bez ordera istog korisnika.
+func (b *OrderBook) Opposite(direction string, excludeUserID uint) []bookEntry {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	side := b.asks
+	if strings.ToLower(direction) == "sell" {
+		side = b.bids
+	}
+
+	result := make([]bookEntry, 0, len(side))
+	for _, e := range side {
+		if e.UserID == excludeUserID {
+			continue
+		}
+		result = append(result, *e)
+	}
+	return result
+}
+
+// Depth vraća ukupnu preostalu količinu na strani knjige za dati smer.
+func (b *OrderBook) Depth(direction string) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	total := 0
+	for _, e := range *b.side(strings.ToLower(direction)) {
+		total += e.Remaining
+	}
+	return total
+}
+
+// bookOrders vraća ordere suprotne strane knjige redosledom prioriteta, onakve
+// kakvi su upisani u knjigu, bez čitanja baze. Order koji se zaista izvršava se
+// pre izmene ponovo čita preko refreshMatch.
+func bookOrders(order types.Order) []types.Order {
+	entries := getBook(order.SecurityID).Opposite(order.Direction, order.UserID)
+	result := make([]types.Order, len(entries))
+	for i, e := range entries {
+		result[i] = e.Order
+	}
+	return result
+}
+
+// refreshMatch ponovo čita order iz knjige neposredno pre izvršenja. Drugi rezultat
+// je false ako order u bazi više ne stoji u knjizi; tada se uklanja i iz knjige.
+func refreshMatch(tx *gorm.DB, match types.Order) (types.Order, bool, error) {
+	var current types.Order
+	err := tx.Where("id = ? AND status = 'approved' AND NOT is_done", match.ID).First(&current).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		getBook(match.SecurityID).remove(match.ID)
+		return match, false, nil
+	}
+	if err != nil {
+		return match, false, err
+	}
+	return current, true, nil
+}
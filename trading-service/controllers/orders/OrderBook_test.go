@@ -0,0 +1,149 @@
+package orders
+
+import (
+	"testing"
+
+	"banka1.com/db"
+	"banka1.com/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func fptr(f float64) *float64 { return &f }
+
+func restingOrder(id, userID uint, direction, orderType string, limit *float64, remaining int, priority int64) types.Order {
+	return types.Order{
+		ID:                id,
+		UserID:            userID,
+		SecurityID:        1,
+		Direction:         direction,
+		OrderType:         orderType,
+		LimitPricePerUnit: limit,
+		Quantity:          remaining,
+		RemainingParts:    ptr(remaining),
+		Status:            "approved",
+		PriorityTime:      priority,
+	}
+}
+
+func entryIDs(entries []bookEntry) []uint {
+	ids := make([]uint, len(entries))
+	for i, e := range entries {
+		ids[i] = e.OrderID
+	}
+	return ids
+}
+
+func TestOrderBook_AsksSortedByPriceThenTime(t *testing.T) {
+	book := newOrderBook(1)
+	book.upsert(restingOrder(1, 10, "sell", "LIMIT", fptr(101), 5, 1))
+	book.upsert(restingOrder(2, 11, "sell", "LIMIT", fptr(100), 5, 3))
+	book.upsert(restingOrder(3, 12, "sell", "LIMIT", fptr(100), 5, 2))
+	book.upsert(restingOrder(4, 13, "sell", "MARKET", nil, 5, 4))
+
+	assert.Equal(t, []uint{4, 3, 2, 1}, entryIDs(book.Opposite("buy", 99)))
+}
+
+func TestOrderBook_BidsSortedByHighestPrice(t *testing.T) {
+	book := newOrderBook(1)
+	book.upsert(restingOrder(1, 10, "buy", "LIMIT", fptr(99), 5, 1))
+	book.upsert(restingOrder(2, 11, "buy", "LIMIT", fptr(100), 5, 2))
+	book.upsert(restingOrder(3, 12, "buy", "LIMIT", fptr(98), 5, 3))
+
+	assert.Equal(t, []uint{2, 1, 3}, entryIDs(book.Opposite("sell", 99)))
+	assert.Equal(t, 15, book.Depth("buy"))
+}
+
+func TestOrderBook_OppositeSkipsOwnOrders(t *testing.T) {
+	book := newOrderBook(1)
+	book.upsert(restingOrder(1, 10, "sell", "LIMIT", fptr(100), 5, 1))
+	book.upsert(restingOrder(2, 11, "sell", "LIMIT", fptr(101), 5, 2))
+
+	assert.Equal(t, []uint{2}, entryIDs(book.Opposite("buy", 10)))
+}
+
+func TestOrderBook_UpsertRemovesFinishedOrders(t *testing.T) {
+	book := newOrderBook(1)
+	order := restingOrder(1, 10, "sell", "LIMIT", fptr(100), 5, 1)
+	book.upsert(order)
+	assert.Equal(t, 5, book.Depth("sell"))
+
+	order.RemainingParts = ptr(2)
+	book.upsert(order)
+	assert.Equal(t, 2, book.Depth("sell"))
+
+	order.IsDone = true
+	order.Status = "done"
+	book.upsert(order)
+	assert.Empty(t, book.Opposite("buy", 99))
+	assert.Equal(t, 0, book.Depth("sell"))
+}
+
+func TestBookOrders_ServedFromBookAndRefreshedBeforeExecution(t *testing.T) {
+	assert.NoError(t, db.InitTestDatabase())
+	const securityID = 9001
+	book := newOrderBook(securityID)
+	booksMu.Lock()
+	books[securityID] = book
+	booksMu.Unlock()
+	t.Cleanup(func() {
+		booksMu.Lock()
+		delete(books, securityID)
+		booksMu.Unlock()
+	})
+
+	stored := restingOrder(0, 10, "sell", "LIMIT", fptr(100), 5, 1)
+	stored.SecurityID = securityID
+	stored.AccountID = 3
+	assert.NoError(t, db.DB.Create(&stored).Error)
+	t.Cleanup(func() { db.DB.Delete(&stored) })
+	book.upsert(stored)
+
+	// Order koji više nije u bazi ostaje u knjizi dok ga izvršenje ne pročita
+	gone := restingOrder(stored.ID+1000, 11, "sell", "LIMIT", fptr(101), 4, 2)
+	gone.SecurityID = securityID
+	book.upsert(gone)
+
+	buy := types.Order{UserID: 1, SecurityID: securityID, Direction: "buy", OrderType: "MARKET"}
+	matches := bookOrders(buy)
+	assert.Equal(t, []uint{stored.ID, gone.ID}, []uint{matches[0].ID, matches[1].ID})
+
+	// Izmena u bazi se vidi tek pri ponovnom čitanju
+	assert.NoError(t, db.DB.Model(&stored).Update("remaining_parts", 3).Error)
+	assert.Equal(t, 5, *matches[0].RemainingParts)
+	current, ok, err := refreshMatch(db.DB, matches[0])
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 3, *current.RemainingParts)
+
+	_, ok, err = refreshMatch(db.DB, matches[1])
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, []uint{stored.ID}, entryIDs(book.Opposite("buy", 1)))
+}
+
+func TestGetBook_CachesSecurityForSettlementCheck(t *testing.T) {
+	assert.NoError(t, db.InitTestDatabase())
+	settlement := "2999-01-01"
+	security := types.Security{Ticker: "STLX", Name: "Settlement test", Type: "Future", LastPrice: 100, SettlementDate: &settlement}
+	assert.NoError(t, db.DB.Create(&security).Error)
+	stored := restingOrder(0, 10, "sell", "LIMIT", fptr(100), 5, 1)
+	stored.SecurityID = security.ID
+	stored.AccountID = 3
+	assert.NoError(t, db.DB.Create(&stored).Error)
+	t.Cleanup(func() {
+		booksMu.Lock()
+		delete(books, security.ID)
+		booksMu.Unlock()
+		db.DB.Delete(&stored)
+	})
+
+	book := getBook(security.ID)
+	assert.Same(t, book, getBook(security.ID))
+
+	// Bez hartije u bazi provera prolazi samo ako settlement datum dolazi iz knjige
+	assert.NoError(t, db.DB.Delete(&security).Error)
+	matches := bookOrders(types.Order{UserID: 1, SecurityID: security.ID, Direction: "buy", OrderType: "MARKET"})
+	assert.Len(t, matches, 1)
+	assert.Equal(t, security.ID, matches[0].Security.ID)
+	assert.True(t, canPreExecute(matches[0]))
+}
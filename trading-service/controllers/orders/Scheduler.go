@@ -0,0 +1,339 @@
+package orders
+
+import (
+	"container/heap"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"banka1.com/clock"
+	"banka1.com/db"
+	"banka1.com/listings/pricefeed"
+	"banka1.com/types"
+)
+
+const (
+	// DefaultSchedulerWorkers je broj šardova (i radnika) kada ORDER_SCHEDULER_WORKERS nije podešen
+	DefaultSchedulerWorkers = 8
+
+	// closedMarketRetry je pauza pre ponovnog pokušaja ordera dok je berza zatvorena
+	closedMarketRetry = 5 * time.Minute
+)
+
+// shard serijski obrađuje ordere hartija koje mu pripadaju (securityID % broj šardova),
+// pa se orderi iste hartije nikada ne izvršavaju paralelno.
+type shard struct {
+	mu     sync.Mutex
+	queue  []uint
+	queued map[uint]bool
+	signal chan struct{}
+}
+
+func newShard() *shard {
+	return &shard{
+		queued: make(map[uint]bool),
+		signal: make(chan struct{}, 1),
+	}
+}
+
+// push dodaje order u red šarda; order koji već čeka se ne duplira.
+func (s *shard) push(orderID uint) {
+	s.mu.Lock()
+	if s.queued[orderID] {
+		s.mu.Unlock()
+		return
+	}
+	s.queued[orderID] = true
+	s.queue = append(s.queue, orderID)
+	s.mu.Unlock()
+
+	select {
+	case s.signal <- struct{}{}:
+	default:
+	}
+}
+
+// pop vraća sledeći order iz reda, drugi rezultat je false ako je red prazan.
+func (s *shard) pop() (uint, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 {
+		return 0, false
+	}
+	orderID := s.queue[0]
+	s.queue = s.queue[1:]
+	delete(s.queued, orderID)
+	return orderID, true
+}
+
+func (s *shard) run() {
+	for range s.signal {
+		for {
+			orderID, ok := s.pop()
+			if !ok {
+				break
+			}
+			processOrder(orderID)
+		}
+	}
+}
+
+// retry je odloženi pokušaj izvršenja ordera.
+type retry struct {
+	OrderID    uint
+	SecurityID uint
+	At         time.Time
+}
+
+// retryHeap je min-heap odloženih pokušaja po vremenu.
+type retryHeap []retry
+
+func (h retryHeap) Len() int           { return len(h) }
+func (h retryHeap) Less(i, j int) bool { return h[i].At.Before(h[j].At) }
+func (h retryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *retryHeap) Push(x any)        { *h = append(*h, x.(retry)) }
+func (h *retryHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// retryQueue drži odložene pokušaje umesto gorutina koje spavaju. notBefore
+// pamti do kada order čeka, da ga buđenje hartije ne bi izvršilo pre vremena.
+type retryQueue struct {
+	mu        sync.Mutex
+	items     retryHeap
+	notBefore map[uint]time.Time
+	wake      chan struct{}
+}
+
+func newRetryQueue() *retryQueue {
+	return &retryQueue{
+		notBefore: make(map[uint]time.Time),
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+func (q *retryQueue) add(item retry) {
+	q.mu.Lock()
+	heap.Push(&q.items, item)
+	q.notBefore[item.OrderID] = item.At
+	q.mu.Unlock()
+	q.signal()
+}
+
+// signal budi gorutinu reda da ponovo izračuna koji su pokušaji na redu.
+func (q *retryQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// due skida sa heap-a sve pokušaje kojima je došlo vreme i vraća koliko se čeka na sledeći.
+func (q *retryQueue) due(now time.Time) ([]retry, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var ready []retry
+	for q.items.Len() > 0 && !q.items[0].At.After(now) {
+		item := heap.Pop(&q.items).(retry)
+		// Zastareo pokušaj: order je u međuvremenu ponovo zakazan za kasnije
+		if at, ok := q.notBefore[item.OrderID]; ok && at.After(item.At) {
+			continue
+		}
+		delete(q.notBefore, item.OrderID)
+		ready = append(ready, item)
+	}
+	if q.items.Len() == 0 {
+		return ready, -1
+	}
+	return ready, q.items[0].At.Sub(now)
+}
+
+// waiting proverava da li order čeka odloženi pokušaj.
+func (q *retryQueue) waiting(orderID uint, now time.Time) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	at, ok := q.notBefore[orderID]
+	return ok && at.After(now)
+}
+
+func (q *retryQueue) clear(orderID uint) {
+	q.mu.Lock()
+	delete(q.notBefore, orderID)
+	q.mu.Unlock()
+}
+
+func (q *retryQueue) run() {
+	timer := time.NewTimer(time.Hour)
+	for {
+		ready, next := q.due(clock.Now())
+		for _, item := range ready {
+			enqueue(item.SecurityID, item.OrderID)
+		}
+		if next < 0 {
+			next = time.Hour
+		}
+		// Virtuelno vreme može da teče brže od stvarnog, pa se red češće proverava
+		if _, simulated := clock.Simulation(); simulated && next > time.Second {
+			next = time.Second
+		}
+		timer.Reset(next)
+
+		select {
+		case <-timer.C:
+		case <-q.wake:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		}
+	}
+}
+
+var (
+	schedulerOnce sync.Once
+	priceWakeOnce sync.Once
+	shards        []*shard
+	retries       *retryQueue
+)
+
+// SchedulerWorkers vraća broj radnika iz ORDER_SCHEDULER_WORKERS, podrazumevano DefaultSchedulerWorkers.
+func SchedulerWorkers() int {
+	if n, err := strconv.Atoi(os.Getenv("ORDER_SCHEDULER_WORKERS")); err == nil && n > 0 {
+		return n
+	}
+	return DefaultSchedulerWorkers
+}
+
+// startScheduler pokreće radnike i red odloženih pokušaja pri prvom korišćenju.
+func startScheduler() {
+	schedulerOnce.Do(func() {
+		shards = make([]*shard, SchedulerWorkers())
+		for i := range shards {
+			shards[i] = newShard()
+			go shards[i].run()
+		}
+		retries = newRetryQueue()
+		go retries.run()
+		clock.OnChange(retries.signal)
+	})
+}
+
+// StartScheduler pokreće izvršavanje ordera, pretplaćuje se na promene cena
+// i budi sve hartije koje imaju ordere u knjizi.
+func StartScheduler() {
+	startScheduler()
+	priceWakeOnce.Do(func() {
+		pricefeed.Subscribe(onPriceWake)
+	})
+
+	booksMu.Lock()
+	securityIDs := make([]uint, 0, len(books))
+	for securityID := range books {
+		securityIDs = append(securityIDs, securityID)
+	}
+	booksMu.Unlock()
+
+	for _, securityID := range securityIDs {
+		WakeSecurity(securityID)
+	}
+	fmt.Printf("Scheduler ordera pokrenut: %d radnika, probuđeno %d hartija\n", len(shards), len(securityIDs))
+}
+
+func enqueue(securityID, orderID uint) {
+	startScheduler()
+	shards[int(securityID%uint(len(shards)))].push(orderID)
+}
+
+// scheduleRetry zakazuje ponovni pokušaj ordera nakon pauze.
+func scheduleRetry(order types.Order, delay time.Duration) {
+	startScheduler()
+	fmt.Printf("Order %d ponovo na redu za %v\n", order.ID, delay)
+	retries.add(retry{OrderID: order.ID, SecurityID: order.SecurityID, At: clock.Now().Add(delay)})
+}
+
+// MatchOrder stavlja order u red šarda njegove hartije; izvršava se asinhrono.
+// Eksplicitan poziv poništava pauzu ordera.
+func MatchOrder(order types.Order) {
+	startScheduler()
+	retries.clear(order.ID)
+	enqueue(order.SecurityID, order.ID)
+}
+
+// WakeSecurity stavlja u red ordere sa vrha obe strane knjige hartije, osim onih
+// koji čekaju odloženi pokušaj. Ako se orderi sa najboljom cenom ne mogu izvršiti,
+// ne mogu ni oni iza njih; izvršenje ponovo budi hartiju, pa sledeći nivo dolazi
+// na red.
+func WakeSecurity(securityID uint) {
+	startScheduler()
+	book := getBook(securityID)
+
+	book.mu.RLock()
+	ids := append(topOfSide(book.bids), topOfSide(book.asks)...)
+	book.mu.RUnlock()
+
+	now := clock.Now()
+	for _, orderID := range ids {
+		if retries.waiting(orderID, now) {
+			continue
+		}
+		enqueue(securityID, orderID)
+	}
+}
+
+// topOfSide vraća ordere sa vrha strane knjige: ordere bez limita i ordere sa
+// najboljom cenom. Poziva se pod book.mu.
+func topOfSide(side []*bookEntry) []uint {
+	var ids []uint
+	var best *float64
+	for _, e := range side {
+		if e.Price != nil {
+			if best != nil && *e.Price != *best {
+				break
+			}
+			best = e.Price
+		}
+		ids = append(ids, e.OrderID)
+	}
+	return ids
+}
+
+// onPriceWake budi hartiju čija se cena promenila, ali samo ako ima ordere u knjizi.
+func onPriceWake(update pricefeed.Update) {
+	var security types.Security
+	if err := db.DB.Select("id").Where("ticker = ?", update.Ticker).First(&security).Error; err != nil {
+		return
+	}
+
+	booksMu.Lock()
+	_, loaded := books[security.ID]
+	booksMu.Unlock()
+	if loaded {
+		WakeSecurity(security.ID)
+	}
+}
+
+// RunTimedJobs pokreće poslove koji zavise od vremena: istek DAY/GTD ordera i
+// settlement datuma, kraj obustava, aukcije, naloge koji predugo čekaju odobrenje,
+// opozvane pozajmice, margin pozive i naknade za pozajmice, uz ponovno slanje
+// nepotvrđenih kompenzacija. Cron ih pokreće svakog minuta, a simulacija odmah
+// posle pomeranja virtuelnog vremena. Svaki posao
+// poredi stanje sa clock.Now(), pa ponovno pokretanje ne obrađuje ništa dvaput.
+func RunTimedJobs() {
+	ExpireOrders()
+	DeclineExpiredSettlements()
+	ResumeExpiredHalts()
+	RunDueAuctions()
+	HandleStaleApprovals()
+	ProcessBorrowRecalls()
+	RevalueMarginAccounts()
+	AccrueBorrowFees()
+	RetryCompensations()
+}
@@ -0,0 +1,74 @@
+package orders
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardPush_Dedupes(t *testing.T) {
+	s := newShard()
+	s.push(1)
+	s.push(2)
+	s.push(1)
+
+	first, ok := s.pop()
+	assert.True(t, ok)
+	assert.Equal(t, uint(1), first)
+	second, _ := s.pop()
+	assert.Equal(t, uint(2), second)
+	_, ok = s.pop()
+	assert.False(t, ok)
+
+	// Nakon što je skinut sa reda, order može ponovo da se doda
+	s.push(1)
+	again, ok := s.pop()
+	assert.True(t, ok)
+	assert.Equal(t, uint(1), again)
+}
+
+func TestRetryQueue_DueInTimeOrder(t *testing.T) {
+	q := newRetryQueue()
+	now := time.Now()
+	q.add(retry{OrderID: 3, At: now.Add(3 * time.Second)})
+	q.add(retry{OrderID: 1, At: now.Add(time.Second)})
+	q.add(retry{OrderID: 2, At: now.Add(2 * time.Second)})
+
+	assert.True(t, q.waiting(1, now))
+
+	ready, next := q.due(now.Add(2 * time.Second))
+	assert.Len(t, ready, 2)
+	assert.Equal(t, uint(1), ready[0].OrderID)
+	assert.Equal(t, uint(2), ready[1].OrderID)
+	assert.Equal(t, time.Second, next)
+	assert.False(t, q.waiting(1, now))
+	assert.True(t, q.waiting(3, now))
+}
+
+func TestRetryQueue_SkipsStaleRetry(t *testing.T) {
+	q := newRetryQueue()
+	now := time.Now()
+	q.add(retry{OrderID: 1, At: now.Add(time.Second)})
+	q.add(retry{OrderID: 1, At: now.Add(time.Minute)})
+
+	ready, _ := q.due(now.Add(2 * time.Second))
+	assert.Empty(t, ready)
+	assert.True(t, q.waiting(1, now.Add(2*time.Second)))
+
+	ready, next := q.due(now.Add(time.Minute))
+	assert.Len(t, ready, 1)
+	assert.Equal(t, time.Duration(-1), next)
+}
+
+func TestTopOfSide_BestLevelOnly(t *testing.T) {
+	book := newOrderBook(1)
+	book.upsert(restingOrder(1, 10, "sell", "LIMIT", fptr(101), 5, 1))
+	book.upsert(restingOrder(2, 11, "sell", "LIMIT", fptr(100), 5, 2))
+	book.upsert(restingOrder(3, 12, "sell", "MARKET", nil, 5, 3))
+	book.upsert(restingOrder(4, 13, "sell", "LIMIT", fptr(100), 5, 4))
+
+	// Orderi bez limita i svi na najboljoj ceni; skuplji nivo čeka izvršenje ovih
+	assert.Equal(t, []uint{3, 2, 4}, topOfSide(book.asks))
+	assert.Empty(t, topOfSide(book.bids))
+}
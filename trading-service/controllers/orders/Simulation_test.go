@@ -0,0 +1,99 @@
+package orders
+
+import (
+	"testing"
+	"time"
+
+	"banka1.com/clock"
+	"banka1.com/db"
+	"banka1.com/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type fixedRandom int
+
+func (f fixedRandom) Intn(n int) int { return int(f) % n }
+
+func TestSettlementExpiry_VirtualTime(t *testing.T) {
+	assert.NoError(t, db.InitTestDatabase())
+	sim := clock.NewSimulated(time.Date(2025, 6, 19, 12, 0, 0, 0, time.UTC), 0)
+	defer clock.Use(sim)()
+
+	settlement := "2025-06-20"
+	security := types.Security{Ticker: "SIMF", Name: "Sim future", Type: "Future", LastPrice: 50, SettlementDate: &settlement}
+	assert.NoError(t, db.DB.Create(&security).Error)
+	order := types.Order{UserID: 1, AccountID: 7, SecurityID: security.ID, Direction: "buy", OrderType: "MARKET", Quantity: 3, RemainingParts: ptr(3), Status: "approved"}
+	assert.NoError(t, db.DB.Create(&order).Error)
+	t.Cleanup(func() {
+		db.DB.Where("order_id = ?", order.ID).Delete(&types.OrderEvent{})
+		db.DB.Delete(&order)
+		db.DB.Delete(&security)
+	})
+
+	DeclineExpiredSettlements()
+	assert.NoError(t, db.DB.First(&order, order.ID).Error)
+	assert.Equal(t, "approved", order.Status)
+
+	sim.Advance(48 * time.Hour)
+	DeclineExpiredSettlements()
+	assert.NoError(t, db.DB.First(&order, order.ID).Error)
+	assert.Equal(t, "declined", order.Status)
+	assert.True(t, order.IsDone)
+	assert.Equal(t, ExpiryReasonSettlement, *order.ExpiryReason)
+
+	var events []types.OrderEvent
+	assert.NoError(t, db.DB.Where("order_id = ?", order.ID).Find(&events).Error)
+	assert.Len(t, events, 1)
+	assert.Equal(t, EventDeclined, events[0].Type)
+}
+
+func TestAfterHoursRetry_VirtualTime(t *testing.T) {
+	sim := clock.NewSimulated(time.Date(2025, 6, 19, 21, 0, 0, 0, time.UTC), 0)
+	defer clock.Use(sim)()
+	defer clock.UseRandom(fixedRandom(4))()
+
+	order := types.Order{ID: 42, SecurityID: 9, AfterHours: true}
+	delay := calculateDelay(order)
+	assert.Equal(t, 1805*time.Second, delay)
+
+	q := newRetryQueue()
+	q.add(retry{OrderID: order.ID, SecurityID: order.SecurityID, At: clock.Now().Add(delay)})
+
+	sim.Advance(30 * time.Minute)
+	ready, next := q.due(clock.Now())
+	assert.Empty(t, ready)
+	assert.Equal(t, 5*time.Second, next)
+	assert.True(t, q.waiting(order.ID, clock.Now()))
+
+	sim.Advance(5 * time.Second)
+	ready, _ = q.due(clock.Now())
+	assert.Len(t, ready, 1)
+	assert.Equal(t, order.ID, ready[0].OrderID)
+}
+
+func TestRunTimedJobs_ExpiresOrderAfterAdvance(t *testing.T) {
+	assert.NoError(t, db.InitTestDatabase())
+	sim := clock.NewSimulated(time.Date(2025, 6, 19, 12, 0, 0, 0, time.UTC), 0)
+	defer clock.Use(sim)()
+
+	security := types.Security{Ticker: "SIMG", Name: "Sim GTD", Type: "Stock", LastPrice: 10}
+	assert.NoError(t, db.DB.Create(&security).Error)
+	expiresAt := sim.Now().Add(time.Hour)
+	order := types.Order{UserID: 1, AccountID: 7, SecurityID: security.ID, Direction: "buy", OrderType: "LIMIT", LimitPricePerUnit: fptr(10), Quantity: 3, RemainingParts: ptr(3), Status: "approved", TimeInForce: TimeInForceGTD, ExpiresAt: &expiresAt}
+	assert.NoError(t, db.DB.Create(&order).Error)
+	t.Cleanup(func() {
+		db.DB.Where("order_id = ?", order.ID).Delete(&types.OrderEvent{})
+		db.DB.Delete(&order)
+		db.DB.Delete(&security)
+	})
+
+	RunTimedJobs()
+	assert.NoError(t, db.DB.First(&order, order.ID).Error)
+	assert.Equal(t, "approved", order.Status)
+
+	sim.Advance(2 * time.Hour)
+	RunTimedJobs()
+	assert.NoError(t, db.DB.First(&order, order.ID).Error)
+	assert.Equal(t, "expired", order.Status)
+	assert.Equal(t, ExpiryReasonGTD, *order.ExpiryReason)
+}
@@ -0,0 +1,169 @@
+package orders
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"banka1.com/clock"
+	"banka1.com/db"
+	"banka1.com/exchanges"
+	"banka1.com/types"
+	"gorm.io/gorm"
+)
+
+const (
+	TimeInForceDay = "DAY" // Važi do zatvaranja berze
+	TimeInForceGTC = "GTC" // Važi dok se ne izvrši ili otkaže
+	TimeInForceGTD = "GTD" // Važi do zadatog trenutka
+	TimeInForceIOC = "IOC" // Izvršava se odmah koliko može, ostatak se otkazuje
+	TimeInForceFOK = "FOK" // Izvršava se odmah u celosti ili se otkazuje
+)
+
+const (
+	ExpiryReasonDay        = "DAY order je istekao zatvaranjem berze"
+	ExpiryReasonGTD        = "GTD order je dostigao rok važenja"
+	ExpiryReasonIOC        = "IOC order: neizvršeni deo je otkazan"
+	ExpiryReasonFOK        = "FOK order nije mogao biti izvršen u celosti"
+	ExpiryReasonSettlement = "Istekao je settlement datum hartije"
+)
+
+// NormalizeTimeInForce vraća time-in-force velikim slovima, podrazumevano GTC.
+func NormalizeTimeInForce(value string) string {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if value == "" {
+		return TimeInForceGTC
+	}
+	return value
+}
+
+// isImmediate označava ordere koji ne ostaju u knjizi posle prvog pokušaja izvršenja.
+func isImmediate(order types.Order) bool {
+	tif := NormalizeTimeInForce(order.TimeInForce)
+	return tif == TimeInForceIOC || tif == TimeInForceFOK
+}
+
+// isAllOrNone označava ordere koji se izvršavaju samo u celosti (AON i FOK).
+func isAllOrNone(order types.Order) bool {
+	return order.AON || NormalizeTimeInForce(order.TimeInForce) == TimeInForceFOK
+}
+
+// ExpiryFor računa trenutak isteka ordera na osnovu time-in-force.
+// DAY važi do sledećeg zatvaranja berze, GTD do zadatog trenutka, ostali nemaju rok.
+func ExpiryFor(order types.Order, requested *time.Time, now time.Time) (*time.Time, error) {
+	switch NormalizeTimeInForce(order.TimeInForce) {
+	case TimeInForceDay:
+		exchange, err := exchanges.ForSecurity(order.SecurityID)
+		if err != nil {
+			// Bez berze nema radnog vremena, pa DAY važi do kraja tekućeg dana
+			fmt.Printf("DAY order za hartiju %d bez berze: %v\n", order.SecurityID, err)
+			endOfDay := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+			return &endOfDay, nil
+		}
+		closeAt, err := exchanges.NextClose(*exchange, now)
+		if err != nil {
+			return nil, err
+		}
+		return &closeAt, nil
+	case TimeInForceGTD:
+		if requested == nil {
+			return nil, errors.New("GTD order mora imati expires_at")
+		}
+		if !requested.After(now) {
+			return nil, errors.New("expires_at mora biti u budućnosti")
+		}
+		expiresAt := *requested
+		return &expiresAt, nil
+	default:
+		return nil, nil
+	}
+}
+
+// ExpireOrder označava order kao istekao i skida ga iz knjige naloga.
+// Već izvršeni delovi ostaju izvršeni, a remaining_parts pokazuje koliko je otkazano.
+func ExpireOrder(order types.Order, reason string) error {
+	var expired bool
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&types.Order{}).
+			Where("id = ? AND status IN ? AND NOT is_done", order.ID, []string{"pending", "approved"}).
+			Updates(map[string]any{
+				"status":        "expired",
+				"is_done":       true,
+				"expiry_reason": reason,
+				"last_modified": clock.Now().Unix(),
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		expired = true
+		return releaseHolds(tx, order.ID)
+	})
+	if err != nil {
+		return err
+	}
+	RemoveFromBook(order)
+	RemoveTrigger(order)
+	if expired {
+		fmt.Printf("Order %d je istekao: %s\n", order.ID, reason)
+		recordStatus(nil, order, EventExpired, "expired", nil, reason)
+		if strings.ToLower(order.Direction) == "sell" {
+			_ = UpdateAvailableVolume(order.SecurityID)
+		}
+	}
+	return nil
+}
+
+// ExpireOrders ističe sve DAY i GTD ordere kojima je prošao rok važenja.
+func ExpireOrders() {
+	var expired []types.Order
+	if err := db.DB.Where("status IN ? AND NOT is_done AND expires_at IS NOT NULL AND expires_at <= ?",
+		[]string{"pending", "approved"}, clock.Now()).Find(&expired).Error; err != nil {
+		fmt.Printf("Greska pri dohvatanju isteklih ordera: %v\n", err)
+		return
+	}
+
+	for _, order := range expired {
+		reason := ExpiryReasonGTD
+		if NormalizeTimeInForce(order.TimeInForce) == TimeInForceDay {
+			reason = ExpiryReasonDay
+		}
+		if err := ExpireOrder(order, reason); err != nil {
+			fmt.Printf("Greska pri isticanju ordera %d: %v\n", order.ID, err)
+		}
+	}
+}
+
+// DeclineExpiredSettlements odbija odobrene ordere čijim hartijama je istekao settlement datum.
+func DeclineExpiredSettlements() {
+	var open []types.Order
+	if err := db.DB.Where("status = ? AND NOT is_done", "approved").Find(&open).Error; err != nil {
+		fmt.Printf("Greska pri dohvatanju neizvrsenih ordera: %v\n", err)
+		return
+	}
+
+	for _, order := range open {
+		if IsSettlementDateValid(&order) {
+			continue
+		}
+		fmt.Printf("Order %d automatski odbijen zbog isteka settlement datuma\n", order.ID)
+		if err := db.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&order).Updates(map[string]any{
+				"status":          "declined",
+				"is_done":         true,
+				"remaining_parts": 0,
+				"expiry_reason":   ExpiryReasonSettlement,
+			}).Error; err != nil {
+				return err
+			}
+			return releaseHolds(tx, order.ID)
+		}); err != nil {
+			fmt.Printf("Greska pri odbijanju ordera %d: %v\n", order.ID, err)
+			continue
+		}
+		RemoveFromBook(order)
+		RemoveTrigger(order)
+		order.Status = "declined"
+		RecordStatus(order, EventDeclined, nil, ExpiryReasonSettlement)
+	}
+}
@@ -0,0 +1,43 @@
+package orders
+
+import (
+	"testing"
+	"time"
+
+	"banka1.com/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTimeInForce(t *testing.T) {
+	assert.Equal(t, TimeInForceGTC, NormalizeTimeInForce(""))
+	assert.Equal(t, TimeInForceIOC, NormalizeTimeInForce(" ioc"))
+}
+
+func TestExpiryFor_GTD(t *testing.T) {
+	now := time.Now()
+	order := types.Order{TimeInForce: TimeInForceGTD}
+
+	_, err := ExpiryFor(order, nil, now)
+	assert.Error(t, err)
+
+	past := now.Add(-time.Hour)
+	_, err = ExpiryFor(order, &past, now)
+	assert.Error(t, err)
+
+	future := now.Add(time.Hour)
+	expiresAt, err := ExpiryFor(order, &future, now)
+	assert.NoError(t, err)
+	assert.Equal(t, future, *expiresAt)
+}
+
+func TestExpiryFor_GTCHasNoExpiry(t *testing.T) {
+	expiresAt, err := ExpiryFor(types.Order{}, nil, time.Now())
+	assert.NoError(t, err)
+	assert.Nil(t, expiresAt)
+}
+
+func TestIsAllOrNone(t *testing.T) {
+	assert.True(t, isAllOrNone(types.Order{AON: true}))
+	assert.True(t, isAllOrNone(types.Order{TimeInForce: TimeInForceFOK}))
+	assert.False(t, isAllOrNone(types.Order{TimeInForce: TimeInForceIOC}))
+}
@@ -0,0 +1,413 @@
+package orders
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"banka1.com/clock"
+	"banka1.com/db"
+	"banka1.com/listings/pricefeed"
+	"banka1.com/types"
+	"gorm.io/gorm"
+)
+
+// trigger je STOP/STOP-LIMIT order koji čeka da cena dostigne stop.
+type trigger struct {
+	OrderID uint
+	Stop    float64
+}
+
+// trailingTrigger je TRAILING-STOP order čiji stop prati najbolju viđenu cenu.
+type trailingTrigger struct {
+	OrderID   uint
+	Direction string
+	Amount    *float64
+	Percent   *float64
+	Reference float64
+	Stop      float64
+}
+
+// triggerSet drži okidače jedne hartije sortirane tako da su na početku oni
+// koji se prvi aktiviraju: kupovine po rastućem stopu (aktivira ih Ask >= stop),
+// prodaje po opadajućem stopu (aktivira ih Bid <= stop). Trailing okidači se
+// pomeraju sa svakom cenom, pa se drže nesortirani.
+type triggerSet struct {
+	buys     []trigger
+	sells    []trigger
+	trailing []*trailingTrigger
+}
+
+var (
+	triggers   = make(map[string]*triggerSet) // ključ je ticker hartije
+	triggersMu sync.Mutex
+	feedOnce   sync.Once
+)
+
+// isPendingTrigger označava STOP i STOP-LIMIT ordere koji još nisu aktivirani.
+func isPendingTrigger(order types.Order) bool {
+	switch strings.ToUpper(order.OrderType) {
+	case "STOP", "STOP-LIMIT", "TRAILING-STOP":
+		return order.ActivatedAt == nil
+	default:
+		return false
+	}
+}
+
+// StartTriggerEngine učitava neaktivirane stop ordere i pretplaćuje se na promene cena.
+func StartTriggerEngine() {
+	feedOnce.Do(func() {
+		pricefeed.Subscribe(OnPriceUpdate)
+	})
+
+	var pending []types.Order
+	if err := db.DB.Preload("Security").
+		Where("status = ? AND NOT is_done AND activated_at IS NULL AND UPPER(order_type) IN ?", "approved", []string{"STOP", "STOP-LIMIT", "TRAILING-STOP"}).
+		Find(&pending).Error; err != nil {
+		fmt.Printf("Greska pri ucitavanju stop ordera: %v\n", err)
+		return
+	}
+
+	loaded := make(map[string]*triggerSet)
+	var unstarted []types.Order
+	for _, order := range pending {
+		if order.Security.Ticker == "" {
+			continue
+		}
+		set, ok := loaded[order.Security.Ticker]
+		if !ok {
+			set = &triggerSet{}
+			loaded[order.Security.Ticker] = set
+		}
+		if isTrailing(order) {
+			if order.TrailReference != nil && order.TrailingStopPrice != nil {
+				set.addTrailing(newTrailingTrigger(order, *order.TrailReference))
+			} else {
+				unstarted = append(unstarted, order)
+			}
+			continue
+		}
+		if order.StopPricePerUnit != nil {
+			set.add(order.ID, order.Direction, *order.StopPricePerUnit)
+		}
+	}
+
+	triggersMu.Lock()
+	triggers = loaded
+	triggersMu.Unlock()
+
+	// Trailing stop koji još nema referentnu cenu je dobija kao pri kreiranju
+	for _, order := range unstarted {
+		AddTrigger(order)
+	}
+
+	fmt.Printf("Ucitano %d stop ordera koji cekaju aktivaciju\n", len(pending))
+}
+
+// AddTrigger registruje neaktiviran stop order. Ako je uslov već ispunjen po
+// trenutnoj ceni listinga, order se odmah aktivira. Trailing stop dobija
+// početnu referentnu cenu iz listinga, a ako je listing nema iz poslednje cene
+// hartije.
+func AddTrigger(order types.Order) {
+	if !isTrailing(order) && order.StopPricePerUnit == nil {
+		fmt.Printf("Stop order %d nema StopPricePerUnit\n", order.ID)
+		return
+	}
+
+	var security types.Security
+	if err := db.DB.First(&security, order.SecurityID).Error; err != nil {
+		fmt.Printf("Security nije pronadjen za ID %d: %v\n", order.SecurityID, err)
+		return
+	}
+
+	var listing types.Listing
+	listingErr := db.DB.Where("ticker = ?", security.Ticker).First(&listing).Error
+
+	var trail *trailingTrigger
+	if isTrailing(order) {
+		reference := order.TrailReference
+		if listingErr == nil {
+			if price := trailPrice(order.Direction, float64(listing.Ask), float64(listing.Bid)); price > 0 &&
+				(reference == nil || improves(order.Direction, *reference, price)) {
+				reference = &price
+			}
+		}
+		// Bez Ask/Bid cene u listingu praćenje počinje od poslednje cene hartije
+		if reference == nil && security.LastPrice > 0 {
+			price := security.LastPrice
+			reference = &price
+		}
+		if reference == nil {
+			fmt.Printf("Trailing stop order %d nema cenu od koje počinje praćenje\n", order.ID)
+			return
+		}
+		trail = newTrailingTrigger(order, *reference)
+		saveTrailing(*trail)
+	} else if listingErr == nil &&
+		stopReached(order.Direction, *order.StopPricePerUnit, float64(listing.Ask), float64(listing.Bid)) {
+		ActivateStopOrder(order.ID)
+		return
+	}
+
+	triggersMu.Lock()
+	set, ok := triggers[security.Ticker]
+	if !ok {
+		set = &triggerSet{}
+		triggers[security.Ticker] = set
+	}
+	set.remove(order.ID)
+	if trail != nil {
+		set.addTrailing(trail)
+	} else {
+		set.add(order.ID, order.Direction, *order.StopPricePerUnit)
+	}
+	triggersMu.Unlock()
+}
+
+// RemoveTrigger uklanja okidač otkazanog ili isteklog stop ordera.
+func RemoveTrigger(order types.Order) {
+	triggersMu.Lock()
+	defer triggersMu.Unlock()
+	for _, set := range triggers {
+		set.remove(order.ID)
+	}
+}
+
+// OnPriceUpdate aktivira sve stop ordere čiji je uslov ispunjen novom cenom.
+func OnPriceUpdate(update pricefeed.Update) {
+	triggersMu.Lock()
+	set, ok := triggers[update.Ticker]
+	var fired []trigger
+	var moved []trailingTrigger
+	if ok {
+		moved = set.follow(update.Ask, update.Bid)
+		fired = set.pop(update.Ask, update.Bid)
+	}
+	triggersMu.Unlock()
+
+	for _, t := range moved {
+		saveTrailing(t)
+	}
+	for _, t := range fired {
+		fmt.Printf("Cena %s (ask %.2f, bid %.2f) je dostigla stop %.2f za order %d\n",
+			update.Ticker, update.Ask, update.Bid, t.Stop, t.OrderID)
+		ActivateStopOrder(t.OrderID)
+	}
+}
+
+// ActivateStopOrder pretvara STOP u MARKET, odnosno STOP-LIMIT u LIMIT order,
+// beleži vreme aktivacije i pokreće izvršavanje.
+func ActivateStopOrder(orderID uint) {
+	var order types.Order
+	if err := db.DB.First(&order, orderID).Error; err != nil {
+		fmt.Printf("Stop order %d nije pronađen: %v\n", orderID, err)
+		return
+	}
+	if !isPendingTrigger(order) {
+		return
+	}
+
+	// STOP i TRAILING-STOP postaju MARKET, STOP-LIMIT postaje LIMIT
+	activatedType := "MARKET"
+	if strings.ToUpper(order.OrderType) == "STOP-LIMIT" {
+		activatedType = "LIMIT"
+	}
+
+	now := clock.Now()
+	activated := false
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&types.Order{}).
+			Where("id = ? AND status = ? AND NOT is_done AND activated_at IS NULL", order.ID, "approved").
+			Updates(map[string]any{
+				"order_type":    activatedType,
+				"activated_at":  now,
+				"priority_time": now.UnixNano(),
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			// Order je u međuvremenu otkazan, istekao ili već aktiviran
+			return result.Error
+		}
+		if err := tx.First(&order, order.ID).Error; err != nil {
+			return err
+		}
+		activated = true
+		return holdShares(tx, order, order.Status)
+	})
+	if err != nil {
+		fmt.Printf("Greska pri aktivaciji stop ordera %d: %v\n", order.ID, err)
+		return
+	}
+	if !activated {
+		return
+	}
+	fmt.Printf("Stop order %d aktiviran kao %s\n", order.ID, activatedType)
+	RecordStatus(order, EventActivated, nil, "stop cena dostignuta, order je sada "+activatedType)
+
+	AddToBook(order)
+	MatchOrder(order)
+}
+
+// stopReached proverava uslov aktivacije: kupovina kada Ask dostigne stop,
+// prodaja kada Bid padne do stopa.
+func stopReached(direction string, stop, ask, bid float64) bool {
+	if strings.ToLower(direction) == "buy" {
+		return ask > 0 && ask >= stop
+	}
+	return bid > 0 && bid <= stop
+}
+
+func (s *triggerSet) add(orderID uint, direction string, stop float64) {
+	t := trigger{OrderID: orderID, Stop: stop}
+	if strings.ToLower(direction) == "buy" {
+		pos := sort.Search(len(s.buys), func(i int) bool { return s.buys[i].Stop > stop })
+		s.buys = append(s.buys, trigger{})
+		copy(s.buys[pos+1:], s.buys[pos:])
+		s.buys[pos] = t
+		return
+	}
+	pos := sort.Search(len(s.sells), func(i int) bool { return s.sells[i].Stop < stop })
+	s.sells = append(s.sells, trigger{})
+	copy(s.sells[pos+1:], s.sells[pos:])
+	s.sells[pos] = t
+}
+
+func (s *triggerSet) addTrailing(t *trailingTrigger) {
+	s.trailing = append(s.trailing, t)
+}
+
+func (s *triggerSet) remove(orderID uint) {
+	for i, t := range s.trailing {
+		if t.OrderID == orderID {
+			s.trailing = append(s.trailing[:i], s.trailing[i+1:]...)
+			return
+		}
+	}
+	for i, t := range s.buys {
+		if t.OrderID == orderID {
+			s.buys = append(s.buys[:i], s.buys[i+1:]...)
+			return
+		}
+	}
+	for i, t := range s.sells {
+		if t.OrderID == orderID {
+			s.sells = append(s.sells[:i], s.sells[i+1:]...)
+			return
+		}
+	}
+}
+
+// pop uklanja i vraća sve okidače koje aktiviraju date ask i bid cene.
+func (s *triggerSet) pop(ask, bid float64) []trigger {
+	var fired []trigger
+
+	n := 0
+	for n < len(s.buys) && stopReached("buy", s.buys[n].Stop, ask, bid) {
+		n++
+	}
+	fired = append(fired, s.buys[:n]...)
+	s.buys = s.buys[n:]
+
+	n = 0
+	for n < len(s.sells) && stopReached("sell", s.sells[n].Stop, ask, bid) {
+		n++
+	}
+	fired = append(fired, s.sells[:n]...)
+	s.sells = s.sells[n:]
+
+	remaining := s.trailing[:0]
+	for _, t := range s.trailing {
+		if stopReached(t.Direction, t.Stop, ask, bid) {
+			fired = append(fired, trigger{OrderID: t.OrderID, Stop: t.Stop})
+			continue
+		}
+		remaining = append(remaining, t)
+	}
+	s.trailing = remaining
+
+	return fired
+}
+
+// follow pomera trailing okidače za koje je nova cena bolja od dosadašnje
+// reference i vraća njihove kopije da bi se novi nivoi upisali u bazu.
+func (s *triggerSet) follow(ask, bid float64) []trailingTrigger {
+	var moved []trailingTrigger
+	for _, t := range s.trailing {
+		price := trailPrice(t.Direction, ask, bid)
+		if price <= 0 || !improves(t.Direction, t.Reference, price) {
+			continue
+		}
+		t.Reference = price
+		t.Stop = trailStop(t.Direction, price, t.Amount, t.Percent)
+		moved = append(moved, *t)
+	}
+	return moved
+}
+
+// HasTrailingReference proverava da li postoji cena od koje trailing stop može da
+// počne praćenje: Ask ili Bid iz listinga, ili poslednja cena hartije.
+func HasTrailingReference(security types.Security, direction string) bool {
+	if security.LastPrice > 0 {
+		return true
+	}
+	var listing types.Listing
+	if err := db.DB.Where("ticker = ?", security.Ticker).First(&listing).Error; err != nil {
+		return false
+	}
+	return trailPrice(direction, float64(listing.Ask), float64(listing.Bid)) > 0
+}
+
+func isTrailing(order types.Order) bool {
+	return strings.ToUpper(order.OrderType) == "TRAILING-STOP"
+}
+
+func newTrailingTrigger(order types.Order, reference float64) *trailingTrigger {
+	return &trailingTrigger{
+		OrderID:   order.ID,
+		Direction: strings.ToLower(order.Direction),
+		Amount:    order.TrailAmount,
+		Percent:   order.TrailPercent,
+		Reference: reference,
+		Stop:      trailStop(order.Direction, reference, order.TrailAmount, order.TrailPercent),
+	}
+}
+
+// trailPrice je cena koju trailing stop prati: Bid za prodaju, Ask za kupovinu.
+func trailPrice(direction string, ask, bid float64) float64 {
+	if strings.ToLower(direction) == "buy" {
+		return ask
+	}
+	return bid
+}
+
+// improves proverava da li je cena bolja od reference: viša za prodaju, niža za kupovinu.
+func improves(direction string, reference, price float64) bool {
+	if strings.ToLower(direction) == "buy" {
+		return price < reference
+	}
+	return price > reference
+}
+
+// trailStop računa nivo aktivacije na razmaku od reference, ispod nje za
+// prodaju i iznad nje za kupovinu.
+func trailStop(direction string, reference float64, amount, percent *float64) float64 {
+	offset := 0.0
+	if amount != nil {
+		offset = *amount
+	} else if percent != nil {
+		offset = reference * *percent / 100
+	}
+	if strings.ToLower(direction) == "buy" {
+		return reference + offset
+	}
+	return reference - offset
+}
+
+func saveTrailing(t trailingTrigger) {
+	if err := db.DB.Model(&types.Order{}).Where("id = ?", t.OrderID).Updates(map[string]any{
+		"trail_reference":     t.Reference,
+		"trailing_stop_price": t.Stop,
+	}).Error; err != nil {
+		fmt.Printf("Greska pri upisu trailing stop nivoa za order %d: %v\n", t.OrderID, err)
+	}
+}
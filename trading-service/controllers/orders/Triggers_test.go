@@ -0,0 +1,120 @@
+package orders
+
+import (
+	"testing"
+	"time"
+
+	"banka1.com/db"
+	"banka1.com/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func triggerIDs(fired []trigger) []uint {
+	ids := make([]uint, len(fired))
+	for i, t := range fired {
+		ids[i] = t.OrderID
+	}
+	return ids
+}
+
+func TestTriggerSet_PopsReachedBuyStops(t *testing.T) {
+	set := &triggerSet{}
+	set.add(1, "buy", 105)
+	set.add(2, "buy", 101)
+	set.add(3, "buy", 110)
+
+	assert.Empty(t, set.pop(100, 99))
+	assert.Equal(t, []uint{2, 1}, triggerIDs(set.pop(106, 105)))
+	assert.Equal(t, []uint{3}, triggerIDs(set.buys))
+}
+
+func TestTriggerSet_PopsReachedSellStops(t *testing.T) {
+	set := &triggerSet{}
+	set.add(1, "sell", 95)
+	set.add(2, "sell", 99)
+	set.add(3, "sell", 90)
+
+	assert.Equal(t, []uint{2, 1}, triggerIDs(set.pop(96, 94)))
+	assert.Equal(t, []uint{3}, triggerIDs(set.sells))
+}
+
+func TestTriggerSet_Remove(t *testing.T) {
+	set := &triggerSet{}
+	set.add(1, "sell", 95)
+	set.add(2, "buy", 105)
+	set.remove(1)
+	set.remove(2)
+
+	assert.Empty(t, set.pop(1000, 1))
+}
+
+func TestIsPendingTrigger(t *testing.T) {
+	now := time.Now()
+	assert.True(t, isPendingTrigger(types.Order{OrderType: "STOP"}))
+	assert.True(t, isPendingTrigger(types.Order{OrderType: "STOP-LIMIT"}))
+	assert.False(t, isPendingTrigger(types.Order{OrderType: "STOP", ActivatedAt: &now}))
+	assert.False(t, isPendingTrigger(types.Order{OrderType: "LIMIT"}))
+}
+
+func TestOrderBook_SkipsPendingStopOrders(t *testing.T) {
+	book := newOrderBook(1)
+	book.upsert(restingOrder(1, 10, "sell", "STOP", nil, 5, 1))
+
+	assert.Equal(t, 0, book.Depth("sell"))
+}
+
+func TestTrailStop(t *testing.T) {
+	assert.Equal(t, 95.0, trailStop("sell", 100, fptr(5), nil))
+	assert.Equal(t, 110.0, trailStop("buy", 100, nil, fptr(10)))
+}
+
+func TestTriggerSet_TrailingSellFollowsBid(t *testing.T) {
+	set := &triggerSet{}
+	order := types.Order{ID: 7, Direction: "sell", OrderType: "TRAILING-STOP", TrailAmount: fptr(5)}
+	set.addTrailing(newTrailingTrigger(order, 100))
+
+	moved := set.follow(121, 120)
+	assert.Len(t, moved, 1)
+	assert.Equal(t, 115.0, moved[0].Stop)
+
+	// Pad cene ne spušta stop
+	assert.Empty(t, set.follow(118, 117))
+	assert.Empty(t, set.pop(118, 117))
+
+	assert.Equal(t, []uint{7}, triggerIDs(set.pop(115, 114)))
+	assert.Empty(t, set.trailing)
+}
+
+func TestTriggerSet_TrailingBuyFollowsAsk(t *testing.T) {
+	set := &triggerSet{}
+	order := types.Order{ID: 8, Direction: "buy", OrderType: "TRAILING-STOP", TrailPercent: fptr(10)}
+	set.addTrailing(newTrailingTrigger(order, 100))
+
+	moved := set.follow(80, 79)
+	assert.Len(t, moved, 1)
+	assert.Equal(t, 88.0, moved[0].Stop)
+	assert.Equal(t, []uint{8}, triggerIDs(set.pop(88, 87)))
+}
+
+func TestAddTrigger_TrailingFallsBackToLastPrice(t *testing.T) {
+	assert.NoError(t, db.InitTestDatabase())
+	security := types.Security{Ticker: "TRLX", Name: "Trailing test", Type: "Stock", LastPrice: 50}
+	assert.NoError(t, db.DB.Create(&security).Error)
+	order := types.Order{UserID: 1, AccountID: 7, SecurityID: security.ID, Direction: "sell", OrderType: "TRAILING-STOP", TrailAmount: fptr(5), Quantity: 1, RemainingParts: ptr(1), Status: "approved"}
+	assert.NoError(t, db.DB.Create(&order).Error)
+	t.Cleanup(func() {
+		RemoveTrigger(order)
+		db.DB.Delete(&order)
+		db.DB.Delete(&security)
+	})
+
+	// Listing ne postoji, pa praćenje počinje od poslednje cene hartije
+	assert.True(t, HasTrailingReference(security, "sell"))
+	AddTrigger(order)
+	assert.NoError(t, db.DB.First(&order, order.ID).Error)
+	assert.Equal(t, 50.0, *order.TrailReference)
+	assert.Equal(t, 45.0, *order.TrailingStopPrice)
+
+	security.LastPrice = 0
+	assert.False(t, HasTrailingReference(security, "sell"))
+}
@@ -13,6 +13,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -23,6 +24,7 @@ func registerTestRoutes(app *fiber.App) {
 	controller := NewOrderController()
 
 	app.Get("/orders/:id", controller.GetOrderByID)
+	app.Get("/orders/:id/events", controller.GetOrderEvents)
 	app.Get("/orders", controller.GetOrders)
 
 	// OVDE izbacujemo middlewares.Auth
@@ -30,7 +32,12 @@ func registerTestRoutes(app *fiber.App) {
 	app.Post("/orders/:id/decline", controller.DeclineOrder)
 	app.Post("/orders/:id/approve", controller.ApproveOrder)
 	app.Post("/orders/:id/cancel", controller.CancelOrder)
+	app.Put("/orders/:id", controller.AmendOrder)
 	app.Get("/profit/:id", controller.GetRealizedProfit)
+
+	groupController := NewOrderGroupController()
+	app.Post("/orders/groups", groupController.CreateOrderGroup)
+	app.Get("/orders/groups/:id", groupController.GetOrderGroup)
 }
 
 func TestMain(m *testing.M) {
@@ -126,6 +133,28 @@ func TestCreateOrder_Success(t *testing.T) {
 	assert.Equal(t, 200, resp.StatusCode)
 }
 
+func TestCreateOrder_ContractSizeMismatch(t *testing.T) {
+	_ = db.DB.Create(&types.Security{ID: 41, Ticker: "CLF6", Type: "Future", Volume: 100, LastPrice: 70.0, Name: "Crude Oil", ContractSize: 1000}).Error
+
+	body := map[string]any{
+		"user_id":       1,
+		"account_id":    1,
+		"security_id":   41,
+		"quantity":      2,
+		"contract_size": 1,
+		"direction":     "buy",
+	}
+	payload, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(payload))
+	req.Header.Set("Authorization", "Bearer mock-token")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-UserID", "1")
+
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 400, resp.StatusCode)
+}
+
 func TestApproveOrderAndMatch_Success(t *testing.T) {
 	order := createTestOrder(t, false)
 
@@ -154,7 +183,8 @@ func TestCancelOrder_Success(t *testing.T) {
 func TestDeclineOrder_Success(t *testing.T) {
 	order := createTestOrder(t, false)
 
-	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/orders/%d/decline", order.ID), nil)
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/orders/%d/decline", order.ID), strings.NewReader(`{"reason":"Prevelik rizik"}`))
+	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer mock-token")
 	req.Header.Set("X-Test-UserID", "1")
 	req.Header.Set("X-Test-Department", "SUPERVISOR")
@@ -162,6 +192,33 @@ func TestDeclineOrder_Success(t *testing.T) {
 	resp, err := app.Test(req)
 	assert.NoError(t, err)
 	assert.Equal(t, 200, resp.StatusCode)
+
+	var declined types.Order
+	assert.NoError(t, db.DB.First(&declined, order.ID).Error)
+	assert.Equal(t, "declined", declined.Status)
+	if assert.NotNil(t, declined.DeclineReason) {
+		assert.Equal(t, "Prevelik rizik", *declined.DeclineReason)
+	}
+}
+
+func TestDeclineOrder_RequiresReason(t *testing.T) {
+	order := createTestOrder(t, false)
+
+	for _, body := range []string{"", `{"reason":"   "}`} {
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/orders/%d/decline", order.ID), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer mock-token")
+		req.Header.Set("X-Test-UserID", "1")
+		req.Header.Set("X-Test-Department", "SUPERVISOR")
+
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, 400, resp.StatusCode)
+	}
+
+	var pending types.Order
+	assert.NoError(t, db.DB.First(&pending, order.ID).Error)
+	assert.Equal(t, "pending", pending.Status)
 }
 
 func TestGetOrderByID_Success(t *testing.T) {
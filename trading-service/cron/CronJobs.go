@@ -1,8 +1,10 @@
 package cron
 
 import (
+	"banka1.com/clock"
 	"banka1.com/controllers/orders"
 	"banka1.com/exchanges"
+	"banka1.com/holds"
 	"banka1.com/listings/forex"
 	"banka1.com/listings/futures"
 	"banka1.com/listings/option"
@@ -68,6 +70,11 @@ func StartScheduler() {
 		SnapshotListingsToHistory()
 	})
 
+	// Isti skup poslova pokreće i simulacija posle pomeranja virtuelnog vremena
+	_, err = c.AddFunc("0 * * * * *", func() {
+		orders.RunTimedJobs()
+	})
+
 	if err != nil {
 		log.Errorf("Greska pri pokretanju cron job-a:", err)
 		return
@@ -147,17 +154,31 @@ func LoadData() {
 
 func SnapshotListingsToHistory() error {
 	var listings []types.Listing
-	if err := db.DB.Find(&listings).Error; err != nil {
+	if err := db.DB.Preload("Exchange").Find(&listings).Error; err != nil {
 		return err
 	}
 
-	today := time.Now().Truncate(24 * time.Hour)
+	now := clock.Now()
 
 	for _, l := range listings {
+		// Snapshot pripada sesiji koja se poslednja zatvorila, u zoni berze; za
+		// listing bez berze (ili sa neispravnim radnim vremenom) to je prethodni UTC dan
+		session := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+		if l.Exchange.ID != 0 {
+			if day, err := exchanges.LastSessionDay(l.Exchange, now); err == nil {
+				session = day
+			}
+			// berza tog dana nije radila (vikend ili praznik) → nema novog dnevnog zapisa
+			if !exchanges.IsTradingDay(l.Exchange, session) {
+				continue
+			}
+		}
+		snapshotDate := time.Date(session.Year(), session.Month(), session.Day(), 0, 0, 0, 0, time.UTC)
+
 		// proveri da li već postoji
 		var existing types.ListingHistory
 		err := db.DB.
-			Where("ticker = ? AND snapshot_date = ?", l.Ticker, today).
+			Where("ticker = ? AND snapshot_date = ?", l.Ticker, snapshotDate).
 			First(&existing).Error
 
 		if err == nil {
@@ -168,18 +189,30 @@ func SnapshotListingsToHistory() error {
 			return err // neki drugi error
 		}
 
+		// na berzama u aukcijskom režimu zvanična cena zatvaranja je cena aukcije zatvaranja
+		price := l.Price
+		var security types.Security
+		if err := db.DB.Select("id").Where("ticker = ?", l.Ticker).First(&security).Error; err == nil {
+			if closePrice, ok := orders.OfficialClose(security.ID, session); ok {
+				price = float32(closePrice)
+				if err := db.DB.Model(&security).Update("previous_close", closePrice).Error; err != nil {
+					return err
+				}
+			}
+		}
+
 		history := types.ListingHistory{
 			Ticker:       l.Ticker,
 			Name:         l.Name,
 			ExchangeID:   l.ExchangeID,
 			LastRefresh:  l.LastRefresh,
-			Price:        l.Price,
+			Price:        price,
 			Ask:          l.Ask,
 			Bid:          l.Bid,
 			Type:         l.Type,
 			Subtype:      l.Subtype,
 			ContractSize: l.ContractSize,
-			SnapshotDate: today,
+			SnapshotDate: snapshotDate,
 		}
 		if err := db.DB.Create(&history).Error; err != nil {
 			return err
@@ -190,7 +223,7 @@ func SnapshotListingsToHistory() error {
 }
 
 func expireOldOptionContracts() {
-	now := time.Now()
+	now := clock.Now()
 
 	var contracts []types.OptionContract
 	if err := db.DB.Where("settlement_at < ? AND status = ?", now, "active").Find(&contracts).Error; err != nil {
@@ -204,6 +237,9 @@ func expireOldOptionContracts() {
 			log.Errorf("Greška pri expirovanju ugovora ID %d: %v", contract.ID, err)
 		} else {
 			log.Infof("Ugovor ID %d označen kao 'expired'", contract.ID)
+			if err := holds.Release(nil, holds.ReasonOTCContract, holds.Ref(contract.ID)); err != nil {
+				log.Errorf("Greška pri oslobađanju hartija ugovora ID %d: %v", contract.ID, err)
+			}
 		}
 	}
 }
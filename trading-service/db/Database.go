@@ -96,6 +96,22 @@ func migrate(db *gorm.DB) {
 		&types.Transaction{},
 		&types.OTCSagaState{},
 		&types.InterbankTxnRecord{},
+		&types.OrderCompensation{},
+		&types.OrderGroup{},
+		&types.ExchangeHoliday{},
+		&types.ExchangeHolidaySeed{},
+		&types.BankInventoryLimit{},
+		&types.OrderEvent{},
+		&types.FeeSchedule{},
+		&types.FeeScheduleTier{},
+		&types.Hold{},
+		&types.MarginAccount{},
+		&types.MarginCall{},
+		&types.SecurityBorrow{},
+		&types.PriceBand{},
+		&types.TradingHalt{},
+		&types.Auction{},
+		&types.ApprovalRule{},
 	)
 }
 
@@ -105,5 +121,5 @@ func InitTestDatabase() error {
 	if err != nil {
 		return err
 	}
-	return DB.AutoMigrate(&types.Security{}, &types.Order{}, &types.Actuary{}, &types.Transaction{}, &types.Portfolio{}, &types.OTCTrade{}, &types.OptionContract{}, &types.Listing{}, &types.OTCSagaState{})
+	return DB.AutoMigrate(&types.Security{}, &types.Order{}, &types.Actuary{}, &types.Transaction{}, &types.Portfolio{}, &types.OTCTrade{}, &types.OptionContract{}, &types.Listing{}, &types.OTCSagaState{}, &types.OrderCompensation{}, &types.OrderGroup{}, &types.Exchange{}, &types.ExchangeHoliday{}, &types.ExchangeHolidaySeed{}, &types.BankInventoryLimit{}, &types.OrderEvent{}, &types.FeeSchedule{}, &types.FeeScheduleTier{}, &types.Hold{}, &types.MarginAccount{}, &types.MarginCall{}, &types.SecurityBorrow{}, &types.PriceBand{}, &types.TradingHalt{}, &types.Auction{}, &types.ApprovalRule{})
 }
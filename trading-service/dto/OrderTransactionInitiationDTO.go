@@ -8,3 +8,14 @@ type OrderTransactionInitiationDTO struct {
 	Fee             float64 `json:"fee"`
 	Direction       string  `json:"direction"`
 }
+
+// OrderTransactionCompensationDTO poništava ranije inicirano plaćanje za order
+// čije izvršenje je vraćeno (rollback).
+type OrderTransactionCompensationDTO struct {
+	Uid             string  `json:"uid"`
+	SellerAccountId uint    `json:"sellerAccountId"`
+	BuyerAccountId  uint    `json:"buyerAccountId"`
+	Amount          float64 `json:"amount"`
+	Fee             float64 `json:"fee"`
+	Reason          string  `json:"reason"`
+}
@@ -0,0 +1,53 @@
+package exchanges
+
+import (
+	"time"
+
+	"banka1.com/types"
+)
+
+const (
+	AuctionOpening = "open"
+	AuctionClosing = "close"
+
+	// Pre zatvaranja berze u aukcijskom režimu orderi se samo prikupljaju za aukciju zatvaranja
+	ClosingCallWindow = 10 * time.Minute
+	// Posle granice aukcija se još može izvršiti ako je cron zakasnio; do tada nema kontinualnog trgovanja
+	AuctionGrace = 15 * time.Minute
+)
+
+// AuctionWindow vraća fazu aukcije u kojoj je berza u trenutku now (AuctionOpening
+// tokom pre-market perioda, AuctionClosing pred zatvaranje), datum sesije u zoni
+// berze (YYYY-MM-DD) i trenutak izvršenja aukcije. Prazna faza znači da berza nije u
+// aukcijskom režimu ili da je u toku kontinualno trgovanje.
+func AuctionWindow(exchange types.Exchange, now time.Time) (string, string, time.Time, error) {
+	if !exchange.AuctionMode {
+		return "", "", time.Time{}, nil
+	}
+	loc, err := Location(exchange)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+
+	local := now.In(loc)
+	if !IsTradingDay(exchange, local) {
+		return "", "", time.Time{}, nil
+	}
+	openAt, err := atClock(exchange.OpenTime, local)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	closeAt, err := atClock(exchange.CloseTime, local)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+
+	session := local.Format("2006-01-02")
+	switch {
+	case !local.Before(openAt.Add(-PreMarketWindow)) && local.Before(openAt.Add(AuctionGrace)):
+		return AuctionOpening, session, openAt, nil
+	case !local.Before(closeAt.Add(-ClosingCallWindow)) && local.Before(closeAt.Add(AuctionGrace)):
+		return AuctionClosing, session, closeAt, nil
+	}
+	return "", "", time.Time{}, nil
+}
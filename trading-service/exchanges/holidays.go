@@ -0,0 +1,155 @@
+package exchanges
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"banka1.com/db"
+	"banka1.com/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const holidayDateLayout = "2006-01-02"
+
+// calendar čuva neradne dane po MIC kodu berze, kako provera radnog vremena
+// ne bi išla u bazu pri svakom pokušaju izvršenja ordera.
+var calendar = struct {
+	sync.RWMutex
+	days map[string]map[string]string
+}{days: make(map[string]map[string]string)}
+
+func setHolidays(list []types.ExchangeHoliday) {
+	days := make(map[string]map[string]string)
+	for _, holiday := range list {
+		mic := strings.TrimSpace(holiday.MicCode)
+		if days[mic] == nil {
+			days[mic] = make(map[string]string)
+		}
+		days[mic][holiday.Date] = holiday.Name
+	}
+
+	calendar.Lock()
+	calendar.days = days
+	calendar.Unlock()
+}
+
+// ReloadHolidays ponovo učitava kalendar neradnih dana iz baze.
+func ReloadHolidays() error {
+	var list []types.ExchangeHoliday
+	if err := db.DB.Find(&list).Error; err != nil {
+		return fmt.Errorf("greška pri učitavanju neradnih dana: %w", err)
+	}
+	setHolidays(list)
+	return nil
+}
+
+// HolidayName vraća naziv praznika ako je dan (u zoni berze) neradni dan berze sa datim MIC kodom.
+func HolidayName(micCode string, day time.Time) (string, bool) {
+	calendar.RLock()
+	defer calendar.RUnlock()
+	name, ok := calendar.days[strings.TrimSpace(micCode)][day.Format(holidayDateLayout)]
+	return name, ok
+}
+
+func IsHoliday(micCode string, day time.Time) bool {
+	_, ok := HolidayName(micCode, day)
+	return ok
+}
+
+// IsTradingDay proverava da li se na berzi trguje na dan trenutka now (u zoni berze).
+func IsTradingDay(exchange types.Exchange, now time.Time) bool {
+	if loc, err := Location(exchange); err == nil {
+		now = now.In(loc)
+	}
+	return !isWeekend(now) && !IsHoliday(exchange.MicCode, now)
+}
+
+// LastTradingDayOfMonth vraća poslednji radni dan berze u mesecu.
+func LastTradingDayOfMonth(exchange types.Exchange, year int, month time.Month) time.Time {
+	day := time.Date(year, month+1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+	for isWeekend(day) || IsHoliday(exchange.MicCode, day) {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+// LoadHolidays upisuje neradne dane iz CSV fajla (Exchange Mic Code, Date, Name)
+// u bazu i osvežava kalendar. Svaki dan iz fajla se upisuje samo prvi put: dan koji
+// je već bio upisan (pa ga je supervizor u međuvremenu obrisao ili preimenovao) i
+// dan koji je supervizor već dodao ostaju kakvi jesu.
+func LoadHolidays(csvPath string) error {
+	file, err := os.Open(csvPath)
+	if err != nil {
+		return fmt.Errorf("failed to open CSV file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	headers, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	expectedHeaders := []string{"Exchange Mic Code", "Date", "Name"}
+	for i, header := range expectedHeaders {
+		if i >= len(headers) || strings.TrimSpace(headers[i]) != header {
+			return fmt.Errorf("CSV header mismatch: expected %s at position %d", header, i)
+		}
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return fmt.Errorf("failed to read CSV data: %w", err)
+	}
+
+	for _, record := range records {
+		if len(record) < 3 {
+			return fmt.Errorf("invalid record format, expected 3 fields, got %d", len(record))
+		}
+		holiday := types.ExchangeHoliday{
+			MicCode: strings.TrimSpace(record[0]),
+			Date:    strings.TrimSpace(record[1]),
+			Name:    strings.TrimSpace(record[2]),
+		}
+		if _, err := time.Parse(holidayDateLayout, holiday.Date); err != nil {
+			return fmt.Errorf("nevalidan datum %q za berzu %s: %w", holiday.Date, holiday.MicCode, err)
+		}
+
+		err := db.DB.Transaction(func(tx *gorm.DB) error {
+			seed := tx.Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&types.ExchangeHolidaySeed{MicCode: holiday.MicCode, Date: holiday.Date})
+			if seed.Error != nil || seed.RowsAffected == 0 {
+				return seed.Error
+			}
+			return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&holiday).Error
+		})
+		if err != nil {
+			return fmt.Errorf("failed to save holiday %s %s: %w", holiday.MicCode, holiday.Date, err)
+		}
+	}
+
+	return ReloadHolidays()
+}
+
+// LoadDefaultHolidays učitava ugrađeni kalendar iz exchanges/holidays.csv. Poziva se
+// jednom pri pokretanju servisa. Fajl pokriva samo XNAS i XCME za 2025. i 2026.
+// godinu; ostale berze i godine supervizor dodaje preko /exchanges/:id/holidays.
+func LoadDefaultHolidays() error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+
+	csvPath := filepath.Join(dir, "exchanges/holidays.csv")
+	if _, err := os.Stat(csvPath); err != nil {
+		return fmt.Errorf("holidays.csv file not found: %w", err)
+	}
+
+	return LoadHolidays(csvPath)
+}
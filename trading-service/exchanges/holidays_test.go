@@ -0,0 +1,82 @@
+package exchanges
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"banka1.com/db"
+	"banka1.com/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func withHolidays(t *testing.T, list ...types.ExchangeHoliday) {
+	setHolidays(list)
+	t.Cleanup(func() { setHolidays(nil) })
+}
+
+func TestMarketStatus_HolidayIsClosed(t *testing.T) {
+	withHolidays(t, types.ExchangeHoliday{MicCode: "XNAS", Date: "2025-07-04", Name: "Independence Day"})
+	loc, _ := time.LoadLocation("America/New_York")
+
+	status, err := MarketStatus(nasdaq, time.Date(2025, 7, 4, 11, 0, 0, 0, loc))
+	assert.NoError(t, err)
+	assert.Equal(t, StatusClosed, status)
+
+	// Praznik jedne berze ne utiče na druge
+	other := nasdaq
+	other.MicCode = "XCME"
+	status, err = MarketStatus(other, time.Date(2025, 7, 4, 11, 0, 0, 0, loc))
+	assert.NoError(t, err)
+	assert.Equal(t, StatusOpen, status)
+}
+
+func TestNextClose_SkipsHoliday(t *testing.T) {
+	withHolidays(t, types.ExchangeHoliday{MicCode: "XNAS", Date: "2025-04-18", Name: "Good Friday"})
+	loc, _ := time.LoadLocation("America/New_York")
+	now := time.Date(2025, 4, 17, 17, 0, 0, 0, loc) // četvrtak posle zatvaranja
+
+	closeAt, err := NextClose(nasdaq, now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 4, 21, 16, 0, 0, 0, loc), closeAt)
+}
+
+func TestLastTradingDayOfMonth(t *testing.T) {
+	// 31.10.2025. je petak
+	assert.Equal(t, time.Date(2025, 10, 31, 0, 0, 0, 0, time.UTC), LastTradingDayOfMonth(nasdaq, 2025, time.October))
+
+	// 31.5.2025. je subota, a 30.5. proglašen neradnim
+	withHolidays(t, types.ExchangeHoliday{MicCode: "XNAS", Date: "2025-05-30", Name: "Test"})
+	assert.Equal(t, time.Date(2025, 5, 29, 0, 0, 0, 0, time.UTC), LastTradingDayOfMonth(nasdaq, 2025, time.May))
+}
+
+func TestLoadHolidays_SeedsOnlyNewDays(t *testing.T) {
+	assert.NoError(t, db.InitTestDatabase())
+	t.Cleanup(func() {
+		db.DB.Where("mic_code = ?", "XTST").Delete(&types.ExchangeHoliday{})
+		db.DB.Where("mic_code = ?", "XTST").Delete(&types.ExchangeHolidaySeed{})
+		setHolidays(nil)
+	})
+
+	csvPath := filepath.Join(t.TempDir(), "holidays.csv")
+	write := func(rows string) {
+		assert.NoError(t, os.WriteFile(csvPath, []byte("Exchange Mic Code,Date,Name\n"+rows), 0o644))
+	}
+	write("XTST,2025-01-01,New Year's Day\nXTST,2025-12-25,Christmas Day\n")
+	assert.NoError(t, LoadHolidays(csvPath))
+
+	// Supervizor briše jedan dan i preimenuje drugi; ponovno učitavanje ih ne vraća
+	assert.NoError(t, db.DB.Where("mic_code = ? AND date = ?", "XTST", "2025-01-01").Delete(&types.ExchangeHoliday{}).Error)
+	assert.NoError(t, db.DB.Model(&types.ExchangeHoliday{}).Where("mic_code = ? AND date = ?", "XTST", "2025-12-25").Update("name", "Božić").Error)
+
+	write("XTST,2025-01-01,New Year's Day\nXTST,2025-12-25,Christmas Day\nXTST,2026-01-01,New Year's Day\n")
+	assert.NoError(t, LoadHolidays(csvPath))
+
+	var list []types.ExchangeHoliday
+	assert.NoError(t, db.DB.Where("mic_code = ?", "XTST").Order("date").Find(&list).Error)
+	assert.Len(t, list, 2)
+	assert.Equal(t, "2025-12-25", list[0].Date)
+	assert.Equal(t, "Božić", list[0].Name)
+	assert.Equal(t, "2026-01-01", list[1].Date)
+}
@@ -0,0 +1,161 @@
+package exchanges
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	// Alpine image nema zoneinfo, pa baza vremenskih zona mora biti ugrađena u binarni fajl
+	_ "time/tzdata"
+
+	"banka1.com/db"
+	"banka1.com/types"
+)
+
+// ForSecurity vraća berzu na kojoj se trguje hartijom (preko listinga sa istim tickerom).
+func ForSecurity(securityID uint) (*types.Exchange, error) {
+	var security types.Security
+	if err := db.DB.First(&security, securityID).Error; err != nil {
+		return nil, fmt.Errorf("hartija %d nije pronađena: %w", securityID, err)
+	}
+
+	var listing types.Listing
+	if err := db.DB.Preload("Exchange").Where("ticker = ?", security.Ticker).First(&listing).Error; err != nil {
+		return nil, fmt.Errorf("listing za %s nije pronađen: %w", security.Ticker, err)
+	}
+	if listing.Exchange.ID == 0 {
+		return nil, fmt.Errorf("listing %s nema berzu", security.Ticker)
+	}
+	return &listing.Exchange, nil
+}
+
+// Location vraća vremensku zonu berze.
+func Location(exchange types.Exchange) (*time.Location, error) {
+	loc, err := time.LoadLocation(strings.TrimSpace(exchange.Timezone))
+	if err != nil {
+		return nil, fmt.Errorf("nepoznata vremenska zona %q za berzu %s: %w", exchange.Timezone, exchange.MicCode, err)
+	}
+	return loc, nil
+}
+
+// atClock vraća trenutak dana day (u zoni berze) za vreme zapisano kao "HH:MM".
+func atClock(value string, day time.Time) (time.Time, error) {
+	parsed, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("nevalidno vreme %q: %w", value, err)
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), parsed.Hour(), parsed.Minute(), 0, 0, day.Location()), nil
+}
+
+func isWeekend(day time.Time) bool {
+	return day.Weekday() == time.Saturday || day.Weekday() == time.Sunday
+}
+
+// NextClose vraća prvo zatvaranje berze posle trenutka now, preskačući vikende i praznike.
+func NextClose(exchange types.Exchange, now time.Time) (time.Time, error) {
+	loc, err := Location(exchange)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	day := now.In(loc)
+	for i := 0; i < 14; i++ {
+		if IsTradingDay(exchange, day) {
+			closeAt, err := atClock(exchange.CloseTime, day)
+			if err != nil {
+				return time.Time{}, err
+			}
+			if closeAt.After(now) {
+				return closeAt, nil
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return time.Time{}, fmt.Errorf("nije pronađeno zatvaranje berze %s", exchange.MicCode)
+}
+
+// LastSessionDay vraća početak (ponoć u zoni berze) poslednjeg dana čije je
+// zatvaranje prošlo do trenutka now: današnjeg ako je berza danas već zatvorena,
+// inače jučerašnjeg. Da li je tog dana bilo trgovanja proverava IsTradingDay.
+func LastSessionDay(exchange types.Exchange, now time.Time) (time.Time, error) {
+	loc, err := Location(exchange)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	local := now.In(loc)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	closeAt, err := atClock(exchange.CloseTime, day)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if local.Before(closeAt) {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day, nil
+}
+
+const (
+	StatusOpen       = "open"
+	StatusClosed     = "closed"
+	StatusPreMarket  = "pre-market"
+	StatusPostMarket = "post-market"
+
+	// Trajanje produžene trgovine pre otvaranja i posle zatvaranja berze
+	PreMarketWindow  = 2 * time.Hour
+	PostMarketWindow = 4 * time.Hour
+)
+
+// MarketStatus vraća stanje berze u trenutku now: open, pre-market, post-market ili closed.
+func MarketStatus(exchange types.Exchange, now time.Time) (string, error) {
+	loc, err := Location(exchange)
+	if err != nil {
+		return "", err
+	}
+
+	local := now.In(loc)
+	if !IsTradingDay(exchange, local) {
+		return StatusClosed, nil
+	}
+
+	openAt, err := atClock(exchange.OpenTime, local)
+	if err != nil {
+		return "", err
+	}
+	closeAt, err := atClock(exchange.CloseTime, local)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case !local.Before(openAt) && local.Before(closeAt):
+		return StatusOpen, nil
+	case !local.Before(openAt.Add(-PreMarketWindow)) && local.Before(openAt):
+		return StatusPreMarket, nil
+	case !local.Before(closeAt) && local.Before(closeAt.Add(PostMarketWindow)):
+		return StatusPostMarket, nil
+	default:
+		return StatusClosed, nil
+	}
+}
+
+// IsAfterHours proverava da li je stanje berze produžena trgovina (pre-market ili
+// post-market). Zatvorena berza nije after-hours.
+func IsAfterHours(status string) bool {
+	return status == StatusPreMarket || status == StatusPostMarket
+}
+
+// StatusForSecurity vraća stanje berze na kojoj se trguje hartijom. Hartije bez
+// poznate berze (ili sa neispravnim radnim vremenom) se tretiraju kao da je berza otvorena.
+func StatusForSecurity(securityID uint, now time.Time) string {
+	exchange, err := ForSecurity(securityID)
+	if err != nil {
+		return StatusOpen
+	}
+	status, err := MarketStatus(*exchange, now)
+	if err != nil {
+		fmt.Printf("Greska pri odredjivanju radnog vremena berze %s: %v\n", exchange.MicCode, err)
+		return StatusOpen
+	}
+	return status
+}
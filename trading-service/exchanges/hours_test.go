@@ -0,0 +1,80 @@
+package exchanges
+
+import (
+	"testing"
+	"time"
+
+	"banka1.com/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var nasdaq = types.Exchange{MicCode: "XNAS", Timezone: "America/New_York", OpenTime: " 09:30", CloseTime: " 16:00"}
+
+func TestNextClose_SameDay(t *testing.T) {
+	loc, _ := time.LoadLocation("America/New_York")
+	now := time.Date(2025, 4, 9, 11, 0, 0, 0, loc) // sreda
+
+	closeAt, err := NextClose(nasdaq, now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 4, 9, 16, 0, 0, 0, loc), closeAt)
+}
+
+func TestNextClose_AfterCloseSkipsWeekend(t *testing.T) {
+	loc, _ := time.LoadLocation("America/New_York")
+	now := time.Date(2025, 4, 11, 17, 0, 0, 0, loc) // petak posle zatvaranja
+
+	closeAt, err := NextClose(nasdaq, now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 4, 14, 16, 0, 0, 0, loc), closeAt)
+}
+
+func TestLastSessionDay_UsesExchangeTimezone(t *testing.T) {
+	loc, _ := time.LoadLocation("America/New_York")
+	tokyo := types.Exchange{MicCode: "XJPX", Timezone: "Asia/Tokyo", OpenTime: "09:00", CloseTime: "15:00"}
+
+	// Ponoć UTC u subotu: u Njujorku je petak uveče, sesija petka je zatvorena
+	now := time.Date(2025, 4, 12, 0, 0, 0, 0, time.UTC)
+	day, err := LastSessionDay(nasdaq, now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 4, 11, 0, 0, 0, 0, loc), day)
+	assert.True(t, IsTradingDay(nasdaq, day))
+
+	// U Tokiju je subota ujutru, poslednja zatvorena sesija je takođe petak
+	day, err = LastSessionDay(tokyo, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 11, day.Day())
+	assert.True(t, IsTradingDay(tokyo, day))
+
+	// Pre zatvaranja je poslednja sesija jučerašnja
+	day, err = LastSessionDay(nasdaq, time.Date(2025, 4, 9, 11, 0, 0, 0, loc))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 4, 8, 0, 0, 0, 0, loc), day)
+}
+
+func TestNextClose_InvalidTimezone(t *testing.T) {
+	_, err := NextClose(types.Exchange{Timezone: "Nepostojeca/Zona", CloseTime: "16:00"}, time.Now())
+	assert.Error(t, err)
+}
+
+func TestMarketStatus(t *testing.T) {
+	loc, _ := time.LoadLocation("America/New_York")
+	cases := []struct {
+		name string
+		now  time.Time
+		want string
+	}{
+		{"otvorena", time.Date(2025, 4, 9, 11, 0, 0, 0, loc), StatusOpen},
+		{"pre-market", time.Date(2025, 4, 9, 8, 0, 0, 0, loc), StatusPreMarket},
+		{"post-market", time.Date(2025, 4, 9, 17, 30, 0, 0, loc), StatusPostMarket},
+		{"noću zatvorena", time.Date(2025, 4, 9, 22, 0, 0, 0, loc), StatusClosed},
+		{"vikend", time.Date(2025, 4, 12, 11, 0, 0, 0, loc), StatusClosed},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, err := MarketStatus(nasdaq, tc.now)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, status)
+			assert.Equal(t, tc.want == StatusPreMarket || tc.want == StatusPostMarket, IsAfterHours(status))
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package holds
+
+import (
+	"strconv"
+
+	"banka1.com/db"
+	"banka1.com/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	AssetSecurity = "security"
+	AssetCash     = "cash"
+)
+
+const (
+	ReasonOrder       = "order"
+	ReasonOTCContract = "otc_contract"
+	ReasonSaga        = "saga"
+)
+
+const (
+	StatusActive   = "active"
+	StatusConsumed = "consumed"
+	StatusReleased = "released"
+)
+
+// Ref pretvara numerički ID vlasnika (order, ugovor) u referencu holda.
+func Ref(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+// OrderRef vraća referencu holda ordera. Take-profit i stop-loss naloge bracket
+// grupe (uloge TAKE_PROFIT i STOP_LOSS) i naloge OCO grupe (uloga LEG) pokriva
+// jedan zajednički hold grupe, jer se izvršava samo jedan od njih.
+func OrderRef(order types.Order) string {
+	if order.GroupID != nil && order.GroupRole != nil &&
+		(*order.GroupRole == "TAKE_PROFIT" || *order.GroupRole == "STOP_LOSS" || *order.GroupRole == "LEG") {
+		return "group-" + Ref(*order.GroupID)
+	}
+	return Ref(order.ID)
+}
+
+func orDefault(tx *gorm.DB) *gorm.DB {
+	if tx == nil {
+		return db.DB
+	}
+	return tx
+}
+
+// Place upisuje hold ili, ako vlasnik već ima hold za istu vrstu imovine, zamenjuje
+// njegovu količinu i iznos i ponovo ga aktivira.
+func Place(tx *gorm.DB, hold types.Hold) error {
+	hold.Status = StatusActive
+	return orDefault(tx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "reason"}, {Name: "owner_ref"}, {Name: "asset"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "security_id", "account_id", "quantity", "amount", "status", "updated_at"}),
+	}).Create(&hold).Error
+}
+
+// Reduce smanjuje aktivne holdove vlasnika na preostalu količinu; gotovina se smanjuje
+// srazmerno. Kada ništa ne preostane, hold je potrošen.
+func Reduce(tx *gorm.DB, reason, ownerRef string, remaining int) error {
+	tx = orDefault(tx)
+	if remaining <= 0 {
+		return Consume(tx, reason, ownerRef)
+	}
+
+	var active []types.Hold
+	if err := tx.Where("reason = ? AND owner_ref = ? AND status = ?", reason, ownerRef, StatusActive).Find(&active).Error; err != nil {
+		return err
+	}
+	for _, hold := range active {
+		if hold.Quantity <= remaining {
+			continue
+		}
+		amount := hold.Amount * float64(remaining) / float64(hold.Quantity)
+		if err := tx.Model(&hold).Updates(map[string]any{"quantity": remaining, "amount": amount}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func closeHolds(tx *gorm.DB, reason, ownerRef, status string) error {
+	return orDefault(tx).Model(&types.Hold{}).
+		Where("reason = ? AND owner_ref = ? AND status = ?", reason, ownerRef, StatusActive).
+		Updates(map[string]any{"quantity": 0, "amount": 0.0, "status": status}).Error
+}
+
+// Consume zatvara holdove vlasnika čija je imovina iskorišćena (izvršen order, prenete hartije).
+func Consume(tx *gorm.DB, reason, ownerRef string) error {
+	return closeHolds(tx, reason, ownerRef, StatusConsumed)
+}
+
+// Release vraća imovinu iz holdova vlasnika na raspolaganje (otkazan order, istekao ugovor, neuspela saga).
+func Release(tx *gorm.DB, reason, ownerRef string) error {
+	return closeHolds(tx, reason, ownerRef, StatusReleased)
+}
+
+// HeldShares sabira hartije korisnika zadržane iz datog razloga.
+func HeldShares(tx *gorm.DB, userID, securityID uint, reason string) (int, error) {
+	var held int64
+	err := orDefault(tx).Model(&types.Hold{}).
+		Where("user_id = ? AND security_id = ? AND asset = ? AND reason = ? AND status = ?",
+			userID, securityID, AssetSecurity, reason, StatusActive).
+		Select("COALESCE(SUM(quantity), 0)").Scan(&held).Error
+	return int(held), err
+}
+
+// HeldCash sabira gotovinu zadržanu na računu, ne računajući hold vlasnika
+// (reason, ownerRef) koji se upravo proverava. Za sve holdove proslediti prazne stringove.
+func HeldCash(tx *gorm.DB, accountID uint, reason, ownerRef string) (float64, error) {
+	var held float64
+	err := orDefault(tx).Model(&types.Hold{}).
+		Where("account_id = ? AND asset = ? AND status = ?", accountID, AssetCash, StatusActive).
+		Where("NOT (reason = ? AND owner_ref = ?)", reason, ownerRef).
+		Select("COALESCE(SUM(amount), 0)").Scan(&held).Error
+	return held, err
+}
+
+// Backfill upisuje holdove za otvorene sell ordere i aktivne OTC ugovore koji su
+// nastali pre uvođenja knjige rezervacija. Postojeći holdovi se ne menjaju.
+func Backfill(tx *gorm.DB) error {
+	tx = orDefault(tx)
+
+	var sells []types.Order
+	if err := tx.Where("lower(direction) = 'sell' AND status = ? AND NOT COALESCE(is_done, false)", "approved").
+		Find(&sells).Error; err != nil {
+		return err
+	}
+	for _, order := range sells {
+		securityID := order.SecurityID
+		hold := types.Hold{UserID: order.UserID, Asset: AssetSecurity, SecurityID: &securityID, Reason: ReasonOrder, OwnerRef: OrderRef(order), Status: StatusActive}
+		if order.RemainingParts != nil {
+			hold.Quantity = *order.RemainingParts
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&hold).Error; err != nil {
+			return err
+		}
+	}
+
+	var contracts []types.OptionContract
+	if err := tx.Where("status = ? AND NOT is_exercised AND seller_id IS NOT NULL AND security_id IS NOT NULL", "active").
+		Find(&contracts).Error; err != nil {
+		return err
+	}
+	for _, contract := range contracts {
+		hold := ContractHold(contract)
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&hold).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ContractHold je hold javnih hartija prodavca koje pokriva OTC ugovor.
+func ContractHold(contract types.OptionContract) types.Hold {
+	return types.Hold{
+		UserID:     *contract.SellerID,
+		Asset:      AssetSecurity,
+		SecurityID: contract.SecurityID,
+		Quantity:   contract.Quantity,
+		Reason:     ReasonOTCContract,
+		OwnerRef:   Ref(contract.ID),
+		Status:     StatusActive,
+	}
+}
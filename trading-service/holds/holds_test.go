@@ -0,0 +1,92 @@
+package holds
+
+import (
+	"testing"
+
+	"banka1.com/db"
+	"banka1.com/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func uintPtr(v uint) *uint { return &v }
+
+func setup(t *testing.T) {
+	assert.NoError(t, db.InitTestDatabase())
+	t.Cleanup(func() {
+		db.DB.Where("1 = 1").Delete(&types.Hold{})
+	})
+}
+
+func TestPlaceReduceAndRelease(t *testing.T) {
+	setup(t)
+
+	assert.NoError(t, Place(nil, types.Hold{UserID: 1, Asset: AssetSecurity, SecurityID: uintPtr(5), Quantity: 10, Reason: ReasonOrder, OwnerRef: Ref(1)}))
+	assert.NoError(t, Place(nil, types.Hold{UserID: 1, Asset: AssetSecurity, SecurityID: uintPtr(5), Quantity: 4, Reason: ReasonOTCContract, OwnerRef: Ref(1)}))
+	assert.NoError(t, Place(nil, types.Hold{UserID: 1, Asset: AssetCash, AccountID: uintPtr(7), Quantity: 10, Amount: 1000, Reason: ReasonOrder, OwnerRef: Ref(2)}))
+
+	held, err := HeldShares(nil, 1, 5, ReasonOrder)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, held)
+	held, err = HeldShares(nil, 1, 5, ReasonOTCContract)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, held)
+
+	assert.NoError(t, Reduce(nil, ReasonOrder, Ref(1), 6))
+	assert.NoError(t, Reduce(nil, ReasonOrder, Ref(2), 3))
+	held, _ = HeldShares(nil, 1, 5, ReasonOrder)
+	assert.Equal(t, 6, held)
+	cash, err := HeldCash(nil, 7, "", "")
+	assert.NoError(t, err)
+	assert.InDelta(t, 300.0, cash, 1e-9)
+
+	// Hold ordera koji se proverava se ne računa
+	cash, _ = HeldCash(nil, 7, ReasonOrder, Ref(2))
+	assert.Zero(t, cash)
+
+	assert.NoError(t, Release(nil, ReasonOrder, Ref(1)))
+	held, _ = HeldShares(nil, 1, 5, ReasonOrder)
+	assert.Zero(t, held)
+
+	var released types.Hold
+	assert.NoError(t, db.DB.Where("reason = ? AND owner_ref = ?", ReasonOrder, Ref(1)).First(&released).Error)
+	assert.Equal(t, StatusReleased, released.Status)
+
+	// Ponovno postavljanje aktivira isti hold
+	assert.NoError(t, Place(nil, types.Hold{UserID: 1, Asset: AssetSecurity, SecurityID: uintPtr(5), Quantity: 2, Reason: ReasonOrder, OwnerRef: Ref(1)}))
+	held, _ = HeldShares(nil, 1, 5, ReasonOrder)
+	assert.Equal(t, 2, held)
+	var count int64
+	db.DB.Model(&types.Hold{}).Where("reason = ? AND owner_ref = ?", ReasonOrder, Ref(1)).Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	assert.NoError(t, Reduce(nil, ReasonOrder, Ref(2), 0))
+	var consumed types.Hold
+	assert.NoError(t, db.DB.Where("reason = ? AND owner_ref = ?", ReasonOrder, Ref(2)).First(&consumed).Error)
+	assert.Equal(t, StatusConsumed, consumed.Status)
+	assert.Zero(t, consumed.Amount)
+}
+
+func TestBackfill(t *testing.T) {
+	setup(t)
+
+	remaining := 3
+	order := types.Order{UserID: 2, AccountID: 1, SecurityID: 6, Direction: "sell", OrderType: "MARKET", Quantity: 5, RemainingParts: &remaining, Status: "approved"}
+	pending := types.Order{UserID: 2, AccountID: 1, SecurityID: 6, Direction: "sell", OrderType: "MARKET", Quantity: 5, RemainingParts: &remaining, Status: "pending"}
+	assert.NoError(t, db.DB.Create(&order).Error)
+	assert.NoError(t, db.DB.Create(&pending).Error)
+	contract := types.OptionContract{SellerID: uintPtr(2), BuyerID: uintPtr(3), SecurityID: uintPtr(6), Quantity: 4, Status: "active"}
+	assert.NoError(t, db.DB.Create(&contract).Error)
+	t.Cleanup(func() {
+		db.DB.Delete(&order)
+		db.DB.Delete(&pending)
+		db.DB.Delete(&contract)
+	})
+
+	assert.NoError(t, Backfill(nil))
+	assert.NoError(t, Backfill(nil))
+
+	held, _ := HeldShares(nil, 2, 6, ReasonOrder)
+	assert.Equal(t, 3, held)
+	held, _ = HeldShares(nil, 2, 6, ReasonOTCContract)
+	assert.Equal(t, 4, held)
+}
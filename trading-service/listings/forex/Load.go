@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"banka1.com/db"
+	"banka1.com/listings/pricefeed"
 	"banka1.com/types"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/log"
@@ -116,6 +117,7 @@ func CreatePairsFromAPI(base string) error {
 		if err := tx.Commit().Error; err != nil {
 			return fmt.Errorf("failed to commit transaction: %w", err)
 		}
+		pricefeed.PublishListing(listing)
 		log.Infof("Successfully loaded forex pair %s\n", ticker)
 	}
 
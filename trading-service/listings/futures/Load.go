@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"banka1.com/db"
+	"banka1.com/exchanges"
+	"banka1.com/listings/pricefeed"
 	"banka1.com/types"
 	"github.com/gofiber/fiber/v2/log"
 )
@@ -196,7 +198,7 @@ func loadFutures(path string) error {
 				log.Infof("Failed to update listing: %v\n", err)
 			}
 		}
-		settlementDate, err := ParseFuturesSettlementDate(ticker)
+		settlementDate, err := ParseFuturesSettlementDate(ticker, exchange)
 		if err != nil {
 			tx.Rollback()
 			log.Errorf("Failed to parse settlement date for ticker %s: %v", ticker, err)
@@ -228,6 +230,7 @@ func loadFutures(path string) error {
 		if err := tx.Commit().Error; err != nil {
 			return fmt.Errorf("failed to commit transaction: %w", err)
 		}
+		pricefeed.PublishListing(listing)
 
 		log.Infof("Loaded future: %v\n", future)
 	}
@@ -250,7 +253,9 @@ var monthCodeMap = map[rune]time.Month{
 	'Z': time.December,
 }
 
-func ParseFuturesSettlementDate(ticker string) (time.Time, error) {
+// ParseFuturesSettlementDate računa datum isteka iz tickera: poslednji radni dan
+// meseca iz koda ugovora, prema kalendaru berze na kojoj se ugovorom trguje.
+func ParseFuturesSettlementDate(ticker string, exchange types.Exchange) (time.Time, error) {
 	if len(ticker) < 3 {
 		return time.Time{}, fmt.Errorf("nevalidan ticker: prekratak")
 	}
@@ -266,7 +271,7 @@ func ParseFuturesSettlementDate(ticker string) (time.Time, error) {
 
 	year := 2000 + parseYearSuffix(yearSuffix)
 
-	settlement := GetLastWeekdayOfMonth(year, month)
+	settlement := exchanges.LastTradingDayOfMonth(exchange, year, month)
 	return settlement, nil
 }
 
@@ -275,12 +280,3 @@ func parseYearSuffix(s string) int {
 	fmt.Sscanf(s, "%02d", &year)
 	return year
 }
-
-func GetLastWeekdayOfMonth(year int, month time.Month) time.Time {
-	firstOfNextMonth := time.Date(year, month+1, 1, 0, 0, 0, 0, time.UTC)
-	lastDay := firstOfNextMonth.AddDate(0, 0, -1)
-	for lastDay.Weekday() == time.Saturday || lastDay.Weekday() == time.Sunday {
-		lastDay = lastDay.AddDate(0, 0, -1)
-	}
-	return lastDay
-}
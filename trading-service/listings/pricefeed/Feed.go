@@ -0,0 +1,65 @@
+package pricefeed
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"banka1.com/types"
+)
+
+// Update je nova cena listinga koju je objavio neki od loader-a.
+type Update struct {
+	Ticker string
+	Price  float64
+	Ask    float64
+	Bid    float64
+	At     time.Time
+}
+
+var (
+	subscribers []func(Update)
+	mu          sync.RWMutex
+)
+
+// Subscribe registruje handler koji se poziva za svaku promenu cene.
+// Handler se izvršava u gorutini loader-a, pa ne sme dugo da blokira.
+func Subscribe(handler func(Update)) {
+	mu.Lock()
+	defer mu.Unlock()
+	subscribers = append(subscribers, handler)
+}
+
+// Publish obaveštava sve pretplatnike o novoj ceni listinga.
+func Publish(update Update) {
+	if update.At.IsZero() {
+		update.At = time.Now()
+	}
+
+	mu.RLock()
+	handlers := make([]func(Update), len(subscribers))
+	copy(handlers, subscribers)
+	mu.RUnlock()
+
+	for _, handler := range handlers {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					fmt.Printf("Pretplatnik na cenu %s je pukao: %v\n", update.Ticker, r)
+				}
+			}()
+			handler(update)
+		}()
+	}
+}
+
+// PublishListing objavljuje trenutne cene listinga nakon što su upisane u bazu.
+func PublishListing(listing types.Listing) {
+	Publish(Update{
+		Ticker: listing.Ticker,
+		Price:  float64(listing.Price),
+		Ask:    float64(listing.Ask),
+		Bid:    float64(listing.Bid),
+		At:     listing.LastRefresh,
+	})
+}
@@ -7,6 +7,7 @@ import (
 
 	"banka1.com/db"
 	"banka1.com/listings/finhub"
+	"banka1.com/listings/pricefeed"
 	"banka1.com/types"
 )
 
@@ -143,6 +144,7 @@ func LoadStockFromAPI(stockRequest finhub.Stock) error {
 	if err := tx.Commit().Error; err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	pricefeed.PublishListing(listing)
 
 	fmt.Printf("Successfully loaded stock data for %s\n", stockRequest.Symbol)
 	return nil
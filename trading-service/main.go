@@ -7,14 +7,16 @@ import (
 	fiberSwagger "github.com/swaggo/fiber-swagger"
 
 	"banka1.com/controllers/orders"
+	"banka1.com/exchanges"
+	"banka1.com/holds"
 
 	"fmt"
 	"os"
-	"time"
 
 	"banka1.com/middlewares"
 
 	"banka1.com/broker"
+	"banka1.com/clock"
 	"banka1.com/db"
 	"banka1.com/types"
 	"github.com/gofiber/fiber/v2"
@@ -39,6 +41,10 @@ func main() {
 		panic("Error loading .env file")
 	}
 
+	if err := clock.ConfigureFromEnv(); err != nil {
+		log.Fatalf("Neispravna podesavanja simulacije: %v", err)
+	}
+
 	broker.Connect(os.Getenv("MESSAGE_BROKER_NETWORK"), os.Getenv("MESSAGE_BROKER_HOST"))
 	db.Init()
 	redisConfig := redis.Config{
@@ -54,6 +60,24 @@ func main() {
 	}
 	defer redis.Close()
 
+	// Kalendar praznika mora biti učitan pre nego što se nalozi krenu izvršavati.
+	// Ugrađeni kalendar dopunjuje bazu samo danima koji nikada nisu upisani.
+	if err := exchanges.LoadDefaultHolidays(); err != nil {
+		fmt.Printf("Greska pri upisu ugradjenog kalendara berzi: %v\n", err)
+	}
+	if err := exchanges.ReloadHolidays(); err != nil {
+		fmt.Printf("Greska pri ucitavanju kalendara berzi: %v\n", err)
+	}
+
+	// Otvoreni orderi i ugovori iz vremena pre knjige rezervacija dobijaju svoje holdove
+	if err := holds.Backfill(nil); err != nil {
+		fmt.Printf("Greska pri popunjavanju knjige rezervacija: %v\n", err)
+	}
+
+	orders.LoadOrderBooks()
+	orders.StartTriggerEngine()
+	orders.StartScheduler()
+
 	cron.StartScheduler()
 
 	broker.FailAllOTC()
@@ -85,55 +109,7 @@ func main() {
 
 	app.Get("/swagger/*", fiberSwagger.WrapHandler)
 
-	ticker := time.NewTicker(5000 * time.Millisecond)
-	done := make(chan bool)
-
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			case <-ticker.C:
-				checkUncompletedOrders()
-			}
-		}
-	}()
-
 	port := os.Getenv("LISTEN_PATH")
 	log.Printf("Swagger UI available at http://localhost%s/swagger/index.html", port)
 	log.Fatal(app.Listen(port))
-
-	ticker.Stop()
-	done <- true
-}
-
-func checkUncompletedOrders() {
-	var undoneOrders []types.Order
-
-	//fmt.Println("Proveravanje neizvršenih naloga...")
-
-	db.DB.Where("status = 'approved' AND NOT is_done").Find(&undoneOrders)
-	//fmt.Printf("Pronadjeno %v neizvršenih naloga\n", len(undoneOrders))
-	previousLength := -1
-
-	for len(undoneOrders) > 0 && previousLength != len(undoneOrders) {
-		//fmt.Printf("Preostalo još %v neizvršenih naloga\n", len(undoneOrders))
-		for _, order := range undoneOrders {
-			if !orders.IsSettlementDateValid(&order) {
-				fmt.Printf("Order %d automatski odbijen zbog isteka settlement datuma\n", order.ID)
-				db.DB.Model(&order).Updates(map[string]any{
-					"status":          "declined",
-					"is_done":         true,
-					"remaining_parts": 0,
-				})
-				continue
-			}
-
-			if orders.CanExecuteAny(order) {
-				orders.MatchOrder(order)
-			}
-		}
-		previousLength = len(undoneOrders)
-		db.DB.Where("status = ? AND is_done = ?", "approved", false).Find(&undoneOrders)
-	}
 }
@@ -8,6 +8,15 @@ import (
 func SetupRoutes(app *fiber.App) {
 	controllers.InitActuaryRoutes(app)
 	controllers.InitOrderRoutes(app)
+	controllers.InitOrderGroupRoutes(app)
+	controllers.InitBankLiquidityRoutes(app)
+	controllers.InitFeeScheduleRoutes(app)
+	controllers.InitMarginRoutes(app)
+	controllers.InitBorrowRoutes(app)
+	controllers.InitPriceBandRoutes(app)
+	controllers.InitTradingHaltRoutes(app)
+	controllers.InitApprovalRuleRoutes(app)
+	controllers.InitOrderApprovalRoutes(app)
 	controllers.InitSecuritiesRoutes(app)
 	controllers.InitExchangeRoutes(app)
 	controllers.InitStockRoutes(app)
@@ -18,4 +27,5 @@ func SetupRoutes(app *fiber.App) {
 	controllers.InitPortfolioRoutes(app)
 	controllers.InitOTCTradeRoutes(app)
 	controllers.InitPortfolioRoutess(app)
+	controllers.InitSimulationRoutes(app)
 }
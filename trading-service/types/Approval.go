@@ -0,0 +1,78 @@
+package types
+
+import "time"
+
+// ApprovalRule je pravilo politike odobravanja naloga agenata. Pravila se proveravaju
+// po prioritetu (manji broj prvi) i primenjuje se akcija prvog pravila čiji su svi
+// zadati uslovi ispunjeni. Uslov koji nije zadat (nil ili prazan) se ne proverava.
+type ApprovalRule struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	Name     string `gorm:"not null" json:"name"`
+	Priority int    `gorm:"not null;default:100;index" json:"priority"`
+	Action   string `gorm:"type:text;not null" json:"action"` // approve, supervisor, reject
+	Enabled  bool   `gorm:"not null" json:"enabled"`
+
+	NeedApproval         *bool    `json:"need_approval,omitempty"`           // Aktuar ima označeno NeedApproval
+	ExceedsLimit         *bool    `json:"exceeds_limit,omitempty"`           // Nalog prelazi preostali limit agenta
+	MinNotional          *float64 `json:"min_notional,omitempty"`            // Procenjena vrednost naloga je najmanje ovoliko
+	InstrumentTypes      string   `gorm:"type:text" json:"instrument_types"` // Tipovi hartija odvojeni zarezom (Stock, Future, Option, Forex)
+	Margin               *bool    `json:"margin,omitempty"`
+	AfterHours           *bool    `json:"after_hours,omitempty"`
+	SettlementWithinDays *int     `json:"settlement_within_days,omitempty"` // Do datuma izmirenja hartije je ostalo najviše ovoliko dana
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// swagger:model
+type ApprovalRuleRequest struct {
+	Name                 string   `json:"name" validate:"required"`
+	Priority             int      `json:"priority"`
+	Action               string   `json:"action" validate:"required,oneof=approve supervisor reject"`
+	Enabled              *bool    `json:"enabled"`
+	NeedApproval         *bool    `json:"need_approval"`
+	ExceedsLimit         *bool    `json:"exceeds_limit"`
+	MinNotional          *float64 `json:"min_notional" validate:"omitempty,gte=0"`
+	InstrumentTypes      string   `json:"instrument_types"`
+	Margin               *bool    `json:"margin"`
+	AfterHours           *bool    `json:"after_hours"`
+	SettlementWithinDays *int     `json:"settlement_within_days" validate:"omitempty,gte=0"`
+}
+
+// swagger:model
+type DeclineOrderRequest struct {
+	Reason string `json:"reason" validate:"required"`
+}
+
+// swagger:model
+type BulkApproveRequest struct {
+	OrderIDs []uint `json:"order_ids" validate:"required,min=1,max=100"`
+}
+
+// swagger:model
+type BulkDeclineRequest struct {
+	OrderIDs []uint `json:"order_ids" validate:"required,min=1,max=100"`
+	Reason   string `json:"reason" validate:"required"`
+}
+
+// BulkApprovalResult je ishod odobravanja ili odbijanja jednog naloga iz grupnog zahteva.
+type BulkApprovalResult struct {
+	OrderID uint   `json:"order_id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ApprovalQueueItem je nalog na čekanju sa podacima o riziku koji supervizoru trebaju za odluku.
+type ApprovalQueueItem struct {
+	Order            OrderResponse `json:"order"`
+	Ticker           string        `json:"ticker"`
+	SecurityType     string        `json:"security_type"`
+	Notional         float64       `json:"notional"`
+	PendingSince     time.Time     `json:"pending_since"`
+	AgeMinutes       int           `json:"age_minutes"`
+	AgentLimit       *float64      `json:"agent_limit,omitempty"`      // Samo za naloge agenata
+	AgentUsedLimit   *float64      `json:"agent_used_limit,omitempty"` // Samo za naloge agenata
+	RemainingLimit   *float64      `json:"remaining_limit,omitempty"`  // Limit agenta koji preostaje pre ovog naloga
+	SettlementDate   *string       `json:"settlement_date,omitempty"`  // Samo za futures i opcije
+	DaysToSettlement *int          `json:"days_to_settlement,omitempty"`
+}
@@ -0,0 +1,67 @@
+package types
+
+import "time"
+
+// MarginAccount prati zaduženje korisnika po margin trgovini. Vrednost portfolija,
+// kapital (duge pozicije i priliv od short prodaja minus zaduženje i short pozicije)
+// i zahtev održavanja se ažuriraju pri svakoj revalorizaciji po cenama iz listinga.
+type MarginAccount struct {
+	ID                     uint       `gorm:"primaryKey" json:"id"`
+	UserID                 uint       `gorm:"not null;uniqueIndex" json:"user_id"`
+	AccountID              uint       `gorm:"not null" json:"account_id"` // Račun na koji idu prinudne prodaje
+	LoanBalance            float64    `gorm:"not null;default:0" json:"loan_balance"`
+	CashBalance            float64    `gorm:"not null;default:0" json:"cash_balance"` // Priliv od short prodaja koji služi kao kolateral
+	MarketValue            float64    `gorm:"not null;default:0" json:"market_value"`
+	ShortValue             float64    `gorm:"not null;default:0" json:"short_value"` // Trenutna vrednost pozajmljenih i prodatih hartija
+	Equity                 float64    `gorm:"not null;default:0" json:"equity"`
+	MaintenanceRequirement float64    `gorm:"not null;default:0" json:"maintenance_requirement"`
+	Status                 string     `gorm:"type:text;not null;default:'ok'" json:"status"` // ok, call, liquidating
+	RevaluedAt             *time.Time `json:"revalued_at,omitempty"`
+	CreatedAt              time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt              time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// MarginCall je poziv za dopunu koji se izdaje kada kapital padne ispod zahteva
+// održavanja. Ako se do roka ne ispuni, pozicije se prinudno prodaju.
+type MarginCall struct {
+	ID                     uint       `gorm:"primaryKey" json:"id"`
+	MarginAccountID        uint       `gorm:"not null;index" json:"margin_account_id"`
+	UserID                 uint       `gorm:"not null;index" json:"user_id"`
+	Status                 string     `gorm:"type:text;not null;default:'open';index" json:"status"` // open, met, liquidated
+	Equity                 float64    `gorm:"not null" json:"equity"`
+	MaintenanceRequirement float64    `gorm:"not null" json:"maintenance_requirement"`
+	Deficit                float64    `gorm:"not null" json:"deficit"`
+	Deadline               time.Time  `gorm:"not null" json:"deadline"`
+	ResolvedAt             *time.Time `json:"resolved_at,omitempty"`
+	LiquidationOrders      int        `gorm:"not null;default:0" json:"liquidation_orders"`
+	CreatedAt              time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt              time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// SecurityBorrow je pozajmica hartija iz inventara banke za short prodaju. Naknada se
+// obračunava dnevno i dodaje na margin zaduženje. Kada banka opozove pozajmicu, a
+// korisnik je ne vrati do roka, pozicija se prinudno zatvara kupovinom (buy-in).
+type SecurityBorrow struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	UserID         uint       `gorm:"not null;index" json:"user_id"`
+	AccountID      uint       `gorm:"not null" json:"account_id"`
+	SecurityID     uint       `gorm:"not null;index" json:"security_id"`
+	OrderID        uint       `gorm:"not null" json:"order_id"` // Short sell order koji je pozajmio hartije
+	Borrowed       int        `gorm:"not null" json:"borrowed"` // Prvobitno pozajmljeno
+	Quantity       int        `gorm:"not null" json:"quantity"` // Još nevraćeno
+	Price          float64    `gorm:"not null" json:"price"`    // Cena u trenutku pozajmice
+	FeeRate        float64    `gorm:"not null" json:"fee_rate"` // Godišnja naknada u procentima
+	AccruedFee     float64    `gorm:"not null;default:0" json:"accrued_fee"`
+	AccruedThrough time.Time  `gorm:"not null" json:"accrued_through"`
+	Status         string     `gorm:"type:text;not null;default:'active';index" json:"status"` // active, recalled, buy_in, closed
+	RecallDeadline *time.Time `json:"recall_deadline,omitempty"`
+	BuyInOrderID   *uint      `json:"buy_in_order_id,omitempty"`
+	ClosedAt       *time.Time `json:"closed_at,omitempty"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+type MarginAccountResponse struct {
+	Account MarginAccount `json:"account"`
+	Calls   []MarginCall  `json:"calls"`
+}
@@ -34,28 +34,70 @@ type Security struct {
 }
 
 type Order struct {
-	ID                uint     `gorm:"primaryKey"`
-	UserID            uint     `gorm:"not null"`
-	AccountID         uint     `gorm:"not null"`
-	SecurityID        uint     `gorm:"not null"`
-	OrderType         string   `gorm:"type:text;not null"`
-	Quantity          int      `gorm:"not null"`
-	ContractSize      int      `gorm:"default:1"`
-	StopPricePerUnit  *float64 `gorm:"default:null"`
-	LimitPricePerUnit *float64 `gorm:"default:null"`
-	Direction         string   `gorm:"type:text;not null"`
-	Status            string   `gorm:"type:text;default:'pending'"`
-	ApprovedBy        *uint    `gorm:"default:null"` // Supervizor koji je odobrio order
-	IsDone            bool     `gorm:"default:false"`
-	LastModified      int64    `gorm:"autoUpdateTime"`
-	RemainingParts    *int     `gorm:"default:null"`
-	AfterHours        bool     `gorm:"default:false"`
-	AON               bool     `gorm:"default:false"`
-	Margin            bool     `gorm:"default:false"`
-	User              uint     `gorm:"foreignKey:UserID"`
-	Account           uint     `gorm:"foreignKey:AccountID"`
-	Security          Security `gorm:"foreignKey:SecurityID"`
-	ApprovedByUser    *uint    `gorm:"foreignKey:ApprovedBy"`
+	ID                uint       `gorm:"primaryKey"`
+	UserID            uint       `gorm:"not null"`
+	AccountID         uint       `gorm:"not null"`
+	SecurityID        uint       `gorm:"not null"`
+	OrderType         string     `gorm:"type:text;not null"`
+	Quantity          int        `gorm:"not null"`
+	ContractSize      int        `gorm:"default:1"`
+	StopPricePerUnit  *float64   `gorm:"default:null"`
+	LimitPricePerUnit *float64   `gorm:"default:null"`
+	Direction         string     `gorm:"type:text;not null"`
+	Status            string     `gorm:"type:text;default:'pending'"`
+	ApprovedBy        *uint      `gorm:"default:null"` // Supervizor koji je odobrio order
+	IsDone            bool       `gorm:"default:false"`
+	LastModified      int64      `gorm:"autoUpdateTime"`
+	PriorityTime      int64      `gorm:"default:0"` // Vreme ulaska u knjigu naloga (unix nano), koristi se za vremenski prioritet
+	RemainingParts    *int       `gorm:"default:null"`
+	AfterHours        bool       `gorm:"default:false"`
+	AON               bool       `gorm:"default:false"`
+	Margin            bool       `gorm:"default:false"`
+	TimeInForce       string     `gorm:"type:text;default:'GTC'"` // DAY, GTC, GTD, IOC, FOK
+	ExpiresAt         *time.Time `gorm:"default:null"`            // Za DAY i GTD ordere
+	ExpiryReason      *string    `gorm:"default:null"`            // Zašto je order istekao
+	ActivatedAt       *time.Time `gorm:"default:null"`            // Kada je STOP/STOP-LIMIT order aktiviran
+	TrailAmount       *float64   `gorm:"default:null"`            // TRAILING-STOP: apsolutni razmak od najbolje cene
+	TrailPercent      *float64   `gorm:"default:null"`            // TRAILING-STOP: razmak u procentima
+	TrailReference    *float64   `gorm:"default:null"`            // Najbolja viđena cena (najviši Bid za sell, najniži Ask za buy)
+	TrailingStopPrice *float64   `gorm:"default:null"`            // Trenutni nivo aktivacije
+	DisplayQuantity   *int       `gorm:"default:null"`            // Iceberg: veličina vidljivog dela
+	VisibleRemaining  *int       `gorm:"default:null"`            // Iceberg: preostalo od trenutno vidljivog dela
+	GroupID           *uint      `gorm:"default:null;index"`      // OCO ili BRACKET grupa kojoj order pripada
+	GroupRole         *string    `gorm:"default:null"`            // ENTRY, TAKE_PROFIT, STOP_LOSS ili LEG (OCO)
+	ApprovalRuleID    *uint      `gorm:"default:null"`            // Pravilo politike odobravanja koje je odredilo status
+	DeclineReason     *string    `gorm:"default:null"`            // Razlog koji je supervizor naveo pri odbijanju
+	EscalatedAt       *time.Time `gorm:"default:null"`            // Kada je order eskaliran jer ga niko nije obradio na vreme
+	User              uint       `gorm:"foreignKey:UserID"`
+	Account           uint       `gorm:"foreignKey:AccountID"`
+	Security          Security   `gorm:"foreignKey:SecurityID"`
+	ApprovedByUser    *uint      `gorm:"foreignKey:ApprovedBy"`
+}
+
+// OrderGroup povezuje ordere koji se međusobno otkazuju (OCO) ili bracket order
+// (entry sa take-profit i stop-loss nalozima).
+type OrderGroup struct {
+	ID        uint    `gorm:"primaryKey"`
+	Type      string  `gorm:"type:text;not null"` // OCO, BRACKET
+	UserID    uint    `gorm:"not null"`
+	Status    string  `gorm:"type:text;default:'active'"` // active, done, cancelled
+	CreatedAt int64   `gorm:"autoCreateTime"`
+	Orders    []Order `gorm:"foreignKey:GroupID"`
+}
+
+// OrderEvent je stavka vremenske linije ordera: kreiranje, odobrenje, izvršenja,
+// izmene, otkazivanje i istek.
+type OrderEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	OrderID   uint      `gorm:"not null;index" json:"order_id"`
+	Type      string    `gorm:"type:text;not null" json:"type"` // created, approved, declined, fill, amended, activated, cancelled, expired, escalated
+	Status    string    `gorm:"type:text" json:"status"`        // Status ordera posle događaja
+	Quantity  int       `gorm:"default:0" json:"quantity"`      // Za fill: izvršena količina, inače preostala
+	Price     *float64  `gorm:"default:null" json:"price,omitempty"`
+	Fee       *float64  `gorm:"default:null" json:"fee,omitempty"`
+	ActorID   *uint     `gorm:"default:null" json:"actor_id,omitempty"` // Korisnik koji je izazvao događaj, nil za sistem
+	Note      string    `gorm:"type:text" json:"note,omitempty"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 }
 
 //	type OTCTrade struct {
@@ -110,6 +152,125 @@ type Portfolio struct {
 	Security      Security `gorm:"foreignKey:SecurityID" json:"security"`
 }
 
+// BankInventoryLimit ograničava poziciju banke u hartiji kada banka preuzima
+// drugu stranu ordera (MARKET_ACCESS_MODE=bank).
+type BankInventoryLimit struct {
+	ID          uint `gorm:"primaryKey" json:"id"`
+	SecurityID  uint `gorm:"not null;uniqueIndex" json:"security_id"`
+	MaxPosition int  `gorm:"not null" json:"max_position"`           // Banka ne kupuje preko ove količine
+	MinPosition int  `gorm:"not null;default:0" json:"min_position"` // Banka ne prodaje ispod ove količine
+}
+
+// PriceBand ograničava cene ordera i izvršenja za tip instrumenta na MaxDeviation
+// procenata od referentne cene iz listinga. Ako se cena internih trgovina u prozoru
+// od VolatilityWindow minuta pomeri više od VolatilityLimit procenata, hartija se
+// automatski obustavlja na HaltMinutes minuta.
+type PriceBand struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	InstrumentType   string    `gorm:"type:text;not null;uniqueIndex" json:"instrument_type"` // Stock, Future, Option, Forex
+	MaxDeviation     float64   `gorm:"not null" json:"max_deviation"`
+	VolatilityLimit  float64   `gorm:"not null;default:0" json:"volatility_limit"` // 0 isključuje automatsku obustavu
+	VolatilityWindow int       `gorm:"not null;default:0" json:"volatility_window"`
+	HaltMinutes      int       `gorm:"not null;default:0" json:"halt_minutes"`
+	CreatedAt        time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// swagger:model
+type PriceBandRequest struct {
+	MaxDeviation     float64 `json:"max_deviation" validate:"gt=0"`
+	VolatilityLimit  float64 `json:"volatility_limit" validate:"gte=0"`
+	VolatilityWindow int     `json:"volatility_window" validate:"gte=0"`
+	HaltMinutes      int     `json:"halt_minutes" validate:"gte=0"`
+}
+
+// TradingHalt obustavlja trgovanje jednom hartijom (SecurityID) ili celom berzom
+// (ExchangeID). Orderi se i dalje primaju, ali se ne izvršavaju dok obustava traje.
+// Automatske obustave se završavaju same u ResumeAt.
+type TradingHalt struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	SecurityID *uint      `gorm:"index" json:"security_id,omitempty"`
+	ExchangeID *uint      `gorm:"index" json:"exchange_id,omitempty"`
+	Reason     string     `gorm:"type:text;not null;default:''" json:"reason"`
+	Automatic  bool       `gorm:"not null;default:false" json:"automatic"`
+	HaltedBy   *uint      `json:"halted_by,omitempty"`
+	StartedAt  time.Time  `gorm:"not null" json:"started_at"`
+	ResumeAt   *time.Time `json:"resume_at,omitempty"`
+	EndedAt    *time.Time `gorm:"index" json:"ended_at,omitempty"`
+	ResumedBy  *uint      `json:"resumed_by,omitempty"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// swagger:model
+type TradingHaltRequest struct {
+	Reason  string `json:"reason"`
+	Minutes int    `json:"minutes" validate:"gte=0"` // 0 znači do ručnog nastavka
+}
+
+// swagger:model
+type BankInventoryResponse struct {
+	SecurityID  uint   `json:"security_id"`
+	Ticker      string `json:"ticker"`
+	Position    int    `json:"position"`
+	Reserved    int    `json:"reserved"` // Količina u otvorenim sell orderima banke
+	MaxPosition int    `json:"max_position"`
+	MinPosition int    `json:"min_position"`
+}
+
+// swagger:model
+type UpdateBankInventoryLimitRequest struct {
+	MaxPosition int `json:"max_position" validate:"gte=0"`
+	MinPosition int `json:"min_position" validate:"gte=0,ltefield=MaxPosition"`
+}
+
+// FeeSchedule je tarifa provizije. Prazan kriterijum (tip ordera, segment klijenta,
+// tip hartije, berza) važi za sve; pri izvršenju se bira najspecifičnija tarifa
+// koja važi u tom trenutku.
+type FeeSchedule struct {
+	ID             uint              `gorm:"primaryKey" json:"id"`
+	Name           string            `gorm:"not null" json:"name"`
+	OrderType      string            `gorm:"type:text;not null;default:''" json:"order_type"`      // MARKET, LIMIT, STOP, STOP-LIMIT
+	Segment        string            `gorm:"type:text;not null;default:''" json:"segment"`         // customer, agent, supervisor
+	InstrumentType string            `gorm:"type:text;not null;default:''" json:"instrument_type"` // Stock, Future, Option, Forex
+	ExchangeID     *uint             `gorm:"default:null" json:"exchange_id"`
+	Minimum        float64           `gorm:"not null;default:0" json:"minimum"`
+	Cap            *float64          `gorm:"default:null" json:"cap"`
+	EffectiveFrom  time.Time         `gorm:"not null" json:"effective_from"`
+	EffectiveTo    *time.Time        `gorm:"default:null" json:"effective_to"`
+	Tiers          []FeeScheduleTier `gorm:"foreignKey:ScheduleID;constraint:OnDelete:CASCADE" json:"tiers"`
+	CreatedAt      time.Time         `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time         `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// FeeScheduleTier je jedan stepen tarife: Percent se naplaćuje na deo vrednosti
+// transakcije do UpTo (poslednji stepen nema gornju granicu).
+type FeeScheduleTier struct {
+	ID         uint     `gorm:"primaryKey" json:"id"`
+	ScheduleID uint     `gorm:"not null;index" json:"schedule_id"`
+	UpTo       *float64 `json:"up_to"`
+	Percent    float64  `gorm:"not null" json:"percent"`
+}
+
+// swagger:model
+type FeeScheduleTierRequest struct {
+	UpTo    *float64 `json:"up_to" validate:"omitempty,gt=0"`
+	Percent float64  `json:"percent" validate:"gte=0,lte=100"`
+}
+
+// swagger:model
+type FeeScheduleRequest struct {
+	Name           string                   `json:"name" validate:"required"`
+	OrderType      string                   `json:"order_type" validate:"omitempty,oneofci=MARKET LIMIT STOP STOP-LIMIT"`
+	Segment        string                   `json:"segment" validate:"omitempty,oneofci=customer agent supervisor"`
+	InstrumentType string                   `json:"instrument_type"`
+	ExchangeID     *uint                    `json:"exchange_id"`
+	Minimum        float64                  `json:"minimum" validate:"gte=0"`
+	Cap            *float64                 `json:"cap" validate:"omitempty,gte=0"`
+	EffectiveFrom  *time.Time               `json:"effective_from"` // Podrazumevano odmah
+	EffectiveTo    *time.Time               `json:"effective_to"`
+	Tiers          []FeeScheduleTierRequest `json:"tiers" validate:"required,min=1,dive"`
+}
+
 type OptionContract struct {
 	ID                  uint       `gorm:"primaryKey" json:"id"`
 	OTCTradeID          uint       `gorm:"not null" json:"otcTradeId"`
@@ -157,6 +318,71 @@ type Exchange struct {
 	Timezone  string `gorm:"not null" json:"timezone,omitempty"`
 	OpenTime  string `gorm:"not null" json:"open_time,omitempty"`
 	CloseTime string `gorm:"not null" json:"close_time,omitempty"`
+	// U aukcijskom režimu se otvaranje i zatvaranje određuju aukcijom umesto kontinualnim trgovanjem
+	AuctionMode bool `gorm:"not null;default:false" json:"auction_mode"`
+}
+
+// Auction je rezultat aukcije otvaranja ili zatvaranja za jednu hartiju: jedinstvena
+// cena po kojoj je izvršena najveća količina prikupljenih ordera.
+type Auction struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ExchangeID  uint      `gorm:"not null;index" json:"exchange_id"`
+	SecurityID  uint      `gorm:"not null;uniqueIndex:idx_auction_session" json:"security_id"`
+	Session     string    `gorm:"not null;uniqueIndex:idx_auction_session" json:"session"` // Datum sesije u zoni berze (YYYY-MM-DD)
+	Kind        string    `gorm:"not null;uniqueIndex:idx_auction_session" json:"kind"`    // open, close
+	Price       float64   `gorm:"not null;default:0" json:"price"`                         // 0 ako se ponude nisu ukrstile
+	Volume      int       `gorm:"not null;default:0" json:"volume"`
+	Trades      int       `gorm:"not null;default:0" json:"trades"`
+	UncrossedAt time.Time `gorm:"not null" json:"uncrossed_at"`
+}
+
+// swagger:model
+type ExchangeAuctionModeRequest struct {
+	Enabled *bool `json:"enabled" validate:"required"`
+}
+
+// ExchangeHoliday je neradni dan berze. Date je datum u vremenskoj zoni berze (YYYY-MM-DD).
+type ExchangeHoliday struct {
+	ID      uint   `gorm:"primaryKey" json:"id"`
+	MicCode string `gorm:"not null;uniqueIndex:idx_holiday_mic_date" json:"mic_code"`
+	Date    string `gorm:"not null;uniqueIndex:idx_holiday_mic_date" json:"date"`
+	Name    string `json:"name"`
+}
+
+// ExchangeHolidaySeed beleži neradne dane koji su već upisani iz ugrađenog CSV
+// kalendara, da se dan koji je supervizor obrisao ili preimenovao ne bi vratio.
+type ExchangeHolidaySeed struct {
+	MicCode string `gorm:"primaryKey"`
+	Date    string `gorm:"primaryKey"`
+}
+
+// swagger:model
+type CreateExchangeHolidayRequest struct {
+	Date string `json:"date" validate:"required,datetime=2006-01-02"`
+	Name string `json:"name"`
+}
+
+// swagger:model
+type ExchangeStatusResponse struct {
+	ExchangeID uint   `json:"exchange_id"`
+	MicCode    string `json:"mic_code"`
+	Status     string `json:"status"`     // open, closed, pre-market, post-market
+	LocalTime  string `json:"local_time"` // Trenutno vreme u zoni berze
+	OpenTime   string `json:"open_time"`
+	CloseTime  string `json:"close_time"`
+	Holiday    string `json:"holiday,omitempty"` // Naziv praznika ako berza danas ne radi
+}
+
+// swagger:model
+type SimulationClockResponse struct {
+	Simulated bool      `json:"simulated"`
+	Now       time.Time `json:"now"`             // Trenutno (virtuelno) vreme servisa
+	Speed     float64   `json:"speed,omitempty"` // Brzina virtuelnog vremena, 0 znači da stoji
+}
+
+// swagger:model
+type AdvanceClockRequest struct {
+	Seconds int64 `json:"seconds" validate:"required,gt=0"`
 }
 
 type InterbankNegotiation struct {
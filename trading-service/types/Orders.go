@@ -1,38 +1,94 @@
 package types
 
+import "time"
+
 // swagger:model
 type OrderResponse struct {
-	ID                uint     `json:"id"`
-	AccountID         uint     `json:"account_id"`
-	UserID            uint     `json:"user_id"`
-	SecurityID        uint     `json:"security_id"`
-	Quantity          int      `json:"quantity"`
-	ContractSize      int      `json:"contract_size"`
-	StopPricePerUnit  *float64 `json:"stop_price_per_unit"`
-	LimitPricePerUnit *float64 `json:"limit_price_per_unit"`
-	Direction         string   `json:"direction"`
-	Status            string   `json:"status"`
-	ApprovedBy        *uint    `json:"approved_by"` // Supervizor koji je odobrio order
-	IsDone            bool     `json:"is_done"`
-	LastModified      int64    `json:"last_modified"`
-	RemainingParts    *int     `json:"remaining_parts"`
-	AfterHours        bool     `json:"after_hours"`
-	AON               bool     `gorm:"default:false"`
-	Margin            bool     `gorm:"default:false"`
+	ID                uint       `json:"id"`
+	AccountID         uint       `json:"account_id"`
+	UserID            uint       `json:"user_id"`
+	SecurityID        uint       `json:"security_id"`
+	Quantity          int        `json:"quantity"`
+	ContractSize      int        `json:"contract_size"`
+	StopPricePerUnit  *float64   `json:"stop_price_per_unit"`
+	LimitPricePerUnit *float64   `json:"limit_price_per_unit"`
+	Direction         string     `json:"direction"`
+	Status            string     `json:"status"`
+	ApprovedBy        *uint      `json:"approved_by"` // Supervizor koji je odobrio order
+	IsDone            bool       `json:"is_done"`
+	LastModified      int64      `json:"last_modified"`
+	RemainingParts    *int       `json:"remaining_parts"`
+	AfterHours        bool       `json:"after_hours"`
+	AON               bool       `gorm:"default:false"`
+	Margin            bool       `gorm:"default:false"`
+	TimeInForce       string     `json:"time_in_force"`
+	ExpiresAt         *time.Time `json:"expires_at"`
+	ExpiryReason      *string    `json:"expiry_reason"`
+	ActivatedAt       *time.Time `json:"activated_at"`
+	TrailAmount       *float64   `json:"trail_amount"`
+	TrailPercent      *float64   `json:"trail_percent"`
+	TrailingStopPrice *float64   `json:"trailing_stop_price"`
+	DisplayQuantity   *int       `json:"display_quantity"`
+	GroupID           *uint      `json:"group_id"`
+	GroupRole         *string    `json:"group_role"`
+	ApprovalRuleID    *uint      `json:"approval_rule_id"`
+	DeclineReason     *string    `json:"decline_reason"`
+	EscalatedAt       *time.Time `json:"escalated_at"`
 }
 
 // swagger:model
 type CreateOrderRequest struct {
-	UserID            uint     `json:"user_id" validate:"required"`
-	AccountID         uint     `json:"account_id" validate:"required"`
-	SecurityID        uint     `json:"security_id" validate:"required"`
-	Quantity          int      `json:"quantity" validate:"required,gt=0"`
-	ContractSize      int      `json:"contract_size" validate:"required"`
-	StopPricePerUnit  *float64 `json:"stop_price_per_unit"`
-	LimitPricePerUnit *float64 `json:"limit_price_per_unit"`
-	Direction         string   `json:"direction" validate:"required,oneofci=buy sell"`
-	AON               bool     `json:"aon"`
-	Margin            bool     `json:"margin"`
+	UserID            uint       `json:"user_id" validate:"required"`
+	AccountID         uint       `json:"account_id" validate:"required"`
+	SecurityID        uint       `json:"security_id" validate:"required"`
+	Quantity          int        `json:"quantity" validate:"required,gt=0"`
+	ContractSize      int        `json:"contract_size" validate:"required"` // Mora biti jednak veličini ugovora hartije
+	StopPricePerUnit  *float64   `json:"stop_price_per_unit"`
+	LimitPricePerUnit *float64   `json:"limit_price_per_unit"`
+	Direction         string     `json:"direction" validate:"required,oneofci=buy sell"`
+	AON               bool       `json:"aon"`
+	Margin            bool       `json:"margin"`
+	TimeInForce       string     `json:"time_in_force" validate:"omitempty,oneofci=DAY GTC GTD IOC FOK"`
+	ExpiresAt         *time.Time `json:"expires_at"`                                     // Obavezno za GTD
+	TrailAmount       *float64   `json:"trail_amount" validate:"omitempty,gt=0"`         // TRAILING-STOP
+	TrailPercent      *float64   `json:"trail_percent" validate:"omitempty,gt=0,lt=100"` // TRAILING-STOP
+	DisplayQuantity   *int       `json:"display_quantity" validate:"omitempty,gt=0"`     // Iceberg: vidljivi deo
+}
+
+// swagger:model
+type OrderEventsResponse struct {
+	OrderID          uint         `json:"order_id"`
+	Status           string       `json:"status"`
+	Quantity         int          `json:"quantity"`
+	FilledQuantity   int          `json:"filled_quantity"`
+	AverageFillPrice *float64     `json:"average_fill_price"` // nil dok nema izvršenja
+	TotalFees        float64      `json:"total_fees"`
+	Events           []OrderEvent `json:"events"`
+}
+
+// swagger:model
+type AmendOrderRequest struct {
+	Quantity          *int     `json:"quantity" validate:"omitempty,gt=0"` // Nova ukupna količina (uključujući izvršeni deo)
+	LimitPricePerUnit *float64 `json:"limit_price_per_unit" validate:"omitempty,gt=0"`
+	StopPricePerUnit  *float64 `json:"stop_price_per_unit" validate:"omitempty,gt=0"`
+}
+
+// swagger:model
+type CreateOrderGroupRequest struct {
+	Type            string               `json:"type" validate:"required,oneofci=OCO BRACKET"`
+	Legs            []CreateOrderRequest `json:"legs" validate:"dive"` // OCO: nalozi koji se međusobno otkazuju
+	Entry           *CreateOrderRequest  `json:"entry"`                // BRACKET: ulazni nalog
+	TakeProfitPrice *float64             `json:"take_profit_price" validate:"omitempty,gt=0"`
+	StopLossPrice   *float64             `json:"stop_loss_price" validate:"omitempty,gt=0"`
+}
+
+// swagger:model
+type OrderGroupResponse struct {
+	ID     uint            `json:"id"`
+	Type   string          `json:"type"`
+	UserID uint            `json:"user_id"`
+	Status string          `json:"status"`
+	Orders []OrderResponse `json:"orders"`
 }
 
 func (Order) TableName() string {
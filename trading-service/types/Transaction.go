@@ -3,20 +3,58 @@ package types
 import "time"
 
 type Transaction struct {
-	ID           uint `gorm:"primaryKey"`
-	OrderID      uint
-	ContractID   uint
-	BuyerID      uint      `gorm:"not null"`
-	SellerID     uint      `gorm:"not null"`
-	SecurityID   uint      `gorm:"not null"`
-	Quantity     int       `gorm:"not null"`
-	PricePerUnit float64   `gorm:"not null"`
-	Fee          float64   `gorm:"not null;default:0"`
-	TotalPrice   float64   `gorm:"not null"`
-	CreatedAt    time.Time `gorm:"autoCreateTime"`
-	TaxPaid      bool      `gorm:"default:false"`
+	ID            uint `gorm:"primaryKey"`
+	OrderID       uint
+	ContractID    uint
+	BuyerID       uint      `gorm:"not null"`
+	SellerID      uint      `gorm:"not null"`
+	SecurityID    uint      `gorm:"not null"`
+	Quantity      int       `gorm:"not null"`
+	PricePerUnit  float64   `gorm:"not null"`
+	Fee           float64   `gorm:"not null;default:0"`
+	FeeScheduleID *uint     `gorm:"default:null"` // Tarifa po kojoj je naplaćena provizija, nil za podrazumevanu
+	TotalPrice    float64   `gorm:"not null"`
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+	TaxPaid       bool      `gorm:"default:false"`
+	BankPrincipal bool      `gorm:"default:false"` // Banka je bila druga strana iz sopstvenog inventara
 }
 
 func (Transaction) TableName() string {
 	return "transactions"
 }
+
+// OrderCompensation beleži kompenzaciju poslatu banking servisu za deo izvršenja
+// ordera koji je poništen nakon što je plaćanje već inicirano.
+type OrderCompensation struct {
+	ID              uint      `gorm:"primaryKey"`
+	OrderID         uint      `gorm:"not null;index"`
+	Uid             string    `gorm:"not null"` // Uid originalne OrderTransactionInitiation poruke
+	BuyerAccountID  uint      `gorm:"not null"`
+	SellerAccountID uint      `gorm:"not null"`
+	Amount          float64   `gorm:"not null"`
+	Fee             float64   `gorm:"not null;default:0"`
+	Reason          string    `gorm:"type:text"`
+	Sent            bool      `gorm:"default:false"` // Banking servis je potvrdio kompenzaciju
+	Attempts        int       `gorm:"not null;default:0"`
+	DeadLetter      bool      `gorm:"default:false;index"` // Odustalo se od slanja, potrebna ručna obrada
+	Error           *string   `gorm:"default:null"`
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+}
+
+// Hold je stavka knjige rezervacija: hartije ili gotovina zadržane za otvoren order,
+// aktivan OTC ugovor ili OTC sagu u toku. Vlasnik je određen razlogom (Reason) i
+// referencom (OwnerRef: ID ordera, ID ugovora ili UID sage).
+type Hold struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"not null;index" json:"user_id"`
+	Asset      string    `gorm:"type:text;not null;uniqueIndex:idx_hold_owner" json:"asset"` // security, cash
+	SecurityID *uint     `gorm:"index" json:"security_id,omitempty"`
+	AccountID  *uint     `gorm:"index" json:"account_id,omitempty"`
+	Quantity   int       `gorm:"not null" json:"quantity"`                                    // Zadržane hartije, za gotovinu količina koju iznos pokriva
+	Amount     float64   `gorm:"not null" json:"amount"`                                      // Zadržana gotovina
+	Reason     string    `gorm:"type:text;not null;uniqueIndex:idx_hold_owner" json:"reason"` // order, otc_contract, saga
+	OwnerRef   string    `gorm:"type:text;not null;uniqueIndex:idx_hold_owner" json:"owner_ref"`
+	Status     string    `gorm:"type:text;not null;default:'active';index" json:"status"` // active, consumed, released
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}